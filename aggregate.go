@@ -0,0 +1,211 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 繰り返した取り込みの解析の説明(.meta.json)を集めて, 誤り率, 振幅, 遷移の速さの長い期間の傾向を見る
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 推移のグラフの大きさ(pt)
+const (
+	TrendChartWidth  = 1200
+	TrendChartHeight = 900
+)
+
+// 1回の取り込みの指標
+// 測れなかった指標はNaN
+type TrendPoint struct {
+	file      string
+	time      time.Time // 取り込みを始めた時刻, 分からない場合はファイルの更新時刻
+	errorRate float64   // フレーミングエラーとパリティエラーの文字の割合
+	amplitude float64   // A-B間電圧差の振幅(V)
+	slewRate  float64   // A-B間電圧差のスルーレート(V/s)
+}
+
+// 傾向を見る指標
+type TrendMetric struct {
+	name  string
+	unit  string
+	scale float64 // 表示する単位への換算係数
+	value func(TrendPoint) float64
+	color color.Color
+}
+
+// 誤り率, 振幅, 遷移の速さ
+var trendMetrics = []TrendMetric{
+	{"error rate", "%", 100, func(p TrendPoint) float64 { return p.errorRate }, colornames.Red},
+	{"amplitude", "V", 1, func(p TrendPoint) float64 { return p.amplitude }, colornames.Darkgreen},
+	{"slew rate", "V/us", 1e-6, func(p TrendPoint) float64 { return p.slewRate }, colornames.Royalblue},
+}
+
+// 0の場合はNaN(要約に無い指標)
+func nanIfZero(v float64) float64 {
+	if v == 0 {
+		return math.NaN()
+	}
+	return v
+}
+
+// 解析の説明を読んで指標にする
+func loadTrendPoint(path string) (TrendPoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return TrendPoint{}, err
+	}
+	var meta SessionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return TrendPoint{}, fmt.Errorf("解析の説明 \"%s\" を読めない: %w", path, err)
+	}
+	point := TrendPoint{
+		file:      path,
+		errorRate: math.NaN(),
+		amplitude: nanIfZero(meta.Summary.Amplitude),
+		slewRate:  nanIfZero(meta.Summary.SlewRate),
+	}
+	if meta.Summary.Bytes > 0 {
+		point.errorRate = float64(meta.Summary.FramingErrors+meta.Summary.ParityErrors) / float64(meta.Summary.Bytes)
+	}
+	if t, err := parseT0(meta.Detected.CaptureStart); err == nil {
+		point.time = t
+	} else if info, err := os.Stat(path); err == nil {
+		point.time = info.ModTime()
+	}
+	return point, nil
+}
+
+// 指標の時間あたりの変化(表示する単位/時間)
+// 測れた取り込みが2つ未満か, 全て同じ時刻の場合はfalse
+func trendSlope(points []TrendPoint, metric TrendMetric) (float64, bool) {
+	xs, ys := []float64{}, []float64{}
+	for _, p := range points {
+		if v := metric.value(p); !math.IsNaN(v) {
+			xs = append(xs, p.time.Sub(points[0].time).Hours())
+			ys = append(ys, v*metric.scale)
+		}
+	}
+	if len(xs) < 2 || xs[0] == xs[len(xs)-1] {
+		return 0, false
+	}
+	_, slope := stat.LinearRegression(xs, ys, nil, false)
+	return slope, true
+}
+
+// 取り込み毎の指標と傾向を表示する
+func printTrend(w io.Writer, points []TrendPoint) {
+	fmt.Fprintf(w, "trend: %d captures  %s .. %s\n", len(points), points[0].time.Format(AbsoluteTimeFormat), points[len(points)-1].time.Format(AbsoluteTimeFormat))
+	fmt.Fprintf(w, "  %-32s %12s %12s %12s  %s\n", "time", "error rate", "amplitude", "slew rate", "file")
+	for _, p := range points {
+		fmt.Fprintf(w, "  %-32s", p.time.Format(AbsoluteTimeFormat))
+		for _, m := range trendMetrics {
+			if v := m.value(p); math.IsNaN(v) {
+				fmt.Fprintf(w, " %12s", "-")
+			} else {
+				fmt.Fprintf(w, " %12s", fmt.Sprintf("%.3f%s", v*m.scale, m.unit))
+			}
+		}
+		fmt.Fprintf(w, "  %s\n", filepath.Base(p.file))
+	}
+	for _, m := range trendMetrics {
+		if slope, ok := trendSlope(points, m); ok {
+			fmt.Fprintf(w, "  %-10s trend %+.4f%s/h\n", m.name, slope, m.unit)
+		}
+	}
+}
+
+// 指標の推移を縦に並べたグラフを保存する
+func saveTrendChart(savefilepath string, points []TrendPoint) error {
+	panels := []*plot.Plot{}
+	for _, m := range trendMetrics {
+		p := plot.New()
+		p.Y.Label.Text = fmt.Sprintf("%s(%s)", m.name, m.unit)
+		p.BackgroundColor = colornames.Snow
+		p.X.Tick.Marker = plot.TimeTicks{Format: "01-02 15:04"}
+		xys := plotter.XYs{}
+		for _, point := range points {
+			if v := m.value(point); !math.IsNaN(v) {
+				xys = append(xys, plotter.XY{X: float64(point.time.UnixNano()) / 1e9, Y: v * m.scale})
+			}
+		}
+		if len(xys) != 0 {
+			line, scatter, err := plotter.NewLinePoints(xys)
+			if err != nil {
+				slog.Error("NewLinePoints", "err", err)
+				return err
+			}
+			line.Color = m.color
+			scatter.Color = m.color
+			p.Add(line, scatter)
+		}
+		panels = append(panels, p)
+	}
+	panels[0].Title.Text = "取り込み毎の指標の推移"
+	panels[len(panels)-1].X.Label.Text = "時刻(UTC)"
+
+	// 時間軸を揃える
+	xMin, xMax := panels[0].X.Min, panels[0].X.Max
+	for _, p := range panels {
+		xMin, xMax = min(xMin, p.X.Min), max(xMax, p.X.Max)
+	}
+	for _, p := range panels {
+		p.X.Min, p.X.Max = xMin, xMax
+	}
+
+	canvas := vgimg.New(vg.Points(TrendChartWidth), vg.Points(TrendChartHeight))
+	dc := draw.New(canvas)
+	dc.SetColor(colornames.Snow)
+	dc.Fill(dc.Rectangle.Path())
+	tiles := draw.Tiles{Rows: len(panels), Cols: 1, PadX: vg.Millimeter, PadY: vg.Millimeter}
+	grid := make([][]*plot.Plot, len(panels))
+	for i, p := range panels {
+		grid[i] = []*plot.Plot{p}
+	}
+	canvases := plot.Align(grid, tiles, dc)
+	for i, p := range panels {
+		p.Draw(canvases[i][0])
+	}
+	return chart.SaveCanvas(savefilepath, canvas, nil)
+}
+
+// 解析の説明を集めて傾向を表示し, グラフを保存する
+func runAggregate(w io.Writer, files []string, savefilepath string) error {
+	points := []TrendPoint{}
+	for _, file := range files {
+		point, err := loadTrendPoint(file)
+		if err != nil {
+			slog.Error("loadTrendPoint", "err", err)
+			return err
+		}
+		points = append(points, point)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+
+	printTrend(w, points)
+	if err := saveTrendChart(savefilepath, points); err != nil {
+		slog.Error("saveTrendChart", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "trend chart \"%s\"\n", savefilepath)
+	return nil
+}
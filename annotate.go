@@ -0,0 +1,125 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 入力の行列に復号した論理レベル, ビット番号, バイト値の列を加えてCSVファイルに書き出す(表計算ソフトで1行ずつ確かめる)
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 文字の中のビット番号(スタートビットを0とし, 8N1ではストップビットが9になる)
+// 無通信の場合はfalse
+func bitIndex(state string, format UartFormat) (int, bool) {
+	switch state {
+	case "START":
+		return 0, true
+	case "PARITY", "PE":
+		return 1 + format.dataBits, true
+	case "STOP", "X":
+		return format.stopBitIndex(), true
+	case "IDLE":
+		return 0, false
+	}
+	var n int
+	if _, err := fmt.Sscanf(state, "Bit#%d", &n); err != nil {
+		return 0, false
+	}
+	return n + 1, true
+}
+
+// 行列に復号した結果の列を加えてCSVファイルに保存する
+// 加える列はlevel(論理レベル), bit(文字の中のビット番号), byte(文字の値)で, 当てはまらない行は空にする
+// bitsとcodesの時間は基準時間originTimeからの相対時間
+func saveAnnotatedCsv(savefilepath string, matrix mat.Matrix, originTime float64, bits []UartBit, codes []UartCode, format UartFormat) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	rows, cols := matrix.Dims()
+	names := []string{"x-axis"}
+	units := []string{"second"}
+	for c := 1; c < cols; c++ {
+		names = append(names, fmt.Sprint(c))
+		units = append(units, "Volt")
+	}
+	writer := csv.NewWriter(f)
+	writer.Write(append(names, "level", "bit", "byte"))
+	writer.Write(append(units, "", "", ""))
+	record := make([]string, cols+3)
+	b, k := 0, 0
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			record[c] = strconv.FormatFloat(matrix.At(r, c), 'g', -1, 64)
+		}
+		// 行の時間を含むビットと文字(どちらも時間順)
+		// ビットの間の僅かな隙間は前のビットに含める
+		t := matrix.At(r, ColTime) - originTime
+		for b+1 < len(bits) && bits[b+1].startTime <= t {
+			b++
+		}
+		for k < len(codes) && codes[k].endTime <= t {
+			k++
+		}
+		level, index, octet := "", "", ""
+		if b < len(bits) && bits[b].startTime <= t && (b+1 < len(bits) || t < bits[b].endTime) {
+			level = strconv.Itoa(bits[b].bit)
+			if n, ok := bitIndex(bits[b].state, format); ok {
+				index = strconv.Itoa(n)
+			}
+		}
+		if k < len(codes) && codes[k].startTime <= t {
+			octet = fmt.Sprintf("0x%02x", codes[k].octet)
+		}
+		record[cols], record[cols+1], record[cols+2] = level, index, octet
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 通信方向の文字
+// 半二重の場合は全ての文字
+func codesOfDirection(codes []UartCode, direction string) []UartCode {
+	selected := []UartCode{}
+	for _, c := range codes {
+		if c.direction == "" || c.direction == direction {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// 復号した結果の列を加えた行列(全二重の場合はTX対とRX対)をCSVファイルに書き出す
+// ファイル名は[基本名]_annotated.csv, RX対は[基本名]_rx_annotated.csv
+func exportAnnotated(w io.Writer, basename string, matrix *mat.Dense, rxMatrix *mat.Dense, originTime float64, bits []UartBit, rxBits []UartBit, codes []UartCode, format UartFormat) error {
+	file := basename + "_annotated.csv"
+	if err := saveAnnotatedCsv(file, matrix, originTime, bits, codesOfDirection(codes, DirectionTx), format); err != nil {
+		slog.Error("saveAnnotatedCsv", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "export \"%s\"\n", file)
+	if rxMatrix != nil {
+		file := basename + "_rx_annotated.csv"
+		if err := saveAnnotatedCsv(file, rxMatrix, originTime, rxBits, codesOfDirection(codes, DirectionRx), format); err != nil {
+			slog.Error("saveAnnotatedCsv", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "export \"%s\"\n", file)
+	}
+	return nil
+}
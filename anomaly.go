@@ -0,0 +1,242 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 検出した異常の一覧(テスト自動化向けのJSON/CSV出力)
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 異常の重大度
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// 異常
+type AnomalyEvent struct {
+	Time      float64 `json:"time"`                // 基準時間からの相対時間(s)
+	Timestamp string  `json:"timestamp,omitempty"` // 絶対時刻, --t0を指定しない場合は空
+	Kind      string  `json:"kind"`                // 異常の種類
+	Severity  string  `json:"severity"`            // 重大度
+	Detail    string  `json:"detail"`              // 詳細
+}
+
+// フレーミングエラー(ストップビットが0)
+func framingAnomalies(bits []UartBit) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, b := range bits {
+		if b.state == "X" {
+			anomalies = append(anomalies, AnomalyEvent{Time: b.startTime, Kind: "framing", Severity: SeverityError, Detail: "ストップビットが0"})
+		}
+	}
+	return anomalies
+}
+
+// パリティが合わない文字
+func parityAnomalies(codes []UartCode) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, c := range codes {
+		if c.parityError {
+			detail := fmt.Sprintf("byte 0x%02x", c.octet)
+			if c.direction != "" {
+				detail = c.direction + " " + detail
+			}
+			anomalies = append(anomalies, AnomalyEvent{Time: c.startTime, Kind: "parity", Severity: SeverityError, Detail: detail})
+		}
+	}
+	return anomalies
+}
+
+// 誤り検出符号の不一致
+func crcAnomalies(frames []UartFrame, crcKind string) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for i, f := range frames {
+		if ok, checked := checkFrameCrc(f, crcKind); checked && !ok {
+			anomalies = append(anomalies, AnomalyEvent{Time: f.startTime, Kind: "crc", Severity: SeverityError, Detail: fmt.Sprintf("frame #%d", i+1)})
+		}
+	}
+	return anomalies
+}
+
+// 1ビットの訂正で直した文字やフレーム
+// 元の復号の異常(framing, crc)とは別に、訂正できたことを記録する
+func correctionAnomalies(corrections []Correction) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, c := range corrections {
+		if !c.ok() {
+			continue
+		}
+		target := "char"
+		if c.frameIndex >= 0 {
+			target = fmt.Sprintf("frame #%d", c.frameIndex+1)
+		}
+		bit := fmt.Sprintf("bit#%d", c.bit)
+		if c.bit == CorrectStopBit {
+			bit = "stop bit"
+		}
+		anomalies = append(anomalies, AnomalyEvent{
+			Time:     c.startTime,
+			Kind:     "corrected",
+			Severity: SeverityWarning,
+			Detail:   fmt.Sprintf("%s %s byte %d %s confidence %.2f", target, c.reason, c.codeIndex, bit, c.confidence),
+		})
+	}
+	return anomalies
+}
+
+// 統計的に異なるフレーム
+func outlierAnomalies(outliers []FrameOutlier) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, o := range outliers {
+		anomalies = append(anomalies, AnomalyEvent{
+			Time:     o.frame.startTime,
+			Kind:     "outlier",
+			Severity: SeverityWarning,
+			Detail:   fmt.Sprintf("frame #%d %s", o.frameIndex+1, strings.Join(o.reasons, ", ")),
+		})
+	}
+	return anomalies
+}
+
+// グリッチ(ビット周期の半分より短いパルス)
+// 時間はoriginTimeを引いてフレームの時間と合わせる
+func glitchAnomalies(matrix mat.Matrix, originTime float64, baudrate int) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	rows, _ := matrix.Dims()
+	minWidth := 0.5 / float64(baudrate)
+	// 直前のパルスの向き(1:Mark, -1:Space)と開始時間
+	level := 0
+	var startTime float64
+	for r := 0; r < rows; r++ {
+		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
+		current := 0
+		if d > Threshould {
+			current = 1
+		} else if d < -Threshould {
+			current = -1
+		} else {
+			continue
+		}
+		if current != level {
+			t := matrix.At(r, ColTime)
+			if level != 0 && t-startTime < minWidth {
+				anomalies = append(anomalies, AnomalyEvent{
+					Time:     startTime - originTime,
+					Kind:     "glitch",
+					Severity: SeverityWarning,
+					Detail:   fmt.Sprintf("pulse %.3fus", (t-startTime)*1e6),
+				})
+			}
+			level = current
+			startTime = t
+		}
+	}
+	return anomalies
+}
+
+// ターンアラウンド違反
+func turnaroundAnomalies(turnarounds []Turnaround) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, ta := range turnarounds {
+		if ta.violation != "" {
+			anomalies = append(anomalies, AnomalyEvent{Time: ta.replyStartTime, Kind: "turnaround", Severity: SeverityWarning, Detail: ta.violation})
+		}
+	}
+	return anomalies
+}
+
+// ドライバイネーブル違反
+func driverEnableAnomalies(checks []DriverEnableCheck) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, c := range checks {
+		if c.violation != "" {
+			anomalies = append(anomalies, AnomalyEvent{Time: c.frame.startTime, Kind: "driver-enable", Severity: SeverityWarning, Detail: c.violation})
+		}
+	}
+	return anomalies
+}
+
+// スルーレート違反
+// エッジの時間は入力CSVの時間なのでoriginTimeを引く
+func slewAnomalies(edges []Edge, originTime float64) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, e := range edges {
+		if e.violation != "" {
+			anomalies = append(anomalies, AnomalyEvent{
+				Time:     e.startTime - originTime,
+				Kind:     "slew",
+				Severity: SeverityWarning,
+				Detail:   fmt.Sprintf("%s %.3fV/us", e.violation, e.slewDiff*1e-6),
+			})
+		}
+	}
+	return anomalies
+}
+
+// 異常に重大度errorのものがあるか
+func hasErrorAnomaly(anomalies []AnomalyEvent) bool {
+	for _, a := range anomalies {
+		if a.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// 異常の一覧をファイルに保存する
+// 拡張子が.jsonの場合は来歴と異常の一覧をまとめたJSON、それ以外は異常の一覧のCSV
+func saveAnomalies(savefilepath string, clock Clock, anomalies []AnomalyEvent, provenance *Provenance) error {
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Time < anomalies[j].Time
+	})
+	if clock.absolute {
+		for i := range anomalies {
+			anomalies[i].Timestamp = clock.format(anomalies[i].Time)
+		}
+	}
+
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(savefilepath), ".json") {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		report := struct {
+			Provenance *Provenance    `json:"provenance,omitempty"`
+			Anomalies  []AnomalyEvent `json:"anomalies"`
+		}{provenance, anomalies}
+		if err := encoder.Encode(report); err != nil {
+			slog.Error("Encode", "err", err)
+			return err
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"time", "timestamp", "kind", "severity", "detail"})
+	for _, a := range anomalies {
+		writer.Write([]string{strconv.FormatFloat(a.Time, 'g', -1, 64), a.Timestamp, a.Kind, a.Severity, a.Detail})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
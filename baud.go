@@ -0,0 +1,186 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定値からボーレートを推定する
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// ボーレートの推定方法
+const (
+	EstimateBaudNone            = "none"            // 推定しない(--baudrateを使う)
+	EstimateBaudPulse           = "pulse"           // 最も短いパルスの幅
+	EstimateBaudAutocorrelation = "autocorrelation" // エッジ間隔の自己相関
+)
+
+// よく使われるボーレート
+var standardBaudrates = []int{300, 600, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 38400, 57600, 76800, 115200, 230400, 460800, 921600}
+
+// 推定値をよく使われるボーレートに丸める許容差(比)
+const StandardBaudTolerance = 0.03
+
+// ボーレートの推定結果
+type BaudEstimate struct {
+	method   string
+	raw      float64 // 推定値(bps)
+	baudrate int     // よく使われるボーレートに丸めた値(bps), 近いものが無い場合は推定値を丸めた値
+	edges    int     // 推定に使ったエッジの数
+}
+
+// A,B間電圧差が0を横切る時間(エッジ)
+// しきい値を超えて反対側に移ったところをエッジとし、直前の0を横切る時間を線形補間で求める
+func edgeTimes(matrix mat.Matrix) []float64 {
+	rows, _ := matrix.Dims()
+	diff := waveform.Differential(nil, matrix)
+	edges := []float64{}
+	level := 0 // 1:Mark, -1:Space
+	for r := 1; r < rows; r++ {
+		current := level
+		if diff[r] > Threshould {
+			current = 1
+		} else if diff[r] < -Threshould {
+			current = -1
+		}
+		if current == level {
+			continue
+		}
+		if level != 0 {
+			// 0を横切った標本の組を遡って探す
+			k := r
+			for k > 0 && float64(current)*diff[k-1] > 0 {
+				k--
+			}
+			if k > 0 {
+				t0, t1 := matrix.At(k-1, ColTime), matrix.At(k, ColTime)
+				d0, d1 := diff[k-1], diff[k]
+				edges = append(edges, t0+(t1-t0)*d0/(d0-d1))
+			}
+		}
+		level = current
+	}
+	return edges
+}
+
+// エッジ間隔
+func edgeIntervals(edges []float64) []float64 {
+	intervals := make([]float64, 0, max(len(edges)-1, 0))
+	for i := 1; i < len(edges); i++ {
+		intervals = append(intervals, edges[i]-edges[i-1])
+	}
+	return intervals
+}
+
+// 短い方から5%のエッジ間隔(雑音による極端に短い間隔を除いた最短のパルス幅)
+func shortestInterval(intervals []float64) float64 {
+	sorted := append([]float64{}, intervals...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/20]
+}
+
+// 最も短いパルスの幅からビット周期を推定する
+// 1ビットだけのパルスがある場合に使える
+func estimateBitTimePulse(intervals []float64) float64 {
+	shortest := shortestInterval(intervals)
+	sum, n := 0.0, 0
+	for _, d := range intervals {
+		if d <= 1.25*shortest {
+			sum += d
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// エッジ間隔の自己相関からビット周期を推定する
+// エッジ間隔はビット周期の整数倍なので、周波数fでcos(2πfd)を平均するとf=1/T(とその整数倍)で1に近づく
+// 最も短いパルスが2ビット以上(1ビットだけのパルスが無い)の場合でも、揃う最も低い周波数からビット周期が分かる
+func estimateBitTimeAutocorrelation(intervals []float64) float64 {
+	shortest := shortestInterval(intervals)
+	// フレーム間の無通信時間のような長い間隔は使わない
+	lags := []float64{}
+	for _, d := range intervals {
+		if d <= 12*shortest {
+			lags = append(lags, d)
+		}
+	}
+	coherence := func(f float64) float64 {
+		sum := 0.0
+		for _, d := range lags {
+			sum += math.Cos(2 * math.Pi * f * d)
+		}
+		return sum / float64(len(lags))
+	}
+
+	// 最も短いパルスの1/2ビットから3ビットまでを探す
+	const steps = 2000
+	fmin, fmax := 0.5/shortest, 3/shortest
+	spectrum := make([]float64, steps+1)
+	peak := 0.0
+	for i := range spectrum {
+		spectrum[i] = coherence(fmin + (fmax-fmin)*float64(i)/steps)
+		peak = math.Max(peak, spectrum[i])
+	}
+	// 揃う(最大値の8割以上の極大)最も低い周波数
+	bitTime := shortest
+	for i := 1; i < steps; i++ {
+		if spectrum[i] >= 0.8*peak && spectrum[i] >= spectrum[i-1] && spectrum[i] >= spectrum[i+1] {
+			bitTime = 1 / (fmin + (fmax-fmin)*float64(i)/steps)
+			break
+		}
+	}
+
+	// 各間隔のビット数を決めて最小二乗法で詰める
+	sumKD, sumKK := 0.0, 0.0
+	for _, d := range lags {
+		k := math.Round(d / bitTime)
+		if k >= 1 && math.Abs(d/bitTime-k) < 0.25 {
+			sumKD += k * d
+			sumKK += k * k
+		}
+	}
+	if sumKK > 0 {
+		bitTime = sumKD / sumKK
+	}
+	return bitTime
+}
+
+// よく使われるボーレートに丸める
+func snapBaudrate(raw float64) int {
+	for _, b := range standardBaudrates {
+		if math.Abs(raw-float64(b)) <= StandardBaudTolerance*float64(b) {
+			return b
+		}
+	}
+	return int(math.Round(raw))
+}
+
+// 測定値からボーレートを推定する
+func estimateBaudrate(matrix mat.Matrix, method string) (BaudEstimate, error) {
+	edges := edgeTimes(matrix)
+	intervals := edgeIntervals(edges)
+	if len(intervals) < 2 {
+		return BaudEstimate{}, fmt.Errorf("エッジが少なすぎてボーレートを推定できない")
+	}
+	var bitTime float64
+	switch method {
+	case EstimateBaudPulse:
+		bitTime = estimateBitTimePulse(intervals)
+	case EstimateBaudAutocorrelation:
+		bitTime = estimateBitTimeAutocorrelation(intervals)
+	default:
+		return BaudEstimate{}, fmt.Errorf("ボーレートの推定方法 \"%s\" には対応していない", method)
+	}
+	if bitTime <= 0 {
+		return BaudEstimate{}, fmt.Errorf("ボーレートを推定できない")
+	}
+	raw := 1 / bitTime
+	return BaudEstimate{method: method, raw: raw, baudrate: snapBaudrate(raw), edges: len(edges)}, nil
+}
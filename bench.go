@@ -0,0 +1,174 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 合成した測定値で解析の速さとメモリ割り当てを測る
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// 合成する測定値のサンプリング周波数(Hz)
+const BenchSampleRate = 200000
+
+// 合成する測定値の振幅(V)と雑音の標準偏差(V)
+const (
+	BenchAmplitude = 2.0
+	BenchNoise     = 0.1
+)
+
+// 乱数のバイト列をUARTで送ったRS485差動波形のCSVファイルを作る
+// 同じフレーム数からは同じファイルを作る
+func writeBenchCsv(csvfilepath string, frames int, baudrate int) (int, error) {
+	file, err := os.Create(csvfilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return 0, err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	random := rand.New(rand.NewSource(1))
+	// アイドル(Mark)10ビット, フレーム毎にスタートビット, データ8ビット, ストップビット, アイドル2ビット
+	bits := make([]bool, 0, 10+frames*12)
+	for i := 0; i < 10; i++ {
+		bits = append(bits, true)
+	}
+	for i := 0; i < frames; i++ {
+		b := random.Intn(256)
+		bits = append(bits, false)
+		for n := 0; n < 8; n++ {
+			bits = append(bits, (b>>n)&1 == 1)
+		}
+		bits = append(bits, true, true, true)
+	}
+
+	fmt.Fprint(writer, "x-axis,1,2\nsecond,Volt,Volt\n")
+	rows := len(bits) * BenchSampleRate / baudrate
+	for r := 0; r < rows; r++ {
+		t := float64(r) / BenchSampleRate
+		v := -BenchAmplitude
+		if bits[min(r*baudrate/BenchSampleRate, len(bits)-1)] {
+			v = BenchAmplitude
+		}
+		a := v/2 + random.NormFloat64()*BenchNoise
+		b := -v/2 + random.NormFloat64()*BenchNoise
+		fmt.Fprintf(writer, "%.6E,%.6E,%.6E\n", t, a, b)
+	}
+	if err := writer.Flush(); err != nil {
+		slog.Error("Flush", "err", err)
+		return 0, err
+	}
+	return rows, nil
+}
+
+// 解析の段階の経過時間
+type BenchStage struct {
+	name    string
+	elapsed time.Duration
+}
+
+// グラフを描かずに読み込みから復号までの段階を実行して、段階毎の経過時間を返す
+func benchDecode(ctx context.Context, csvfilepath string, option InsightOption) ([]BenchStage, error) {
+	stages := []BenchStage{}
+	start := time.Now()
+	lap := func(name string) {
+		now := time.Now()
+		stages = append(stages, BenchStage{name, now.Sub(start)})
+		start = now
+	}
+
+	matrix, _, err := prepareInputMatrix(ctx, io.Discard, csvfilepath, option)
+	if err != nil {
+		slog.Error("prepareInputMatrix", "err", err)
+		return nil, err
+	}
+	lap("load")
+
+	if option.filter == FilterSma && option.smoothWindow == 0 {
+		option.smoothWindow = autoSmoothingWindow(matrix, option.baudrate)
+	}
+	filtered, err := applyFilter(matrix, option)
+	if err != nil {
+		slog.Error("applyFilter", "err", err)
+		return nil, err
+	}
+	lap("filter")
+
+	reshaped, _, _, err := decodeWaveforms(matrix, nil, filtered, nil, option)
+	if err != nil {
+		slog.Error("decodeWaveforms", "err", err)
+		return nil, err
+	}
+	lap("reshape")
+
+	if _, _, err := analyzePulses(reshaped, option.format, option.decodeMode); err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return nil, err
+	}
+	lap("analyze")
+	return stages, nil
+}
+
+// 合成した測定値をcount回解析して、経過時間とメモリ割り当てを書く
+// fullを指定した場合はグラフを含めた全ての解析を、それ以外は読み込みから復号までを測る
+func runBench(ctx context.Context, w io.Writer, option InsightOption, frames int, count int, full bool) error {
+	// グラフの大きさは小さくして解析の時間を目立たせる
+	option.graphWidth, option.graphHeight = 640, 300
+	// 同じ出力を繰り返し作るので黙って置き換える
+	option.overwrite, option.versionOutputs = true, false
+	// 合成する測定値は8N1
+	option.format, _ = parseUartFormat(DefaultUartFormat)
+
+	dir, err := os.MkdirTemp("", "pulseinsight-bench")
+	if err != nil {
+		slog.Error("MkdirTemp", "err", err)
+		return err
+	}
+	defer os.RemoveAll(dir)
+	csvfilepath := filepath.Join(dir, "bench.csv")
+	rows, err := writeBenchCsv(csvfilepath, frames, option.baudrate)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "bench %d frames  %d rows  %dbps  %s %s/%s\n", frames, rows, option.baudrate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	var best time.Duration
+	for i := 1; i <= count; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		var stages []BenchStage
+		if full {
+			err = insightTheCsvFile(ctx, io.Discard, csvfilepath, option)
+		} else {
+			stages, err = benchDecode(ctx, csvfilepath, option)
+		}
+		if err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if i == 1 || elapsed < best {
+			best = elapsed
+		}
+		fmt.Fprintf(w, "run %d  %v  %.0f rows/s  alloc %.1fMB  %d allocs  %d GC\n",
+			i, elapsed.Round(time.Microsecond), float64(rows)/elapsed.Seconds(),
+			float64(after.TotalAlloc-before.TotalAlloc)/1e6, after.Mallocs-before.Mallocs, after.NumGC-before.NumGC)
+		for _, stage := range stages {
+			fmt.Fprintf(w, "  %-8s %v\n", stage.name, stage.elapsed.Round(time.Microsecond))
+		}
+	}
+	fmt.Fprintf(w, "best %v  %.0f rows/s\n", best.Round(time.Microsecond), float64(rows)/best.Seconds())
+	return nil
+}
@@ -0,0 +1,206 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 文字の中のパルスの長さをビット周期の整数倍と比べて, 長過ぎるビットや短過ぎるビットを探す(UARTのタイミングが狂った機器を見つける)
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 文字の中のパルス(同じレベルが続く区間)
+type BitPulse struct {
+	startTime float64  // パルスの始まり(基準時間からの相対時間)
+	length    float64  // 長さ(ビット)
+	bits      int      // 長さに最も近いビット数
+	code      UartCode // パルスを含む文字
+	mark      bool     // Markのパルスか(falseはSpace)
+}
+
+// 長さの狂い(ビット)
+func (p BitPulse) deviation() float64 {
+	return p.length - float64(p.bits)
+}
+
+// 文字の中のパルスの長さを測る
+// エッジはしきい値を離れた時と反対側のしきい値を越えた時の中間とし, 両端のエッジが同じ文字の
+// スタートビットの始まりからストップビットの始まりまでにあるパルスだけを測る
+func measureBitPulses(matrix mat.Matrix, originTime float64, codes []UartCode, baudrate int, format UartFormat) []BitPulse {
+	period := 1 / float64(baudrate)
+	stopOffset := float64(format.stopBitIndex()) * period
+	edges := measureEdges(matrix)
+	times := make([]float64, len(edges))
+	for i, e := range edges {
+		times[i] = (e.startTime+e.endTime)/2 - originTime
+	}
+	pulses := []BitPulse{}
+	k := 0
+	for i := 0; i+1 < len(edges); i++ {
+		begin, end := times[i], times[i+1]
+		// 始まりのエッジを含む文字(スタートビットのエッジは半ビットまでずれてもよい)
+		for k < len(codes) && codes[k].startTime+stopOffset+period/2 < begin {
+			k++
+		}
+		if k == len(codes) {
+			break
+		}
+		code := codes[k]
+		if begin < code.startTime-period/2 || end > code.startTime+stopOffset+period/2 {
+			continue
+		}
+		length := (end - begin) / period
+		pulses = append(pulses, BitPulse{
+			startTime: begin,
+			length:    length,
+			bits:      max(1, int(math.Round(length))),
+			code:      code,
+			mark:      edges[i].rising,
+		})
+	}
+	return pulses
+}
+
+// 長さの狂いが許容範囲(ビット)を超えたパルス
+func abnormalBitPulses(pulses []BitPulse, tolerance float64) []BitPulse {
+	abnormal := []BitPulse{}
+	for _, p := range pulses {
+		if math.Abs(p.deviation()) > tolerance {
+			abnormal = append(abnormal, p)
+		}
+	}
+	return abnormal
+}
+
+// パルスの説明
+func (p BitPulse) String() string {
+	level, judge := "space", "too short"
+	if p.mark {
+		level = "mark"
+	}
+	if p.deviation() > 0 {
+		judge = "too long"
+	}
+	return fmt.Sprintf("byte 0x%02x  %s %d bits measured %.2f bits (%+.2f UI)  %s", p.code.octet, level, p.bits, p.length, p.deviation(), judge)
+}
+
+// パルスの長さの狂いを書く
+func printBitLength(w io.Writer, clock Clock, label string, pulses []BitPulse, tolerance float64) {
+	if len(pulses) == 0 {
+		fmt.Fprintf(w, "bit length%s: no pulses\n", label)
+		return
+	}
+	minDev, maxDev := math.Inf(1), math.Inf(-1)
+	for _, p := range pulses {
+		minDev = min(minDev, p.deviation())
+		maxDev = max(maxDev, p.deviation())
+	}
+	abnormal := abnormalBitPulses(pulses, tolerance)
+	fmt.Fprintf(w, "bit length%s: %d pulses  deviation %+.2f..%+.2f UI  tolerance ±%.2f UI\n", label, len(pulses), minDev, maxDev, tolerance)
+	for _, p := range abnormal {
+		fmt.Fprintf(w, "  %s  %s\n", clock.format(p.startTime), p)
+	}
+	fmt.Fprintf(w, "bit length violations%s: %d\n", label, len(abnormal))
+}
+
+// 長さの狂いが許容範囲を超えたパルス
+func bitLengthAnomalies(pulses []BitPulse, tolerance float64) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, p := range abnormalBitPulses(pulses, tolerance) {
+		anomalies = append(anomalies, AnomalyEvent{
+			Time:     p.startTime,
+			Kind:     "bit-length",
+			Severity: SeverityWarning,
+			Detail:   p.String(),
+		})
+	}
+	return anomalies
+}
+
+// 同じビット数のMarkとSpaceのパルスの長さの平均
+type DutyGroup struct {
+	bits   int     // ビット数
+	mark   float64 // Markのパルスの長さの平均(ビット)
+	space  float64 // Spaceのパルスの長さの平均(ビット)
+	marks  int     // Markのパルスの数
+	spaces int     // Spaceのパルスの数
+}
+
+// MarkとSpaceの長さの差(UI)
+func (g DutyGroup) difference() float64 {
+	return g.mark - g.space
+}
+
+// MarkとSpaceのパルスの長さの偏り(ドライバの立ち上がりと立ち下がりの遅れの違い)
+// 同じビット数のMarkとSpaceの両方があるものだけを比べる
+type DutyAsymmetry struct {
+	groups []DutyGroup
+}
+
+// パルスの長さの偏りを測る
+func measureDutyAsymmetry(pulses []BitPulse) DutyAsymmetry {
+	groups := map[int]*DutyGroup{}
+	maxBits := 0
+	for _, p := range pulses {
+		g, ok := groups[p.bits]
+		if !ok {
+			g = &DutyGroup{bits: p.bits}
+			groups[p.bits] = g
+		}
+		if p.mark {
+			g.mark += p.length
+			g.marks++
+		} else {
+			g.space += p.length
+			g.spaces++
+		}
+		maxBits = max(maxBits, p.bits)
+	}
+	asymmetry := DutyAsymmetry{groups: []DutyGroup{}}
+	for bits := 1; bits <= maxBits; bits++ {
+		g, ok := groups[bits]
+		if !ok || g.marks == 0 || g.spaces == 0 {
+			continue
+		}
+		g.mark /= float64(g.marks)
+		g.space /= float64(g.spaces)
+		asymmetry.groups = append(asymmetry.groups, *g)
+	}
+	return asymmetry
+}
+
+// MarkとSpaceの長さの差(UI, パルスの数で重み付けた平均)
+// 正の場合はMarkが長い(立ち下がりが立ち上がりより遅れる)
+func (a DutyAsymmetry) difference() float64 {
+	sum, weight := 0.0, 0
+	for _, g := range a.groups {
+		sum += g.difference() * float64(g.marks+g.spaces)
+		weight += g.marks + g.spaces
+	}
+	if weight == 0 {
+		return 0
+	}
+	return sum / float64(weight)
+}
+
+// 1ビット毎にMarkとSpaceが替わる場合のMarkの割合(%)
+func (a DutyAsymmetry) duty() float64 {
+	return 50 * (1 + a.difference()/2)
+}
+
+// パルスの長さの偏りを書く
+func printDutyAsymmetry(w io.Writer, label string, asymmetry DutyAsymmetry, baudrate int) {
+	if len(asymmetry.groups) == 0 {
+		fmt.Fprintf(w, "duty asymmetry%s: no mark and space pulses of equal length\n", label)
+		return
+	}
+	period := 1 / float64(baudrate)
+	d := asymmetry.difference()
+	fmt.Fprintf(w, "duty asymmetry%s: mark-space %+.3f UI (%+.3fus)  duty %.1f%%\n", label, d, d*period*1e6, asymmetry.duty())
+	for _, g := range asymmetry.groups {
+		fmt.Fprintf(w, "  %d-bit: mark %.3f (%d)  space %.3f (%d)  %+.3f UI\n", g.bits, g.mark, g.marks, g.space, g.spaces, g.difference())
+	}
+}
@@ -0,0 +1,121 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 読み込みと波形整形の結果を保存して再実行を速くする解析キャッシュ
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// キャッシュの形式を変えたら増やす
+const AnalysisCacheVersion = 1
+
+// 解析キャッシュ
+// グラフやレポートの設定だけを変えた再実行では、CSVファイルの読み込みと波形整形を省く
+type AnalysisCache struct {
+	Matrix     *mat.Dense // 読み込んで補正した行列(時間,A,B[,DE]または時間,TX A,TX B,RX A,RX B)
+	Header     [][]string // 入力CSVのヘッダー行
+	Reshaped   *mat.Dense // 整形後の行列(TX対)
+	RxReshaped *mat.Dense // 整形後の行列(RX対), 半二重ではnil
+	OriginTime float64    // 基準時間
+}
+
+// 入力ファイルの内容と読み込みと波形整形に関わる設定から解析キャッシュのファイル名を決める
+func analysisCachePath(csvfilepath string, option InsightOption) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", AnalysisCacheVersion)
+	files := append([]string{csvfilepath}, option.stitchFiles...)
+	// Tektronixの波形ファイルはB線のファイルも含める
+	if strings.EqualFold(filepath.Ext(csvfilepath), IsfExt) {
+		bPath, err := isfPairFile(csvfilepath, option.bFile)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, bPath)
+	}
+	for _, file := range files {
+		sum, err := fileSha256(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", sum)
+	}
+	fmt.Fprintf(h, "%d %s %s %q %s %s %g %g %v %v %g\n",
+		option.baudrate, option.estimateBaud, option.badRows, option.columnNames, option.timeUnit, option.voltageUnit,
+		option.aScale, option.bScale, option.invertA, option.invertB, option.skew)
+	fmt.Fprintf(h, "%s %d %d %g %g %g %v %s %s\n",
+		option.filter, option.smoothWindow, option.waveletLevels, option.emaAlpha, option.kalmanQ, option.kalmanR,
+		option.decodeFilter, option.edgeDetect, option.inputType)
+	// 同じファイルでも拡張子によらずVCD, WAVとして読むと別の測定値になる
+	fmt.Fprintf(h, "%d %q %g %v %v\n", option.skipLines, option.delimiter, option.wavFullScale, option.vcdInput, option.wavInput)
+	fmt.Fprintf(h, "%s %g %s %v %g %g\n", option.resync, option.resyncIdle, option.format, option.format.idleSpace, option.threshold, option.noiseFloor)
+
+	dir := option.cacheDir
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userCacheDir, "pulseinsight")
+	}
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil))+".gob"), nil
+}
+
+// 解析キャッシュを読み込む
+func loadAnalysisCache(cachePath string) (*AnalysisCache, error) {
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var cache AnalysisCache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		slog.Warn("broken cache", "file", cachePath, "err", err)
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// 解析キャッシュを保存する
+func saveAnalysisCache(cachePath string, matrix *mat.Dense, header [][]string, reshaped mat.Matrix, rxReshaped mat.Matrix, originTime float64) error {
+	cache := AnalysisCache{
+		Matrix:     matrix,
+		Header:     header,
+		Reshaped:   mat.DenseCopyOf(reshaped),
+		OriginTime: originTime,
+	}
+	if rxReshaped != nil {
+		cache.RxReshaped = mat.DenseCopyOf(rxReshaped)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		slog.Error("MkdirAll", "err", err)
+		return err
+	}
+	// 書きかけのファイルを読まないように、一時ファイルに書いてから名前を変える
+	file, err := os.CreateTemp(filepath.Dir(cachePath), ".cache")
+	if err != nil {
+		slog.Error("CreateTemp", "err", err)
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(cache); err != nil {
+		file.Close()
+		slog.Error("Encode", "err", err)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		slog.Error("Close", "err", err)
+		return err
+	}
+	return os.Rename(file.Name(), cachePath)
+}
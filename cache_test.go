@@ -0,0 +1,32 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 同じファイルをCSV, VCD, WAVとして読む場合は別のキャッシュにする
+func TestAnalysisCachePathInputKind(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.dat")
+	if err := os.WriteFile(path, []byte("0,0,0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	option := InsightOption{cacheDir: dir}
+	seen := map[string]string{}
+	for _, kind := range []string{"csv", "vcd", "wav"} {
+		option.vcdInput, option.wavInput = kind == "vcd", kind == "wav"
+		cachePath, err := analysisCachePath(path, option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := seen[cachePath]; ok {
+			t.Errorf("%s and %s share \"%s\"", other, kind, cachePath)
+		}
+		seen[cachePath] = kind
+	}
+}
@@ -0,0 +1,327 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 既知のバイト列を取り込んだ測定値で復号のしきい値, スキュー, 移動平均の窓を探し, 最も良い設定をプロファイルに書く
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 既定で試す復号のしきい値(V)
+const CalibrateThresholds = "0.2,0.3,0.5,0.7,1,1.5,2,3"
+
+// 校正の設定
+type CalibrateOption struct {
+	expected   []byte    // 測定値に含まれる既知のバイト列
+	thresholds []float64 // 試す復号のしきい値(V, 昇順)
+	skewRange  float64   // 試すスキューの範囲(±ビット周期に対する比)
+	skewSteps  int       // スキューの片側の段階数
+	windows    []int     // 試す移動平均の窓(サンプル数, 0は生の波形を復号する), 空の場合はビット周期から決める
+	name       string    // 書き込むプロファイルの名前
+	dryRun     bool      // プロファイルを書き込まない
+}
+
+// スキューと移動平均の窓の組の結果
+type CalibrateCandidate struct {
+	skew          float64 // スキュー(s)
+	window        int     // 移動平均の窓(0は生の波形)
+	passed        []bool  // しきい値毎に既知のバイト列をそのまま復号できた
+	low, high     int     // 復号できたしきい値の最も長く続く範囲(添字), 無い場合はlow > high
+	byteErrors    int     // 最も少ない既知のバイト列との違い(挿入と削除と置換の回数)
+	framingErrors int     // その時のフレーミングエラーの数
+}
+
+// 復号できたしきい値の範囲の上限と下限の比(大きいほど余裕がある), 無い場合は0
+func (c CalibrateCandidate) margin(thresholds []float64) float64 {
+	if c.low > c.high {
+		return 0
+	}
+	return thresholds[c.high] / thresholds[c.low]
+}
+
+// 復号できたしきい値の範囲の中点
+func (c CalibrateCandidate) threshold(thresholds []float64) float64 {
+	return (thresholds[c.low] + thresholds[c.high]) / 2
+}
+
+// 良い順に並べるための比較
+// しきい値の余裕が大きい, 既知のバイト列との違いが少ない, 生の波形を復号する, スキューが小さい順
+func (c CalibrateCandidate) better(other CalibrateCandidate, thresholds []float64) bool {
+	if m, n := c.margin(thresholds), other.margin(thresholds); m != n {
+		return m > n
+	}
+	if c.byteErrors != other.byteErrors {
+		return c.byteErrors < other.byteErrors
+	}
+	if c.framingErrors != other.framingErrors {
+		return c.framingErrors < other.framingErrors
+	}
+	if c.window != other.window {
+		return c.window < other.window
+	}
+	return math.Abs(c.skew) < math.Abs(other.skew)
+}
+
+// "05 30 31", "053031", "05,30,31" のような16進数のバイト列を読む
+func parseExpectedBytes(s string) ([]byte, error) {
+	text := strings.NewReplacer(" ", "", ",", "", ":", "", "0x", "", "0X", "").Replace(s)
+	octets, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("既知のバイト列 \"%s\" を読めない: %w", s, err)
+	}
+	if len(octets) == 0 {
+		return nil, fmt.Errorf("既知のバイト列が空")
+	}
+	return octets, nil
+}
+
+// --expectか--expect-fileの既知のバイト列を読む
+// expectFileの場合はファイルの中身をそのまま使う
+func loadExpectedBytes(expect string, expectFile string) ([]byte, error) {
+	switch {
+	case expect != "" && expectFile != "":
+		return nil, fmt.Errorf("--expect と --expect-file は同時に指定できない")
+	case expectFile != "":
+		octets, err := os.ReadFile(expectFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(octets) == 0 {
+			return nil, fmt.Errorf("既知のバイト列のファイル \"%s\" が空", expectFile)
+		}
+		return octets, nil
+	case expect != "":
+		return parseExpectedBytes(expect)
+	default:
+		return nil, fmt.Errorf("既知のバイト列を --expect か --expect-file で指定する")
+	}
+}
+
+// "0.5,1,2" のようなしきい値(V)の一覧を読んで昇順に並べる
+func parseCalibrateThresholds(s string) ([]float64, error) {
+	thresholds := []float64{}
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("しきい値 \"%s\" は正の数にする", field)
+		}
+		thresholds = append(thresholds, v)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// "0,4,8" のような移動平均の窓の一覧を読む(空の場合は空)
+func parseCalibrateWindows(s string) ([]int, error) {
+	windows := []int{}
+	if strings.TrimSpace(s) == "" {
+		return windows, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("移動平均の窓 \"%s\" は0以上の整数にする", field)
+		}
+		windows = append(windows, v)
+	}
+	return windows, nil
+}
+
+// ビット周期から試す移動平均の窓を決める
+// 生の波形と, ビット周期の1/16, 1/8, 1/4に相当するサンプル数
+func autoCalibrateWindows(matrix mat.Matrix, baudrate int) []int {
+	w := autoSmoothingWindow(matrix, baudrate)
+	windows := []int{0}
+	for _, v := range []int{max(1, w/2), w, 2 * w} {
+		if v != windows[len(windows)-1] {
+			windows = append(windows, v)
+		}
+	}
+	return windows
+}
+
+// 復号できたしきい値の最も長く続く範囲
+func longestPassedRun(passed []bool) (int, int) {
+	low, high := 0, -1
+	for i := 0; i < len(passed); {
+		if !passed[i] {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < len(passed) && passed[j+1] {
+			j++
+		}
+		if j-i > high-low {
+			low, high = i, j
+		}
+		i = j + 1
+	}
+	return low, high
+}
+
+// スキューと移動平均の窓の組で, しきい値毎に復号して既知のバイト列と比べる
+func calibrateCandidate(ctx context.Context, matrix *mat.Dense, skew float64, window int, option InsightOption, calibrate CalibrateOption) (CalibrateCandidate, error) {
+	candidate := CalibrateCandidate{skew: skew, window: window, passed: make([]bool, len(calibrate.thresholds)), byteErrors: math.MaxInt}
+	skewed := mat.DenseCopyOf(matrix)
+	applySkew(skewed, skew)
+	option.decodeFilter = window > 0
+	var filtered mat.Matrix = skewed
+	if window > 0 {
+		option.filter, option.smoothWindow = FilterSma, window
+		var err error
+		if filtered, err = applyFilter(skewed, option); err != nil {
+			slog.Error("applyFilter", "err", err)
+			return candidate, err
+		}
+	}
+	// A,B間電圧差の最大の大きさ以上のしきい値では何も復号できない
+	peak := 0.0
+	for _, d := range waveform.Differential(nil, filtered) {
+		peak = max(peak, math.Abs(d))
+	}
+	for i, threshold := range calibrate.thresholds {
+		if err := ctx.Err(); err != nil {
+			return candidate, checkCanceled(ctx)
+		}
+		var decoded []byte
+		framingErrors := 0
+		if threshold < peak {
+			option.threshold = threshold
+			var err error
+			decoded, framingErrors, err = decodeOctets(skewed, filtered, option)
+			if err != nil && !errors.Is(err, waveform.ErrNoRuns) {
+				return candidate, err
+			}
+		}
+		byteErrors := countByteErrors(calibrate.expected, decoded)
+		candidate.passed[i] = byteErrors == 0 && framingErrors == 0
+		// 何も復号できなかったしきい値は, 他のしきい値でも何も復号できなかった場合だけ数える
+		if len(decoded) == 0 && candidate.byteErrors != math.MaxInt {
+			continue
+		}
+		if byteErrors < candidate.byteErrors || (byteErrors == candidate.byteErrors && framingErrors < candidate.framingErrors) {
+			candidate.byteErrors, candidate.framingErrors = byteErrors, framingErrors
+		}
+	}
+	candidate.low, candidate.high = longestPassedRun(candidate.passed)
+	return candidate, nil
+}
+
+// しきい値毎の合否を "..++++.." のように表す
+func passedMarks(passed []bool) string {
+	var b strings.Builder
+	for _, p := range passed {
+		if p {
+			b.WriteByte('+')
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
+
+// 校正した設定のプロファイル(フラグの名前と値)
+func calibratedProfile(best CalibrateCandidate, thresholds []float64, option InsightOption) map[string]any {
+	profile := map[string]any{
+		"baudrate":        option.baudrate,
+		"frame":           option.format.String(),
+		"threshold":       math.Round(best.threshold(thresholds)*1000) / 1000,
+		"skew":            best.skew,
+		"decode-filtered": best.window > 0,
+	}
+	if best.window > 0 {
+		profile["filter"] = FilterSma
+		profile["window"] = best.window
+	}
+	if option.format.idleSpace {
+		profile["idle-level"] = IdleLevelSpace
+	}
+	return profile
+}
+
+// 既知のバイト列を取り込んだ測定値で, 復号のしきい値, スキュー, 移動平均の窓を変えて復号し,
+// 既知のバイト列をそのまま復号できるしきい値の範囲が最も広い組を選んで, しきい値は範囲の中点にする
+// 全二重の場合は送信対だけを調べる
+func runCalibrate(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption, calibrate CalibrateOption) error {
+	// スキューは校正で決めるので, 指定したスキューは使わない
+	option.skew = 0
+	matrix, _, err := prepareInputMatrix(ctx, io.Discard, csvfilepath, option)
+	if err != nil {
+		slog.Error("prepareInputMatrix", "err", err)
+		return err
+	}
+	if isDuplex(matrix) {
+		matrix, _ = splitDuplex(matrix)
+		fmt.Fprintln(w, "calibrate: full duplex, TX pair only")
+	}
+	windows := calibrate.windows
+	if len(windows) == 0 {
+		windows = autoCalibrateWindows(matrix, option.baudrate)
+	}
+	skews := []float64{}
+	for step := -calibrate.skewSteps; step <= calibrate.skewSteps; step++ {
+		skews = append(skews, float64(step)*calibrate.skewRange/float64(max(calibrate.skewSteps, 1))/float64(option.baudrate))
+	}
+	fmt.Fprintf(w, "calibrate: \"%s\"  expect %d bytes  %d skews x %d windows x %d thresholds\n",
+		csvfilepath, len(calibrate.expected), len(skews), len(windows), len(calibrate.thresholds))
+
+	thresholdTexts := make([]string, len(calibrate.thresholds))
+	for i, threshold := range calibrate.thresholds {
+		thresholdTexts[i] = strconv.FormatFloat(threshold, 'g', -1, 64)
+	}
+	fmt.Fprintf(w, "  thresholds(V): %s\n", strings.Join(thresholdTexts, " "))
+	fmt.Fprintf(w, "  %10s %7s %-*s %12s %15s\n", "skew(us)", "window", max(len(calibrate.thresholds), 6), "passed", "byte errors", "framing errors")
+	var best *CalibrateCandidate
+	for _, skew := range skews {
+		for _, window := range windows {
+			candidate, err := calibrateCandidate(ctx, matrix, skew, window, option, calibrate)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "  %+10.3f %7d %-*s %12d %15d\n", skew*1e6, window, max(len(calibrate.thresholds), 6), passedMarks(candidate.passed), candidate.byteErrors, candidate.framingErrors)
+			if best == nil || candidate.better(*best, calibrate.thresholds) {
+				best = &candidate
+			}
+		}
+	}
+	if best.low > best.high {
+		return fmt.Errorf("どの設定でも既知のバイト列をそのまま復号できない(最も少ない違い%dバイト, フレーミングエラー%d)", best.byteErrors, best.framingErrors)
+	}
+	filtered := "raw"
+	if best.window > 0 {
+		filtered = fmt.Sprintf("sma window %d", best.window)
+	}
+	fmt.Fprintf(w, "best: threshold %.3fV (passed %g..%gV)  skew %+.3fus  %s\n",
+		best.threshold(calibrate.thresholds), calibrate.thresholds[best.low], calibrate.thresholds[best.high], best.skew*1e6, filtered)
+
+	if calibrate.dryRun {
+		return nil
+	}
+	path, err := profilesFilePath(option.profilesFile)
+	if err != nil {
+		slog.Error("profilesFilePath", "err", err)
+		return err
+	}
+	if err := saveProfile(path, calibrate.name, calibratedProfile(*best, calibrate.thresholds, option)); err != nil {
+		slog.Error("saveProfile", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "profile \"%s\" saved to \"%s\" (--profile %s)\n", calibrate.name, path, calibrate.name)
+	return nil
+}
@@ -0,0 +1,43 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 長い解析を割り込み(Ctrl-C)や時間切れで途中で止める
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 中断した場合の終了コード(128+SIGINT)
+const ExitCanceled = 130
+
+// 割り込み(Ctrl-C)かSIGTERMを受けると終わるコンテキスト
+// 解析は段の切れ目で止まり, 頼んだグラフの保存を終えてから戻る
+// 止まるのを待たずに終わらせたい場合は, もう一度Ctrl-Cを押す(2回目からは通常の割り込みになる)
+func newSignalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// 中断されていないか調べる
+// 中断されていた場合はその理由を含むエラーを返す
+func checkCanceled(ctx context.Context) error {
+	err := ctx.Err()
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("解析が時間切れになった: %w", err)
+	default:
+		return fmt.Errorf("解析を中断した: %w", err)
+	}
+}
@@ -0,0 +1,57 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 解析段ごとの波形のグラフとフレームの統計のグラフを作るかどうかを選ぶ
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 解析段ごとの波形のグラフ
+const (
+	ChartRaw      = chart.Raw      // 測定値(_voltage.png)
+	ChartFiltered = chart.Filtered // フィルタ後(_filtered.png)
+	ChartReshaped = chart.Reshaped // 波形整形後(_reshaped.png)
+	ChartUart     = chart.Uart     // 復号したビットとバイト(_uart.png)
+	ChartStacked  = chart.Stacked  // A線, B線, A-B間電圧差を縦に並べた測定値(_stacked.png), 既定では作らない
+	ChartDiff     = chart.Diff     // A-B間電圧差としきい値の測定値(_diff.png), 既定では作らない
+	ChartTimeline = "timeline"     // フレームのタイムライン(_timeline.png)
+	ChartHeatmap  = "heatmap"      // バイト値のヒートマップ(_heatmap.png)
+	ChartByteGap  = "bytegap"      // バイト間の無通信時間のヒストグラム(_bytegap.png)
+	ChartFrameGap = "framegap"     // フレーム間の無通信時間のヒストグラム(_framegap.png)
+	ChartNone     = "none"         // グラフを作らない
+)
+
+// 既定では積み重ねたグラフとA-B間電圧差のグラフ以外の全てのグラフを作る
+const DefaultCharts = ChartRaw + "," + ChartFiltered + "," + ChartReshaped + "," + ChartUart + "," +
+	ChartTimeline + "," + ChartHeatmap + "," + ChartByteGap + "," + ChartFrameGap
+
+// 作るグラフ
+type ChartSelection map[string]bool
+
+// カンマ区切りのグラフの一覧を読む
+func parseChartSelection(text string) (ChartSelection, error) {
+	selection := ChartSelection{}
+	for _, name := range strings.Split(text, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case ChartRaw, ChartFiltered, ChartReshaped, ChartUart, ChartStacked, ChartDiff,
+			ChartTimeline, ChartHeatmap, ChartByteGap, ChartFrameGap:
+			selection[name] = true
+		case ChartNone, "":
+		default:
+			return nil, fmt.Errorf("グラフ \"%s\" には対応していない(%s, %s, %s, %s)", name, DefaultCharts, ChartStacked, ChartDiff, ChartNone)
+		}
+	}
+	return selection, nil
+}
+
+// グラフを1つでも作るかどうか
+// noneだけを選んだ場合は他のオプションで頼んだグラフ(使用率, ダッシュボード, タイル画像)も作らない
+func (s ChartSelection) any() bool {
+	return len(s) > 0
+}
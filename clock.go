@@ -0,0 +1,61 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 時間の表示(キャプチャ開始からの秒数、または絶対時刻)
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 絶対時刻の表示形式
+const AbsoluteTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// 時間の表示
+type Clock struct {
+	originTime float64   // 相対時間の基準にした入力CSVの時間(通常はスタートビット開始時間)
+	t0         time.Time // 入力CSVの時間0の時刻
+	absolute   bool      // 絶対時刻で表示するか
+}
+
+// 時刻文字列を解析する
+func parseT0(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("時刻 \"%s\" を解析できない", s)
+}
+
+// ヘッダー行から時刻が書かれた欄を探す
+func findHeaderTime(header [][]string) (time.Time, bool) {
+	for _, record := range header {
+		for _, field := range record {
+			if t, err := parseT0(field); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// 入力CSVの時間を時刻にする
+func (c Clock) captureTime(t float64) time.Time {
+	return c.t0.Add(time.Duration(t * float64(time.Second)))
+}
+
+// 基準時間からの相対時間を時刻にする
+func (c Clock) relativeTime(t float64) time.Time {
+	return c.captureTime(c.originTime + t)
+}
+
+// 基準時間からの相対時間を表示用の文字列にする
+func (c Clock) format(t float64) string {
+	if c.absolute {
+		return c.relativeTime(t).Format(AbsoluteTimeFormat)
+	}
+	return fmt.Sprintf("%.6fs", t)
+}
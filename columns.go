@@ -0,0 +1,120 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// CSVファイルの区切り文字とヘッダー行の扱い, 列名か列番号による列の選択
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 列名か1始まりの列番号で選ぶ列(時間, A線, B線の順), 空の場合は既定の列
+type ColumnNames [3]string
+
+// 区切り文字の指定(1文字か, tab, space, semicolon, comma)
+func parseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "", "comma":
+		return ',', nil
+	case "tab", "\\t":
+		return '\t', nil
+	case "space":
+		return ' ', nil
+	case "semicolon":
+		return ';', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("区切り文字 \"%s\" には対応していない", s)
+	}
+	return r, nil
+}
+
+// CSVファイルの読み込み方
+func csvLoadOptions(option InsightOption) (waveform.LoadOptions, error) {
+	delimiter, err := parseDelimiter(option.delimiter)
+	if err != nil {
+		return waveform.LoadOptions{}, err
+	}
+	if option.skipLines < 0 {
+		return waveform.LoadOptions{}, fmt.Errorf("読み飛ばす行数 %d には対応していない", option.skipLines)
+	}
+	headerLines := option.skipLines
+	if headerLines == 0 {
+		headerLines = waveform.NoHeader
+	}
+	return waveform.LoadOptions{BadRows: option.badRows, HeaderLines: headerLines, Delimiter: delimiter}, nil
+}
+
+// ヘッダー行から列名の列番号を探す
+// 列名が無く, 1始まりの列番号の場合はその列
+func findColumn(header [][]string, name string) (int, bool) {
+	for _, record := range header {
+		for c, field := range record {
+			if strings.EqualFold(strings.TrimSpace(field), strings.TrimSpace(name)) {
+				return c, true
+			}
+		}
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(name)); err == nil && n >= 1 {
+		return n - 1, true
+	}
+	return 0, false
+}
+
+// 列名で選んだ列を時間, A線, B線の順に並べ替える
+// 残りの列は元の順番で後ろに続ける
+func selectColumns(matrix *mat.Dense, header [][]string, names ColumnNames) (*mat.Dense, [][]string, error) {
+	rows, cols := matrix.Dims()
+
+	order := []int{}
+	for i, name := range names {
+		index := []int{ColTime, ColWireA, ColWireB}[i]
+		if name != "" {
+			c, ok := findColumn(header, name)
+			if !ok {
+				return nil, nil, fmt.Errorf("列名 \"%s\" がヘッダー行にない(列番号は1始まり)", name)
+			}
+			index = c
+		}
+		if index >= cols {
+			return nil, nil, fmt.Errorf("列%dがない", index+1)
+		}
+		if slices.Contains(order, index) {
+			return nil, nil, fmt.Errorf("列%dが重複して選ばれている", index+1)
+		}
+		order = append(order, index)
+	}
+	for c := 0; c < cols; c++ {
+		if !slices.Contains(order, c) {
+			order = append(order, c)
+		}
+	}
+
+	selected := mat.NewDense(rows, cols, nil)
+	for to, from := range order {
+		selected.SetCol(to, mat.Col(nil, from, matrix))
+	}
+
+	// ヘッダー行も同じように並べ替える
+	selectedHeader := make([][]string, len(header))
+	for i, record := range header {
+		for _, from := range order {
+			if from < len(record) {
+				selectedHeader[i] = append(selectedHeader[i], record[from])
+			} else {
+				selectedHeader[i] = append(selectedHeader[i], "")
+			}
+		}
+	}
+
+	return selected, selectedHeader, nil
+}
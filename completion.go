@@ -0,0 +1,89 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// シェルの補完スクリプト
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// 補完スクリプトはフラグとサブコマンドの候補を--generate-bash-completionで本体に問い合わせる
+var completionScripts = map[string]string{
+	"bash": `_pulseinsight_completion() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local words=("${COMP_WORDS[@]:0:$COMP_CWORD}")
+  local opts
+  if [[ "$cur" == "-"* ]]; then
+    opts=$("${words[@]}" "$cur" --generate-bash-completion 2>/dev/null)
+  else
+    opts=$("${words[@]}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "${opts}" -- "${cur}"))
+}
+complete -o bashdefault -o default -F _pulseinsight_completion pulseinsight
+`,
+	"zsh": `#compdef pulseinsight
+
+_pulseinsight() {
+  local -a opts
+  local cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _pulseinsight pulseinsight
+`,
+	"powershell": `Register-ArgumentCompleter -Native -CommandName pulseinsight -ScriptBlock {
+  param($wordToComplete, $commandAst, $cursorPosition)
+  $line = $commandAst.ToString()
+  Invoke-Expression "$line --generate-bash-completion" | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+  }
+}
+`,
+}
+
+// 対応するシェル
+func completionShells() []string {
+	shells := []string{"fish"}
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// シェルの補完スクリプトを書く
+func writeCompletion(w io.Writer, app *cli.App, shell string) error {
+	if shell == "fish" {
+		// fishはフラグとサブコマンドを全て書き出す
+		script, err := app.ToFishCompletion()
+		if err != nil {
+			slog.Error("ToFishCompletion", "err", err)
+			return err
+		}
+		_, err = io.WriteString(w, script)
+		return err
+	}
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("シェル \"%s\" には対応していない(%s)", shell, strings.Join(completionShells(), ", "))
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
@@ -0,0 +1,222 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ストップビットや誤り検出符号が合わない文字やフレームを、際どいビットを1つ反転して直してみる
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 反転を試みるビットの強さの上限(典型的なビットの強さに対する比)
+const CorrectMaxStrength = 0.75
+
+// 反転したのがストップビットであることを表すビット番号
+const CorrectStopBit = -1
+
+// 訂正の理由
+const (
+	CorrectFraming = "framing" // ストップビットが0
+	CorrectCrc     = "crc"     // 誤り検出符号の不一致
+)
+
+// 1方向の解析したビット列と波形(訂正と軟判定に使う)
+type BitWaveform struct {
+	bits    []UartBit
+	format  UartFormat // 文字の形式
+	bitTime float64    // ビット周期(s)
+	times   []float64  // 基準時間からの相対時間
+	diff    []float64  // A,B間電圧差
+	typical float64    // 典型的なビットの強さ(ビットのA,B間電圧差の絶対値の中央値)
+}
+
+// ビット列と解析した波形から作る
+func newBitWaveform(bits []UartBit, matrix mat.Matrix, originTime float64, baudrate int, format UartFormat) BitWaveform {
+	times := mat.Col(nil, ColTime, matrix)
+	for i := range times {
+		times[i] -= originTime
+	}
+	s := BitWaveform{bits: bits, format: format, bitTime: 1 / float64(baudrate), times: times, diff: waveform.Differential(nil, matrix)}
+	levels := make([]float64, len(bits))
+	for i, b := range bits {
+		quarter := (b.endTime - b.startTime) / 4
+		levels[i] = math.Abs(s.mean(b.startTime+quarter, b.endTime-quarter))
+	}
+	sort.Float64s(levels)
+	if len(levels) > 0 {
+		s.typical = levels[len(levels)/2]
+	}
+	return s
+}
+
+// 区間[from,to)のA,B間電圧差の平均
+// 区間に標本が無い場合はfromの直後の標本
+func (s BitWaveform) mean(from float64, to float64) float64 {
+	begin := sort.SearchFloat64s(s.times, from)
+	end := max(sort.SearchFloat64s(s.times, to), begin+1)
+	end = min(end, len(s.diff))
+	if begin >= end {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range s.diff[begin:end] {
+		sum += v
+	}
+	return sum / float64(end-begin)
+}
+
+// 文字のスタートビットの次からn番目のビットの強さ(典型的なビットに対する比, 0-1)
+// 受信機と同じようにスタートビットの開始から数えたビットの中央の半分の区間を平均する
+func (s BitWaveform) strength(start int, n int) float64 {
+	if s.typical == 0 {
+		return 1
+	}
+	from := s.bits[start].startTime + (float64(n+1)+0.25)*s.bitTime
+	return math.Min(math.Abs(s.mean(from, from+0.5*s.bitTime))/s.typical, 1)
+}
+
+// 文字のスタートビットの位置
+func (s BitWaveform) startBitIndex(startTime float64) (int, bool) {
+	i := sort.Search(len(s.bits), func(i int) bool { return s.bits[i].startTime >= startTime })
+	if i+s.format.stopBitIndex() >= len(s.bits) || s.bits[i].startTime != startTime {
+		return 0, false
+	}
+	return i, true
+}
+
+// 1ビットの訂正の結果
+type Correction struct {
+	reason     string  // 訂正の理由(CorrectFraming, CorrectCrc)
+	startTime  float64 // 文字またはフレームの開始時間
+	direction  string  // 全二重の場合の通信方向, 半二重では空
+	frameIndex int     // フレーム番号(0始まり), 文字の訂正では-1
+	original   []byte  // 訂正前のバイト列
+	codeIndex  int     // 反転したビットのあるバイトの位置, 訂正できなかった場合は-1
+	bit        int     // 反転したビット(0から: データ, CorrectStopBit: ストップビット)
+	corrected  []byte  // 訂正後のバイト列
+	confidence float64 // 確からしさ(0-1), 反転したビットが際どいほど高い
+}
+
+// 訂正できたか
+func (c Correction) ok() bool {
+	return c.codeIndex >= 0
+}
+
+// ストップビットが0の文字
+// ストップビットが際どい場合はストップビットを反転して直す
+// 直後にスタートビットが続いて復号されなかった文字も対象にする
+func correctFramingErrors(source BitWaveform, direction string) []Correction {
+	corrections := []Correction{}
+	// スタートビットからストップビットまでのビット数
+	stop := source.format.stopBitIndex()
+	for i, b := range source.bits {
+		if b.state != "X" || i < stop || source.bits[i-stop].state != "START" {
+			continue
+		}
+		var octet byte
+		for n, data := range source.bits[i-stop+1 : i-stop+1+source.format.dataBits] {
+			octet |= byte(data.bit) << n
+		}
+		c := Correction{
+			reason:     CorrectFraming,
+			startTime:  source.bits[i-stop].startTime,
+			direction:  direction,
+			frameIndex: -1,
+			original:   []byte{octet},
+			codeIndex:  -1,
+		}
+		if s := source.strength(i-stop, stop-1); s < CorrectMaxStrength {
+			c.codeIndex, c.bit = 0, CorrectStopBit
+			c.corrected = []byte{octet}
+			c.confidence = 1 - s
+		}
+		corrections = append(corrections, c)
+	}
+	return corrections
+}
+
+// 誤り検出符号が合わないフレーム
+// 誤り検出符号が合うように際どいデータビットを1つ反転して直す
+// 候補が複数ある場合は最も弱いビットを選ぶ
+func correctCrcErrors(frames []UartFrame, sources map[string]BitWaveform, crcKind string) []Correction {
+	corrections := []Correction{}
+	for i, f := range frames {
+		if ok, checked := checkFrameCrc(f, crcKind); !checked || ok {
+			continue
+		}
+		octets := make([]byte, len(f.codes))
+		for k, code := range f.codes {
+			octets[k] = code.octet
+		}
+		c := Correction{
+			reason:     CorrectCrc,
+			startTime:  f.startTime,
+			direction:  f.direction,
+			frameIndex: i,
+			original:   octets,
+			codeIndex:  -1,
+		}
+		source, ok := sources[f.direction]
+		best := CorrectMaxStrength
+		for k := 0; ok && k < len(f.codes); k++ {
+			start, found := source.startBitIndex(f.codes[k].startTime)
+			if !found {
+				continue
+			}
+			for bit := 0; bit < source.format.dataBits; bit++ {
+				s := source.strength(start, bit)
+				if s >= best {
+					continue
+				}
+				candidate := UartFrame{codes: append([]UartCode{}, f.codes...)}
+				candidate.codes[k].octet ^= 1 << bit
+				if ok, _ := checkFrameCrc(candidate, crcKind); ok {
+					best = s
+					c.codeIndex, c.bit = k, bit
+					c.corrected = append([]byte{}, octets...)
+					c.corrected[k] ^= 1 << bit
+					c.confidence = 1 - s
+				}
+			}
+		}
+		corrections = append(corrections, c)
+	}
+	return corrections
+}
+
+// 訂正の結果を表示する
+// 訂正したものは元の復号と区別できるようにcorrectedと表示する
+func printCorrections(w io.Writer, clock Clock, corrections []Correction) {
+	fmt.Fprintf(w, "correction: %d failed\n", len(corrections))
+	corrected := 0
+	for _, c := range corrections {
+		if c.frameIndex >= 0 {
+			fmt.Fprintf(w, "  frame #%d", c.frameIndex+1)
+		} else {
+			fmt.Fprint(w, "  char")
+		}
+		if c.direction != "" {
+			fmt.Fprintf(w, " %s", c.direction)
+		}
+		fmt.Fprintf(w, " %s  %s", clock.format(c.startTime), c.reason)
+		if !c.ok() {
+			fmt.Fprintf(w, "  uncorrectable  % x\n", c.original)
+			continue
+		}
+		corrected++
+		if c.bit == CorrectStopBit {
+			fmt.Fprintf(w, "  byte %d stop bit", c.codeIndex)
+		} else {
+			fmt.Fprintf(w, "  byte %d bit#%d 0x%02x -> 0x%02x", c.codeIndex, c.bit, c.original[c.codeIndex], c.corrected[c.codeIndex])
+		}
+		fmt.Fprintf(w, "  confidence %.2f  corrected % x\n", c.confidence, c.corrected)
+	}
+	fmt.Fprintf(w, "corrected: %d  uncorrectable: %d\n", corrected, len(corrections)-corrected)
+}
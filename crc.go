@@ -0,0 +1,47 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// フレームの誤り検出符号の検査
+package main
+
+// 誤り検出符号の種類
+const (
+	CrcNone   = "none"   // 検査しない
+	CrcModbus = "modbus" // Modbus RTUのCRC-16(フレーム末尾2バイト, 下位バイトが先)
+)
+
+// Modbus RTUのCRC-16を計算する
+func crc16Modbus(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// フレームの誤り検出符号を検査する
+// 検査できた場合にcheckedがtrueになる
+func checkFrameCrc(f UartFrame, crcKind string) (ok bool, checked bool) {
+	switch crcKind {
+	case CrcModbus:
+		n := len(f.codes)
+		if n < 3 {
+			return false, false
+		}
+		data := make([]byte, n-2)
+		for i := range data {
+			data[i] = f.codes[i].octet
+		}
+		received := uint16(f.codes[n-2].octet) | uint16(f.codes[n-1].octet)<<8
+		return crc16Modbus(data) == received, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,246 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 波形の縮小図, アイダイアグラム, 異常の数と主な指標を1枚にまとめた画像(チャットやチケットに貼る)
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/text"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/chart"
+	"pulseinsight/pkg/waveform"
+)
+
+// ダッシュボードの大きさ(pt)
+const (
+	DashboardWidth  = 1600
+	DashboardHeight = 900
+)
+
+// アイダイアグラムに重ねるビットの最大数
+const DashboardEyeMaxBits = 400
+
+// ダッシュボードの指標
+type DashboardMetric struct {
+	name  string // 名前
+	value string // 値
+	alert bool   // 異常が有る
+}
+
+// ダッシュボードに載せる指標
+func dashboardMetrics(csvfilepath string, baudrate int, traffic TrafficSummary, anomalies []AnomalyEvent) []DashboardMetric {
+	metrics := []DashboardMetric{
+		{name: "file", value: filepath.Base(csvfilepath)},
+		{name: "baud rate", value: fmt.Sprintf("%d bps", baudrate)},
+		{name: "capture", value: fmt.Sprintf("%.6fs", traffic.duration)},
+		{name: "frames", value: fmt.Sprintf("%d", traffic.frames)},
+		{name: "bytes", value: fmt.Sprintf("%d", traffic.bytes)},
+	}
+	directions := []string{}
+	for direction := range traffic.busyTime {
+		directions = append(directions, direction)
+	}
+	sort.Strings(directions)
+	for _, direction := range directions {
+		name := "utilization"
+		if direction != "" {
+			name += " " + direction
+		}
+		utilization := 0.0
+		if traffic.duration > 0 {
+			utilization = 100 * traffic.busyTime[direction] / traffic.duration
+		}
+		metrics = append(metrics, DashboardMetric{name: name, value: fmt.Sprintf("%.1f%%", utilization)})
+	}
+
+	// 異常の種類ごとの数
+	counts := map[string]int{}
+	alerts := map[string]bool{}
+	for _, a := range anomalies {
+		counts[a.Kind]++
+		if a.Severity == SeverityError {
+			alerts[a.Kind] = true
+		}
+	}
+	kinds := []string{}
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	if len(kinds) == 0 {
+		metrics = append(metrics, DashboardMetric{name: "anomalies", value: "none"})
+	}
+	for _, kind := range kinds {
+		metrics = append(metrics, DashboardMetric{name: kind, value: fmt.Sprintf("%d", counts[kind]), alert: alerts[kind]})
+	}
+	return metrics
+}
+
+// A-B間電圧差の波形を横幅に合わせて間引く
+func dashboardThumbnail(matrix mat.Matrix, pixels int) plotter.XYs {
+	rows, _ := matrix.Dims()
+	diff := mat.NewDense(rows, 2, nil)
+	diff.SetCol(0, mat.Col(nil, ColTime, matrix))
+	diff.SetCol(1, waveform.Differential(nil, matrix))
+	trace := TileTrace{diff, 1, "A-B", colornames.Darkgreen}
+	return decimateTrace(trace, matrix.At(0, ColTime), matrix.At(rows-1, ColTime), pixels)
+}
+
+// アイダイアグラムの折れ線
+// 各ビットの始まりの前後(-0.5UIから1.5UI)のA-B間電圧差を, ビットの始まりを0に揃えて重ねる
+// ビットが多い場合は等間隔に選んでmaxBits本にする
+func eyeTraces(matrix mat.Matrix, bits []UartBit, originTime float64, baudrate int, maxBits int) []plotter.XYs {
+	period := 1 / float64(baudrate)
+	active := []UartBit{}
+	for _, b := range bits {
+		if b.state != "IDLE" {
+			active = append(active, b)
+		}
+	}
+	step := max(1, len(active)/maxBits)
+	traces := []plotter.XYs{}
+	for i := 0; i < len(active); i += step {
+		if xys := eyeTrace(matrix, active[i].startTime+originTime, period); len(xys) >= 2 {
+			traces = append(traces, xys)
+		}
+	}
+	return traces
+}
+
+// 始まりがstartのビットの前後(-0.5UIから1.5UI)のA-B間電圧差
+// 横軸はビットの始まりを0とするUI
+func eyeTrace(matrix mat.Matrix, start float64, period float64) plotter.XYs {
+	rows, _ := matrix.Dims()
+	xys := plotter.XYs{}
+	for r := findRowAtTime(matrix, start-period/2); r < rows && matrix.At(r, ColTime) <= start+1.5*period; r++ {
+		xys = append(xys, plotter.XY{
+			X: (matrix.At(r, ColTime) - start) / period,
+			Y: matrix.At(r, ColWireA) - matrix.At(r, ColWireB),
+		})
+	}
+	return xys
+}
+
+// アイダイアグラムのプロット
+// 折れ線にしきい値の横線とマスク試験の多角形(eyeMaskがnilの場合は重ねない)を重ねる
+func newEyePlot(traces []plotter.XYs, option chart.Option, eyeMask *EyeMask) (*plot.Plot, error) {
+	eye := plot.New()
+	eye.Title.Text = "アイダイアグラム"
+	eye.X.Label.Text = "UI(復号したビットの始まりを0とする)"
+	eye.Y.Label.Text = "A-B(V)"
+	eye.BackgroundColor = colornames.Snow
+	for _, xys := range traces {
+		trace, err := plotter.NewLine(xys)
+		if err != nil {
+			slog.Error("NewLine", "err", err)
+			return nil, err
+		}
+		trace.Color = color.NRGBA{R: 0x00, G: 0x64, B: 0x00, A: 0x40}
+		eye.Add(trace)
+	}
+	chart.AddThresholdLines(eye, option.Threshold)
+	// マスク試験の多角形
+	if eyeMask != nil {
+		for _, polygon := range eyeMask.Polygons {
+			xys := make(plotter.XYs, len(polygon))
+			for i, p := range polygon {
+				xys[i] = plotter.XY{X: p[0], Y: p[1]}
+			}
+			mask, err := plotter.NewPolygon(xys)
+			if err != nil {
+				slog.Error("NewPolygon", "err", err)
+				return nil, err
+			}
+			mask.Color = color.NRGBA{R: 0xff, G: 0x00, B: 0x00, A: 0x40}
+			mask.LineStyle.Color = colornames.Red
+			eye.Add(mask)
+		}
+	}
+	eye.X.Min, eye.X.Max = -0.5, 1.5
+	return eye, nil
+}
+
+// 指標の表を描く
+func drawDashboardMetrics(c draw.Canvas, metrics []DashboardMetric) {
+	style := text.Style{
+		Color:   color.Black,
+		Font:    font.From(plot.DefaultFont, vg.Points(16)),
+		Handler: plot.DefaultTextHandler,
+	}
+	lineHeight := style.FontExtents().Height * 1.3
+	nameWidth := vg.Length(0)
+	for _, m := range metrics {
+		nameWidth = max(nameWidth, style.Width(m.name))
+	}
+	y := c.Max.Y - 2*lineHeight
+	for _, m := range metrics {
+		style.Color = colornames.Dimgray
+		c.FillText(style, vg.Point{X: c.Min.X + vg.Points(20), Y: y}, m.name)
+		style.Color = color.Black
+		if m.alert {
+			style.Color = colornames.Red
+		}
+		c.FillText(style, vg.Point{X: c.Min.X + vg.Points(40) + nameWidth, Y: y}, m.value)
+		y -= lineHeight
+	}
+}
+
+// ダッシュボードを保存する
+// 上段に波形の縮小図, 下段の左にアイダイアグラム, 右に指標を描く
+func saveDashboard(savefilepath string, option chart.Option, eyeMask *EyeMask, matrix mat.Matrix, bits []UartBit, originTime float64, baudrate int, metrics []DashboardMetric) error {
+	canvas := vgimg.New(vg.Points(DashboardWidth), vg.Points(DashboardHeight))
+	dc := draw.New(canvas)
+	dc.SetColor(colornames.Snow)
+	dc.Fill(dc.Rectangle.Path())
+	top := draw.Crop(dc, 0, 0, vg.Points(DashboardHeight*0.6), 0)
+	bottom := draw.Crop(dc, 0, 0, 0, -vg.Points(DashboardHeight*0.4))
+	eyeArea := draw.Crop(bottom, 0, -vg.Points(DashboardWidth/2), 0, 0)
+	metricsArea := draw.Crop(bottom, vg.Points(DashboardWidth/2), 0, 0, 0)
+
+	// 波形の縮小図
+	thumbnail := plot.New()
+	thumbnail.Title.Text = option.Title
+	thumbnail.X.Label.Text = option.XLabel
+	thumbnail.Y.Label.Text = "A-B(V)"
+	thumbnail.BackgroundColor = colornames.Snow
+	if option.XToTime != nil {
+		thumbnail.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+	line, err := plotter.NewLine(dashboardThumbnail(matrix, DashboardWidth))
+	if err != nil {
+		slog.Error("NewLine", "err", err)
+		return err
+	}
+	line.Color = colornames.Darkgreen
+	thumbnail.Add(line)
+	chart.AddThresholdLines(thumbnail, option.Threshold)
+	chart.ScaleTimeAxis(thumbnail, option)
+	thumbnail.Draw(top)
+
+	// アイダイアグラム
+	eye, err := newEyePlot(eyeTraces(matrix, bits, originTime, baudrate, DashboardEyeMaxBits), option, eyeMask)
+	if err != nil {
+		return err
+	}
+	eye.Draw(eyeArea)
+
+	// 指標
+	drawDashboardMetrics(metricsArea, metrics)
+
+	// プロットを画像ファイルに保存
+	return chart.SaveCanvas(savefilepath, canvas, option.PngTexts)
+}
@@ -0,0 +1,49 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号の厳しさ(適合試験向けのstrict, 乱れた測定値からの回収向けのpermissive)
+package main
+
+import (
+	"errors"
+)
+
+// 復号の厳しさ
+const (
+	DecodeNormal     = "normal"     // ストップビットが0の場合は次のビットが1ならストップビットとみなし, 0ならスタートビットとして続ける
+	DecodeStrict     = "strict"     // 最初のフレーミングエラー, パリティエラー, 誤り検出符号の誤りで打ち切る
+	DecodePermissive = "permissive" // ストップビットが0の場合は文字の中の立ち下がりから同期し直して続ける
+)
+
+// --strictと--permissiveから復号の厳しさを決める
+func newDecodeMode(strict bool, permissive bool) (string, error) {
+	switch {
+	case strict && permissive:
+		return "", errors.New("--strictと--permissiveは一緒に使えない")
+	case strict:
+		return DecodeStrict, nil
+	case permissive:
+		return DecodePermissive, nil
+	}
+	return DecodeNormal, nil
+}
+
+// 最初の復号の誤り(フレーミングエラー, パリティエラー, 誤り検出符号の誤り)
+// 誤りが無ければfalse
+func firstDecodeError(bits []UartBit, codes []UartCode, frames []UartFrame, crcKind string) (AnomalyEvent, bool) {
+	events := framingAnomalies(bits)
+	events = append(events, parityAnomalies(codes)...)
+	if crcKind != CrcNone {
+		events = append(events, crcAnomalies(frames, crcKind)...)
+	}
+	if len(events) == 0 {
+		return AnomalyEvent{}, false
+	}
+	first := events[0]
+	for _, e := range events[1:] {
+		if e.Time < first.Time {
+			first = e
+		}
+	}
+	return first, true
+}
@@ -0,0 +1,192 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号した結果の機械可読な出力(JSON, CSV, バイト列)
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 復号した結果の出力の形式
+const (
+	DecodeOutputNone = ""     // 出力しない
+	DecodeOutputJson = "json" // フレーム, 文字, ビットの入れ子のJSON
+	DecodeOutputCsv  = "csv"  // 1行に1文字のCSV
+	DecodeOutputBin  = "bin"  // 復号したバイトをそのまま続けたもの
+)
+
+// 標準出力に書く出力ファイルの名前
+const DecodeOutputStdout = "-"
+
+// 出力するビット
+type DecodedBit struct {
+	State string  `json:"state"` // 受信機の状態(START, Bit#n, PARITY, PE, STOP, X)
+	Start float64 `json:"start"` // 基準時間からの相対時間(s)
+	End   float64 `json:"end"`
+	Value int     `json:"value"`
+}
+
+// 出力する文字
+type DecodedByte struct {
+	Start        float64      `json:"start"` // 基準時間からの相対時間(s)
+	End          float64      `json:"end"`
+	Value        byte         `json:"value"`
+	Bits         []DecodedBit `json:"bits"`
+	ParityError  bool         `json:"parityError"`
+	FramingError bool         `json:"framingError"` // 文字の中にストップビットが0のビットがある
+}
+
+// 出力するフレーム
+type DecodedFrame struct {
+	Segment   int           `json:"segment,omitempty"` // 1始まりのセグメント番号, セグメントに分けない場合は0
+	Frame     int           `json:"frame"`             // 1始まりのフレーム番号
+	Start     float64       `json:"start"`             // 基準時間からの相対時間(s)
+	End       float64       `json:"end"`
+	Timestamp string        `json:"timestamp,omitempty"` // 絶対時刻, --t0を指定しない場合は空
+	Direction string        `json:"direction,omitempty"` // 全二重の場合の通信方向, 半二重では空
+	Bytes     []DecodedByte `json:"bytes"`
+	CrcError  bool          `json:"crcError"` // --crcを指定しない場合は常にfalse
+}
+
+// 出力するJSON
+type DecodeOutput struct {
+	Provenance *Provenance    `json:"provenance,omitempty"`
+	Baudrate   int            `json:"baudRate"`
+	Format     string         `json:"format"`
+	Frames     []DecodedFrame `json:"frames"`
+}
+
+// 出力の形式を確かめる
+func checkDecodeOutput(kind string) error {
+	switch kind {
+	case DecodeOutputNone, DecodeOutputJson, DecodeOutputCsv, DecodeOutputBin:
+		return nil
+	}
+	return fmt.Errorf("出力の形式 \"%s\" には対応していない(%s, %s, %s)", kind, DecodeOutputJson, DecodeOutputCsv, DecodeOutputBin)
+}
+
+// 文字の期間のビット
+// bitsは時間順
+func bitsOfCode(bits []UartBit, code UartCode) []UartBit {
+	from := sort.Search(len(bits), func(i int) bool { return bits[i].startTime >= code.startTime })
+	to := from
+	for to < len(bits) && bits[to].endTime <= code.endTime {
+		to++
+	}
+	return bits[from:to]
+}
+
+// 出力するフレームを作る
+// txBitsとrxBitsは通信方向毎(半二重はtxBitsだけ)の復号したビット
+// segmentがnilでなければフレームにセグメント番号を付ける
+func decodedFrames(frames []UartFrame, txBits []UartBit, rxBits []UartBit, clock Clock, crcKind string, segment *InputSegment) []DecodedFrame {
+	decoded := make([]DecodedFrame, len(frames))
+	for i, f := range frames {
+		bits := txBits
+		if f.direction == DirectionRx {
+			bits = rxBits
+		}
+		d := DecodedFrame{Frame: i + 1, Start: f.startTime, End: f.endTime, Direction: f.direction, Bytes: make([]DecodedByte, len(f.codes))}
+		if segment != nil {
+			d.Segment = segment.index
+		}
+		if clock.absolute {
+			d.Timestamp = clock.format(f.startTime)
+		}
+		for k, c := range f.codes {
+			b := DecodedByte{Start: c.startTime, End: c.endTime, Value: c.octet, Bits: []DecodedBit{}, ParityError: c.parityError, FramingError: c.framingError}
+			for _, bit := range bitsOfCode(bits, c) {
+				b.Bits = append(b.Bits, DecodedBit{State: bit.state, Start: bit.startTime, End: bit.endTime, Value: bit.bit})
+				if bit.state == "X" {
+					b.FramingError = true
+				}
+			}
+			d.Bytes[k] = b
+		}
+		if ok, checked := checkFrameCrc(f, crcKind); checked && !ok {
+			d.CrcError = true
+		}
+		decoded[i] = d
+	}
+	return decoded
+}
+
+// 復号した結果をkindの形式でwに書く
+func writeDecodeOutput(w io.Writer, kind string, output DecodeOutput) error {
+	switch kind {
+	case DecodeOutputJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	case DecodeOutputCsv:
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"segment", "frame", "byte", "start", "end", "timestamp", "direction", "value", "bit_starts", "parity_error", "framing_error", "crc_error"})
+		for _, f := range output.Frames {
+			for k, b := range f.Bytes {
+				starts := make([]string, len(b.Bits))
+				for n, bit := range b.Bits {
+					starts[n] = strconv.FormatFloat(bit.Start, 'g', -1, 64)
+				}
+				writer.Write([]string{
+					strconv.Itoa(f.Segment),
+					strconv.Itoa(f.Frame),
+					strconv.Itoa(k),
+					strconv.FormatFloat(b.Start, 'g', -1, 64),
+					strconv.FormatFloat(b.End, 'g', -1, 64),
+					f.Timestamp,
+					f.Direction,
+					fmt.Sprintf("0x%02x", b.Value),
+					strings.Join(starts, " "),
+					strconv.FormatBool(b.ParityError),
+					strconv.FormatBool(b.FramingError),
+					strconv.FormatBool(f.CrcError),
+				})
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case DecodeOutputBin:
+		for _, f := range output.Frames {
+			octets := make([]byte, len(f.Bytes))
+			for k, b := range f.Bytes {
+				octets[k] = b.Value
+			}
+			if _, err := w.Write(octets); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return checkDecodeOutput(kind)
+}
+
+// 復号した結果をファイル(DecodeOutputStdoutの場合は標準出力)に保存する
+func saveDecodeOutput(savefilepath string, kind string, output DecodeOutput) error {
+	if savefilepath == DecodeOutputStdout {
+		if err := writeDecodeOutput(os.Stdout, kind, output); err != nil {
+			slog.Error("writeDecodeOutput", "err", err)
+			return err
+		}
+		return nil
+	}
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+	if err := writeDecodeOutput(f, kind, output); err != nil {
+		slog.Error("writeDecodeOutput", "err", err)
+		return err
+	}
+	return nil
+}
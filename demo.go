@@ -0,0 +1,40 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 組み込みの測定例を解析して見せる
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 見本にする測定例(RS485半二重, 9600bps)
+const DemoCapture = "halfduplex.csv"
+
+// 見本の測定例を保存するファイル名
+const DemoFile = "pulseinsight_demo.csv"
+
+// 見本の測定例をディレクトリに書き出して解析する
+// グラフ等の出力も同じディレクトリに置く
+func runDemo(ctx context.Context, w io.Writer, dirpath string, option InsightOption) error {
+	data, err := selftestFiles.ReadFile(path.Join(SelftestDir, DemoCapture))
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return err
+	}
+	if err := os.MkdirAll(dirpath, 0o755); err != nil {
+		slog.Error("MkdirAll", "err", err)
+		return err
+	}
+	csvfilepath := filepath.Join(dirpath, DemoFile)
+	if err := os.WriteFile(csvfilepath, data, 0o644); err != nil {
+		slog.Error("WriteFile", "err", err)
+		return err
+	}
+	return insightTheCsvFile(ctx, w, csvfilepath, option)
+}
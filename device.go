@@ -0,0 +1,129 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 半二重の従局(アドレス)ごとの統計
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// 従局ごとの統計
+type DeviceStats struct {
+	address    string  // 従局のアドレス
+	requests   int     // 主局からの要求のフレーム数
+	responses  int     // 従局からの応答のフレーム数
+	noResponse int     // 応答の無かった要求の数
+	awaiting   int     // 取り込みの終わりで応答を待っていた要求の数(応答が無かったとは数えない)
+	crcErrors  int     // 誤り検出符号が合わないフレームの数(要求と応答の両方)
+	badAnswers int     // 要求に続いた応答のうち誤り検出符号が合わない応答の数
+	bytes      int     // 要求と応答のバイト数
+	answered   int     // 応答時間を測れた応答の数
+	minLatency float64 // 要求の終わりから応答の始まりまでの最短時間(s)
+	maxLatency float64 // 最長時間(s)
+	sumLatency float64 // 合計時間(s)
+}
+
+// 応答時間の平均(s)
+func (d DeviceStats) meanLatency() float64 {
+	if d.answered == 0 {
+		return math.NaN()
+	}
+	return d.sumLatency / float64(d.answered)
+}
+
+// 誤り率
+// 来るはずだった応答(取り込みの終わりで待っていた要求を除く要求の数)のうち, 無かった応答と誤り検出符号が合わない応答の割合とする
+// 来るはずだった応答が無い場合はNaN
+func (d DeviceStats) errorRate() float64 {
+	expected := d.requests - d.awaiting
+	if expected == 0 {
+		return math.NaN()
+	}
+	return float64(d.noResponse+d.badAnswers) / float64(expected)
+}
+
+// 従局ごとにフレーム数, 誤り, 応答時間を集計する
+// 送信元と宛先はシーケンス図と同じく主局の要求に同じアドレスのフレームが続いたら応答とみなす
+// 別のアドレスのフレームは次の要求になるので, 待っていた要求には応答が無かったと数える
+func summarizeDevices(messages []SequenceMessage, crcKind string) []DeviceStats {
+	// 先に全ての従局を並べておく
+	index := map[string]int{}
+	devices := []DeviceStats{}
+	for _, m := range messages {
+		address := m.to
+		if m.to == SequenceMaster {
+			address = m.from
+		}
+		if _, ok := index[address]; !ok {
+			index[address] = len(devices)
+			devices = append(devices, DeviceStats{address: address, minLatency: math.Inf(1), maxLatency: math.Inf(-1)})
+		}
+	}
+	device := func(address string) *DeviceStats { return &devices[index[address]] }
+
+	var pending *SequenceMessage // 応答を待っている要求
+	for i := range messages {
+		m := &messages[i]
+		address := m.to
+		if m.to == SequenceMaster {
+			address = m.from
+		}
+		d := device(address)
+		d.bytes += len(m.frame.codes)
+		crcError := false
+		if ok, checked := checkFrameCrc(m.frame, crcKind); checked && !ok {
+			d.crcErrors++
+			crcError = true
+		}
+		if m.from == SequenceMaster {
+			if pending != nil {
+				device(pending.to).noResponse++
+			}
+			d.requests++
+			pending = m
+			continue
+		}
+		d.responses++
+		if pending != nil {
+			if crcError {
+				d.badAnswers++
+			}
+			latency := m.frame.startTime - pending.frame.endTime
+			d.minLatency = math.Min(d.minLatency, latency)
+			d.maxLatency = math.Max(d.maxLatency, latency)
+			d.sumLatency += latency
+			d.answered++
+		}
+		pending = nil
+	}
+	// 取り込みの終わりで待っていた要求は応答が来る前に取り込みが終わったのかもしれない
+	if pending != nil {
+		device(pending.to).awaiting++
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].address < devices[j].address })
+	return devices
+}
+
+// 従局ごとの統計を並べて表示する
+func printDeviceStats(w io.Writer, devices []DeviceStats) {
+	fmt.Fprintf(w, "devices: %d\n", len(devices))
+	fmt.Fprintf(w, "  %-8s %8s %9s %11s %10s %8s %10s %28s\n",
+		"address", "requests", "responses", "no response", "crc errors", "bytes", "error rate", "latency min/mean/max (ms)")
+	for _, d := range devices {
+		rate := "-"
+		if errorRate := d.errorRate(); !math.IsNaN(errorRate) {
+			rate = fmt.Sprintf("%.2f%%", 100*errorRate)
+		}
+		latency := "-"
+		if d.answered != 0 {
+			latency = fmt.Sprintf("%.3f/%.3f/%.3f", d.minLatency*1e3, d.meanLatency()*1e3, d.maxLatency*1e3)
+		}
+		fmt.Fprintf(w, "  %-8s %8d %9d %11d %10d %8d %10s %28s\n",
+			d.address, d.requests, d.responses, d.noResponse, d.crcErrors, d.bytes, rate, latency)
+	}
+}
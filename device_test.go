@@ -0,0 +1,59 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 先頭のバイトがアドレスのフレーム
+func testDeviceFrame(start float64, octets ...byte) UartFrame {
+	codes := make([]UartCode, len(octets))
+	for i, o := range octets {
+		codes[i] = UartCode{startTime: start + float64(i)*1e-3, endTime: start + float64(i+1)*1e-3, octet: o}
+	}
+	return UartFrame{startTime: start, endTime: start + float64(len(octets))*1e-3, codes: codes}
+}
+
+// 応答の無かった要求, 応答までの時間, 取り込みの終わりで待っていた要求
+func TestSummarizeDevices(t *testing.T) {
+	frames := []UartFrame{
+		testDeviceFrame(0.00, 0x01, 0x03), // 0x01への要求, 応答が無い
+		testDeviceFrame(0.10, 0x02, 0x03), // 0x02への要求
+		testDeviceFrame(0.11, 0x02, 0x03), // 0x02の応答
+		testDeviceFrame(0.20, 0x01, 0x03), // 0x01への要求, 取り込みの終わり
+	}
+	messages, err := sequenceMessages(frames, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := summarizeDevices(messages, CrcNone)
+	if len(devices) != 2 || devices[0].address != "0x01" || devices[1].address != "0x02" {
+		t.Fatalf("devices %+v", devices)
+	}
+
+	d1 := devices[0]
+	if d1.requests != 2 || d1.responses != 0 || d1.noResponse != 1 || d1.awaiting != 1 {
+		t.Errorf("0x01: requests %d responses %d no response %d awaiting %d", d1.requests, d1.responses, d1.noResponse, d1.awaiting)
+	}
+	// 取り込みの終わりで待っていた要求は誤り率に入れない
+	if rate := d1.errorRate(); rate != 1 {
+		t.Errorf("0x01: error rate %g, want 1", rate)
+	}
+
+	d2 := devices[1]
+	if d2.requests != 1 || d2.responses != 1 || d2.noResponse != 0 || d2.awaiting != 0 || d2.errorRate() != 0 {
+		t.Errorf("0x02: %+v", d2)
+	}
+	if d2.answered != 1 || math.Abs(d2.meanLatency()-0.008) > 1e-9 {
+		t.Errorf("0x02: %d answered, mean latency %g", d2.answered, d2.meanLatency())
+	}
+
+	// 取り込みの終わりの要求だけでは誤り率を決めない
+	only := summarizeDevices(messages[3:], CrcNone)
+	if only[0].noResponse != 0 || only[0].awaiting != 1 || !math.IsNaN(only[0].errorRate()) {
+		t.Errorf("awaiting only: %+v error rate %g", only[0], only[0].errorRate())
+	}
+}
@@ -0,0 +1,130 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 実行環境と設定の診断
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"gonum.org/v1/plot/font"
+)
+
+// グラフに使う文字
+const DoctorSampleText = "A,B線電圧の時間変化(s)"
+
+// 診断結果を1行書く
+func printDoctor(w io.Writer, ok bool, item string, detail string) {
+	result := "ok  "
+	if !ok {
+		result = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %s: %s\n", result, item, detail)
+}
+
+// 埋め込みフォントにグラフの文字があるか調べる
+func checkFont() error {
+	if !font.DefaultCache.Has(fontIpaexGothic) {
+		return fmt.Errorf("フォント %s が読み込まれていない", fontIpaexGothic.Typeface)
+	}
+	ttf, err := opentype.Parse(fontDataIpaexGothic)
+	if err != nil {
+		return err
+	}
+	var buf sfnt.Buffer
+	for _, r := range DoctorSampleText {
+		if index, err := ttf.GlyphIndex(&buf, r); err != nil {
+			return err
+		} else if index == 0 {
+			return fmt.Errorf("フォント %s に文字 '%c' が無い", fontIpaexGothic.Typeface, r)
+		}
+	}
+	return nil
+}
+
+// ディレクトリにファイルを作れるか調べる
+func checkWritable(dirpath string) error {
+	file, err := os.CreateTemp(dirpath, ".pulseinsight-doctor")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// 入力CSVファイルを読めるか調べる
+func checkInput(ctx context.Context, csvfilepath string, option InsightOption) (string, error) {
+	matrix, _, err := loadCsv(ctx, csvfilepath, option, nil)
+	if err != nil {
+		return "", err
+	}
+	rows, cols := matrix.Dims()
+	interval := sampleInterval(matrix)
+	if interval <= 0 {
+		return "", fmt.Errorf("時間列が増加していない")
+	}
+	samplesPerBit := 1 / float64(option.baudrate) / interval
+	return fmt.Sprintf("%d rows  %d columns  interval %.3fus  %.1f samples/bit", rows, cols, interval*1e6, samplesPerBit), nil
+}
+
+// 有効な設定を書く
+// 指定しなかった設定には(default)を付ける
+func printEffectiveConfig(w io.Writer, c *cli.Context) {
+	parameters := effectiveParameters(c)
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "configuration:")
+	for _, name := range names {
+		source := ""
+		if !c.IsSet(name) {
+			source = "  (default)"
+		}
+		fmt.Fprintf(w, "  --%s=%s%s\n", name, parameters[name], source)
+	}
+}
+
+// 実行環境と設定を診断する
+// CSVファイルを指定した場合は読めるか、出力先(CSVファイルと同じディレクトリ)に書けるかも調べる
+func runDoctor(w io.Writer, c *cli.Context, csvfilepath string, option InsightOption) error {
+	failed := 0
+	check := func(item string, detail string, err error) {
+		if err != nil {
+			failed++
+			detail = err.Error()
+		}
+		printDoctor(w, err == nil, item, detail)
+	}
+
+	printDoctor(w, true, "version", fmt.Sprintf("%s %s %s/%s", c.App.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH))
+	check("font", string(fontIpaexGothic.Typeface), checkFont())
+
+	outputDir := "."
+	if csvfilepath != "" {
+		outputDir = filepath.Dir(csvfilepath)
+	}
+	check("output directory", outputDir, checkWritable(outputDir))
+
+	if csvfilepath != "" {
+		detail, err := checkInput(c.Context, csvfilepath, option)
+		check("input file", csvfilepath+"  "+detail, err)
+	}
+
+	printEffectiveConfig(w, c)
+
+	if failed != 0 {
+		return fmt.Errorf("診断で%d件の問題が見つかった", failed)
+	}
+	return nil
+}
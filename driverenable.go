@@ -0,0 +1,100 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ドライバイネーブル(DE/RE)信号とフレームの対応を調べる
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 入力CSVがドライバイネーブル列付きの半二重(時間, A線, B線, DE)か
+func hasDriverEnable(matrix mat.Matrix) bool {
+	_, cols := matrix.Dims()
+	return cols == ColDriverEnable+1
+}
+
+// ドライバイネーブルが有効だった期間
+type EnableInterval struct {
+	onTime  float64
+	offTime float64
+}
+
+// DE列がしきい値を超えている期間を取り出す
+// 時間はoriginTimeを引いてフレームの時間と合わせる
+func findEnableIntervals(matrix mat.Matrix, originTime float64, deThreshold float64) []EnableInterval {
+	rows, _ := matrix.Dims()
+	intervals := []EnableInterval{}
+	enabled := false
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime) - originTime
+		on := matrix.At(r, ColDriverEnable) > deThreshold
+		if on && !enabled {
+			intervals = append(intervals, EnableInterval{onTime: t, offTime: t})
+		}
+		if on {
+			intervals[len(intervals)-1].offTime = t
+		}
+		enabled = on
+	}
+	return intervals
+}
+
+// フレームごとのドライバイネーブルの検査結果
+type DriverEnableCheck struct {
+	frame     UartFrame
+	enabled   bool    // フレーム開始時にドライバが有効だったか
+	lead      float64 // ドライバ有効からスタートビットまでの時間(s)
+	release   float64 // ストップビット終了からドライバ無効までの時間(s), 負はストップビット前に無効
+	violation string  // 違反内容, 違反なしは空
+}
+
+// フレームごとにドライバイネーブルのタイミングを検査する
+func checkDriverEnable(intervals []EnableInterval, frames []UartFrame, minLead float64, maxRelease float64) []DriverEnableCheck {
+	checks := []DriverEnableCheck{}
+	for _, f := range frames {
+		check := DriverEnableCheck{frame: f}
+		for _, iv := range intervals {
+			if iv.onTime <= f.startTime && f.startTime <= iv.offTime {
+				check.enabled = true
+				check.lead = f.startTime - iv.onTime
+				check.release = iv.offTime - f.endTime
+				break
+			}
+		}
+		switch {
+		case !check.enabled:
+			check.violation = "ドライバ無効のまま送信した"
+		case check.lead < minLead:
+			check.violation = "ドライバ有効がスタートビットに間に合っていない"
+		case check.release < 0:
+			check.violation = "ストップビット終了前にドライバを無効にした"
+		case check.release > maxRelease:
+			check.violation = "ドライバ開放が遅い"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// ドライバイネーブルの検査結果を表示する
+// labelは検査に使った期間の出所(DE列の場合は空)
+func printDriverEnable(w io.Writer, clock Clock, label string, checks []DriverEnableCheck) {
+	violations := 0
+	fmt.Fprintf(w, "driver enable%s:\n", label)
+	for i, c := range checks {
+		fmt.Fprintf(w, "  #%d start %s", i+1, clock.format(c.frame.startTime))
+		if c.enabled {
+			fmt.Fprintf(w, "  lead %.3fms  release %.3fms", c.lead*1e3, c.release*1e3)
+		}
+		if c.violation != "" {
+			violations++
+			fmt.Fprintf(w, "  VIOLATION: %s", c.violation)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "driver enable violations%s: %d\n", label, violations)
+}
@@ -0,0 +1,78 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号したフレームをソースコードの配列として表示する(ファームウェアや試験コードに貼り付ける)
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ソースコードの言語
+const (
+	DumpCodeNone = ""
+	DumpCodeC    = "c"
+	DumpCodeGo   = "go"
+)
+
+// 1行に並べるバイト数
+const DumpCodeBytesPerLine = 12
+
+// 配列の名前
+// Cはframe_001, Goはframe001のように番号(1始まり)を付け, 全二重では末尾に通信方向を付ける
+func dumpCodeName(index int, f UartFrame, language string) string {
+	direction := strings.ToLower(f.direction)
+	if language == DumpCodeGo {
+		if direction != "" {
+			direction = strings.ToUpper(direction[:1]) + direction[1:]
+		}
+		return fmt.Sprintf("frame%03d%s", index+1, direction)
+	}
+	if direction != "" {
+		direction = "_" + direction
+	}
+	return fmt.Sprintf("frame_%03d%s", index+1, direction)
+}
+
+// フレームのバイトを1行ずつに分けて0x00,の形で並べる
+func dumpCodeLines(f UartFrame) []string {
+	lines := []string{}
+	for i := 0; i < len(f.codes); i += DumpCodeBytesPerLine {
+		octets := []string{}
+		for _, c := range f.codes[i:min(i+DumpCodeBytesPerLine, len(f.codes))] {
+			octets = append(octets, fmt.Sprintf("0x%02x,", c.octet))
+		}
+		lines = append(lines, strings.Join(octets, " "))
+	}
+	return lines
+}
+
+// フレームをソースコードの配列として表示する
+func printDumpCode(w io.Writer, clock Clock, frames []UartFrame, language string) {
+	switch language {
+	case DumpCodeC:
+		fmt.Fprintln(w, "#include <stdint.h>")
+		fmt.Fprintln(w)
+		for i, f := range frames {
+			fmt.Fprintf(w, "/* %s  %d bytes */\n", clock.format(f.startTime), len(f.codes))
+			fmt.Fprintf(w, "static const uint8_t %s[%d] = {\n", dumpCodeName(i, f, language), len(f.codes))
+			for _, line := range dumpCodeLines(f) {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+			fmt.Fprintln(w, "};")
+		}
+	case DumpCodeGo:
+		fmt.Fprintln(w, "var (")
+		for i, f := range frames {
+			fmt.Fprintf(w, "\t// %s  %d bytes\n", clock.format(f.startTime), len(f.codes))
+			fmt.Fprintf(w, "\t%s = []byte{\n", dumpCodeName(i, f, language))
+			for _, line := range dumpCodeLines(f) {
+				fmt.Fprintf(w, "\t\t%s\n", line)
+			}
+			fmt.Fprintln(w, "\t}")
+		}
+		fmt.Fprintln(w, ")")
+	}
+}
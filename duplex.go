@@ -0,0 +1,75 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// RS422全二重(送信対と受信対の2対)の解析
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 全二重の通信方向
+const (
+	DirectionTx = "TX" // 送信対(入力CSVの列2,3番目)
+	DirectionRx = "RX" // 受信対(入力CSVの列4,5番目)
+)
+
+// 入力CSVが全二重(時間, TX対A,B線, RX対A,B線)か
+func isDuplex(matrix mat.Matrix) bool {
+	_, cols := matrix.Dims()
+	return cols > ColRxWireB
+}
+
+// 全二重の行列を送信対(時間,A,B)と受信対(時間,A,B)の行列に分ける
+func splitDuplex(matrix mat.Matrix) (*mat.Dense, *mat.Dense) {
+	rows, _ := matrix.Dims()
+	tx := mat.NewDense(rows, 3, nil)
+	rx := mat.NewDense(rows, 3, nil)
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime)
+		tx.Set(r, ColTime, t)
+		tx.Set(r, ColWireA, matrix.At(r, ColWireA))
+		tx.Set(r, ColWireB, matrix.At(r, ColWireB))
+		rx.Set(r, ColTime, t)
+		rx.Set(r, ColWireA, matrix.At(r, ColRxWireA))
+		rx.Set(r, ColWireB, matrix.At(r, ColRxWireB))
+	}
+	return tx, rx
+}
+
+// 送信対と受信対のコードに通信方向を付けて時間順に並べる
+func mergeUartCodes(tx []UartCode, rx []UartCode) []UartCode {
+	codes := make([]UartCode, 0, len(tx)+len(rx))
+	for _, v := range tx {
+		v.direction = DirectionTx
+		codes = append(codes, v)
+	}
+	for _, v := range rx {
+		v.direction = DirectionRx
+		codes = append(codes, v)
+	}
+	sort.SliceStable(codes, func(i, j int) bool {
+		return codes[i].startTime < codes[j].startTime
+	})
+	return codes
+}
+
+// 通信方向が変わるごとに区切って16進ダンプを表示する
+func dumpDuplexCodes(w io.Writer, clock Clock, codes []UartCode) {
+	for i := 0; i < len(codes); {
+		// 同じ通信方向が続く範囲
+		j := i
+		bytes := []byte{}
+		for ; j < len(codes) && codes[j].direction == codes[i].direction; j++ {
+			bytes = append(bytes, codes[j].octet)
+		}
+		fmt.Fprintf(w, "%s %s\n", codes[i].direction, clock.format(codes[i].startTime))
+		fmt.Fprint(w, hex.Dump(bytes))
+		i = j
+	}
+}
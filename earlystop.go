@@ -0,0 +1,94 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 指定した数のフレームか式に合うフレームを復号した所で解析を打ち切る(大きな測定値で通信路を手早く確かめる)
+package main
+
+import (
+	"log/slog"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 打ち切ったフレームの後ろに残す時間(文字数)
+const EarlyStopMarginChars = 1
+
+// 打ち切る位置
+type EarlyStop struct {
+	frame    int     // 打ち切ったフレームの番号(1始まり)
+	time     float64 // フレームの開始時間(基準時間からの相対時間)
+	cutTime  float64 // これより後の行を捨てる入力CSVの時間
+	matched  bool    // 式に合うフレームで打ち切った
+	original int     // 打ち切る前の行数
+}
+
+// 打ち切る位置を探す
+// 測定値を一通り復号してフレームに分け, maxFrames番目のフレームか式stopAfterに合う最初のフレームの終わりを返す
+// どちらにも当たらない場合はfalse
+func findEarlyStop(matrix *mat.Dense, rxMatrix *mat.Dense, option InsightOption) (EarlyStop, bool, error) {
+	var stopExpr FrameExpr
+	if option.stopAfter != "" {
+		expr, err := parseWhere(option.stopAfter)
+		if err != nil {
+			slog.Error("parseWhere", "err", err)
+			return EarlyStop{}, false, err
+		}
+		stopExpr = expr
+	}
+	if option.filter == FilterSma && option.smoothWindow == 0 {
+		option.smoothWindow = autoSmoothingWindow(matrix, option.baudrate)
+	}
+
+	filtered, err := applyFilter(matrix, option)
+	if err != nil {
+		slog.Error("applyFilter", "err", err)
+		return EarlyStop{}, false, err
+	}
+	var rxFiltered mat.Matrix
+	if rxMatrix != nil {
+		if rxFiltered, err = applyFilter(rxMatrix, option); err != nil {
+			slog.Error("applyFilter", "err", err)
+			return EarlyStop{}, false, err
+		}
+	}
+	reshaped, rxReshaped, originTime, err := decodeWaveforms(matrix, rxMatrix, filtered, rxFiltered, option)
+	if err != nil {
+		slog.Error("decodeWaveforms", "err", err)
+		return EarlyStop{}, false, err
+	}
+	_, codes, err := analyzePulses(reshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return EarlyStop{}, false, err
+	}
+	if rxReshaped != nil {
+		_, rxCodes, err := analyzePulses(rxReshaped, option.format, option.decodeMode)
+		if err != nil {
+			slog.Error("analyzePulses", "err", err)
+			return EarlyStop{}, false, err
+		}
+		codes = mergeUartCodes(codes, rxCodes)
+	}
+
+	rows, _ := matrix.Dims()
+	for i, f := range groupFrames(codes, option.format.charTime(option.baudrate), option.frameGap) {
+		matched := stopExpr != nil && stopExpr(f, option.addressByte) != 0
+		if matched || (option.maxFrames > 0 && i+1 >= option.maxFrames) {
+			return EarlyStop{
+				frame:    i + 1,
+				time:     f.startTime,
+				cutTime:  originTime + f.endTime + EarlyStopMarginChars*option.format.charTime(option.baudrate),
+				matched:  matched,
+				original: rows,
+			}, true, nil
+		}
+	}
+	return EarlyStop{}, false, nil
+}
+
+// 打ち切る時間より後の行を捨てる
+func truncateMatrix(matrix *mat.Dense, cutTime float64) *mat.Dense {
+	rows, cols := matrix.Dims()
+	r := min(rows, max(2, findRowAtTime(matrix, cutTime)))
+	return matrix.Slice(0, r, 0, cols).(*mat.Dense)
+}
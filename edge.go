@@ -0,0 +1,90 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 微分とゼロ交差によるエッジ検出
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// エッジ検出の方式
+const (
+	EdgeLevel      = "level"      // A,B間電圧差としきい値の比較
+	EdgeDerivative = "derivative" // A,B間電圧差の微分とゼロ交差
+)
+
+// A,B間電圧差の微分からエッジを検出して、しきい値で判定できる理想的な波形(時間,A,B)にする
+// 基線が揺れていてもエッジの前後の電圧差は保たれるので、電圧の絶対値ではなく変化でMarkとSpaceを判定する
+// 微分は前後1/4ビットの平均電圧の差で求めてノイズを抑え、
+// それが最も大きなエッジの半分を超えた区間で微分の変化がゼロに交差する(微分が最大になる)行をエッジとする
+func derivativeEdgeWaveform(original mat.Matrix, baudrate int) mat.Matrix {
+	rows, _ := original.Dims()
+	// A,B間電圧差の累積和
+	sum := make([]float64, rows+1)
+	floats.CumSum(sum[1:], waveform.Differential(nil, original))
+
+	// 微分の前後で平均を取るサンプル数
+	window := 1
+	if interval := sampleInterval(original); interval > 0 {
+		window = max(1, int(1/float64(baudrate)/interval/4))
+	}
+	mean := func(begin, end int) float64 {
+		begin, end = max(0, begin), min(rows, end)
+		if begin >= end {
+			return 0
+		}
+		return (sum[end] - sum[begin]) / float64(end-begin)
+	}
+	derivative := make([]float64, rows)
+	for r := 1; r < rows; r++ {
+		derivative[r] = mean(r, r+window) - mean(r-window, r)
+	}
+
+	// ノイズで切り替わらないように、しきい値は最も大きなエッジの半分以上にする
+	threshold := Threshould
+	for _, v := range derivative {
+		threshold = max(threshold, math.Abs(v)/2)
+	}
+
+	// 1:Mark, -1:Space, UARTのアイドルはMark
+	level := make([]float64, rows)
+	state := 1.0
+	for r := 0; r < rows; {
+		if math.Abs(derivative[r]) <= threshold {
+			level[r] = state
+			r++
+			continue
+		}
+		// 微分がしきい値を超えて同じ向きに続く区間
+		direction := math.Copysign(1, derivative[r])
+		end, peak := r, r
+		for end < rows && derivative[end]*direction > threshold {
+			if math.Abs(derivative[end]) > math.Abs(derivative[peak]) {
+				peak = end
+			}
+			end++
+		}
+		// 同じ向きへのエッジは無視する
+		for k := r; k < end; k++ {
+			if k == peak {
+				state = direction
+			}
+			level[k] = state
+		}
+		r = end
+	}
+
+	matrix := mat.NewDense(rows, 3, nil)
+	for r := 0; r < rows; r++ {
+		matrix.Set(r, ColTime, original.At(r, ColTime))
+		matrix.Set(r, ColWireA, level[r]*Threshould)
+		matrix.Set(r, ColWireB, -level[r]*Threshould)
+	}
+	return matrix
+}
@@ -0,0 +1,104 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 外部イベントログ(PLCやアプリケーションのログ)との対応付け
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 外部イベント
+type ExternalEvent = chart.Event
+
+// 外部イベントログを読み込む
+// 拡張子が.jsonの場合は[{"time":0.012,"label":"..."}]の配列
+// それ以外は各行に時間(s),内容が書かれたCSVで、時間が数値でない行(ヘッダー行)は読み飛ばす
+func loadEvents(filePath string) ([]ExternalEvent, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		slog.Error("Open", "err", err)
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []ExternalEvent{}
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		if err := json.NewDecoder(f).Decode(&events); err != nil {
+			slog.Error("Decode", "err", err)
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			slog.Error("ReadAll", "err", err)
+			return nil, err
+		}
+		for r, record := range records {
+			if len(record) < 2 {
+				continue
+			}
+			t, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+			if err != nil {
+				// 1行目はヘッダー行とみなして黙って読み飛ばす
+				if r != 0 {
+					slog.Warn("skip event", "row", r+1)
+				}
+				continue
+			}
+			events = append(events, ExternalEvent{Time: t, Label: strings.Join(record[1:], ",")})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+	return events, nil
+}
+
+// 各々のイベントの時間をoriginTimeとの相対時間にする
+func shiftEvents(events []ExternalEvent, originTime float64) []ExternalEvent {
+	shifted := make([]ExternalEvent, len(events))
+	for i, e := range events {
+		shifted[i] = ExternalEvent{Time: e.Time - originTime, Label: e.Label}
+	}
+	return shifted
+}
+
+// フレームと外部イベントを時間順に表示する
+func printTimeline(w io.Writer, clock Clock, frames []UartFrame, events []ExternalEvent) {
+	fmt.Fprintln(w, "timeline:")
+	i, j := 0, 0
+	for i < len(frames) || j < len(events) {
+		if j >= len(events) || (i < len(frames) && frames[i].startTime <= events[j].Time) {
+			f := frames[i]
+			bytes := make([]byte, len(f.codes))
+			for k, c := range f.codes {
+				bytes[k] = c.octet
+			}
+			direction := f.direction
+			if direction == "" {
+				direction = "--"
+			}
+			fmt.Fprintf(w, "  %s  %s  frame #%d  %s\n", clock.format(f.startTime), direction, i+1, hex.EncodeToString(bytes))
+			i++
+		} else {
+			fmt.Fprintf(w, "  %s  **  event  %s\n", clock.format(events[j].Time), events[j].Label)
+			j++
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 途中の行列(フィルタ後, 整形後)をCSVファイルに書き出す
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 行列をCSVファイルに保存する
+// 入力CSVと同じ2行のヘッダー(列番号, 単位)を付けて、他のツールでも読めるようにする
+func saveMatrixCsv(savefilepath string, matrix mat.Matrix) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	rows, cols := matrix.Dims()
+	names := []string{"x-axis"}
+	units := []string{"second"}
+	for c := 1; c < cols; c++ {
+		names = append(names, fmt.Sprint(c))
+		units = append(units, "Volt")
+	}
+	writer := csv.NewWriter(f)
+	writer.Write(names)
+	writer.Write(units)
+	record := make([]string, cols)
+	for r := 0; r < rows; r++ {
+		for c := range record {
+			record[c] = strconv.FormatFloat(matrix.At(r, c), 'g', -1, 64)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 行列(全二重の場合はTX対とRX対)をCSVファイルに書き出す
+// ファイル名は[基本名]_[段階].csv, RX対は[基本名]_rx_[段階].csv
+func exportMatrices(w io.Writer, basename string, stage string, matrix mat.Matrix, rxMatrix mat.Matrix) error {
+	files := []string{basename + "_" + stage + ".csv"}
+	matrices := []mat.Matrix{matrix}
+	if rxMatrix != nil {
+		files = append(files, basename+"_rx_"+stage+".csv")
+		matrices = append(matrices, rxMatrix)
+	}
+	for i, m := range matrices {
+		if err := saveMatrixCsv(files[i], m); err != nil {
+			slog.Error("saveMatrixCsv", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "export \"%s\"\n", files[i])
+	}
+	return nil
+}
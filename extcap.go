@@ -0,0 +1,127 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// Wiresharkのextcapインターフェースとpcap形式の出力
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// extcapのインターフェース名
+const ExtcapInterface = "pulseinsight"
+
+// pcapのリンク層ヘッダー種別(DLT_USER0)
+// Wiresharkの設定でDLT_USER0にmbrtu等のプロトコルを割り当てて解析する
+const LinkTypeUser0 = 147
+
+// Wiresharkから渡されるextcapの引数
+type ExtcapOption struct {
+	interfaces    bool   // --extcap-interfaces
+	iface         string // --extcap-interface
+	dlts          bool   // --extcap-dlts
+	config        bool   // --extcap-config
+	version       string // --extcap-version
+	capture       bool   // --capture
+	fifo          string // --fifo
+	captureFilter string // --extcap-capture-filter
+	file          string // 解析するCSVファイル
+}
+
+// extcapとして呼ばれたか
+func (e ExtcapOption) requested() bool {
+	return e.interfaces || e.dlts || e.config || e.capture
+}
+
+// extcapの要求に答える
+// キャプチャはCSVファイルを解析して、フレームをpcap形式でWiresharkのFIFOに書く
+func runExtcap(ctx context.Context, w io.Writer, extcap ExtcapOption, option InsightOption, version string) error {
+	switch {
+	case extcap.interfaces:
+		fmt.Fprintf(w, "extcap {version=%s}{help=https://github.com/ak1211/pulseinsight}\n", version)
+		fmt.Fprintf(w, "interface {value=%s}{display=pulseinsight RS485 CSVファイル}\n", ExtcapInterface)
+	case extcap.iface != ExtcapInterface:
+		return fmt.Errorf("extcapインターフェース \"%s\" は無い", extcap.iface)
+	case extcap.dlts:
+		fmt.Fprintf(w, "dlt {number=%d}{name=USER0}{display=RS485 フレーム}\n", LinkTypeUser0)
+	case extcap.config:
+		fmt.Fprintln(w, "arg {number=0}{call=--file}{display=CSVファイル}{type=fileselect}{mustexist=true}{required=true}")
+		fmt.Fprintf(w, "arg {number=1}{call=--baudrate}{display=ボーレート}{type=integer}{default=%d}\n", option.baudrate)
+		fmt.Fprintf(w, "arg {number=2}{call=--frame-gap}{display=フレーム間隔(文字)}{type=double}{default=%g}\n", option.frameGap)
+	case extcap.capture:
+		if extcap.file == "" {
+			return fmt.Errorf("CSVファイルが指定されていません")
+		}
+		if extcap.fifo == "" {
+			return fmt.Errorf("FIFOが指定されていません")
+		}
+		option.pcapFile = extcap.fifo
+		return insightTheCsvFile(ctx, w, extcap.file, option)
+	}
+	return nil
+}
+
+// pcapのタイムスタンプ
+// 時刻の分からない入力CSVは時間0を1970-01-01T00:00:00Zとする
+func pcapTimestamp(clock Clock, t float64) time.Time {
+	if clock.absolute {
+		return clock.relativeTime(t)
+	}
+	return time.Unix(0, 0).Add(time.Duration((clock.originTime + t) * float64(time.Second)))
+}
+
+// フレームをpcap形式で書く
+func writePcap(w io.Writer, clock Clock, frames []UartFrame) error {
+	header := struct {
+		Magic        uint32
+		VersionMajor uint16
+		VersionMinor uint16
+		ThisZone     int32
+		SigFigs      uint32
+		SnapLen      uint32
+		LinkType     uint32
+	}{0xa1b23c4d, 2, 4, 0, 0, 65535, LinkTypeUser0}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		slog.Error("binary.Write", "err", err)
+		return err
+	}
+	for _, f := range frames {
+		payload := make([]byte, len(f.codes))
+		for i, c := range f.codes {
+			payload[i] = c.octet
+		}
+		ts := pcapTimestamp(clock, f.startTime)
+		record := struct {
+			Seconds     uint32
+			Nanoseconds uint32
+			CapturedLen uint32
+			OriginalLen uint32
+		}{uint32(ts.Unix()), uint32(ts.Nanosecond()), uint32(len(payload)), uint32(len(payload))}
+		if err := binary.Write(w, binary.LittleEndian, record); err != nil {
+			slog.Error("binary.Write", "err", err)
+			return err
+		}
+		if _, err := w.Write(payload); err != nil {
+			slog.Error("Write", "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// フレームをpcapファイルに保存する
+func savePcap(savefilepath string, clock Clock, frames []UartFrame) error {
+	file, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer file.Close()
+	return writePcap(file, clock, frames)
+}
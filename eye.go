@@ -0,0 +1,265 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// アイダイアグラム(A-B間電圧差をビット周期で折り返して重ね, アイの高さと幅を測る)
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// アイダイアグラムの画像の大きさ(pt)
+const (
+	EyeWidth  = 1200
+	EyeHeight = 900
+)
+
+// アイダイアグラムに重ねるビットの既定の最大数
+const EyeMaxTraces = 2000
+
+// アイの高さを測るビットの中央の範囲(±UI)
+const EyeCenterWindow = 0.1
+
+// アイダイアグラムの設定
+type EyeOption struct {
+	maxTraces int // 重ねて描くビットの最大数(測定は全てのビットで行う)
+}
+
+// アイの測定値
+// 横軸はビットの始まりを0とするUI, 縦軸は測ったままの向きのA-B間電圧差(V)
+type EyeMeasure struct {
+	bits      int       // 重ねたビット数(IDLEを除く)
+	upper     float64   // ビットの中央で正の側にあるべきサンプルの最小値
+	lower     float64   // ビットの中央で負の側にあるべきサンプルの最大値
+	crossings []float64 // ビットの始まりの前後(-0.5から0.5UI)で0Vを横切った位置
+}
+
+// アイの高さ(V)
+func (m EyeMeasure) height() float64 {
+	return m.upper - m.lower
+}
+
+// 0Vを横切った位置の広がり(UI, 最大と最小の差)
+func (m EyeMeasure) jitter() float64 {
+	if len(m.crossings) == 0 {
+		return 0
+	}
+	return slices.Max(m.crossings) - slices.Min(m.crossings)
+}
+
+// アイの幅(UI)
+func (m EyeMeasure) width() float64 {
+	return 1 - m.jitter()
+}
+
+// ビットの始まりを文字毎にスタートビットの始まりから1ビット周期の倍数に揃える
+// 波形整形した区間は同じ値が続くとサンプルの間隔ずつ遅れるので, 受信機と同じく立ち下がりで同期し直して折り返す
+func foldBits(bits []UartBit, baudrate int) []UartBit {
+	period := 1 / float64(baudrate)
+	folded := make([]UartBit, len(bits))
+	frameStart, inFrame := 0.0, false
+	for i, b := range bits {
+		switch {
+		case b.state == "START":
+			frameStart, inFrame = b.startTime, true
+		case b.state == "IDLE":
+			inFrame = false
+		case inFrame:
+			k := math.Round((b.startTime - frameStart) / period)
+			b.startTime = frameStart + k*period
+			b.endTime = b.startTime + period
+		}
+		folded[i] = b
+	}
+	return folded
+}
+
+// 全てのビット(IDLEを除く)をアイダイアグラムと同じく重ねてアイの高さと幅を測る
+// 高さはビットの中央(0.5±EyeCenterWindow UI)で, 復号した値の側の最も内側のサンプルの差にする
+// 幅は1UIからビットの始まりで0Vを横切った位置の広がりを引く
+// アイドルがSpaceの配線では値1のビットを負の側として扱う
+func measureEye(matrix mat.Matrix, bits []UartBit, originTime float64, baudrate int, idleSpace bool) (EyeMeasure, error) {
+	period := 1 / float64(baudrate)
+	measure := EyeMeasure{upper: math.Inf(1), lower: math.Inf(-1), crossings: []float64{}}
+	for _, b := range bits {
+		if b.state == "IDLE" {
+			continue
+		}
+		xys := eyeTrace(matrix, b.startTime+originTime, period)
+		if len(xys) < 2 {
+			continue
+		}
+		measure.bits++
+		positive := (b.bit == 1) != idleSpace
+		for k, p := range xys {
+			if math.Abs(p.X-0.5) <= EyeCenterWindow {
+				if positive {
+					measure.upper = min(measure.upper, p.Y)
+				} else {
+					measure.lower = max(measure.lower, p.Y)
+				}
+			}
+			if k == 0 {
+				continue
+			}
+			// 0Vを横切った位置を直線で補間する
+			q := xys[k-1]
+			if (q.Y < 0) != (p.Y < 0) {
+				x := q.X + (p.X-q.X)*q.Y/(q.Y-p.Y)
+				if x >= -0.5 && x < 0.5 {
+					measure.crossings = append(measure.crossings, x)
+				}
+			}
+		}
+	}
+	if measure.bits == 0 {
+		return measure, fmt.Errorf("アイダイアグラムに重ねるビットがない")
+	}
+	if math.IsInf(measure.upper, 0) || math.IsInf(measure.lower, 0) {
+		return measure, fmt.Errorf("ビットの中央にMarkかSpaceのサンプルがない(サンプリングが粗すぎる)")
+	}
+	return measure, nil
+}
+
+// アイの測定値を表示する
+func printEyeMeasure(w io.Writer, measure EyeMeasure, baudrate int) {
+	period := 1 / float64(baudrate)
+	fmt.Fprintf(w, "eye height: %.3fV (upper %.3fV, lower %.3fV at %.2f..%.2f UI)\n",
+		measure.height(), measure.upper, measure.lower, 0.5-EyeCenterWindow, 0.5+EyeCenterWindow)
+	if len(measure.crossings) == 0 {
+		fmt.Fprintln(w, "eye width: - (no crossings)")
+		return
+	}
+	fmt.Fprintf(w, "eye width: %.3f UI (%.3fus), crossing jitter %.3f UI p-p from %d crossings\n",
+		measure.width(), measure.width()*period*1e6, measure.jitter(), len(measure.crossings))
+}
+
+// アイダイアグラムを保存する
+// 高さをビットの中央の縦線, 幅を0Vの横線で描き込む
+func saveEyeDiagram(savefilepath string, option chart.Option, eyeMask *EyeMask, traces []plotter.XYs, measure EyeMeasure) error {
+	eye, err := newEyePlot(traces, option, eyeMask)
+	if err != nil {
+		return err
+	}
+	eye.Title.Text = fmt.Sprintf("%s  アイの高さ %.3fV", option.Title, measure.height())
+	markers := []plotter.XYs{{{X: 0.5, Y: measure.lower}, {X: 0.5, Y: measure.upper}}}
+	if len(measure.crossings) != 0 {
+		eye.Title.Text += fmt.Sprintf("  幅 %.3fUI", measure.width())
+		markers = append(markers, plotter.XYs{{X: slices.Max(measure.crossings), Y: 0}, {X: 1 + slices.Min(measure.crossings), Y: 0}})
+	}
+	for _, xys := range markers {
+		marker, err := plotter.NewLine(xys)
+		if err != nil {
+			slog.Error("NewLine", "err", err)
+			return err
+		}
+		marker.Color = colornames.Red
+		marker.Width = vg.Points(2)
+		eye.Add(marker)
+	}
+	canvas := vgimg.New(vg.Points(EyeWidth), vg.Points(EyeHeight))
+	eye.Draw(draw.New(canvas))
+	return chart.SaveCanvas(savefilepath, canvas, option.PngTexts)
+}
+
+// 測定値を復号してアイダイアグラムを描き, アイの高さと幅を表示する
+// 全二重の場合は送信対だけを描く
+func runEye(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption, eye EyeOption) error {
+	if option.inputType == InputLogic {
+		return fmt.Errorf("論理レベルの入力にはアイダイアグラムを描けない")
+	}
+	outputs, err := newOutputPolicy(option.overwrite, option.versionOutputs, time.Now())
+	if err != nil {
+		return err
+	}
+	var eyeMask *EyeMask
+	if option.eyeMaskFile != "" {
+		mask, err := loadEyeMask(option.eyeMaskFile)
+		if err != nil {
+			slog.Error("loadEyeMask", "err", err)
+			return err
+		}
+		eyeMask = &mask
+	}
+	matrix, _, err := prepareInputMatrix(ctx, io.Discard, csvfilepath, option)
+	if err != nil {
+		slog.Error("prepareInputMatrix", "err", err)
+		return err
+	}
+	if option.provenance != nil {
+		if option.provenance, err = option.provenance.withInput(csvfilepath); err != nil {
+			slog.Error("withInput", "err", err)
+			return err
+		}
+	}
+	if isDuplex(matrix) {
+		matrix, _ = splitDuplex(matrix)
+		fmt.Fprintln(w, "eye: full duplex, TX pair only")
+	}
+	if option.estimateBaud != EstimateBaudNone {
+		estimate, err := estimateBaudrate(matrix, option.estimateBaud)
+		if err != nil {
+			slog.Error("estimateBaudrate", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "baud rate: %d bps (%s estimate %.1f bps from %d edges)\n", estimate.baudrate, estimate.method, estimate.raw, estimate.edges)
+		option.baudrate = estimate.baudrate
+	}
+	if option.filter == FilterSma && option.smoothWindow == 0 {
+		option.smoothWindow = autoSmoothingWindow(matrix, option.baudrate)
+	}
+
+	filtered, err := applyFilter(matrix, option)
+	if err != nil {
+		slog.Error("applyFilter", "err", err)
+		return err
+	}
+	reshaped, _, originTime, err := decodeWaveforms(matrix, nil, filtered, nil, option)
+	if err != nil {
+		slog.Error("decodeWaveforms", "err", err)
+		return err
+	}
+	bits, _, err := analyzePulses(reshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return err
+	}
+	bits = foldBits(bits, option.baudrate)
+	measure, err := measureEye(matrix, bits, originTime, option.baudrate, option.format.idleSpace)
+	if err != nil {
+		return err
+	}
+	traces := eyeTraces(matrix, bits, originTime, option.baudrate, eye.maxTraces)
+	fmt.Fprintf(w, "eye: \"%s\"  %d bps  %d bits (%d traces drawn)\n", csvfilepath, option.baudrate, measure.bits, len(traces))
+	printEyeMeasure(w, measure, option.baudrate)
+
+	stem, ext := outputStem(csvfilepath, option.outputPrefix)
+	savefilepath := outputs.file(stem + "_" + ext[1:] + "_eye.png")
+	chartOption := chart.Option{
+		Title:     fmt.Sprintf("%s  %d bps", filepath.Base(csvfilepath), option.baudrate),
+		PngTexts:  option.provenance.pngTexts(),
+		Threshold: option.threshold,
+	}
+	if err := saveEyeDiagram(savefilepath, chartOption, eyeMask, traces, measure); err != nil {
+		slog.Error("saveEyeDiagram", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "eye diagram saved to \"%s\"\n", savefilepath)
+	return nil
+}
@@ -0,0 +1,160 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// アイダイアグラムのマスク試験(マスクの多角形に入ったビットを違反とする)
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot/plotter"
+)
+
+// 表示するマスク違反の数
+const EyeMaskMaxListed = 10
+
+// アイダイアグラムのマスク
+// 頂点の横軸はビットの始まりを0とするUI(アイダイアグラムと同じく-0.5から1.5), 縦軸はA-B間電圧差(V)
+type EyeMask struct {
+	Name     string         `json:"name,omitempty"`
+	Polygons [][][2]float64 `json:"polygons"` // 多角形毎の頂点([UI, V])
+}
+
+// マスクに入ったビット
+type EyeMaskHit struct {
+	time    float64 // ビットの始まり(基準時間からの相対時間)
+	polygon int     // 0始まりの多角形の番号
+	samples int     // 多角形に入ったサンプル数(辺を横切っただけの場合は0)
+}
+
+// マスク試験の結果
+type EyeMaskResult struct {
+	bits int          // 試験したビット数
+	hits []EyeMaskHit // マスクに入ったビット
+}
+
+// マスクのファイルを読み込む
+func loadEyeMask(path string) (EyeMask, error) {
+	var mask EyeMask
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return mask, err
+	}
+	if err := json.Unmarshal(data, &mask); err != nil {
+		return mask, fmt.Errorf("マスク \"%s\" を読めない: %w", path, err)
+	}
+	if len(mask.Polygons) == 0 {
+		return mask, fmt.Errorf("マスク \"%s\" に多角形がない", path)
+	}
+	for i, polygon := range mask.Polygons {
+		if len(polygon) < 3 {
+			return mask, fmt.Errorf("マスク \"%s\" の多角形#%dの頂点が%d個しかない(3個以上必要)", path, i+1, len(polygon))
+		}
+	}
+	return mask, nil
+}
+
+// 点が多角形の内側にあるか(交差数判定)
+func insidePolygon(x float64, y float64, polygon [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		xi, yi := polygon[i][0], polygon[i][1]
+		xj, yj := polygon[j][0], polygon[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// 線分p1-p2と線分q1-q2が交わるか
+func segmentsCross(p1 [2]float64, p2 [2]float64, q1 [2]float64, q2 [2]float64) bool {
+	cross := func(o, a, b [2]float64) float64 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+	d1, d2 := cross(q1, q2, p1), cross(q1, q2, p2)
+	d3, d4 := cross(p1, p2, q1), cross(p1, p2, q2)
+	return ((d1 > 0) != (d2 > 0)) && ((d3 > 0) != (d4 > 0))
+}
+
+// 折れ線が多角形に入ったか
+// 多角形の内側のサンプル数と, サンプルの間で辺を横切ったか
+func traceHitsPolygon(xys plotter.XYs, polygon [][2]float64) (int, bool) {
+	samples := 0
+	for _, p := range xys {
+		if insidePolygon(p.X, p.Y, polygon) {
+			samples++
+		}
+	}
+	if samples > 0 {
+		return samples, true
+	}
+	// サンプルの間隔より狭い多角形を飛び越えた
+	for k := 1; k < len(xys); k++ {
+		p1, p2 := [2]float64{xys[k-1].X, xys[k-1].Y}, [2]float64{xys[k].X, xys[k].Y}
+		for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+			if segmentsCross(p1, p2, polygon[j], polygon[i]) {
+				return 0, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// 全てのビット(IDLEを除く)をアイダイアグラムと同じく重ねてマスクと比べる
+func testEyeMask(matrix mat.Matrix, bits []UartBit, originTime float64, baudrate int, mask EyeMask) EyeMaskResult {
+	period := 1 / float64(baudrate)
+	result := EyeMaskResult{hits: []EyeMaskHit{}}
+	for _, b := range bits {
+		if b.state == "IDLE" {
+			continue
+		}
+		xys := eyeTrace(matrix, b.startTime+originTime, period)
+		if len(xys) < 2 {
+			continue
+		}
+		result.bits++
+		for i, polygon := range mask.Polygons {
+			if samples, hit := traceHitsPolygon(xys, polygon); hit {
+				result.hits = append(result.hits, EyeMaskHit{time: b.startTime, polygon: i, samples: samples})
+				break
+			}
+		}
+	}
+	return result
+}
+
+// マスク試験の結果を表示する
+func printEyeMask(w io.Writer, clock Clock, label string, mask EyeMask, result EyeMaskResult) {
+	verdict := "PASS"
+	if len(result.hits) > 0 {
+		verdict = "FAIL"
+	}
+	name := mask.Name
+	if name == "" {
+		name = "-"
+	}
+	fmt.Fprintf(w, "eye mask%s: %s  %d polygons  %d bits  %d hits  %s\n", label, name, len(mask.Polygons), result.bits, len(result.hits), verdict)
+	for i, hit := range result.hits {
+		if i == EyeMaskMaxListed {
+			fmt.Fprintf(w, "    ...(%d more)\n", len(result.hits)-i)
+			break
+		}
+		fmt.Fprintf(w, "    hit %s  polygon #%d  %d samples\n", clock.format(hit.time), hit.polygon+1, hit.samples)
+	}
+}
+
+// マスクに入ったビット
+func eyeMaskAnomalies(result EyeMaskResult) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, hit := range result.hits {
+		anomalies = append(anomalies, AnomalyEvent{Time: hit.time, Kind: "eye-mask", Severity: SeverityError, Detail: fmt.Sprintf("マスクの多角形#%dに入った", hit.polygon+1)})
+	}
+	return anomalies
+}
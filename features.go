@@ -0,0 +1,158 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 機械学習向けのビット毎の特徴量
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 1ビットの特徴量
+type BitFeature struct {
+	bit           UartBit
+	direction     string  // 全二重の場合の通信方向, 半二重では空
+	width         float64 // ビットの幅(s)
+	mean          float64 // ビットの区間のA,B間電圧差の平均(V)
+	variance      float64 // ビットの区間のA,B間電圧差の分散(V^2)
+	leadingSlope  float64 // ビットの始まりのエッジの傾き(V/s), 前のビットと同じ値の場合は0
+	trailingSlope float64 // ビットの終わりのエッジの傾き(V/s), 次のビットと同じ値の場合は0
+}
+
+// 区間[from,to]のA,B間電圧差の最も急な傾き
+func (s BitWaveform) steepestSlope(from float64, to float64) float64 {
+	begin := sort.SearchFloat64s(s.times, from)
+	end := min(sort.SearchFloat64s(s.times, to)+1, len(s.diff))
+	steepest := 0.0
+	for r := begin + 1; r < end; r++ {
+		dt := s.times[r] - s.times[r-1]
+		if dt <= 0 {
+			continue
+		}
+		if slope := (s.diff[r] - s.diff[r-1]) / dt; math.Abs(slope) > math.Abs(steepest) {
+			steepest = slope
+		}
+	}
+	return steepest
+}
+
+// 解析したビット列の特徴量
+// エッジの傾きはビットの境界の前後1/4ビットで最も急な傾き
+func bitFeatures(source BitWaveform, direction string) []BitFeature {
+	features := make([]BitFeature, 0, len(source.bits))
+	for i, b := range source.bits {
+		begin := sort.SearchFloat64s(source.times, b.startTime)
+		end := min(sort.SearchFloat64s(source.times, b.endTime)+1, len(source.diff))
+		mean, variance := 0.0, 0.0
+		if n := end - begin; n > 0 {
+			for _, d := range source.diff[begin:end] {
+				mean += d
+			}
+			mean /= float64(n)
+			for _, d := range source.diff[begin:end] {
+				variance += (d - mean) * (d - mean)
+			}
+			variance /= float64(n)
+		}
+
+		f := BitFeature{bit: b, direction: direction, width: b.endTime - b.startTime, mean: mean, variance: variance}
+		quarter := source.bitTime / 4
+		if i > 0 && source.bits[i-1].bit != b.bit {
+			f.leadingSlope = source.steepestSlope(b.startTime-quarter, b.startTime+quarter)
+		}
+		if i+1 < len(source.bits) && source.bits[i+1].bit != b.bit {
+			f.trailingSlope = source.steepestSlope(b.endTime-quarter, b.endTime+quarter)
+		}
+		features = append(features, f)
+	}
+	return features
+}
+
+// 特徴量を保存する
+// 拡張子が.parquetの場合はParquet形式, それ以外はCSV
+func saveBitFeatures(savefilepath string, clock Clock, features []BitFeature) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(savefilepath), ".parquet") {
+		if err := writeBitFeaturesParquet(f, clock, features); err != nil {
+			slog.Error("Write", "err", err)
+			return err
+		}
+		return f.Close()
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"start", "timestamp", "direction", "state", "bit", "width", "mean", "variance", "leading_slope", "trailing_slope"})
+	for _, v := range features {
+		timestamp := ""
+		if clock.absolute {
+			timestamp = clock.format(v.bit.startTime)
+		}
+		writer.Write([]string{
+			strconv.FormatFloat(v.bit.startTime, 'g', -1, 64),
+			timestamp,
+			v.direction,
+			v.bit.state,
+			strconv.Itoa(v.bit.bit),
+			strconv.FormatFloat(v.width, 'g', 6, 64),
+			strconv.FormatFloat(v.mean, 'g', 6, 64),
+			strconv.FormatFloat(v.variance, 'g', 6, 64),
+			strconv.FormatFloat(v.leadingSlope, 'g', 6, 64),
+			strconv.FormatFloat(v.trailingSlope, 'g', 6, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 特徴量をParquet形式でwに書く
+// 列はCSVと同じで, 数値は丸めずに書く
+func writeBitFeaturesParquet(w io.Writer, clock Clock, features []BitFeature) error {
+	n := len(features)
+	starts, timestamps, directions, states, bits := make([]float64, n), make([]string, n), make([]string, n), make([]string, n), make([]int32, n)
+	widths, means, variances, leadingSlopes, trailingSlopes := make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	for i, v := range features {
+		starts[i] = v.bit.startTime
+		if clock.absolute {
+			timestamps[i] = clock.format(v.bit.startTime)
+		}
+		directions[i] = v.direction
+		states[i] = v.bit.state
+		bits[i] = int32(v.bit.bit)
+		widths[i] = v.width
+		means[i] = v.mean
+		variances[i] = v.variance
+		leadingSlopes[i] = v.leadingSlope
+		trailingSlopes[i] = v.trailingSlope
+	}
+	columns := []ParquetColumn{
+		newParquetDoubleColumn("start", starts),
+		newParquetStringColumn("timestamp", timestamps),
+		newParquetStringColumn("direction", directions),
+		newParquetStringColumn("state", states),
+		newParquetInt32Column("bit", bits),
+		newParquetDoubleColumn("width", widths),
+		newParquetDoubleColumn("mean", means),
+		newParquetDoubleColumn("variance", variances),
+		newParquetDoubleColumn("leading_slope", leadingSlopes),
+		newParquetDoubleColumn("trailing_slope", trailingSlopes),
+	}
+	return writeParquet(w, n, columns, "pulseinsight")
+}
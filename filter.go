@@ -0,0 +1,216 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ノイズ除去フィルタの選択
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// フィルタの種類
+const (
+	FilterSma     = "sma"     // 移動平均
+	FilterWavelet = "wavelet" // ウェーブレット縮退(Haar, ソフトしきい値)
+	FilterEma     = "ema"     // 指数移動平均
+	FilterKalman  = "kalman"  // 段差を検出して追従するカルマンフィルタ
+)
+
+// フィルタ適用後のグラフの題名
+var filterTitles = map[string]string{
+	FilterSma:     "ローパスフィルタ適用後",
+	FilterWavelet: "ウェーブレットノイズ除去後",
+	FilterEma:     "指数移動平均適用後",
+	FilterKalman:  "カルマンフィルタ適用後",
+}
+
+// 選んだフィルタを掛ける
+func applyFilter(original mat.Matrix, option InsightOption) (mat.Matrix, error) {
+	switch option.filter {
+	case FilterSma:
+		return waveform.Smooth(original, option.smoothWindow)
+	case FilterWavelet:
+		return applyWaveletDenoise(original, option.waveletLevels)
+	case FilterEma:
+		if option.emaAlpha <= 0 || option.emaAlpha > 1 {
+			return nil, fmt.Errorf("指数移動平均の係数%gは0より大きく1以下にする", option.emaAlpha)
+		}
+		return applyColumnFilter(original, func(x []float64) []float64 {
+			return exponentialSmoothing(x, option.emaAlpha)
+		}), nil
+	case FilterKalman:
+		if option.kalmanQ <= 0 || option.kalmanR <= 0 {
+			return nil, errors.New("カルマンフィルタの分散は正の値にする")
+		}
+		return applyColumnFilter(original, func(x []float64) []float64 {
+			return kalmanStepFilter(x, option.kalmanQ, option.kalmanR)
+		}), nil
+	default:
+		return nil, fmt.Errorf("フィルタ \"%s\" には対応していない", option.filter)
+	}
+}
+
+// サンプリング間隔(s)
+// 時間列の隣り合う行の差の中央値
+func sampleInterval(matrix mat.Matrix) float64 {
+	rows, _ := matrix.Dims()
+	if rows < 2 {
+		return 0
+	}
+	diffs := make([]float64, rows-1)
+	for r := range diffs {
+		diffs[r] = matrix.At(r+1, ColTime) - matrix.At(r, ColTime)
+	}
+	sort.Float64s(diffs)
+	return diffs[len(diffs)/2]
+}
+
+// ビット周期の1/8に相当するサンプル数を移動平均の窓の大きさにする
+// 高いボーレートでもビットを鈍らせないように最小は1
+func autoSmoothingWindow(matrix mat.Matrix, baudrate int) int {
+	interval := sampleInterval(matrix)
+	if interval <= 0 {
+		return 1
+	}
+	samplesPerBit := 1 / float64(baudrate) / interval
+	return max(1, int(math.Round(samplesPerBit/8)))
+}
+
+// ウェーブレット縮退でノイズを除去する
+// 時間列以外の全ての列に掛ける
+func applyWaveletDenoise(original mat.Matrix, levels int) (mat.Matrix, error) {
+	rows, cols := original.Dims()
+	if levels < 1 || rows < 1<<levels {
+		return nil, fmt.Errorf("ウェーブレットの分解レベル%dに対してデータ数が不足している", levels)
+	}
+	matrix := mat.DenseCopyOf(original)
+	for c := ColWireA; c < cols; c++ {
+		matrix.SetCol(c, haarDenoise(mat.Col(nil, c, original), levels))
+	}
+	return matrix, nil
+}
+
+// Haarウェーブレットで分解して、詳細係数にソフトしきい値を掛けてから再構成する
+// しきい値は最も細かい詳細係数の中央絶対偏差から求めた雑音の標準偏差によるuniversal threshold
+func haarDenoise(x []float64, levels int) []float64 {
+	n := len(x)
+	// 2^levelsの倍数になるように最後の値で埋める
+	block := 1 << levels
+	padded := make([]float64, (n+block-1)/block*block)
+	copy(padded, x)
+	for i := n; i < len(padded); i++ {
+		padded[i] = x[n-1]
+	}
+
+	// 分解
+	details := make([][]float64, levels)
+	approx := padded
+	for l := 0; l < levels; l++ {
+		half := len(approx) / 2
+		next := make([]float64, half)
+		details[l] = make([]float64, half)
+		for i := 0; i < half; i++ {
+			next[i] = (approx[2*i] + approx[2*i+1]) / math.Sqrt2
+			details[l][i] = (approx[2*i] - approx[2*i+1]) / math.Sqrt2
+		}
+		approx = next
+	}
+
+	// 雑音の標準偏差を推定してしきい値を決める
+	abs := make([]float64, len(details[0]))
+	for i, v := range details[0] {
+		abs[i] = math.Abs(v)
+	}
+	sort.Float64s(abs)
+	sigma := abs[len(abs)/2] / 0.6745
+	threshold := sigma * math.Sqrt(2*math.Log(float64(len(padded))))
+
+	// ソフトしきい値
+	for _, d := range details {
+		for i, v := range d {
+			d[i] = math.Copysign(math.Max(math.Abs(v)-threshold, 0), v)
+		}
+	}
+
+	// 再構成
+	for l := levels - 1; l >= 0; l-- {
+		next := make([]float64, 2*len(approx))
+		for i := range approx {
+			next[2*i] = (approx[i] + details[l][i]) / math.Sqrt2
+			next[2*i+1] = (approx[i] - details[l][i]) / math.Sqrt2
+		}
+		approx = next
+	}
+
+	return approx[:n]
+}
+
+// 時間列以外の全ての列に同じフィルタを掛ける
+func applyColumnFilter(original mat.Matrix, filter func([]float64) []float64) mat.Matrix {
+	_, cols := original.Dims()
+	matrix := mat.DenseCopyOf(original)
+	for c := ColWireA; c < cols; c++ {
+		matrix.SetCol(c, filter(mat.Col(nil, c, original)))
+	}
+	return matrix
+}
+
+// 指数移動平均
+// alphaが大きいほど新しい値を重視する
+func exponentialSmoothing(x []float64, alpha float64) []float64 {
+	y := make([]float64, len(x))
+	for i, v := range x {
+		if i == 0 {
+			y[i] = v
+		} else {
+			y[i] = alpha*v + (1-alpha)*y[i-1]
+		}
+	}
+	return y
+}
+
+// 一定値が続く信号(ランダムウォーク)向けのカルマンフィルタ
+// qはプロセス雑音の分散、rは観測雑音の分散
+// 観測値が推定値から3σを超えて同じ向きに2回続けて離れた場合は段差とみなして推定をやり直し、エッジを鈍らせない
+func kalmanStepFilter(x []float64, q float64, r float64) []float64 {
+	y := make([]float64, len(x))
+	if len(x) == 0 {
+		return y
+	}
+	estimate := x[0]
+	variance := r
+	// 直前に3σを超えた向き(1, -1), 超えていなければ0
+	outlier := 0.0
+	for i, z := range x {
+		// 予測
+		variance += q
+		innovation := z - estimate
+		if math.Abs(innovation) > 3*math.Sqrt(variance+r) {
+			direction := math.Copysign(1, innovation)
+			if outlier == direction {
+				// 段差
+				estimate = (z + x[i-1]) / 2
+				variance = r / 2
+				outlier = 0
+				y[i] = estimate
+				continue
+			}
+			outlier = direction
+		} else {
+			outlier = 0
+		}
+		// 更新
+		gain := variance / (variance + r)
+		estimate += gain * innovation
+		variance *= 1 - gain
+		y[i] = estimate
+	}
+	return y
+}
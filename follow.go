@@ -0,0 +1,238 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定ソフトが書き込み中のCSVファイルを追いかけて復号する(tail -f のように)
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// ファイルが伸びるのを待つ間隔
+const FollowPollInterval = 200 * time.Millisecond
+
+// バスが無通信にならなくても復号する行数
+const FollowMaxRows = 1 << 20
+
+// 追いかけている途中の行
+type FollowBuffer struct {
+	header [][]string // 読み飛ばしたヘッダー行
+	data   []float64  // 読み込んだ行(行優先)
+	cols   int        // 列数(最初のデータ行に合わせる)
+	line   int        // 読み込んだ行数(ヘッダー行を含む)
+}
+
+// 1行を数値に変換して加える
+func (b *FollowBuffer) appendLine(text string, comma rune, badRows string) error {
+	b.line++
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = comma
+	record, err := reader.Read()
+	if err == nil && b.cols != 0 && len(record) < b.cols {
+		err = fmt.Errorf("列数が%d(%d必要)", len(record), b.cols)
+	}
+	if err == nil {
+		if b.cols == 0 {
+			b.cols = len(record)
+		}
+		values := make([]float64, b.cols)
+		for c := range values {
+			if values[c], err = strconv.ParseFloat(strings.TrimSpace(record[c]), 64); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			b.data = append(b.data, values...)
+			return nil
+		}
+	}
+	if badRows == BadRowsAbort {
+		slog.Error("bad row", "row", b.line, "err", err)
+		return fmt.Errorf("%d行目: %w", b.line, err)
+	}
+	slog.Warn("skip row", "row", b.line, "err", err)
+	return nil
+}
+
+// 行数
+func (b *FollowBuffer) rows() int {
+	if b.cols == 0 {
+		return 0
+	}
+	return len(b.data) / b.cols
+}
+
+// 読み込んだ行から解析する行列を作る
+// 列の選択, 単位の換算, プローブの減衰比と極性, 時間のずれの補正は通常の解析と同じ
+func (b *FollowBuffer) matrix(option InsightOption) (*mat.Dense, error) {
+	matrix := mat.NewDense(b.rows(), b.cols, append([]float64{}, b.data...))
+	header := b.header
+	var err error
+	if option.columnNames != (ColumnNames{}) {
+		if matrix, header, err = selectColumns(matrix, header, option.columnNames); err != nil {
+			slog.Error("selectColumns", "err", err)
+			return nil, err
+		}
+	}
+	if err := applyUnits(matrix, header, option.timeUnit, option.voltageUnit); err != nil {
+		slog.Error("applyUnits", "err", err)
+		return nil, err
+	}
+	aScale, bScale := option.aScale, option.bScale
+	if option.invertA {
+		aScale = -aScale
+	}
+	if option.invertB {
+		bScale = -bScale
+	}
+	applyProbeScale(matrix, aScale, bScale)
+	applySkew(matrix, option.skew)
+	return matrix, nil
+}
+
+// 最後の行から遡ってバスが無通信だった時間(s)
+// 一度もアイドルと反対の向きになっていない場合はfalse
+// signはアイドルの向きの符号
+func trailingIdleTime(matrix mat.Matrix, sign float64) (float64, bool) {
+	rows, _ := matrix.Dims()
+	diff := waveform.Differential(nil, matrix)
+	r := rows - 1
+	for r >= 0 && sign*diff[r] > -Threshould {
+		r--
+	}
+	if r < 0 {
+		return 0, false
+	}
+	return matrix.At(rows-1, ColTime) - matrix.At(r, ColTime), true
+}
+
+// 読み込んだ行を復号して表示する
+// 最後のフレームが終わっていない(無通信の時間がフレーム間隔に満たない)場合は、finalでなければ次の行を待つ
+func (b *FollowBuffer) decode(w io.Writer, clock Clock, option InsightOption, final bool) error {
+	if b.rows() < 2 {
+		return nil
+	}
+	matrix, err := b.matrix(option)
+	if err != nil {
+		return err
+	}
+	if isDuplex(matrix) {
+		return fmt.Errorf("追従モードは全二重に対応していない")
+	}
+	idle, active := trailingIdleTime(matrix, option.format.idleSign())
+	if !active {
+		// 無通信が続いているだけなので最後の行だけ残す
+		b.data = append(b.data[:0], b.data[len(b.data)-b.cols:]...)
+		return nil
+	}
+	if !final && b.rows() < FollowMaxRows && idle < option.frameGap*option.format.charTime(option.baudrate) {
+		return nil
+	}
+
+	reshaped, _, originTime, err := decodeWaveforms(matrix, nil, matrix, nil, option)
+	if err != nil {
+		slog.Error("decodeWaveforms", "err", err)
+		return err
+	}
+	bits, codes, err := analyzePulses(reshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return err
+	}
+	for _, f := range groupFrames(codes, option.format.charTime(option.baudrate), option.frameGap) {
+		octets := make([]byte, len(f.codes))
+		for i, c := range f.codes {
+			octets[i] = c.octet
+		}
+		fmt.Fprintf(w, "%s  % x\n", clock.format(originTime+f.startTime), octets)
+	}
+	for _, bit := range bits {
+		if bit.state == "X" {
+			fmt.Fprintf(w, "%s  framing error\n", clock.format(originTime+bit.startTime))
+		}
+	}
+	b.data = b.data[:0]
+	return nil
+}
+
+// CSVファイルを追いかけて, 書き足された行を復号して表示する
+// ctxが終わったら残りの行を復号して終わる
+func followCsvFile(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) error {
+	f, err := os.Open(csvfilepath)
+	if err != nil {
+		slog.Error("Open", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	var clock Clock
+	if option.t0 != "" {
+		if clock.t0, err = parseT0(option.t0); err != nil {
+			slog.Error("parseT0", "err", err)
+			return err
+		}
+		clock.absolute = true
+	}
+
+	comma, err := parseDelimiter(option.delimiter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "following \"%s\" (Ctrl-Cで終わる)\n", csvfilepath)
+	reader := bufio.NewReader(f)
+	buffer := FollowBuffer{}
+	partial := ""
+	for {
+		text, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			slog.Error("ReadString", "err", err)
+			return err
+		}
+		if err == nil {
+			text, partial = partial+strings.TrimRight(text, "\r\n"), ""
+			if len(buffer.header) < option.skipLines {
+				reader := csv.NewReader(strings.NewReader(text))
+				reader.Comma = comma
+				record, _ := reader.Read()
+				buffer.header = append(buffer.header, record)
+				buffer.line++
+				if len(buffer.header) == option.skipLines && option.t0 == "" {
+					clock.t0, clock.absolute = findHeaderTime(buffer.header)
+				}
+				continue
+			}
+			if text != "" {
+				if err := buffer.appendLine(text, comma, option.badRows); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// 書き込み途中の行は次に読んだ続きとつなげる
+		partial += text
+		if err := buffer.decode(w, clock, option, false); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return buffer.decode(w, clock, option, true)
+		case <-time.After(FollowPollInterval):
+		}
+	}
+}
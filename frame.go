@@ -0,0 +1,65 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 無通信時間で区切ったコードのまとまり(フレーム)
+package main
+
+import "fmt"
+
+// フレーム
+type UartFrame struct {
+	startTime float64
+	endTime   float64
+	direction string // 全二重の場合の通信方向, 半二重では空
+	codes     []UartCode
+}
+
+// 無通信時間がgapChars文字分を超えるか、通信方向が変わるところでコードをフレームに分ける
+// charTimeは1文字の時間(s)
+func groupFrames(codes []UartCode, charTime float64, gapChars float64) []UartFrame {
+	frames := []UartFrame{}
+	maxGap := gapChars * charTime
+	for i, v := range codes {
+		if i == 0 || v.direction != codes[i-1].direction || v.startTime-codes[i-1].endTime > maxGap {
+			frames = append(frames, UartFrame{
+				startTime: v.startTime,
+				direction: v.direction,
+			})
+		}
+		last := &frames[len(frames)-1]
+		last.endTime = v.endTime
+		last.codes = append(last.codes, v)
+	}
+	return frames
+}
+
+// パリティが合わない文字の数
+func (f UartFrame) parityErrors() int {
+	n := 0
+	for _, c := range f.codes {
+		if c.parityError {
+			n++
+		}
+	}
+	return n
+}
+
+// フレーム内のaddressByteバイト目をアドレスとして取り出す
+func (f UartFrame) address(addressByte int) (byte, bool) {
+	if addressByte < 0 || addressByte >= len(f.codes) {
+		return 0, false
+	}
+	return f.codes[addressByte].octet, true
+}
+
+// 送信元を表す名前
+// 全二重の場合は通信方向、半二重の場合はアドレス
+func (f UartFrame) talker(addressByte int) string {
+	if f.direction != "" {
+		return f.direction
+	}
+	if addr, ok := f.address(addressByte); ok {
+		return fmt.Sprintf("0x%02X", addr)
+	}
+	return "?"
+}
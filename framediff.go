@@ -0,0 +1,268 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// フレームの一覧をJSONで保存し, 以前の解析で保存した一覧(参照)とフレーム単位で比べる(増えた, 無くなった, 変わったフレーム)
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// 最長共通部分列で揃える表の大きさの上限(参照のフレーム数×今回のフレーム数)
+// 超えた場合は先頭から順に対応させる
+const FrameDiffMaxCells = 1 << 24
+
+// 変わったフレームで書く異なるバイトの最大数
+const FrameDiffMaxBytes = 8
+
+// 保存するフレーム
+type FrameRecord struct {
+	Time      float64 `json:"time"`                // 基準時間からの相対時間(s)
+	Timestamp string  `json:"timestamp,omitempty"` // 絶対時刻, --t0を指定しない場合は空
+	Direction string  `json:"direction,omitempty"` // 全二重の場合の通信方向, 半二重では空
+	Talker    string  `json:"talker"`              // 送信元を表す名前
+	Bytes     string  `json:"bytes"`               // バイト列(16進数)
+}
+
+// 保存するフレームの一覧
+type FrameReport struct {
+	Provenance *Provenance   `json:"provenance,omitempty"`
+	Frames     []FrameRecord `json:"frames"`
+}
+
+// フレームの差の種類
+const (
+	FrameAdded   = "added"   // 参照に無いフレーム
+	FrameMissing = "missing" // 参照に有って今回に無いフレーム
+	FrameChanged = "changed" // 同じ位置で内容が変わったフレーム
+)
+
+// フレームの差
+type FrameDiff struct {
+	kind      string // 差の種類(FrameAdded, FrameMissing, FrameChanged)
+	reference int    // 参照のフレーム番号(0始まり), FrameAddedでは-1
+	current   int    // 今回のフレーム番号(0始まり), FrameMissingでは-1
+}
+
+// 保存するフレームを作る
+func frameRecords(frames []UartFrame, clock Clock, addressByte int) []FrameRecord {
+	records := make([]FrameRecord, len(frames))
+	for i, f := range frames {
+		octets := make([]byte, len(f.codes))
+		for k, c := range f.codes {
+			octets[k] = c.octet
+		}
+		records[i] = FrameRecord{
+			Time:      f.startTime,
+			Direction: f.direction,
+			Talker:    f.talker(addressByte),
+			Bytes:     hex.EncodeToString(octets),
+		}
+		if clock.absolute {
+			records[i].Timestamp = clock.format(f.startTime)
+		}
+	}
+	return records
+}
+
+// フレームの一覧をJSONファイルに保存する
+func saveFrames(savefilepath string, records []FrameRecord, provenance *Provenance) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(FrameReport{Provenance: provenance, Frames: records}); err != nil {
+		slog.Error("Encode", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 保存したフレームの一覧を読み込む
+func loadFrames(filePath string) ([]FrameRecord, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		slog.Error("Open", "err", err)
+		return nil, err
+	}
+	defer f.Close()
+
+	var report FrameReport
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		slog.Error("Decode", "err", err)
+		return nil, fmt.Errorf("参照ファイル \"%s\" を読めない: %w", filePath, err)
+	}
+	for i, r := range report.Frames {
+		if _, err := hex.DecodeString(r.Bytes); err != nil {
+			return nil, fmt.Errorf("参照ファイル \"%s\" のフレーム #%d のバイト列 \"%s\" が16進数ではない", filePath, i+1, r.Bytes)
+		}
+	}
+	return report.Frames, nil
+}
+
+// フレームの内容が同じか(時間は比べない)
+func sameFrame(a, b FrameRecord) bool {
+	return a.Direction == b.Direction && a.Bytes == b.Bytes
+}
+
+// 参照と今回のフレームを比べる
+// 内容が同じフレームを最長共通部分列で揃え, 揃った間に残った参照と今回のフレームは
+// 通信方向が同じなら順に組にして変わったフレーム, 残りを無くなったか増えたフレームとする
+// 取り込みごとに時間は違うので, 時間は比べない
+func diffFrames(reference, current []FrameRecord) []FrameDiff {
+	n, m := len(reference), len(current)
+	// 先頭と末尾の同じフレームは表に入れない
+	head := 0
+	for head < n && head < m && sameFrame(reference[head], current[head]) {
+		head++
+	}
+	tail := 0
+	for tail < n-head && tail < m-head && sameFrame(reference[n-1-tail], current[m-1-tail]) {
+		tail++
+	}
+	ref, cur := reference[head:n-tail], current[head:m-tail]
+
+	// 揃った組(参照, 今回)
+	pairs := [][2]int{}
+	if len(ref)*len(cur) <= FrameDiffMaxCells {
+		// lcs[i][j]はref[i:]とcur[j:]の最長共通部分列の長さ
+		lcs := make([][]int, len(ref)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(cur)+1)
+		}
+		for i := len(ref) - 1; i >= 0; i-- {
+			for j := len(cur) - 1; j >= 0; j-- {
+				if sameFrame(ref[i], cur[j]) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		for i, j := 0, 0; i < len(ref) && j < len(cur); {
+			switch {
+			case sameFrame(ref[i], cur[j]):
+				pairs = append(pairs, [2]int{i, j})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	} else {
+		for i := 0; i < len(ref) && i < len(cur); i++ {
+			if sameFrame(ref[i], cur[i]) {
+				pairs = append(pairs, [2]int{i, i})
+			}
+		}
+	}
+	pairs = append(pairs, [2]int{len(ref), len(cur)})
+
+	diffs := []FrameDiff{}
+	i, j := 0, 0
+	for _, p := range pairs {
+		// 揃った組の間に残ったフレーム
+		for i < p[0] || j < p[1] {
+			switch {
+			case i < p[0] && j < p[1] && ref[i].Direction == cur[j].Direction:
+				diffs = append(diffs, FrameDiff{kind: FrameChanged, reference: head + i, current: head + j})
+				i++
+				j++
+			case i < p[0]:
+				diffs = append(diffs, FrameDiff{kind: FrameMissing, reference: head + i, current: -1})
+				i++
+			default:
+				diffs = append(diffs, FrameDiff{kind: FrameAdded, reference: -1, current: head + j})
+				j++
+			}
+		}
+		i, j = p[0]+1, p[1]+1
+	}
+	return diffs
+}
+
+// バイト列を空白で区切る
+func spacedHex(s string) string {
+	octets := []string{}
+	for k := 0; k+1 < len(s); k += 2 {
+		octets = append(octets, s[k:k+2])
+	}
+	return strings.Join(octets, " ")
+}
+
+// 変わったフレームの異なるバイト
+func changedBytes(reference, current FrameRecord) string {
+	a, _ := hex.DecodeString(reference.Bytes)
+	b, _ := hex.DecodeString(current.Bytes)
+	changes := []string{}
+	if len(a) != len(b) {
+		changes = append(changes, fmt.Sprintf("length %d -> %d", len(a), len(b)))
+	}
+	count := 0
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] == b[k] {
+			continue
+		}
+		if count == FrameDiffMaxBytes {
+			changes = append(changes, "…")
+			break
+		}
+		changes = append(changes, fmt.Sprintf("[%d] %02x -> %02x", k, a[k], b[k]))
+		count++
+	}
+	return strings.Join(changes, ", ")
+}
+
+// フレーム番号と通信方向
+func frameLabel(index int, r FrameRecord) string {
+	label := fmt.Sprintf("#%d", index+1)
+	if r.Direction != "" {
+		label += " " + r.Direction
+	}
+	return label
+}
+
+// 参照との差を書く
+func printFrameDiff(w io.Writer, clock Clock, referenceFile string, reference, current []FrameRecord, diffs []FrameDiff) {
+	counts := map[string]int{}
+	for _, d := range diffs {
+		counts[d.kind]++
+	}
+	fmt.Fprintf(w, "reference \"%s\": %d frames  current: %d frames\n", referenceFile, len(reference), len(current))
+	for _, d := range diffs {
+		switch d.kind {
+		case FrameAdded:
+			c := current[d.current]
+			fmt.Fprintf(w, "  + %-8s %s  %s\n", frameLabel(d.current, c), clock.format(c.Time), spacedHex(c.Bytes))
+		case FrameMissing:
+			r := reference[d.reference]
+			fmt.Fprintf(w, "  - %-8s %s  %s  (reference)\n", frameLabel(d.reference, r), r.timeText(), spacedHex(r.Bytes))
+		case FrameChanged:
+			r, c := reference[d.reference], current[d.current]
+			fmt.Fprintf(w, "  ~ %-8s %s  %s  (reference %s: %s)\n", frameLabel(d.current, c), clock.format(c.Time), changedBytes(r, c), frameLabel(d.reference, r), spacedHex(r.Bytes))
+		}
+	}
+	unchanged := len(current) - counts[FrameAdded] - counts[FrameChanged]
+	fmt.Fprintf(w, "reference diff: %d unchanged  %d added  %d missing  %d changed\n", unchanged, counts[FrameAdded], counts[FrameMissing], counts[FrameChanged])
+}
+
+// 参照のフレームの時間の表記(絶対時刻が無ければ相対時間)
+func (r FrameRecord) timeText() string {
+	if r.Timestamp != "" {
+		return r.Timestamp
+	}
+	return fmt.Sprintf("%.6fs", r.Time)
+}
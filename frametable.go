@@ -0,0 +1,69 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// UART通信のグラフの下に描くフレームの一覧表の行を作る
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 表に載せるフレームの最大数
+const FrameTableMaxRows = 32
+
+// 表に載せる1フレームの最大バイト数
+const FrameTableMaxBytes = 24
+
+// フレームの一覧表の行を作る
+// フレーミングエラー(ストップビットが0)はフレームの時間内に有るものを数える
+func frameTableRows(frames []UartFrame, bits []UartBit, clock Clock, crcKind string) []chart.FrameTableRow {
+	rows := []chart.FrameTableRow{}
+	for i, f := range frames {
+		if i == FrameTableMaxRows {
+			rows = append(rows, chart.FrameTableRow{Index: "…", Octets: fmt.Sprintf("他 %d フレーム", len(frames)-i)})
+			break
+		}
+		octets := []string{}
+		for k, c := range f.codes {
+			if k == FrameTableMaxBytes {
+				octets = append(octets, "…")
+				break
+			}
+			octets = append(octets, fmt.Sprintf("%02x", c.octet))
+		}
+		status := []string{}
+		if ok, checked := checkFrameCrc(f, crcKind); checked && !ok {
+			status = append(status, "CRC NG")
+		}
+		framingErrors := 0
+		for _, b := range bits {
+			if b.state == "X" && b.startTime >= f.startTime && b.startTime <= f.endTime {
+				framingErrors++
+			}
+		}
+		if framingErrors > 0 {
+			status = append(status, fmt.Sprintf("framing error x%d", framingErrors))
+		}
+		if parityErrors := f.parityErrors(); parityErrors > 0 {
+			status = append(status, fmt.Sprintf("parity error x%d", parityErrors))
+		}
+		row := chart.FrameTableRow{
+			Index:  fmt.Sprintf("#%d", i+1),
+			Time:   clock.format(f.startTime),
+			Octets: strings.Join(octets, " "),
+			Status: "OK",
+			Failed: len(status) != 0,
+		}
+		if f.direction != "" {
+			row.Index += " " + f.direction
+		}
+		if row.Failed {
+			row.Status = strings.Join(status, ", ")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
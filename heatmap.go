@@ -0,0 +1,111 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号したバイト値の時間変化を示すヒートマップ
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// ヒートマップの1区間の幅(ポイント)
+const HeatmapBinWidth = 4
+
+// 時間の区間ごとのバイト値の出現回数
+type ByteHeatmap struct {
+	start  float64        // 最初の区間の開始時間
+	width  float64        // 区間の幅(s)
+	counts [][256]float64 // 区間ごとのバイト値の出現回数
+	peak   float64        // 最も多い出現回数
+}
+
+// フレームのバイト値を時間の区間ごとに数える
+func countByteValues(frames []UartFrame, start float64, end float64, bins int) ByteHeatmap {
+	bins = max(bins, 1)
+	h := ByteHeatmap{start: start, width: (end - start) / float64(bins), counts: make([][256]float64, bins)}
+	if h.width <= 0 {
+		h.width = 1
+	}
+	for _, f := range frames {
+		for _, code := range f.codes {
+			i := int((code.startTime - start) / h.width)
+			i = max(0, min(i, bins-1))
+			h.counts[i][code.octet]++
+			h.peak = math.Max(h.peak, h.counts[i][code.octet])
+		}
+	}
+	return h
+}
+
+// plotter.GridXYZ
+func (h ByteHeatmap) Dims() (int, int) { return len(h.counts), 256 }
+func (h ByteHeatmap) X(c int) float64  { return h.start + (float64(c)+0.5)*h.width }
+func (h ByteHeatmap) Y(r int) float64  { return float64(r) }
+
+// 出現しなかったバイト値は背景を見せる
+func (h ByteHeatmap) Z(c int, r int) float64 {
+	if h.counts[c][r] == 0 {
+		return math.NaN()
+	}
+	return h.counts[c][r]
+}
+
+// バイト値のヒートマップのグラフを保存する
+func saveByteHeatmap(savefilepath string, graphWidth int, graphHeight int, option chart.Option, heatmap ByteHeatmap) error {
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// 横軸を絶対時刻で表示する
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+
+	// 縦軸は16進数で表示する
+	ticks := []plot.Tick{}
+	for v := 0; v <= 0x100; v += 0x20 {
+		ticks = append(ticks, plot.Tick{Value: float64(min(v, 0xFF)), Label: fmt.Sprintf("0x%02X", min(v, 0xFF))})
+	}
+	p.Y.Tick.Marker = plot.ConstantTicks(ticks)
+
+	hm := plotter.NewHeatMap(heatmap, palette.Heat(16, 1))
+	hm.Min = 0
+	hm.Max = math.Max(heatmap.peak, 1)
+	hm.Rasterized = true
+	p.Add(hm)
+
+	// 外部イベントを縦線で示す
+	if err := chart.AddEventMarkers(p, option.Events); err != nil {
+		return err
+	}
+
+	// 横軸の単位を表示範囲に合わせる
+	chart.ScaleTimeAxis(p, option)
+
+	// プロットを画像ファイルに保存
+	if err := p.Save(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)), savefilepath); err != nil {
+		slog.Error("Save", "err", err)
+		return err
+	}
+	// 来歴を埋め込む
+	if err := embedPngFileTexts(savefilepath, option.PngTexts); err != nil {
+		return err
+	}
+
+	return nil
+}
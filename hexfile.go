@@ -0,0 +1,174 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ペイロードをIntel HEXかMotorola S-recordで書き出す(バスで送られたファームウェアの取り出し)
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HEXファイルの形式
+const (
+	HexIntel   = "ihex"
+	HexSRecord = "srec"
+)
+
+// 1つのレコードのデータの最大バイト数
+const HexRecordBytes = 16
+
+// アドレスを付けたペイロード
+type HexSegment struct {
+	address uint32
+	data    []byte
+}
+
+// 拡張子からHEXファイルの形式を決める
+func hexFormat(savefilepath string) (string, error) {
+	switch strings.ToLower(filepath.Ext(savefilepath)) {
+	case ".hex", ".ihex", ".ihx":
+		return HexIntel, nil
+	case ".srec", ".s19", ".s28", ".s37", ".mot":
+		return HexSRecord, nil
+	default:
+		return "", fmt.Errorf("HEXファイルの拡張子 \"%s\" には対応していない(.hex, .srec)", filepath.Ext(savefilepath))
+	}
+}
+
+// フレームのペイロードにアドレスを付ける
+// addressがnilの場合はペイロードをつなげたバイト位置をアドレスにする
+// skipはペイロードの先頭から除くバイト数(アドレスなどのプロトコルの欄)
+func hexSegments(frames []UartFrame, crcKind string, address FrameExpr, addressByte int, skip int) ([]HexSegment, error) {
+	segments := []HexSegment{}
+	offset := 0
+	for i, f := range frames {
+		payload := framePayload(f, crcKind)
+		if skip >= len(payload) {
+			continue
+		}
+		payload = payload[skip:]
+		at := offset
+		if address != nil {
+			at = address(f, addressByte)
+		}
+		if at < 0 || int64(at)+int64(len(payload)) > 1<<32 {
+			return nil, fmt.Errorf("フレーム#%dのアドレス %d は範囲外", i+1, at)
+		}
+		segments = append(segments, HexSegment{address: uint32(at), data: payload})
+		offset += len(payload)
+	}
+	return segments, nil
+}
+
+// バイトの和の下位8ビット
+func byteSum(octets []byte) byte {
+	var sum byte
+	for _, v := range octets {
+		sum += v
+	}
+	return sum
+}
+
+// Intel HEXの1レコード
+func writeIntelRecord(w io.Writer, address uint16, recordType byte, data []byte) {
+	record := append([]byte{byte(len(data)), byte(address >> 8), byte(address), recordType}, data...)
+	// 検査和はバイトの和の2の補数
+	fmt.Fprintf(w, ":%X%02X\n", record, -byteSum(record))
+}
+
+// Intel HEXで書く
+// 64KiBを超えるアドレスは拡張リニアアドレスレコード(04)で上位16ビットを指定する
+func writeIntelHex(w io.Writer, segments []HexSegment) {
+	upper := uint32(0)
+	for _, s := range segments {
+		for i := 0; i < len(s.data); {
+			address := s.address + uint32(i)
+			if address>>16 != upper {
+				upper = address >> 16
+				writeIntelRecord(w, 0, 0x04, []byte{byte(upper >> 8), byte(upper)})
+			}
+			// レコードは64KiBの境界をまたがない
+			n := min(HexRecordBytes, len(s.data)-i, int(0x10000-address&0xFFFF))
+			writeIntelRecord(w, uint16(address), 0x00, s.data[i:i+n])
+			i += n
+		}
+	}
+	writeIntelRecord(w, 0, 0x01, nil)
+}
+
+// S-recordの1レコード
+func writeSRecord(w io.Writer, recordType int, addressBytes int, address uint32, data []byte) {
+	record := []byte{byte(addressBytes + len(data) + 1)}
+	for k := addressBytes - 1; k >= 0; k-- {
+		record = append(record, byte(address>>(8*k)))
+	}
+	record = append(record, data...)
+	// 検査和はバイトの和の1の補数
+	fmt.Fprintf(w, "S%d%X%02X\n", recordType, record, ^byteSum(record))
+}
+
+// Motorola S-recordで書く
+// アドレスの大きさに合わせてS1(16ビット), S2(24ビット), S3(32ビット)を使う
+func writeSRecords(w io.Writer, segments []HexSegment) {
+	last := uint32(0)
+	count := 0
+	for _, s := range segments {
+		if len(s.data) > 0 {
+			last = max(last, s.address+uint32(len(s.data))-1)
+		}
+	}
+	dataType, endType, addressBytes := 1, 9, 2
+	switch {
+	case last > 0xFFFFFF:
+		dataType, endType, addressBytes = 3, 7, 4
+	case last > 0xFFFF:
+		dataType, endType, addressBytes = 2, 8, 3
+	}
+
+	writeSRecord(w, 0, 2, 0, []byte("pulseinsight"))
+	for _, s := range segments {
+		for i := 0; i < len(s.data); i += HexRecordBytes {
+			n := min(HexRecordBytes, len(s.data)-i)
+			writeSRecord(w, dataType, addressBytes, s.address+uint32(i), s.data[i:i+n])
+			count++
+		}
+	}
+	if count <= 0xFFFF {
+		writeSRecord(w, 5, 2, uint32(count), nil)
+	}
+	writeSRecord(w, endType, addressBytes, 0, nil)
+}
+
+// ペイロードをHEXファイルに保存する
+func saveHexFile(savefilepath string, segments []HexSegment) error {
+	format, err := hexFormat(savefilepath)
+	if err != nil {
+		slog.Error("hexFormat", "err", err)
+		return err
+	}
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	switch format {
+	case HexIntel:
+		writeIntelHex(w, segments)
+	case HexSRecord:
+		writeSRecords(w, segments)
+	}
+	if err := w.Flush(); err != nil {
+		slog.Error("Flush", "err", err)
+		return err
+	}
+	return nil
+}
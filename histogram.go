@@ -0,0 +1,83 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// バイト間とフレーム間の無通信時間のヒストグラム
+package main
+
+import (
+	"log/slog"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// ヒストグラムの階級数
+const HistogramBins = 50
+
+// フレーム内で連続するバイト間の無通信時間(s)
+func interByteGaps(frames []UartFrame) []float64 {
+	gaps := []float64{}
+	for _, f := range frames {
+		for i := 1; i < len(f.codes); i++ {
+			gaps = append(gaps, f.codes[i].startTime-f.codes[i-1].endTime)
+		}
+	}
+	return gaps
+}
+
+// 連続するフレーム間の無通信時間(s)
+func interFrameGaps(frames []UartFrame) []float64 {
+	gaps := []float64{}
+	for i := 1; i < len(frames); i++ {
+		gaps = append(gaps, frames[i].startTime-frames[i-1].endTime)
+	}
+	return gaps
+}
+
+// 無通信時間のヒストグラムを保存する
+func saveGapHistogram(savefilepath string, graphWidth int, graphHeight int, option chart.Option, gaps []float64) error {
+	if len(gaps) == 0 {
+		slog.Warn("no gaps", "file", savefilepath)
+		return nil
+	}
+
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// ミリ秒単位にする
+	values := make(plotter.Values, len(gaps))
+	for i, v := range gaps {
+		values[i] = v * 1e3
+	}
+
+	hist, err := plotter.NewHist(values, HistogramBins)
+	if err != nil {
+		slog.Error("NewHist", "err", err)
+		return err
+	}
+	hist.FillColor = colornames.Steelblue
+	hist.LineStyle.Color = colornames.Darkblue
+	p.Add(hist)
+
+	// プロットを画像ファイルに保存
+	if err := p.Save(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)), savefilepath); err != nil {
+		slog.Error("Save", "err", err)
+		return err
+	}
+	// 来歴を埋め込む
+	if err := embedPngFileTexts(savefilepath, option.PngTexts); err != nil {
+		return err
+	}
+
+	return nil
+}
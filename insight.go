@@ -0,0 +1,382 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 通常の解析(測定値の全体を行列にする)だけで作る報告と出力ファイル
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 測定値の全体を復号した結果
+// 半二重の場合はrxで始まるものはnil
+type DecodedCapture struct {
+	clock      Clock
+	matrix     *mat.Dense // 入力の行列(全二重はTX対)
+	rxMatrix   *mat.Dense // 入力の行列(全二重のRX対)
+	filtered   mat.Matrix // フィルタ後の行列
+	rxFiltered mat.Matrix
+	originTime float64
+	baudrate   int
+	bits       []UartBit // 両方向のビット
+	txBits     []UartBit
+	rxBits     []UartBit
+	codes      []UartCode
+	frames     []UartFrame // 報告に使う(--whereで絞り込んだ)フレーム
+	runts      []RuntPulse
+	rxRunts    []RuntPulse
+}
+
+// 全二重か
+func (c DecodedCapture) duplex() bool {
+	return c.rxMatrix != nil
+}
+
+// 入力の波形のグラフ(A,B線, 3段, A-B間電圧差)とタイル画像ピラミッドの保存を頼む
+// prefixは出力ファイルの基本名(入力ファイルの拡張子付き)
+func saveInputCharts(w io.Writer, plots *PlotStage, prefix string, charts ChartSelection, chartOption chart.Option, matrix *mat.Dense, rxMatrix *mat.Dense, option InsightOption) {
+	graphWidth, graphHeight := option.graphWidth, option.graphHeight
+	if charts[ChartRaw] {
+		plots.saveChart(prefix+"_voltage.png", ChartRaw, graphWidth, graphHeight, chartOption, matrix)
+	}
+	if charts[ChartStacked] {
+		stackedOption := chartOption
+		stackedOption.Title = "A,B線とA-B間電圧差の時間変化"
+		// 3段に分けるので高さを3倍にする
+		plots.saveChart(prefix+"_stacked.png", ChartStacked, graphWidth, graphHeight*3, stackedOption, matrix)
+	}
+	if charts[ChartDiff] {
+		diffOption := chartOption
+		diffOption.Title = "A-B間電圧差の時間変化"
+		plots.saveChart(prefix+"_diff.png", ChartDiff, graphWidth, graphHeight, diffOption, matrix)
+	}
+
+	// 拡大縮小して見るためのタイル画像ピラミッド
+	if option.tileWidth > 0 && charts.any() {
+		traces := []TileTrace{
+			{matrix, ColWireA, "A線", colornames.Darkmagenta},
+			{matrix, ColWireB, "B線", colornames.Darkcyan},
+		}
+		if hasDriverEnable(matrix) {
+			traces = append(traces, TileTrace{matrix, ColDriverEnable, "DE", colornames.Goldenrod})
+		}
+		if rxMatrix != nil {
+			traces = append(traces,
+				TileTrace{rxMatrix, ColWireA, "RX A線", colornames.Orangered},
+				TileTrace{rxMatrix, ColWireB, "RX B線", colornames.Royalblue})
+		}
+		tilesDir := prefix + "_tiles"
+		plots.saveTilePyramid(tilesDir, traces, option.tileWidth, graphHeight, option.provenance)
+		fmt.Fprintf(w, "tiles \"%s\"\n", filepath.Join(tilesDir, "index.html"))
+	}
+}
+
+// 整形したパルスを復号する
+// 全二重の場合はRX対も復号して, 文字を時間順に合わせる
+func decodeReshaped(reshaped, rxReshaped mat.Matrix, option InsightOption) (txBits []UartBit, rxBits []UartBit, codes []UartCode, err error) {
+	txBits, codes, err = analyzePulses(reshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return nil, nil, nil, err
+	}
+	if rxReshaped == nil {
+		return txBits, nil, codes, nil
+	}
+	rxBits, rxCodes, err := analyzePulses(rxReshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return nil, nil, nil, err
+	}
+	return txBits, rxBits, mergeUartCodes(codes, rxCodes), nil
+}
+
+// 雑音の床で0にした行と, 送信の途中で始まった取り込みの読み飛ばしを表示する
+func reportNoiseGateAndResync(w io.Writer, c DecodedCapture, option InsightOption) []AnomalyEvent {
+	if txGate, rxGate := findNoiseGates(c.matrix, c.rxMatrix, c.filtered, c.rxFiltered, option); rxGate != nil {
+		printNoiseGate(w, " "+DirectionTx, txGate)
+		printNoiseGate(w, " "+DirectionRx, *rxGate)
+	} else {
+		printNoiseGate(w, "", txGate)
+	}
+	txResync, rxResync := findResyncs(c.matrix, c.rxMatrix, c.filtered, c.rxFiltered, option)
+	resyncs := resyncAnomalies(txResync, c.originTime)
+	if rxResync != nil {
+		printResync(w, c.clock, " "+DirectionTx, txResync)
+		printResync(w, c.clock, " "+DirectionRx, *rxResync)
+		resyncs = append(resyncs, resyncAnomalies(*rxResync, c.originTime)...)
+	} else {
+		printResync(w, c.clock, "", txResync)
+	}
+	return resyncs
+}
+
+// 要求から応答までのターンアラウンドを表示する
+// 全二重の場合はDE列を見ない
+func reportTurnaround(w io.Writer, c DecodedCapture, allFrames []UartFrame, option InsightOption) []AnomalyEvent {
+	minTurnaround := option.minTurnaround
+	if minTurnaround == 0 {
+		minTurnaround = 3.5 * option.format.charTime(c.baudrate)
+	}
+	var turnarounds []Turnaround
+	if c.duplex() {
+		turnarounds = analyzeTurnaround(nil, c.originTime, allFrames, minTurnaround)
+	} else {
+		turnarounds = analyzeTurnaround(c.matrix, c.originTime, allFrames, minTurnaround)
+	}
+	printTurnaround(w, c.clock, allFrames, turnarounds)
+	return turnaroundAnomalies(turnarounds)
+}
+
+// 長さの狂ったビットとMarkとSpaceの長さの偏りを表示する
+func reportBitPulses(w io.Writer, c DecodedCapture, option InsightOption) []AnomalyEvent {
+	if c.duplex() {
+		txPulses := measureBitPulses(c.matrix, c.originTime, codesOfDirection(c.codes, DirectionTx), c.baudrate, option.format)
+		printBitLength(w, c.clock, " "+DirectionTx, txPulses, option.bitTolerance)
+		rxPulses := measureBitPulses(c.rxMatrix, c.originTime, codesOfDirection(c.codes, DirectionRx), c.baudrate, option.format)
+		printBitLength(w, c.clock, " "+DirectionRx, rxPulses, option.bitTolerance)
+		printDutyAsymmetry(w, " "+DirectionTx, measureDutyAsymmetry(txPulses), c.baudrate)
+		printDutyAsymmetry(w, " "+DirectionRx, measureDutyAsymmetry(rxPulses), c.baudrate)
+		return append(bitLengthAnomalies(txPulses, option.bitTolerance), bitLengthAnomalies(rxPulses, option.bitTolerance)...)
+	}
+	pulses := measureBitPulses(c.matrix, c.originTime, c.codes, c.baudrate, option.format)
+	printBitLength(w, c.clock, "", pulses, option.bitTolerance)
+	printDutyAsymmetry(w, "", measureDutyAsymmetry(pulses), c.baudrate)
+	return bitLengthAnomalies(pulses, option.bitTolerance)
+}
+
+// ラントを表示する
+func reportRunts(w io.Writer, c DecodedCapture) []AnomalyEvent {
+	if c.duplex() {
+		printRunts(w, c.clock, " "+DirectionTx, c.runts)
+		printRunts(w, c.clock, " "+DirectionRx, c.rxRunts)
+	} else {
+		printRunts(w, c.clock, "", c.runts)
+	}
+	return runtAnomalies(append(append([]RuntPulse{}, c.runts...), c.rxRunts...), c.originTime)
+}
+
+// ビットの波形から, 1ビットの訂正, ビット毎の軟判定, 機械学習向けのビット毎の特徴量を作る
+func reportBitWaveforms(w io.Writer, c DecodedCapture, option InsightOption) ([]AnomalyEvent, error) {
+	if !option.correct && option.softBitsFile == "" && option.bitFeaturesFile == "" {
+		return nil, nil
+	}
+	// 通信方向毎(半二重は空文字列)の解析したビット列と波形
+	directions := []string{""}
+	sources := map[string]BitWaveform{}
+	txSource, rxSource := mat.Matrix(c.matrix), mat.Matrix(c.rxMatrix)
+	if option.decodeFilter {
+		txSource, rxSource = c.filtered, c.rxFiltered
+	}
+	if c.duplex() {
+		directions = []string{DirectionTx, DirectionRx}
+		sources[DirectionTx] = newBitWaveform(c.txBits, txSource, c.originTime, c.baudrate, option.format)
+		sources[DirectionRx] = newBitWaveform(c.rxBits, rxSource, c.originTime, c.baudrate, option.format)
+	} else {
+		sources[""] = newBitWaveform(c.txBits, txSource, c.originTime, c.baudrate, option.format)
+	}
+
+	anomalies := []AnomalyEvent{}
+	// ストップビットや誤り検出符号が合わない文字やフレームを1ビットの訂正で直してみる
+	if option.correct {
+		corrections := []Correction{}
+		for _, direction := range directions {
+			corrections = append(corrections, correctFramingErrors(sources[direction], direction)...)
+		}
+		corrections = append(corrections, correctCrcErrors(c.frames, sources, option.crcKind)...)
+		sort.SliceStable(corrections, func(i, j int) bool {
+			return corrections[i].startTime < corrections[j].startTime
+		})
+		printCorrections(w, c.clock, corrections)
+		anomalies = append(anomalies, correctionAnomalies(corrections)...)
+	}
+
+	// ビット毎の軟判定
+	if option.softBitsFile != "" {
+		softBits := []SoftBit{}
+		for _, direction := range directions {
+			softBits = append(softBits, softDecideBits(sources[direction], direction, option.format.idleSign())...)
+		}
+		printSoftBits(w, c.clock, softBits)
+		if err := saveSoftBits(option.softBitsFile, c.clock, softBits); err != nil {
+			slog.Error("saveSoftBits", "err", err)
+			return nil, err
+		}
+	}
+
+	// 機械学習向けのビット毎の特徴量
+	if option.bitFeaturesFile != "" {
+		features := []BitFeature{}
+		for _, direction := range directions {
+			features = append(features, bitFeatures(sources[direction], direction)...)
+		}
+		if err := saveBitFeatures(option.bitFeaturesFile, c.clock, features); err != nil {
+			slog.Error("saveBitFeatures", "err", err)
+			return nil, err
+		}
+		fmt.Fprintf(w, "bit features: %d bits \"%s\"\n", len(features), option.bitFeaturesFile)
+	}
+	return anomalies, nil
+}
+
+// ストップビット終了からドライバ無効までの最大時間(s)
+func deMaxRelease(option InsightOption, baudrate int) float64 {
+	if option.deMaxRelease == 0 {
+		return 1 / float64(baudrate)
+	}
+	return option.deMaxRelease
+}
+
+// DE列のドライバイネーブルを調べる
+func reportDriverEnable(w io.Writer, c DecodedCapture, option InsightOption) []AnomalyEvent {
+	if !hasDriverEnable(c.matrix) {
+		return nil
+	}
+	intervals := findEnableIntervals(c.matrix, c.originTime, option.deThreshold)
+	checks := checkDriverEnable(intervals, c.frames, option.deMinLead, deMaxRelease(option, c.baudrate))
+	printDriverEnable(w, c.clock, "", checks)
+	return driverEnableAnomalies(checks)
+}
+
+// バスの状態(Mark, Space, 駆動されていない無通信)を表示して, 指定したファイルに保存する
+// 半二重でDE列が無い場合は, 駆動されていた期間をドライバが有効だった期間とみなしてドライバイネーブルを調べる
+func reportBusStates(w io.Writer, c DecodedCapture, option InsightOption) ([]AnomalyEvent, error) {
+	idleBias, _ := parseIdleBias(option.idleBias)
+	busSegments := map[string][]BusSegment{}
+	anomalies := []AnomalyEvent{}
+	if c.duplex() {
+		for _, pair := range []struct {
+			direction string
+			matrix    mat.Matrix
+		}{{DirectionTx, c.matrix}, {DirectionRx, c.rxMatrix}} {
+			limit := newBusStateLimit(pair.matrix, option.drivenThreshold, idleBias, option.idleBand, c.baudrate)
+			busSegments[pair.direction] = classifyBusStates(pair.matrix, c.originTime, limit, c.baudrate)
+			printBusStates(w, " "+pair.direction, busSegments[pair.direction], limit)
+		}
+	} else {
+		limit := newBusStateLimit(c.matrix, option.drivenThreshold, idleBias, option.idleBand, c.baudrate)
+		busSegments[""] = classifyBusStates(c.matrix, c.originTime, limit, c.baudrate)
+		printBusStates(w, "", busSegments[""], limit)
+		if !hasDriverEnable(c.matrix) && hasUndriven(busSegments[""]) {
+			checks := checkDriverEnable(drivenIntervals(busSegments[""]), c.frames, option.deMinLead, deMaxRelease(option, c.baudrate))
+			printDriverEnable(w, c.clock, " (bus state)", checks)
+			anomalies = append(anomalies, driverEnableAnomalies(checks)...)
+		}
+	}
+	if option.busStateFile != "" {
+		if err := saveBusStates(option.busStateFile, c.clock, busSegments); err != nil {
+			slog.Error("saveBusStates", "err", err)
+			return nil, err
+		}
+	}
+	return anomalies, nil
+}
+
+// スルーレートを測る
+// 論理レベルの入力には遷移の傾きが無いので測らない
+func reportSlewRate(w io.Writer, c DecodedCapture, option InsightOption) []AnomalyEvent {
+	slewLimit := SlewLimit{
+		minSlew:       option.minSlew * 1e6,
+		maxSlew:       option.maxSlew * 1e6,
+		maxTransition: option.maxTransition / float64(c.baudrate),
+	}
+	switch {
+	case option.inputType == InputLogic:
+		fmt.Fprintln(w, "slew rate: skipped (logic input)")
+		return nil
+	case c.duplex():
+		txEdges := measureEdges(c.matrix)
+		checkSlewLimit(txEdges, slewLimit)
+		printSlewRate(w, c.clock, " "+DirectionTx, txEdges)
+		rxEdges := measureEdges(c.rxMatrix)
+		checkSlewLimit(rxEdges, slewLimit)
+		printSlewRate(w, c.clock, " "+DirectionRx, rxEdges)
+		return append(slewAnomalies(txEdges, c.originTime), slewAnomalies(rxEdges, c.originTime)...)
+	default:
+		edges := measureEdges(c.matrix)
+		checkSlewLimit(edges, slewLimit)
+		printSlewRate(w, c.clock, "", edges)
+		return slewAnomalies(edges, c.originTime)
+	}
+}
+
+// アイダイアグラムのマスク試験
+// 論理レベルの入力にはバスの電圧が無いので試験しない
+func reportEyeMask(w io.Writer, c DecodedCapture, eyeMask *EyeMask, option InsightOption) []AnomalyEvent {
+	switch {
+	case eyeMask == nil:
+		return nil
+	case option.inputType == InputLogic:
+		fmt.Fprintln(w, "eye mask: skipped (logic input)")
+		return nil
+	case c.duplex():
+		txResult := testEyeMask(c.matrix, c.txBits, c.originTime, c.baudrate, *eyeMask)
+		printEyeMask(w, c.clock, " "+DirectionTx, *eyeMask, txResult)
+		rxResult := testEyeMask(c.rxMatrix, c.rxBits, c.originTime, c.baudrate, *eyeMask)
+		printEyeMask(w, c.clock, " "+DirectionRx, *eyeMask, rxResult)
+		return append(eyeMaskAnomalies(txResult), eyeMaskAnomalies(rxResult)...)
+	default:
+		result := testEyeMask(c.matrix, c.txBits, c.originTime, c.baudrate, *eyeMask)
+		printEyeMask(w, c.clock, "", *eyeMask, result)
+		return eyeMaskAnomalies(result)
+	}
+}
+
+// 推奨する受信側の設定とバスの助言を表示する
+// 論理レベルの入力にはバスの電圧が無いのでバスの助言は除く
+func reportRecommendations(w io.Writer, c DecodedCapture, option InsightOption) {
+	if c.duplex() {
+		txRecommendations := recommendFor(c.matrix, c.originTime, c.txBits, codesOfDirection(c.codes, DirectionTx), c.frames, DirectionTx, c.runts, c.baudrate, option.format)
+		rxRecommendations := recommendFor(c.rxMatrix, c.originTime, c.rxBits, codesOfDirection(c.codes, DirectionRx), c.frames, DirectionRx, c.rxRunts, c.baudrate, option.format)
+		if option.inputType == InputLogic {
+			txRecommendations, rxRecommendations = withoutBusAdvice(txRecommendations), withoutBusAdvice(rxRecommendations)
+		}
+		printRecommendations(w, " "+DirectionTx, txRecommendations)
+		printRecommendations(w, " "+DirectionRx, rxRecommendations)
+		return
+	}
+	recommendations := recommendFor(c.matrix, c.originTime, c.txBits, c.codes, c.frames, "", c.runts, c.baudrate, option.format)
+	if option.inputType == InputLogic {
+		recommendations = withoutBusAdvice(recommendations)
+	}
+	printRecommendations(w, "", recommendations)
+}
+
+// 解析の説明(.meta.json)を保存する
+func saveInsightMeta(w io.Writer, metaFile string, c DecodedCapture, traffic TrafficSummary, anomalies []AnomalyEvent, earlyStopped bool, option InsightOption) error {
+	detected := detectedProperties(c.matrix, c.rxMatrix, c.baudrate, c.originTime, c.clock)
+	detected.BaudEstimated = option.estimateBaud != EstimateBaudNone
+	detected.Format = option.format.String()
+	detected.SmoothWindow = option.smoothWindow
+	detected.EarlyStopped = earlyStopped
+	detected.FramesFiltered = option.where != ""
+	if lengths := measureStopBits(c.frames, c.baudrate, "", option.format); len(lengths) != 0 {
+		detected.StopBits = estimateStopBits(lengths)
+	}
+	meta := SessionMeta{
+		Provenance: option.provenance,
+		Detected:   detected,
+		Summary:    summarizeSession(traffic, c.bits, c.codes, anomalies),
+	}
+	if option.inputType != InputLogic {
+		codes := c.codes
+		if c.duplex() {
+			codes = codesOfDirection(c.codes, DirectionTx)
+		}
+		measureSignalSummary(&meta.Summary, c.matrix, c.originTime, codes, c.baudrate)
+	}
+	if err := saveSessionMeta(metaFile, meta); err != nil {
+		slog.Error("saveSessionMeta", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "meta \"%s\"\n", metaFile)
+	return nil
+}
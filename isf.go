@@ -0,0 +1,243 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// Tektronixのオシロスコープが保存した波形ファイル(.isf, 1ファイル1チャンネル)の読み込み
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Tektronixの波形ファイルの拡張子
+const IsfExt = ".isf"
+
+// 波形の前文(WFMPRE)の項目
+// ファイルには短い形(BYT_Nなど)で書かれる場合がある
+var isfKeys = []string{"BYT_NR", "BIT_NR", "ENCDG", "BN_FMT", "BYT_OR", "NR_PT", "PT_FMT", "XINCR", "PT_OFF", "XZERO", "XUNIT", "YMULT", "YZERO", "YOFF", "YUNIT", "WFID"}
+
+// 1チャンネルの波形
+type IsfWaveform struct {
+	label  string    // 波形の説明(WFID)
+	xIncr  float64   // 点の間隔(s)
+	xZero  float64   // 基準点の時間(s)
+	ptOff  float64   // 基準点
+	values []float64 // 電圧(V)
+}
+
+// i番目の点の時間
+func (w IsfWaveform) time(i int) float64 {
+	return w.xZero + w.xIncr*(float64(i)-w.ptOff)
+}
+
+// 前文の項目の名前を長い形にする(短い形は長い形の先頭3文字以上)
+func isfKey(name string) string {
+	name = strings.ToUpper(name)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, key := range isfKeys {
+		if name == key || (len(name) >= 3 && strings.HasPrefix(key, name)) {
+			return key
+		}
+	}
+	return name
+}
+
+// 前文(":WFMPRE:BYT_NR 2;BIT_NR 16;...")を項目の名前と値にする
+func parseIsfPreamble(text string) map[string]string {
+	preamble := map[string]string{}
+	for _, item := range strings.Split(text, ";") {
+		item = strings.TrimSpace(item)
+		name, value, _ := strings.Cut(item, " ")
+		if name == "" {
+			continue
+		}
+		preamble[isfKey(name)] = strings.Trim(strings.TrimSpace(value), "\"")
+	}
+	return preamble
+}
+
+// 前文の数値
+func isfNumber(preamble map[string]string, key string, fallback float64) (float64, error) {
+	text, ok := preamble[key]
+	if !ok {
+		return fallback, nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("波形の前文の%s \"%s\" を読めない", key, text)
+	}
+	return v, nil
+}
+
+// Tektronixの波形ファイルを読む
+func loadIsfWaveform(filePath string) (IsfWaveform, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return IsfWaveform{}, err
+	}
+	// 前文と":CURVE "に続く波形(2進数はブロック#NLLL..., ASCIIはカンマ区切り)
+	upper := bytes.ToUpper(content[:min(len(content), 4096)])
+	curve := bytes.Index(upper, []byte(":CURV"))
+	space := bytes.IndexByte(upper[max(curve, 0):], ' ')
+	if curve < 0 || space < 0 {
+		return IsfWaveform{}, fmt.Errorf("\"%s\" はTektronixの波形ファイルではない(:CURVEがない)", filePath)
+	}
+	preamble := parseIsfPreamble(string(content[:curve]))
+	block := content[curve+space+1:]
+
+	w := IsfWaveform{label: preamble["WFID"]}
+	var yMult, yZero, yOff, points float64
+	for _, field := range []struct {
+		v        *float64
+		key      string
+		fallback float64
+	}{
+		{&w.xIncr, "XINCR", math.NaN()}, {&w.xZero, "XZERO", 0}, {&w.ptOff, "PT_OFF", 0},
+		{&yMult, "YMULT", math.NaN()}, {&yZero, "YZERO", 0}, {&yOff, "YOFF", 0}, {&points, "NR_PT", -1},
+	} {
+		if *field.v, err = isfNumber(preamble, field.key, field.fallback); err != nil {
+			return IsfWaveform{}, err
+		}
+		if math.IsNaN(*field.v) {
+			return IsfWaveform{}, fmt.Errorf("\"%s\" の波形の前文に%sがない", filePath, field.key)
+		}
+	}
+	if w.xIncr <= 0 {
+		return IsfWaveform{}, fmt.Errorf("\"%s\" の点の間隔が%gs", filePath, w.xIncr)
+	}
+	if format := strings.ToUpper(preamble["PT_FMT"]); format != "" && !strings.HasPrefix(format, "Y") {
+		return IsfWaveform{}, fmt.Errorf("\"%s\" の点の形式%sには対応していない(Yだけ)", filePath, format)
+	}
+
+	var raw []float64
+	if len(block) < 2 || block[0] != '#' {
+		raw, err = parseScpiAscii(bytes.TrimRight(block, " \r\n;"))
+	} else {
+		raw, err = parseIsfBinary(block, preamble)
+	}
+	if err != nil {
+		return IsfWaveform{}, fmt.Errorf("\"%s\": %w", filePath, err)
+	}
+	if points >= 0 && int(points) < len(raw) {
+		raw = raw[:int(points)]
+	}
+	if len(raw) == 0 {
+		return IsfWaveform{}, fmt.Errorf("\"%s\" に測定値がない", filePath)
+	}
+	w.values = make([]float64, len(raw))
+	for i, v := range raw {
+		w.values[i] = (v-yOff)*yMult + yZero
+	}
+	return w, nil
+}
+
+// IEEE 488.2のブロック(#NLLL...)の2進数の波形を読む
+func parseIsfBinary(block []byte, preamble map[string]string) ([]float64, error) {
+	digits := int(block[1] - '0')
+	if digits < 1 || digits > 9 || len(block) < 2+digits {
+		return nil, fmt.Errorf("波形のブロックの長さを読めない")
+	}
+	length, err := strconv.Atoi(string(block[2 : 2+digits]))
+	if err != nil {
+		return nil, fmt.Errorf("波形のブロックの長さ \"%s\" を読めない", block[2:2+digits])
+	}
+	data := block[2+digits:]
+	if length < len(data) {
+		data = data[:length]
+	}
+	width, err := isfNumber(preamble, "BYT_NR", 1)
+	if err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if strings.HasPrefix(strings.ToUpper(preamble["BYT_OR"]), "LSB") {
+		order = binary.LittleEndian
+	}
+	format := strings.ToUpper(preamble["BN_FMT"])
+	if format == "" {
+		format = "RI"
+	}
+	n := int(width)
+	values := make([]float64, 0, len(data)/max(n, 1))
+	for i := 0; i+n <= len(data); i += n {
+		b := data[i : i+n]
+		switch {
+		case format == "FP" && n == 4:
+			values = append(values, float64(math.Float32frombits(order.Uint32(b))))
+		case format == "RI" && n == 1:
+			values = append(values, float64(int8(b[0])))
+		case format == "RI" && n == 2:
+			values = append(values, float64(int16(order.Uint16(b))))
+		case format == "RI" && n == 4:
+			values = append(values, float64(int32(order.Uint32(b))))
+		case format == "RP" && n == 1:
+			values = append(values, float64(b[0]))
+		case format == "RP" && n == 2:
+			values = append(values, float64(order.Uint16(b)))
+		case format == "RP" && n == 4:
+			values = append(values, float64(order.Uint32(b)))
+		default:
+			return nil, fmt.Errorf("波形の形式%s, %dバイトには対応していない", format, n)
+		}
+	}
+	return values, nil
+}
+
+// B線の波形ファイル
+// 指定しない場合はA線のファイルの名前のCH1をCH2にしたファイル(TEK0000CH1.isf → TEK0000CH2.isf)
+func isfPairFile(filePath string, bFile string) (string, error) {
+	if bFile != "" {
+		return bFile, nil
+	}
+	dir, base := filepath.Split(filePath)
+	i := strings.LastIndex(strings.ToUpper(base), "CH1")
+	if i < 0 {
+		return "", fmt.Errorf("B線の波形ファイルを--b-fileで指定する(\"%s\" の名前にCH1がない)", filePath)
+	}
+	return filepath.Join(dir, base[:i]+base[i:i+2]+"2"+base[i+3:]), nil
+}
+
+// A線とB線の波形ファイルを読み込んで, 時間(s), A線電圧(V), B線電圧(V)の行列にする
+// 時間は前文の点の間隔と基準点の時間から決める
+func loadIsfFiles(filePath string, bFile string) (*mat.Dense, [][]string, error) {
+	bPath, err := isfPairFile(filePath, bFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := loadIsfWaveform(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := loadIsfWaveform(bPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if math.Abs(a.xIncr-b.xIncr) > a.xIncr*1e-6 || math.Abs(a.time(0)-b.time(0)) > a.xIncr/2 {
+		return nil, nil, fmt.Errorf("\"%s\" と \"%s\" の時間軸が違う(同じ取り込みのA線とB線を指定する)", filePath, bPath)
+	}
+	rows := min(len(a.values), len(b.values))
+	data := make([]float64, 0, 3*rows)
+	for i := 0; i < rows; i++ {
+		data = append(data, a.time(i), a.values[i], b.values[i])
+	}
+	header := [][]string{{"Time", isfLabel(a.label, filePath), isfLabel(b.label, bPath)}, {"s", "V", "V"}}
+	return mat.NewDense(rows, 3, data), header, nil
+}
+
+// 波形の説明("Ch1, DC coupling, ...")の最初の欄, 無い場合はファイル名
+func isfLabel(label string, filePath string) string {
+	if first, _, _ := strings.Cut(label, ","); strings.TrimSpace(first) != "" {
+		return strings.TrimSpace(first)
+	}
+	return filepath.Base(filePath)
+}
@@ -0,0 +1,77 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 読み込みながら復号して、フレームを見つけ次第表示する(長いファイルの解析の途中経過)
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// 読み込みながら復号する
+// 復号の仕方は追従モード(--follow)と同じで, バスがフレーム間隔だけ無通信になる度にそれまでの行を復号する
+type LiveDecoder struct {
+	w        io.Writer
+	option   InsightOption
+	clock    Clock
+	buffer   FollowBuffer
+	started  bool // 最初の塊を受け取った
+	checked  bool // 全二重かどうかを調べた
+	disabled bool // 全二重なので途中経過を出せない
+}
+
+// 読み込みながら復号する
+func newLiveDecoder(w io.Writer, csvfilepath string, option InsightOption) *LiveDecoder {
+	fmt.Fprintf(w, "live frames \"%s\" (見つけ次第表示する)\n", csvfilepath)
+	return &LiveDecoder{w: w, option: option}
+}
+
+// 読み込んだ塊の行を加えて, 終わったフレームを表示する
+func (d *LiveDecoder) feed(header [][]string, data []float64, cols int) error {
+	if d.disabled {
+		return nil
+	}
+	if !d.started {
+		d.started = true
+		d.buffer.header = header
+		d.buffer.cols = cols
+		if d.option.t0 != "" {
+			t0, err := parseT0(d.option.t0)
+			if err != nil {
+				slog.Error("parseT0", "err", err)
+				return err
+			}
+			d.clock = Clock{t0: t0, absolute: true}
+		} else {
+			d.clock.t0, d.clock.absolute = findHeaderTime(header)
+		}
+	}
+	d.buffer.data = append(d.buffer.data, data...)
+	if d.buffer.rows() < 2 {
+		return nil
+	}
+	if !d.checked {
+		d.checked = true
+		if matrix, err := d.buffer.matrix(d.option); err == nil && isDuplex(matrix) {
+			slog.Warn("live frames are not available for full duplex")
+			d.disabled = true
+			d.buffer.data = nil
+			return nil
+		}
+	}
+	return d.buffer.decode(d.w, d.clock, d.option, false)
+}
+
+// 残りの行を復号して表示する
+func (d *LiveDecoder) finish() error {
+	if d.disabled {
+		return nil
+	}
+	if err := d.buffer.decode(d.w, d.clock, d.option, true); err != nil {
+		return err
+	}
+	fmt.Fprintln(d.w, "live frames done")
+	return nil
+}
@@ -0,0 +1,89 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ロジックアナライザが書き出した論理レベル(0/1)のCSVをA線, B線の理想的な電圧に置き換えて, しきい値の段を経ずにビットの時間を解析する
+package main
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 入力CSVの値の種類
+const (
+	InputAnalog = "analog" // A線, B線の電圧
+	InputLogic  = "logic"  // UARTの信号線の論理レベル(0/1, 1がMark)
+)
+
+// 論理レベルを置き換えるA-B間電圧差(V)
+// A線とB線に半分ずつ振り分け, しきい値から十分に離す
+const LogicDifferential = 2.0
+
+// 変化した所だけを書き出したCSVを補う1ビットあたりのサンプル数
+const LogicOversample = 32
+
+// 論理レベルの列(時間, 信号線[, 信号線])をA線, B線の列(時間, A, B[, RX A, RX B])に置き換える
+// 信号線が2本の場合は1本目を送信対, 2本目を受信対とする
+func logicToDifferential(matrix mat.Matrix, invert [2]bool) (*mat.Dense, error) {
+	rows, cols := matrix.Dims()
+	channels := cols - 1
+	if channels != 1 && channels != 2 {
+		return nil, fmt.Errorf("論理レベルの信号線 %d 本には対応していない(1本か2本)", channels)
+	}
+	converted := mat.NewDense(rows, 1+2*channels, nil)
+	for r := 0; r < rows; r++ {
+		converted.Set(r, ColTime, matrix.At(r, ColTime))
+		for ch := 0; ch < channels; ch++ {
+			v := matrix.At(r, 1+ch)
+			if v != 0 && v != 1 {
+				return nil, fmt.Errorf("論理レベルの列 %d のデータの %d 行目の値 %g が0か1ではない(--input-type logic)", 1+ch, r+1, v)
+			}
+			if invert[ch] {
+				v = 1 - v
+			}
+			// 1(Mark)はA線が高い
+			level := (2*v - 1) * LogicDifferential / 2
+			converted.Set(r, 1+2*ch, level)
+			converted.Set(r, 2+2*ch, -level)
+		}
+	}
+	return converted, nil
+}
+
+// 変化した所だけを書き出したCSVを一定の間隔のサンプルにする
+// サンプリング間隔がintervalより短い場合はそのまま返す
+func resampleLogic(matrix *mat.Dense, interval float64) *mat.Dense {
+	rows, cols := matrix.Dims()
+	if rows < 2 || sampleInterval(matrix) <= interval {
+		return matrix
+	}
+	start, end := matrix.At(0, ColTime), matrix.At(rows-1, ColTime)
+	samples := int((end-start)/interval) + 1
+	resampled := mat.NewDense(samples, cols, nil)
+	r := 0
+	for i := 0; i < samples; i++ {
+		t := start + float64(i)*interval
+		// 時間tまでに最後に変化した行の値を保つ
+		for r+1 < rows && matrix.At(r+1, ColTime) <= t {
+			r++
+		}
+		resampled.Set(i, ColTime, t)
+		for c := 1; c < cols; c++ {
+			resampled.Set(i, c, matrix.At(r, c))
+		}
+	}
+	return resampled
+}
+
+// バスの電圧に関わる助言(バイアス, 終端)を除く
+// 論理レベルの入力には電圧が無いので助言できない
+func withoutBusAdvice(recommendations []Recommendation) []Recommendation {
+	selected := []Recommendation{}
+	for _, r := range recommendations {
+		if r.name != "bias" && r.name != "termination" {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
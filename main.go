@@ -6,29 +6,27 @@
 package main
 
 import (
+	"context"
 	_ "embed"
-	"encoding/binary"
-	"encoding/csv"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"log/slog"
-	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
-	"golang.org/x/image/colornames"
 	"golang.org/x/image/font/opentype"
 	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/font"
 	"gonum.org/v1/plot/plotter"
-	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
+
+	"pulseinsight/pkg/chart"
+	"pulseinsight/pkg/uart"
+	"pulseinsight/pkg/waveform"
 )
 
 // 埋め込みIPAexフォント
@@ -39,72 +37,49 @@ var (
 )
 
 const (
-	ColTime            = 0   // 入力CSVの列1番目:時間(s)
-	ColWireA           = 1   // 入力CSVの列2番目:RS485/422バスA線電圧(V)
-	ColWireB           = 2   // 入力CSVの列3番目:RS485/422バスB線電圧(V)
-	Threshould float64 = 1.0 // 差動通信のしきい値(V)
+	ColTime                 = waveform.ColTime      // 入力CSVの列1番目:時間(s)
+	ColWireA                = waveform.ColWireA     // 入力CSVの列2番目:RS485/422バスA線電圧(V)
+	ColWireB                = waveform.ColWireB     // 入力CSVの列3番目:RS485/422バスB線電圧(V)
+	ColDriverEnable         = chart.ColDriverEnable // 半二重でDE列付きの場合、入力CSVの列4番目:ドライバイネーブル(DE/RE)信号電圧(V)
+	ColRxWireA              = 3                     // 全二重の場合、入力CSVの列4番目:RS422受信対A線電圧(V)
+	ColRxWireB              = 4                     // 全二重の場合、入力CSVの列5番目:RS422受信対B線電圧(V)
+	Threshould      float64 = waveform.Threshold    // 差動通信のしきい値(V)
 )
 
-// 行列を表示する関数
-func matPrint(X mat.Matrix) {
-	fmt.Printf("%v\n", mat.Formatted(X, mat.Prefix(""), mat.Excerpt(0)))
-}
+// 不正な行の扱い
+const (
+	BadRowsSkip  = waveform.BadRowsSkip  // 警告を出して読み飛ばす
+	BadRowsAbort = waveform.BadRowsAbort // 解析を中止する
+)
 
-// 解析対象のCSVファイルを読み込んで、行列を返す
-func loadCsv(filePath string) (*mat.Dense, error) {
-	// CSVファイルを開く
-	f, err := os.Open(filePath)
+// 解析対象のCSVファイルを読み込んで、行列と読み飛ばしたヘッダー行を返す
+// 列数は最初のデータ行に合わせ、列が足りない行や数値でない値がある行はbadRowsに従って扱う
+// ctxが終わったら読み込みを止める
+// onRowsがnilでなければ、読み込んだ塊ごとに新しく加えた行(行優先)を渡す
+func loadCsv(ctx context.Context, filePath string, option InsightOption, onRows func(header [][]string, data []float64, cols int) error) (*mat.Dense, [][]string, error) {
+	options, err := csvLoadOptions(option)
 	if err != nil {
-		slog.Error("Open", "err", err)
-		return nil, err
+		return nil, nil, err
 	}
-	defer f.Close()
-
-	// CSVリーダーを作成
-	reader := csv.NewReader(f)
-
-	// ヘッダー行と名前が書かれた行を読み飛ばす
-	var skipLines int
-	for skipLines = 0; skipLines < 2; skipLines++ {
-		if _, err := reader.Read(); err != nil {
-			slog.Error("Read", "err", err)
-			return nil, err
+	options.OnRows = onRows
+	var matrix *mat.Dense
+	var header [][]string
+	if isStdin(filePath) {
+		var r io.Reader
+		if r, err = openStdin(); err == nil {
+			matrix, header, err = waveform.LoadCSVFrom(ctx, r, options)
 		}
+	} else {
+		matrix, header, err = waveform.LoadCSV(ctx, filePath, options)
+	}
+	if ctx.Err() != nil {
+		return nil, nil, checkCanceled(ctx)
 	}
-
-	// 残りの行を読み込む
-	records, err := reader.ReadAll()
 	if err != nil {
-		slog.Error("ReadAll", "err", err)
-		return nil, err
-	}
-
-	// データを格納するスライスを作成
-	data := []float64{}
-	rows := len(records)
-	cols := len(records[0])
-
-	// CSVデータをスライスに変換
-	for r, record := range records {
-		for c, value := range record {
-			var floatValue float64
-			if value == "" {
-				slog.Warn("assigned to Zero", "row", skipLines+1+r, "column", 1+c)
-				// 空カラムには0を割り当てる
-				floatValue = 0.0
-			} else {
-				floatValue, err = strconv.ParseFloat(value, 64)
-				if err != nil {
-					slog.Error("ParseFloat", "err", err)
-					return nil, err
-				}
-			}
-			data = append(data, floatValue)
-		}
+		slog.Error("LoadCSV", "err", err)
+		return nil, nil, err
 	}
-
-	// 行列を作成
-	return mat.NewDense(rows, cols, data), nil
+	return matrix, header, nil
 }
 
 type UartBit struct {
@@ -119,542 +94,2143 @@ func (b UartBit) toString() string {
 }
 
 type UartCode struct {
-	startTime float64
-	endTime   float64
-	octet     byte
+	startTime    float64
+	endTime      float64
+	octet        byte
+	parityError  bool   // パリティが合わない
+	framingError bool   // ストップビットが0(DecodePermissiveの場合だけ残す)
+	direction    string // 全二重の場合の通信方向(DirectionTx, DirectionRx), 半二重では空
 }
 
 func (c UartCode) toString() string {
-	return fmt.Sprintf("(%08b)\n%d, 0x%02x, '%c'", c.octet, c.octet, c.octet, c.octet)
+	s := fmt.Sprintf("(%08b)\n%d, 0x%02x, '%c'", c.octet, c.octet, c.octet, c.octet)
+	if c.direction != "" {
+		return c.direction + " " + s
+	}
+	return s
 }
 
-type ChartOption struct {
-	titleText     string
-	xLabelText    string
-	yLabelText    string
-	uartBitValues []UartBit
-	uartCodes     []UartCode
+// グラフに値を示すビット
+func chartBits(bits []UartBit) []chart.Bit {
+	labels := make([]chart.Bit, len(bits))
+	for i, b := range bits {
+		labels[i] = chart.Bit{Time: b.startTime, Label: b.toString(), Idle: b.state == "IDLE"}
+	}
+	return labels
 }
 
-// グラフを保存する
-func saveChart(savefilepath string, graphWidth int, graphHeight int, option ChartOption, matrix mat.Matrix) error {
-	p := plot.New()
+// グラフに値を示す文字
+func chartCodes(codes []UartCode) []chart.Code {
+	labels := make([]chart.Code, len(codes))
+	for i, c := range codes {
+		labels[i] = chart.Code{Time: c.startTime, Label: c.toString(), Rx: c.direction == DirectionRx}
+	}
+	return labels
+}
 
-	p.Title.Text = option.titleText
-	p.X.Label.Text = option.xLabelText
-	p.Y.Label.Text = option.yLabelText
+// 解析する波形(生かフィルタ後)を選んで波形整形する
+// 最初のスタートビット開始時間を基準時間にする
+// 全二重の場合は送受信で同じ基準時間にして時間順に並べられるようにする
+func decodeWaveforms(matrix *mat.Dense, rxMatrix *mat.Dense, filtered mat.Matrix, rxFiltered mat.Matrix, option InsightOption) (mat.Matrix, mat.Matrix, float64, error) {
+	decodeSource, rxDecodeSource := decodeSources(matrix, rxMatrix, filtered, rxFiltered, option)
+
+	// A,B間電圧差は基準時間の検出と波形整形で使い回す
+	// 取り込みが送信の途中で始まった場合は十分な無通信まで読み飛ばす
+	// 雑音の床より小さいものは0にし, 復号のしきい値を指定した場合は拡大縮小する
+	diff, _ := decisionDiff(decodeSource, option)
+	resyncDiff(decodeSource, diff, option)
+	var rxDiff []float64
+	if rxDecodeSource != nil {
+		rxDiff, _ = decisionDiff(rxDecodeSource, option)
+		resyncDiff(rxDecodeSource, rxDiff, option)
+	}
 
-	// 背景色
-	p.BackgroundColor = colornames.Snow
+	txOriginTime, txOk := waveform.FindLevelTime(decodeSource, diff, option.format.idleSpace)
+	originTime := txOriginTime
+	if rxDecodeSource != nil {
+		if rxOriginTime, ok := waveform.FindLevelTime(rxDecodeSource, rxDiff, option.format.idleSpace); ok && (!txOk || rxOriginTime < txOriginTime) {
+			originTime = rxOriginTime
+		}
+	}
 
-	// 補助線
-	//	p.Add(plotter.NewGrid())
+	reshaped, err := waveform.Reshape(decodeSource, diff, option.baudrate, originTime)
+	if err != nil {
+		slog.Error("Reshape", "err", err)
+		return nil, nil, 0, err
+	}
+	var rxReshaped mat.Matrix
+	if rxDecodeSource != nil {
+		rxReshaped, err = waveform.Reshape(rxDecodeSource, rxDiff, option.baudrate, originTime)
+		if err != nil {
+			slog.Error("Reshape", "err", err)
+			return nil, nil, 0, err
+		}
+	}
+	return reshaped, rxReshaped, originTime, nil
+}
 
-	// 凡例の位置を右下に設定
-	p.Legend.Top = false
-	p.Legend.Left = false
-	p.Legend.Padding = vg.Points(5)
+// 復号に使う測定値(全二重でなければrxはnil)
+func decodeSources(matrix *mat.Dense, rxMatrix *mat.Dense, filtered mat.Matrix, rxFiltered mat.Matrix, option InsightOption) (mat.Matrix, mat.Matrix) {
+	var decodeSource, rxDecodeSource mat.Matrix = matrix, nil
+	if rxMatrix != nil {
+		rxDecodeSource = rxMatrix
+	}
+	if option.decodeFilter {
+		decodeSource, rxDecodeSource = filtered, rxFiltered
+	}
+	if option.edgeDetect == EdgeDerivative {
+		decodeSource = derivativeEdgeWaveform(decodeSource, option.baudrate)
+		if rxDecodeSource != nil {
+			rxDecodeSource = derivativeEdgeWaveform(rxDecodeSource, option.baudrate)
+		}
+	}
+	return decodeSource, rxDecodeSource
+}
 
-	rows, cols := matrix.Dims()
+// 解析
+// formatの文字の形式で復号し, パリティが合わない文字は復号した上でparityErrorを付ける
+// modeがDecodePermissiveの場合は同期し直して続け, ストップビットが0の文字もframingErrorを付けて残す
+func analyzePulses(reshaped mat.Matrix, format UartFormat, mode string) ([]UartBit, []UartCode, error) {
+	config := format.uartConfig(0)
+	config.Permissive = mode == DecodePermissive
+	bits, codes, err := uart.DecodeReshaped(reshaped, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	signal, uartCodes := fromDecoded(bits, codes)
+	return signal, uartCodes, nil
+}
 
-	if cols < 3 {
-		slog.Error("列数が不足")
-		return nil
+// 復号したビットと文字
+func fromDecoded(bits []uart.Bit, codes []uart.Code) ([]UartBit, []UartCode) {
+	signal := make([]UartBit, len(bits))
+	for i, b := range bits {
+		signal[i] = UartBit{b.StartTime, b.EndTime, b.State, b.Value}
 	}
+	uartCodes := make([]UartCode, len(codes))
+	for i, c := range codes {
+		uartCodes[i] = UartCode{startTime: c.StartTime, endTime: c.EndTime, octet: c.Octet, parityError: c.ParityError, framingError: c.FramingError}
+	}
+	return signal, uartCodes
+}
 
-	// A線電圧
-	wireA := make(plotter.XYs, rows)
-	for row := range wireA {
-		wireA[row].X = matrix.At(row, ColTime)
-		wireA[row].Y = matrix.At(row, ColWireA)
+// 解析オプション
+type InsightOption struct {
+	baudrate        int
+	estimateBaud    string     // ボーレートの推定方法(EstimateBaudNone, EstimateBaudPulse, EstimateBaudAutocorrelation)
+	frameFormat     string     // 文字の形式の表記(例: 8N1)
+	dataBits        int        // データビット数, 0の場合はframeFormatのまま
+	parity          string     // パリティ, 空の場合はframeFormatのまま
+	stopBits        float64    // ストップビット数, 0の場合はframeFormatのまま
+	format          UartFormat // frameFormat, dataBits, parity, stopBitsから決めた文字の形式
+	graphWidth      int
+	graphHeight     int
+	frameGap        float64 // フレームの区切りとみなす無通信時間(文字数)
+	minTurnaround   float64 // 応答までの最小ターンアラウンド時間(s), 0の場合は3.5文字分
+	deThreshold     float64 // ドライバイネーブル信号のしきい値(V)
+	drivenThreshold float64 // バスが駆動されているとみなすA-B間電圧差の大きさ(V)
+	idleBias        string  // 駆動されていない時のA-B間電圧差(V), IdleBiasAutoの場合は測定値から決める
+	idleBand        float64 // 駆動されていない時のA-B間電圧差の許容範囲(±V)
+	busStateFile    string  // バスの状態の区間を保存するCSVファイル, 空の場合は保存しない
+	deMinLead       float64 // ドライバ有効からスタートビットまでの最小時間(s)
+	deMaxRelease    float64 // ストップビット終了からドライバ無効までの最大時間(s), 0の場合は1ビット分
+	minSlew         float64 // A,B間電圧差の最小スルーレート(V/us), 0の場合は制限なし
+	maxSlew         float64 // A,B間電圧差の最大スルーレート(V/us), 0の場合は制限なし
+	maxTransition   float64 // 最大遷移時間(ビット周期に対する比)
+	bitTolerance    float64 // 文字の中のパルスの長さの狂いの許容範囲(ビット周期に対する比)
+	prbsOrder       int     // ビット誤り率試験のPRBSの次数(7, 15), 0の場合は試験しない
+	eventsFile      string  // 外部イベントログファイル, 空の場合は使わない
+	t0              string  // 入力CSVの時間0の時刻, 空の場合はヘッダー行から探す
+	addressByte     int     // フレーム内のアドレスの位置(0始まり)
+	utilWindow      float64 // バス使用率の時間変化のグラフの区間(s), 0の場合はグラフを作らない
+	pollInterval    float64 // 主局の要求の予定の周期(s), 0の場合は調べない
+	crcKind         string  // フレームの誤り検出符号の種類(CrcNone, CrcModbus)
+	anomalyFile     string  // 異常の一覧を保存するファイル(.json, .csv), 空の場合は保存しない
+	failOnError     bool    // 重大度errorの異常があれば終了コードを0以外にする
+	badRows         string  // 入力CSVの不正な行の扱い(BadRowsSkip, BadRowsAbort)
+	columnNames     ColumnNames
+	timeUnit        string        // 入力CSVの時間列の単位, 空の場合はヘッダー行から検出する
+	voltageUnit     string        // 入力CSVの電圧列の単位, 空の場合はヘッダー行から検出する
+	aScale          float64       // A線のプローブの減衰比
+	bScale          float64       // B線のプローブの減衰比
+	invertA         bool          // A線の極性を反転する
+	invertB         bool          // B線の極性を反転する
+	skew            float64       // B線のA線に対する遅れ(s)
+	filter          string        // ノイズ除去フィルタの種類(FilterSma, FilterWavelet, FilterEma, FilterKalman)
+	smoothWindow    int           // 移動平均の窓の大きさ(サンプル数), 0の場合はビット周期から決める
+	waveletLevels   int           // ウェーブレットの分解レベル
+	emaAlpha        float64       // 指数移動平均の係数
+	kalmanQ         float64       // カルマンフィルタのプロセス雑音の分散(V^2)
+	kalmanR         float64       // カルマンフィルタの観測雑音の分散(V^2)
+	decodeFilter    bool          // フィルタ適用後の波形を解析する
+	correct         bool          // ストップビットや誤り検出符号が合わない文字やフレームを1ビットの訂正で直してみる
+	softBitsFile    string        // ビット毎の軟判定を保存するCSVファイル, 空の場合は保存しない
+	bitFeaturesFile string        // 機械学習向けのビット毎の特徴量を保存するCSVファイル, 空の場合は保存しない
+	edgeDetect      string        // エッジ検出の方式(EdgeLevel, EdgeDerivative)
+	resync          string        // 取り込みが送信の途中で始まった場合の読み飛ばし方(ResyncAuto, ResyncIdle, ResyncOff)
+	idleLevel       string        // アイドルの向き(IdleLevelMark, IdleLevelSpace), formatに反映する
+	resyncIdle      float64       // 同期に使う無通信のビット数, 0の場合は1文字の長さ
+	threshold       float64       // 復号のしきい値(A-B間電圧差, V), 0の場合はThreshould
+	noiseFloor      float64       // 波形整形の前に0にするA-B間電圧差の大きさ(V), 0の場合は0にしない
+	inputType       string        // 入力CSVの値の種類(InputAnalog, InputLogic)
+	tileWidth       int           // タイル画像の幅(px), 0の場合はタイル画像ピラミッドを作らない
+	pcapFile        string        // フレームを保存するpcapファイル
+	framesFile      string        // フレームの一覧を保存するJSONファイル, 空の場合は保存しない
+	decodeOutput    string        // 復号した結果の出力の形式(DecodeOutputNone, DecodeOutputJson, DecodeOutputCsv, DecodeOutputBin)
+	outFile         string        // 復号した結果の出力ファイル, 空の場合は[入力ファイル]_decoded.[形式], DecodeOutputStdoutの場合は標準出力
+	referenceFile   string        // 比べる参照のフレームの一覧(JSON), 空の場合は比べない
+	meta            bool          // 出力ファイルの横に解析の説明(.meta.json)を書く
+	overwrite       bool          // 既に有る出力ファイルを黙って置き換える
+	outputPrefix    string        // 出力ファイルの基本名, 空の場合は入力ファイルの名前(標準入力はStdinOutputPrefix)
+	versionOutputs  bool          // 既に有る出力ファイルは残し, 時刻を付けた別の名前で出力する
+	sequenceFile    string        // 通信の流れを保存するシーケンス図のファイル(.mmd, .puml), 空の場合は保存しない
+	trafficFile     string        // 送信元と宛先の組ごとの通信量を保存するCSVファイル, 空の場合は保存しない
+	where           string        // 報告とグラフに使うフレームを絞り込む式, 空の場合は絞り込まない
+	follow          bool          // 書き込み中のCSVファイルを追いかけて復号する
+	strict          bool          // 最初の復号の誤りで打ち切る
+	permissive      bool          // ストップビットが0の場合は同期し直して続ける
+	decodeMode      string        // 復号の厳しさ(DecodeNormal, DecodeStrict, DecodePermissive), strictとpermissiveから決める
+	liveFrames      bool          // 読み込みながら復号して、フレームを見つけ次第表示する
+	profile         string        // 使うプロファイルの名前, 空の場合は使わない
+	profilesFile    string        // プロファイルの設定ファイル, 空の場合はユーザーの設定ディレクトリ
+	payloadDir      string        // フレーム毎のペイロードを保存するディレクトリ, 空の場合は保存しない
+	hexFile         string        // ペイロードを保存するIntel HEX(.hex)かS-record(.srec)のファイル, 空の場合は保存しない
+	hexAddress      string        // ペイロードのアドレスを求める式, 空の場合はペイロードをつなげたバイト位置
+	hexSkip         int           // ペイロードの先頭から除くバイト数
+	dumpCode        string        // フレームを配列として表示するソースコードの言語(DumpCodeC, DumpCodeGo), 空の場合は表示しない
+	compressIdle    float64       // グラフの横軸で詰める無通信時間の下限(文字数), 0の場合は詰めない
+	frameTable      bool          // UART通信のグラフの下にフレームの一覧表を描く
+	dashboard       bool          // 波形の縮小図, アイダイアグラム, 主な指標を1枚にまとめた画像を作る
+	eyeMaskFile     string        // アイダイアグラムのマスク試験に使うマスク(JSON)のファイル, 空の場合は試験しない
+	traceFile       string        // 解析の段のトレース(OTLP/JSON)を書き足すファイル, 空の場合は書き出さない
+	timeout         time.Duration // 1ファイルの解析時間の上限, 0の場合は上限なし
+	maxFrames       int           // このフレーム数を復号した所で解析を打ち切る, 0の場合は打ち切らない
+	stopAfter       string        // この式に合うフレームを復号した所で解析を打ち切る, 空の場合は打ち切らない
+	charts          string        // 作る波形のグラフのカンマ区切りの一覧(ChartRaw, ChartFiltered, ChartReshaped, ChartUart)
+	plotWorkers     int           // 同時に描くグラフの数, 0の場合はCPUの数
+	provenance      *Provenance   // 出力ファイルに埋め込む来歴, nilの場合は埋め込まない
+	stitch          bool          // 複数のCSVファイルをつなげて解析する
+	stitchFiles     []string      // 最初のCSVファイルの後ろにつなげるCSVファイル
+	skipLines       int           // 読み飛ばすヘッダー行の数
+	vcdInput        bool          // 拡張子によらずVCDファイルとして読む
+	wavInput        bool          // 拡張子によらずWAVファイルとして読む
+	wavFullScale    float64       // WAVファイルのフルスケールに当たる電圧(V)
+	bFile           string        // Tektronixの波形ファイル(.isf)のB線のファイル, 空の場合はA線のファイル名のCH1をCH2にする
+	delimiter       string        // CSVファイルの区切り文字(parseDelimiter)
+	segments        bool          // 時間が戻った所でセグメントに分けて, セグメント毎に解析する
+	segmentCol      string        // セグメント番号の列名, 空の場合はsegmentsに従う
+	segment         *InputSegment // 解析するセグメント, nilの場合はCSVファイル全体
+	exportFiltered  bool          // フィルタ後の行列をCSVファイルに書き出す
+	exportReshaped  bool          // 整形後の行列をCSVファイルに書き出す
+	exportAnnotated bool          // 入力の行列に復号した結果の列を加えてCSVファイルに書き出す
+	stream          bool          // 全体を行列にせずに, 塊ごとにフィルタ, 波形整形, 復号へ流す
+	cache           bool          // 解析キャッシュを使う
+	cacheDir        string        // 解析キャッシュのディレクトリ, 空の場合はユーザーのキャッシュディレクトリ
+}
+
+// CSV以外の入力(sigrokのセッションファイル, VCD, WAV, オシロスコープの波形ファイル)
+func isNonCsvInput(csvfilepath string, option InsightOption) bool {
+	if option.vcdInput || option.wavInput {
+		return true
 	}
-	// 折れ線グラフを作成
-	if line, points, err := plotter.NewLinePoints(wireA); err != nil {
-		slog.Error("NewLine", "err", err)
-	} else {
-		points.Shape = draw.CrossGlyph{}
-		line.Color = colornames.Darkmagenta
-		p.Add(line, points)
-		p.Legend.Add("A線", line) // 凡例
+	ext := filepath.Ext(csvfilepath)
+	for _, e := range []string{SigrokExt, VcdExt, WavExt, IsfExt, ScopeBinExt, RigolWfmExt} {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
 	}
+	return false
+}
 
-	// B線電圧
-	wireB := make(plotter.XYs, rows)
-	for row := range wireA {
-		wireB[row].X = matrix.At(row, ColTime)
-		wireB[row].Y = matrix.At(row, ColWireB)
+// CSVファイルを読み込んで、列を選び、時間を秒に、電圧をボルトに揃える
+func loadInputMatrix(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) (*mat.Dense, [][]string, error) {
+	// sigrokのセッションファイルは時間(s), A線電圧(V), B線電圧(V)の行列になっている
+	if strings.EqualFold(filepath.Ext(csvfilepath), SigrokExt) {
+		if option.liveFrames {
+			return nil, nil, fmt.Errorf("--live-framesはsigrokのセッションファイルには対応していない")
+		}
+		matrix, header, err := loadSigrokSession(csvfilepath, option.columnNames[1], option.columnNames[2])
+		if err != nil {
+			slog.Error("loadSigrokSession", "err", err)
+			return nil, nil, err
+		}
+		return matrix, header, checkCanceled(ctx)
 	}
-	// 折れ線グラフを作成
-	if line, points, err := plotter.NewLinePoints(wireB); err != nil {
-		slog.Error("NewLine", "err", err)
-	} else {
-		points.Shape = draw.CrossGlyph{}
-		line.Color = colornames.Darkcyan
-		p.Add(line, points)
-		p.Legend.Add("B線", line) // 凡例
-	}
-
-	// 各々ビットの値
-	if len(option.uartBitValues) != 0 {
-		labelPoints := make([]plotter.XY, len(option.uartBitValues))
-		labelTexts := make([]string, len(option.uartBitValues))
-		for i, v := range option.uartBitValues {
-			labelPoints[i].X = v.startTime
-			labelPoints[i].Y = 0
-			labelTexts[i] = v.toString()
-		}
-		// データポイントにラベルを追加
-		labels, err := plotter.NewLabels(plotter.XYLabels{
-			XYs:    labelPoints,
-			Labels: labelTexts,
-		})
+	// VCDファイルは値が変化した時刻の行になっている
+	// 論理レベルは論理レベルの段で一定の間隔にし, 電圧はここで一定の間隔にする
+	if option.vcdInput || strings.EqualFold(filepath.Ext(csvfilepath), VcdExt) {
+		if option.liveFrames {
+			return nil, nil, fmt.Errorf("--live-framesはVCDファイルには対応していない")
+		}
+		matrix, header, err := loadVcdFile(csvfilepath, option.columnNames[1], option.columnNames[2], option.inputType == InputLogic)
 		if err != nil {
-			slog.Error("NewLabels", "err", err)
-			return err
+			slog.Error("loadVcdFile", "err", err)
+			return nil, nil, err
 		}
-		// ラベルの回転を設定
-		for i := range labels.TextStyle {
-			labels.TextStyle[i].Rotation = -math.Pi / 2 // 右90度回転
+		if option.inputType != InputLogic {
+			matrix = resampleLogic(matrix, 1/float64(option.baudrate*LogicOversample))
 		}
-		// ラベルを追加する
-		p.Add(labels)
+		return matrix, header, checkCanceled(ctx)
 	}
 
-	//
-	if len(option.uartCodes) != 0 {
-		labelPoints := make([]plotter.XY, len(option.uartCodes))
-		labelTexts := make([]string, len(option.uartCodes))
-		for i, v := range option.uartCodes {
-			labelPoints[i].X = v.startTime
-			labelPoints[i].Y = -1
-			labelTexts[i] = v.toString()
+	// WAVファイルはサンプリングレートで時間を決める
+	if option.wavInput || strings.EqualFold(filepath.Ext(csvfilepath), WavExt) {
+		if option.liveFrames {
+			return nil, nil, fmt.Errorf("--live-framesはWAVファイルには対応していない")
 		}
-		// データポイントにラベルを追加
-		labels, err := plotter.NewLabels(plotter.XYLabels{
-			XYs:    labelPoints,
-			Labels: labelTexts,
-		})
+		matrix, header, err := loadWavFile(csvfilepath, option.wavFullScale)
 		if err != nil {
-			slog.Error("NewLabels", "err", err)
-			return err
+			slog.Error("loadWavFile", "err", err)
+			return nil, nil, err
+		}
+		checkWavSampleRate(matrix, option.baudrate)
+		return matrix, header, checkCanceled(ctx)
+	}
+
+	// オシロスコープの波形ファイルは見出しの点の間隔と最初の点の時間で時間を決める
+	switch ext := filepath.Ext(csvfilepath); {
+	case strings.EqualFold(ext, IsfExt), strings.EqualFold(ext, ScopeBinExt), strings.EqualFold(ext, RigolWfmExt):
+		if option.liveFrames {
+			return nil, nil, fmt.Errorf("--live-framesはオシロスコープの波形ファイルには対応していない")
+		}
+		var matrix *mat.Dense
+		var header [][]string
+		var err error
+		switch {
+		case strings.EqualFold(ext, IsfExt):
+			matrix, header, err = loadIsfFiles(csvfilepath, option.bFile)
+		case strings.EqualFold(ext, RigolWfmExt):
+			matrix, header, err = loadRigolWfmFile(csvfilepath, option.columnNames[1], option.columnNames[2])
+		default:
+			matrix, header, err = loadScopeBinFile(csvfilepath, option.columnNames[1], option.columnNames[2])
+		}
+		if err != nil {
+			slog.Error("loadScopeWaveform", "err", err)
+			return nil, nil, err
+		}
+		return matrix, header, checkCanceled(ctx)
+	}
+
+	// 読み込みながら復号して, フレームを見つけ次第表示する
+	var live *LiveDecoder
+	var onRows func(header [][]string, data []float64, cols int) error
+	if option.liveFrames {
+		live = newLiveDecoder(w, csvfilepath, option)
+		onRows = live.feed
+	}
+	matrix, header, err := loadCsv(ctx, csvfilepath, option, onRows)
+	if err != nil {
+		slog.Error("loadCsv", "err", err)
+		return nil, nil, err
+	}
+	if live != nil {
+		if err := live.finish(); err != nil {
+			slog.Error("finish", "err", err)
+			return nil, nil, err
 		}
-		// ラベル
-		for i := range labels.TextStyle {
-			labels.TextStyle[i].Font.Size = 22
-			labels.TextStyle[i].Color = colornames.Darkgreen
+	}
+
+	// 列名で列を選ぶ
+	if option.columnNames != (ColumnNames{}) {
+		if matrix, header, err = selectColumns(matrix, header, option.columnNames); err != nil {
+			slog.Error("selectColumns", "err", err)
+			return nil, nil, err
 		}
-		// ラベルを追加する
-		p.Add(labels)
 	}
 
-	// プロットを画像ファイルに保存
-	if err := p.Save(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)), savefilepath); err != nil {
-		log.Fatalf("could not save plot: %v", err)
+	// ヘッダーのサンプリング間隔で時間列を確かめる
+	if preamble, ok := findPreamble(header); ok {
+		applyPreamble(w, matrix, preamble)
 	}
 
-	return nil
+	// 時間を秒に、電圧をボルトに換算する
+	if err := applyUnits(matrix, header, option.timeUnit, option.voltageUnit); err != nil {
+		slog.Error("applyUnits", "err", err)
+		return nil, nil, err
+	}
+	return matrix, header, nil
 }
 
-// 移動平均フィルタを掛ける
-func applySmoothing(original mat.Matrix, windowSize int) (mat.Matrix, error) {
-	rows, cols := original.Dims()
-
-	if rows < windowSize {
-		return nil, errors.New("データ数が不足している")
+// 解析する行列を用意する
+// CSVファイルを読み込み、続けて測定したCSVファイルをつなげて、プローブの減衰比と極性、A線とB線の時間のずれを補正する
+func prepareInputMatrix(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) (*mat.Dense, [][]string, error) {
+	matrix, header, err := loadInputMatrix(ctx, w, csvfilepath, option)
+	if err != nil {
+		slog.Error("loadInputMatrix", "err", err)
+		return nil, nil, err
 	}
 
-	if cols != 3 {
-		slog.Warn("期待している列数と違う")
+	// 続けて測定したCSVファイルをつなげる
+	if len(option.stitchFiles) != 0 {
+		if matrix, err = stitchInputFiles(ctx, w, matrix, header, option.stitchFiles, option); err != nil {
+			slog.Error("stitchInputFiles", "err", err)
+			return nil, nil, err
+		}
 	}
 
-	var averageA float64
-	var averageB float64
-	for r := 0; r < windowSize; r++ {
-		averageA += original.At(r, ColWireA)
-		averageB += original.At(r, ColWireB)
+	if matrix, err = conditionInputMatrix(matrix, option); err != nil {
+		slog.Error("conditionInputMatrix", "err", err)
+		return nil, nil, err
 	}
-	averageA /= float64(windowSize)
-	averageB /= float64(windowSize)
+	return matrix, header, nil
+}
 
-	// データを格納するスライスを作成
-	filteredData := []float64{}
+// 読み込んだ行列を補正する
+// 論理レベルは電圧に置き換え, アナログの測定値はプローブの減衰比と極性, A線とB線の時間のずれを補正する
+func conditionInputMatrix(matrix *mat.Dense, option InsightOption) (*mat.Dense, error) {
+	// 論理レベルはA線, B線の電圧に置き換えて, 変化した所だけの場合は一定の間隔のサンプルにする
+	// 論理レベルにプローブの減衰比と時間のずれは無いので補正しない
+	if option.inputType == InputLogic {
+		converted, err := logicToDifferential(matrix, [2]bool{option.invertA, option.invertB})
+		if err != nil {
+			slog.Error("logicToDifferential", "err", err)
+			return nil, err
+		}
+		return resampleLogic(converted, 1/float64(option.baudrate*LogicOversample)), nil
+	}
 
-	// 移動平均
-	for r := 0; r < rows-windowSize; r++ {
-		time := original.At(r+windowSize, ColTime)
-		filteredData = append(filteredData, time, averageA, averageB)
-		// 最初の値を引く
-		averageA -= original.At(r, ColWireA) / float64(windowSize)
-		averageB -= original.At(r, ColWireB) / float64(windowSize)
-		// 現在の値を足す
-		averageA += original.At(r+windowSize, ColWireA) / float64(windowSize)
-		averageB += original.At(r+windowSize, ColWireB) / float64(windowSize)
+	// プローブの減衰比と極性の反転
+	aScale, bScale := option.aScale, option.bScale
+	if option.invertA {
+		aScale = -aScale
+	}
+	if option.invertB {
+		bScale = -bScale
 	}
+	applyProbeScale(matrix, aScale, bScale)
 
-	matrix := mat.NewDense(rows-windowSize, cols, filteredData)
+	// A線とB線の時間のずれを補正する
+	applySkew(matrix, option.skew)
 	return matrix, nil
 }
 
-// 波形整形
-func reshapeWaveform(original mat.Matrix, baudrate int) (mat.Matrix, error) {
-	matrix := mat.DenseCopyOf(original)
-	rows, _ := matrix.Dims()
+// CSVファイルを調べる
+// トレースファイルが指定されている場合は解析の段の時間をトレースとして書き足す
+// ctxが終わるか, 1ファイルの解析時間の上限を過ぎたら段の切れ目で止める
+func insightTheCsvFile(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) error {
+	if option.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, option.timeout)
+		defer cancel()
+	}
+	if option.traceFile == "" {
+		return analyzeTheCsvFile(ctx, w, csvfilepath, option, nil)
+	}
+	tracer := newTracer()
+	span := tracer.start("insight")
+	span.setAttribute("file", csvfilepath)
+	span.setAttribute("baudrate", fmt.Sprintf("%d", option.baudrate))
+	err := analyzeTheCsvFile(ctx, w, csvfilepath, option, span)
+	span.finish(err)
+	version := "1.0.0"
+	if option.provenance != nil {
+		version = option.provenance.Version
+	}
+	if err := tracer.save(option.traceFile, version); err != nil {
+		slog.Error("save", "err", err)
+		return err
+	}
+	return err
+}
 
-	// スタートビット開始時間を検出する
-	var startbitTime float64
-	for r := 0; r < rows; r++ {
-		if matrix.At(r, ColWireA)-matrix.At(r, ColWireB) < -Threshould {
-			startbitTime = matrix.At(r, ColTime)
-			break
-		}
-	}
-	// 各々の時間をスタートビット開始時間との相対時間にする
-	for r := 0; r < rows; r++ {
-		t := matrix.At(r, ColTime)
-		matrix.Set(r, ColTime, t-startbitTime)
-	}
-
-	// 周期T
-	T := 1 / float64(baudrate)
-
-	// データを格納するスライスを作成
-	data := []float64{}
-
-	for r := 0; r < rows; r++ {
-		startTime := matrix.At(r, ColTime)
-		// 差動伝送なのでA,B間電圧差が正(A線+,B線-)の時にMark、負(A線-,B線+)の時にSpace
-		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
-		if d > Threshould {
-			// Mark
-			data = append(data, startTime, 1, -1) // Mark開始時間
-			var endTime float64 = startTime
-			// 継続時間
-			for ; endTime-startTime < T && r < rows; r++ {
-				e := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
-				if e > Threshould {
-					endTime = matrix.At(r, ColTime)
-				} else {
-					break
-				}
-			}
-			data = append(data, endTime, 1, -1) // Mark終了時間
-		} else if d < -Threshould {
-			// Space
-			data = append(data, startTime, -1, 1) // Space開始時間
-			var endTime float64 = startTime
-			// 継続時間
-			for ; endTime-startTime < T && r < rows; r++ {
-				e := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
-				if e < -Threshould {
-					endTime = matrix.At(r, ColTime)
-				} else {
-					break
-				}
-			}
-			data = append(data, endTime, -1, 1) // Space終了時間
-		} else {
-			// 閾値以下はノイズなので追加しない
+// CSVファイルを解析する
+// spanがnilでなければ段ごとに子のスパンを作る
+// 中断された場合も, それまでに頼んだグラフは保存してから戻る
+func analyzeTheCsvFile(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption, span *TraceSpan) error {
+	baudrate := option.baudrate
+	graphWidth := option.graphWidth
+	graphHeight := option.graphHeight
+
+	charts, err := validateInsightOption(option)
+	if err != nil {
+		return err
+	}
+	outputs, err := newOutputPolicy(option.overwrite, option.versionOutputs, time.Now())
+	if err != nil {
+		return err
+	}
+	// 全体を行列にせずに少しずつ読んで解析する
+	if option.stream {
+		return streamTheCsvFile(ctx, w, csvfilepath, option, outputs, span)
+	}
+	var eyeMask *EyeMask
+	if option.eyeMaskFile != "" {
+		mask, err := loadEyeMask(option.eyeMaskFile)
+		if err != nil {
+			slog.Error("loadEyeMask", "err", err)
+			return err
 		}
+		eyeMask = &mask
 	}
 
-	newMatrix := mat.NewDense(len(data)/3, 3, data)
-	return newMatrix, nil
-}
-
-// 解析
-func analyzePulses(reshaped mat.Matrix) ([]UartBit, []UartCode, error) {
-	rows, cols := reshaped.Dims()
+	// グラフの描画と保存は描画段で解析と並行して進める
+	plots := startPlotStage(ctx, span.child("plot"), option.plotWorkers)
+	defer plots.wait()
 
-	if cols != 3 {
-		slog.Warn("期待している列数と違う")
+	// 外部イベント
+	events := []ExternalEvent{}
+	if option.eventsFile != "" {
+		var err error
+		if events, err = loadEvents(option.eventsFile); err != nil {
+			slog.Error("loadEvents", "err", err)
+			return err
+		}
 	}
 
-	// 状態
-	var state string = "IDLE"
-	// コード
-	var octet uint8
+	if option.segment != nil {
+		fmt.Fprintf(w, "input file \"%s\" segment #%d/%d\n", csvfilepath, option.segment.index, option.segment.count)
+	} else {
+		fmt.Fprintf(w, "input file \"%s\"\n", csvfilepath)
+	}
 
-	// 状態移行
-	shiftState := func(bit uint8) {
-		bit &= 1
-		switch state {
-		case "IDLE":
-			if bit == 0 { // バスアイドル状態からA線が下降したら開始
-				state = "START"
-			}
-			octet = 0 // 初期化
-
-		case "START":
-			state = "Bit#0"
-			octet = 0         // 初期化
-			octet |= bit << 0 // Bit#0
-
-		case "Bit#0":
-			state = "Bit#1"
-			octet |= bit << 1 // Bit#1
-
-		case "Bit#1":
-			state = "Bit#2"
-			octet |= bit << 2 // Bit#2
-
-		case "Bit#2":
-			state = "Bit#3"
-			octet |= bit << 3 // Bit#3
-
-		case "Bit#3":
-			state = "Bit#4"
-			octet |= bit << 4 // Bit#4
-
-		case "Bit#4":
-			state = "Bit#5"
-			octet |= bit << 5 // Bit#5
-
-		case "Bit#5":
-			state = "Bit#6"
-			octet |= bit << 6 // Bit#6
-
-		case "Bit#6":
-			state = "Bit#7"
-			octet |= bit << 7 // Bit#7
-
-		case "Bit#7":
-			if bit == 1 {
-				state = "STOP" // パリティなしなのでここまで
-			} else {
-				state = "X"
-			}
+	// 解析キャッシュ
+	var cache *AnalysisCache
+	cachePath := ""
+	if option.cache && option.segment == nil {
+		var err error
+		if cachePath, err = analysisCachePath(csvfilepath, option); err != nil {
+			slog.Error("analysisCachePath", "err", err)
+			return err
+		}
+		if cache, err = loadAnalysisCache(cachePath); err == nil {
+			fmt.Fprintf(w, "cache hit \"%s\"\n", cachePath)
+		}
+	}
 
-		case "STOP":
-			if bit == 1 {
-				state = "IDLE"
-			} else {
-				state = "START"
-			}
+	// 解析対象の行列
+	var matrix *mat.Dense
+	var header [][]string
+	if cache != nil {
+		matrix, header = cache.Matrix, cache.Header
+	} else if option.segment != nil {
+		matrix, header = option.segment.matrix, option.segment.header
+	} else {
+		parseSpan := span.child("parse")
+		matrix, header, err = prepareInputMatrix(ctx, w, csvfilepath, option)
+		parseSpan.finish(err)
+		if err != nil {
+			slog.Error("prepareInputMatrix", "err", err)
+			return err
+		}
+	}
+	inputMatrix := matrix
 
-		case "X":
-			if bit == 1 {
-				state = "STOP"
-			} else {
-				state = "START"
-			}
+	// 出力ファイルに埋め込む来歴に入力ファイルを加える(標準入力は読み込んだ後でハッシュが決まる)
+	if option.provenance != nil {
+		var err error
+		if option.provenance, err = option.provenance.withInput(csvfilepath); err != nil {
+			slog.Error("withInput", "err", err)
+			return err
+		}
+	}
 
-		default:
-			state = "X"
-		}
-	}
-	// データを格納するスライスを作成
-	signal := []UartBit{}
-	codes := []UartCode{}
-	var startOctetTime float64
-
-	for r := 0; r < rows; r += 2 {
-		startTime := reshaped.At(r, ColTime)
-		startA := reshaped.At(r, ColWireA)
-		startB := reshaped.At(r, ColWireB)
-		diff := startA - startB
-		endTime := reshaped.At(r+1, ColTime)
-		endA := reshaped.At(r+1, ColWireA)
-		endB := reshaped.At(r+1, ColWireB)
-		if startA != endA || startB != endB {
-			return nil, nil, errors.New("データ不一致")
-		}
-		if diff > Threshould {
-			// Mark
-			// Logical: 1
-			shiftState(1)
-			signal = append(signal, UartBit{startTime, endTime, state, 1})
-		} else if diff < -Threshould {
-			// Space
-			// Logical: 0
-			shiftState(0)
-			signal = append(signal, UartBit{startTime, endTime, state, 0})
-		} else {
-			continue
-		}
-		if state == "START" {
-			startOctetTime = startTime
-		} else if state == "STOP" {
-			codes = append(codes, UartCode{startOctetTime, endTime, octet})
-		}
-	}
-
-	return signal, codes, nil
-}
+	// 時間の表示
+	var clock Clock
+	if option.t0 != "" {
+		if clock.t0, err = parseT0(option.t0); err != nil {
+			slog.Error("parseT0", "err", err)
+			return err
+		}
+		clock.absolute = true
+	} else {
+		clock.t0, clock.absolute = findHeaderTime(header)
+	}
 
-// CSVファイルを調べる
-func insightTheCsvFile(csvfilepath string, baudrate int, graphWidth int, graphHeight int) error {
-	fmt.Printf("input file \"%s\"\n", csvfilepath)
+	// 出力ファイルの基本名と指定した出力ファイルの名前
+	basename, ext := outputs.resolveOutputs(csvfilepath, &option)
 
-	// 解析対象の行列
-	matrix, err := loadCsv(csvfilepath)
-	if err != nil {
-		slog.Error("loadCsv", "err", err)
-		return err
+	// 全二重(TX対とRX対の4線)の場合は送信対と受信対に分ける
+	var rxMatrix *mat.Dense
+	if isDuplex(matrix) {
+		matrix, rxMatrix = splitDuplex(matrix)
 	}
 
-	// 入力ファイル拡張子
-	ext := filepath.Ext(csvfilepath)
+	// 測定値からボーレートを推定する
+	if option.estimateBaud != EstimateBaudNone {
+		estimate, err := estimateBaudrate(matrix, option.estimateBaud)
+		if err != nil {
+			slog.Error("estimateBaudrate", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "baud rate: %d bps (%s estimate %.1f bps from %d edges)\n", estimate.baudrate, estimate.method, estimate.raw, estimate.edges)
+		baudrate = estimate.baudrate
+		option.baudrate = estimate.baudrate
+	}
 
-	// 入力ファイル拡張子を取り除く
-	basename := strings.TrimSuffix(csvfilepath, ext)
+	// 指定したフレームまでで解析を打ち切る
+	// 打ち切った後ろの行を捨てて, 以降のグラフと報告は残りの行だけで作る
+	earlyStopped := false
+	if option.maxFrames > 0 || option.stopAfter != "" {
+		stop, found, err := findEarlyStop(matrix, rxMatrix, option)
+		if err != nil {
+			slog.Error("findEarlyStop", "err", err)
+			return err
+		}
+		if found {
+			matrix = truncateMatrix(matrix, stop.cutTime)
+			if rxMatrix != nil {
+				rxMatrix = truncateMatrix(rxMatrix, stop.cutTime)
+			}
+			rows, _ := matrix.Dims()
+			reason := "max frames"
+			if stop.matched {
+				reason = "\"" + option.stopAfter + "\" matched"
+			}
+			fmt.Fprintf(w, "stopped after frame #%d at %.6fs (%s): %d of %d rows\n", stop.frame, stop.time, reason, rows, stop.original)
+			earlyStopped = true
+		}
+	}
 
-	// グラフファイル
-	chartfile := basename + "_" + ext[1:] + "_voltage.png"
+	// 片方のしきい値だけを越えて戻るパルス
+	runts := detectRunts(matrix, baudrate)
+	var rxRunts []RuntPulse
+	if rxMatrix != nil {
+		rxRunts = detectRunts(rxMatrix, baudrate)
+	}
+	allRunts := append(append([]RuntPulse{}, runts...), rxRunts...)
 
 	// グラフをファイルに保存
-	var chartOption = ChartOption{
-		titleText:     "A,B線電圧の時間変化",
-		xLabelText:    "時間(s)",
-		yLabelText:    "電圧(V)",
-		uartBitValues: []UartBit{},
-		uartCodes:     []UartCode{},
+	var chartOption = chart.Option{
+		Title:        "A,B線電圧の時間変化",
+		XLabel:       "時間(s)",
+		YLabel:       "電圧(V)",
+		Events:       events,
+		Runts:        runtTimes(allRunts, 0),
+		CompressIdle: option.compressIdle * option.format.charTime(baudrate),
+		CodeBits:     option.format.stopBitIndex() + 1,
+		IdleSpace:    option.format.idleSpace,
+		PngTexts:     option.provenance.pngTexts(),
+		Threshold:    option.threshold,
+	}
+	if clock.absolute {
+		chartOption.XLabel = "時刻"
+		chartOption.XToTime = clock.captureTime
+	}
+	if rxMatrix != nil {
+		chartOption.RxMatrix = rxMatrix
+	}
+	saveInputCharts(w, plots, basename+"_"+ext[1:], charts, chartOption, matrix, rxMatrix, option)
+
+	// 移動平均の窓の大きさ
+	if option.filter == FilterSma && option.smoothWindow == 0 {
+		option.smoothWindow = autoSmoothingWindow(matrix, baudrate)
+		fmt.Fprintf(w, "smoothing window: %d samples (auto)\n", option.smoothWindow)
 	}
-	saveChart(chartfile, graphWidth, graphHeight, chartOption, matrix)
 
-	// ローパスフィルタ適用
-	filtered, err := applySmoothing(matrix, 8)
+	// ノイズ除去フィルタ適用
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	filterSpan := span.child("filter")
+	filterSpan.setAttribute("filter", option.filter)
+	filtered, err := applyFilter(matrix, option)
 	if err != nil {
-		slog.Error("applySmoothing", "err", err)
+		filterSpan.finish(err)
+		slog.Error("applyFilter", "err", err)
 		return err
 	}
+	var rxFiltered mat.Matrix
+	if rxMatrix != nil {
+		rxFiltered, err = applyFilter(rxMatrix, option)
+		if err != nil {
+			filterSpan.finish(err)
+			slog.Error("applyFilter", "err", err)
+			return err
+		}
+		chartOption.RxMatrix = rxFiltered
+	}
+	filterSpan.finish(nil)
 
 	// フィルタ後グラフファイル
 	filteredChartFile := basename + "_" + ext[1:] + "_filtered.png"
 
 	// グラフをファイルに保存
-	chartOption.titleText = "ローパスフィルタ適用後"
-	saveChart(filteredChartFile, graphWidth, graphHeight, chartOption, filtered)
+	chartOption.Title = filterTitles[option.filter]
+	if charts[ChartFiltered] {
+		plots.saveChart(filteredChartFile, ChartFiltered, graphWidth, graphHeight, chartOption, filtered)
+	}
+
+	// フィルタ後の行列をCSVファイルに書き出す
+	if option.exportFiltered {
+		if err := exportMatrices(w, basename+"_"+ext[1:], "filtered", filtered, rxFiltered); err != nil {
+			return err
+		}
+	}
 
 	// 波形整形
-	reshaped, err := reshapeWaveform(matrix, baudrate)
-	if err != nil {
-		slog.Error("reshapeWaveform", "err", err)
-		return err
+	var reshaped, rxReshaped mat.Matrix
+	var originTime float64
+	// 打ち切った場合は整形後の行列の長さが合わないので解析キャッシュを使わない
+	if cache != nil && !earlyStopped {
+		reshaped, originTime = cache.Reshaped, cache.OriginTime
+		if cache.RxReshaped != nil {
+			rxReshaped = cache.RxReshaped
+		}
+	} else {
+		if err := checkCanceled(ctx); err != nil {
+			return err
+		}
+		reshapeSpan := span.child("reshape")
+		reshaped, rxReshaped, originTime, err = decodeWaveforms(matrix, rxMatrix, filtered, rxFiltered, option)
+		reshapeSpan.finish(err)
+		if err != nil {
+			slog.Error("decodeWaveforms", "err", err)
+			return err
+		}
+		if option.cache && !earlyStopped {
+			if err := saveAnalysisCache(cachePath, inputMatrix, header, reshaped, rxReshaped, originTime); err != nil {
+				slog.Error("saveAnalysisCache", "err", err)
+				return err
+			}
+		}
+	}
+	clock.originTime = originTime
+	if rxReshaped != nil {
+		chartOption.RxMatrix = rxReshaped
 	}
 
 	// 波形整形後グラフファイル
 	reshapedChartFile := basename + "_" + ext[1:] + "_reshaped.png"
 
 	// グラフをファイルに保存
-	chartOption.titleText = "波形整形後"
-	chartOption.yLabelText = "[1,-1]正規化"
-	saveChart(reshapedChartFile, graphWidth, graphHeight, chartOption, reshaped)
+	chartOption.Title = "波形整形後"
+	chartOption.YLabel = "[1,-1]正規化"
+	chartOption.Events = shiftEvents(events, originTime)
+	chartOption.Runts = runtTimes(allRunts, originTime)
+	if clock.absolute {
+		chartOption.XToTime = clock.relativeTime
+	}
+	if charts[ChartReshaped] {
+		plots.saveChart(reshapedChartFile, ChartReshaped, graphWidth, graphHeight, chartOption, reshaped)
+	}
+
+	// 整形後の行列をCSVファイルに書き出す
+	if option.exportReshaped {
+		if err := exportMatrices(w, basename+"_"+ext[1:], "reshaped", reshaped, rxReshaped); err != nil {
+			return err
+		}
+	}
 
 	// 解析
-	uartBitValues, uartCodes, err := analyzePulses(reshaped)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	decodeSpan := span.child("decode")
+	txUartBitValues, rxUartBitValues, uartCodes, err := decodeReshaped(reshaped, rxReshaped, option)
+	if err != nil {
+		decodeSpan.finish(err)
+		return err
+	}
+	decodeSpan.setAttribute("codes", fmt.Sprintf("%d", len(uartCodes)))
+	decodeSpan.finish(nil)
+	uartBitValues := append(txUartBitValues, rxUartBitValues...)
+
+	// 入力の行列に復号した結果の列を加えてCSVファイルに書き出す
+	if option.exportAnnotated {
+		if err := exportAnnotated(w, basename+"_"+ext[1:], matrix, rxMatrix, originTime, txUartBitValues, rxUartBitValues, uartCodes, option.format); err != nil {
+			return err
+		}
+	}
+
+	// 報告とグラフに使うフレームを絞り込む
+	// ターンアラウンドとストップビットは前後のフレームが要るので全てのフレームで測る
+	allFrames := groupFrames(uartCodes, option.format.charTime(baudrate), option.frameGap)
+	frames, err := whereFrames(w, allFrames, option)
 	if err != nil {
-		slog.Error("analyzePulses", "err", err)
 		return err
 	}
+	shownCodes, shownBits := uartCodes, uartBitValues
+	if option.where != "" {
+		shownCodes = frameCodes(frames)
+		shownBits = codeBits(uartBitValues, shownCodes)
+	}
+
+	// 測定値の全体を復号した結果
+	capture := DecodedCapture{
+		clock:      clock,
+		matrix:     matrix,
+		rxMatrix:   rxMatrix,
+		filtered:   filtered,
+		rxFiltered: rxFiltered,
+		originTime: originTime,
+		baudrate:   baudrate,
+		bits:       uartBitValues,
+		txBits:     txUartBitValues,
+		rxBits:     rxUartBitValues,
+		codes:      uartCodes,
+		frames:     frames,
+		runts:      runts,
+		rxRunts:    rxRunts,
+	}
 
 	// グラフファイル
 	uartChartFile := basename + "_" + ext[1:] + "_uart.png"
 
 	// グラフをファイルに保存
-	chartOption.titleText = "UART通信"
-	chartOption.yLabelText = "[1,-1]正規化"
-	chartOption.uartBitValues = uartBitValues
-	chartOption.uartCodes = uartCodes
-	saveChart(uartChartFile, graphWidth, graphHeight, chartOption, reshaped)
+	chartOption.Title = "UART通信"
+	chartOption.YLabel = "[1,-1]正規化"
+	chartOption.Bits = chartBits(shownBits)
+	chartOption.Codes = chartCodes(shownCodes)
+	chartOption.BitPeriod = 1 / float64(baudrate)
+	if option.frameTable {
+		chartOption.FrameTable = frameTableRows(frames, uartBitValues, clock, option.crcKind)
+	}
+	if charts[ChartUart] {
+		plots.saveChart(uartChartFile, ChartUart, graphWidth, graphHeight, chartOption, reshaped)
+	}
 
 	// 表示
-	bytes := []byte{}
-	for _, v := range uartCodes {
-		bytes = append(bytes, v.octet)
+	printDecodedCodes(w, clock, uartCodes, rxMatrix != nil, option)
+	// 雑音の床で0にした行と送信の途中で始まった取り込みの読み飛ばし
+	resyncs := reportNoiseGateAndResync(w, capture, option)
+	// 取り込みの終わりで途切れた文字とフレーム
+	// 解析を打ち切った場合は取り込みの終わりではないので調べない
+	truncations := []AnomalyEvent{}
+	if !earlyStopped {
+		rows, _ := matrix.Dims()
+		captureEnd := matrix.At(rows-1, ColTime) - originTime
+		truncations = reportTruncations(w, clock, txUartBitValues, rxUartBitValues, rxMatrix != nil, captureEnd, baudrate, option)
 	}
-	if len(bytes) > 0 {
-		stdoutDumper := hex.Dumper(os.Stdout)
-		defer stdoutDumper.Close()
-		binary.Write(stdoutDumper, binary.LittleEndian, bytes)
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
 	}
 
-	if false {
-		matPrint(matrix)
+	// 復号した結果を機械可読な形式で保存する
+	// --strictで打ち切る場合もそれまでに頼んだグラフは保存してから戻る
+	if err := saveDecodedAndCheckStrict(w, basename+"_"+ext[1:], clock, uartBitValues, txUartBitValues, rxUartBitValues, uartCodes, frames, allFrames, baudrate, option); err != nil {
+		return err
 	}
 
-	return nil
-}
+	// ターンアラウンド
+	turnarounds := reportTurnaround(w, capture, allFrames, option)
 
-func init() {
-	// IPAexゴシックフォントを準備する
-	ttf, err := opentype.Parse(fontDataIpaexGothic)
+	// ストップビットの数と文字間の無通信時間
+	reportStopBits(w, allFrames, rxMatrix != nil, baudrate, option.format)
+
+	// フレームを保存する
+	if err := saveFrameOutputs(w, clock, frames, option); err != nil {
+		return err
+	}
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+
+	// 検出した異常
+	anomalies := framingAnomalies(uartBitValues)
+	anomalies = append(anomalies, parityAnomalies(uartCodes)...)
+	anomalies = append(anomalies, turnarounds...)
+	anomalies = append(anomalies, resyncs...)
+	anomalies = append(anomalies, truncations...)
+	anomalies = append(anomalies, glitchAnomalies(matrix, originTime, baudrate)...)
+	if rxMatrix != nil {
+		anomalies = append(anomalies, glitchAnomalies(rxMatrix, originTime, baudrate)...)
+	}
+
+	// 長さの狂ったビットとMarkとSpaceの長さの偏り
+	anomalies = append(anomalies, reportBitPulses(w, capture, option)...)
+
+	// ラント
+	anomalies = append(anomalies, reportRunts(w, capture)...)
+
+	// 誤り検出符号
+	if option.crcKind != CrcNone {
+		crcErrors := crcAnomalies(frames, option.crcKind)
+		fmt.Fprintf(w, "crc errors: %d\n", len(crcErrors))
+		anomalies = append(anomalies, crcErrors...)
+	}
+
+	// 1ビットの訂正, ビット毎の軟判定, 機械学習向けのビット毎の特徴量
+	bitAnomalies, err := reportBitWaveforms(w, capture, option)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	anomalies = append(anomalies, bitAnomalies...)
 
-	fontIpaexGothic = font.Font{Typeface: "IPAexGothic"}
-	font.DefaultCache.Add([]font.Face{
-		{
-			Font: fontIpaexGothic,
-			Face: ttf,
-		},
-	})
+	// フレームからの報告とグラフ
+	rows, _ := matrix.Dims()
+	traffic, frameAnomalies, err := reportFrames(ctx, w, plots, FrameReportInput{
+		prefix:       basename + "_" + ext[1:],
+		clock:        clock,
+		frames:       frames,
+		codes:        uartCodes,
+		captureStart: matrix.At(0, ColTime) - originTime,
+		captureEnd:   matrix.At(rows-1, ColTime) - originTime,
+		originTime:   originTime,
+		duplex:       rxMatrix != nil,
+		events:       events,
+		chartOption:  chartOption,
+		charts:       charts,
+		baudrate:     baudrate,
+	}, option)
+	if err != nil {
+		return err
+	}
+	anomalies = append(anomalies, frameAnomalies...)
 
-	if !font.DefaultCache.Has(fontIpaexGothic) {
-		panic(fmt.Errorf("typeface %s, font load error", fontIpaexGothic.Typeface))
+	// ドライバイネーブル
+	anomalies = append(anomalies, reportDriverEnable(w, capture, option)...)
+
+	// バスの状態
+	busAnomalies, err := reportBusStates(w, capture, option)
+	if err != nil {
+		return err
 	}
+	anomalies = append(anomalies, busAnomalies...)
 
-	// デフォルトフォントをIPAexゴシックにする
-	plot.DefaultFont = fontIpaexGothic
-	plotter.DefaultFont = fontIpaexGothic
-}
+	// スルーレート
+	anomalies = append(anomalies, reportSlewRate(w, capture, option)...)
 
-func main() {
-	var (
-		baudrate    int
-		graphWidth  int
-		graphHeight int
-	)
-
-	app := &cli.App{
-		Name:    "pulseinsight",
-		Usage:   "RS485バスの測定値を解析する",
-		Version: "1.0.0",
-		Flags: []cli.Flag{
-			&cli.IntFlag{
-				Name:        "baudrate",
-				Aliases:     []string{"baud"},
-				Usage:       "ボーレート",
-				Destination: &baudrate,
-				Value:       9600,
-			},
-			&cli.IntFlag{
-				Name:        "width",
-				Aliases:     []string{"W", "Wpx"},
-				Usage:       "グラフの横ピクセル",
-				Destination: &graphWidth,
-				Value:       640 * 16,
-			},
+	// アイダイアグラムのマスク試験
+	anomalies = append(anomalies, reportEyeMask(w, capture, eyeMask, option)...)
+
+	// ビット誤り率試験
+	reportBert(w, clock, uartCodes, rxMatrix != nil, baudrate, option)
+
+	// 推奨する受信側の設定とバスの助言
+	reportRecommendations(w, capture, option)
+
+	// 1枚にまとめた画像
+	if option.dashboard && charts.any() {
+		dashboardFile := basename + "_" + ext[1:] + "_dashboard.png"
+		dashboardOption := chart.Option{
+			Title:     "A-B間電圧差",
+			XLabel:    "時間(s)",
+			PngTexts:  option.provenance.pngTexts(),
+			Threshold: option.threshold,
+		}
+		if clock.absolute {
+			dashboardOption.XLabel = "時刻"
+			dashboardOption.XToTime = clock.captureTime
+		}
+		metrics := dashboardMetrics(csvfilepath, baudrate, traffic, anomalies)
+		plots.saveDashboard(dashboardFile, dashboardOption, eyeMask, matrix, txUartBitValues, originTime, baudrate, metrics)
+		fmt.Fprintf(w, "dashboard \"%s\"\n", dashboardFile)
+	}
+
+	// 異常の一覧
+	if option.anomalyFile != "" {
+		if err := saveAnomalies(option.anomalyFile, clock, anomalies, option.provenance); err != nil {
+			slog.Error("saveAnomalies", "err", err)
+			return err
+		}
+	}
+	// 解析の説明
+	if option.meta {
+		if err := saveInsightMeta(w, basename+"_"+ext[1:]+".meta.json", capture, traffic, anomalies, earlyStopped, option); err != nil {
+			return err
+		}
+	}
+	// 描画段が終わるのを待つ
+	if err := plots.wait(); err != nil {
+		slog.Error("plot", "err", err)
+		return err
+	}
+
+	if option.failOnError && hasErrorAnomaly(anomalies) {
+		return cli.Exit("重大な異常を検出した", 1)
+	}
+
+	return nil
+}
+
+// フレームを指定したファイルに保存して, 参照と比べ, ソースコードの配列として表示する
+// pcap, フレームの一覧, ペイロード, Intel HEX / S-record, シーケンス図
+func saveFrameOutputs(w io.Writer, clock Clock, frames []UartFrame, option InsightOption) error {
+	// フレームをpcap形式で保存する
+	if option.pcapFile != "" {
+		if err := savePcap(option.pcapFile, clock, frames); err != nil {
+			slog.Error("savePcap", "err", err)
+			return err
+		}
+	}
+
+	// フレームの一覧を保存して参照と比べる
+	if option.framesFile != "" || option.referenceFile != "" {
+		records := frameRecords(frames, clock, option.addressByte)
+		// 同じファイルを保存先にした場合に備えて参照を先に読む
+		if option.referenceFile != "" {
+			reference, err := loadFrames(option.referenceFile)
+			if err != nil {
+				slog.Error("loadFrames", "err", err)
+				return err
+			}
+			printFrameDiff(w, clock, option.referenceFile, reference, records, diffFrames(reference, records))
+		}
+		if option.framesFile != "" {
+			if err := saveFrames(option.framesFile, records, option.provenance); err != nil {
+				slog.Error("saveFrames", "err", err)
+				return err
+			}
+		}
+	}
+
+	// フレーム毎のペイロードを別々のファイルに保存する
+	if option.payloadDir != "" {
+		if err := savePayloads(option.payloadDir, clock, frames, option.crcKind); err != nil {
+			slog.Error("savePayloads", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "payloads: %d files \"%s\"\n", len(frames), option.payloadDir)
+	}
+
+	// ペイロードをIntel HEXかS-recordで保存する
+	if option.hexFile != "" {
+		var address FrameExpr
+		if option.hexAddress != "" {
+			var err error
+			if address, err = parseWhere(option.hexAddress); err != nil {
+				slog.Error("parseWhere", "err", err)
+				return err
+			}
+		}
+		segments, err := hexSegments(frames, option.crcKind, address, option.addressByte, option.hexSkip)
+		if err != nil {
+			slog.Error("hexSegments", "err", err)
+			return err
+		}
+		if err := saveHexFile(option.hexFile, segments); err != nil {
+			slog.Error("saveHexFile", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "hex file: %d segments \"%s\"\n", len(segments), option.hexFile)
+	}
+
+	// フレームをソースコードの配列として表示する
+	if option.dumpCode != DumpCodeNone {
+		printDumpCode(w, clock, frames, option.dumpCode)
+	}
+
+	// 通信の流れをシーケンス図で保存する
+	if option.sequenceFile != "" {
+		messages, err := sequenceMessages(frames, option.addressByte)
+		if err != nil {
+			slog.Error("sequenceMessages", "err", err)
+			return err
+		}
+		if err := saveSequenceDiagram(option.sequenceFile, clock, messages); err != nil {
+			slog.Error("saveSequenceDiagram", "err", err)
+			return err
+		}
+		fmt.Fprintf(w, "sequence diagram: %d messages \"%s\"\n", len(messages), option.sequenceFile)
+	}
+	return nil
+}
+
+func init() {
+	// IPAexゴシックフォントを準備する
+	ttf, err := opentype.Parse(fontDataIpaexGothic)
+	if err != nil {
+		panic(err)
+	}
+
+	fontIpaexGothic = font.Font{Typeface: "IPAexGothic"}
+	font.DefaultCache.Add([]font.Face{
+		{
+			Font: fontIpaexGothic,
+			Face: ttf,
+		},
+	})
+
+	if !font.DefaultCache.Has(fontIpaexGothic) {
+		panic(fmt.Errorf("typeface %s, font load error", fontIpaexGothic.Typeface))
+	}
+
+	// デフォルトフォントをIPAexゴシックにする
+	plot.DefaultFont = fontIpaexGothic
+	plotter.DefaultFont = fontIpaexGothic
+}
+
+// コマンドラインアプリケーションを作る
+func newApp() *cli.App {
+	var option InsightOption
+	var extcap ExtcapOption
+	var profile ProfileOption
+
+	return &cli.App{
+		Name:                 "pulseinsight",
+		Usage:                "RS485バスの測定値を解析する",
+		Version:              "1.0.0",
+		EnableBashCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "profile",
+				Usage:       "名前を付けた設定(プロファイル)を使う(modbus-9600, modbus-19200, dmx, 設定ファイルのプロファイル), コマンドラインで指定したフラグが優先する",
+				Destination: &option.profile,
+			},
+			&cli.StringFlag{
+				Name:        "profiles-file",
+				Usage:       "プロファイルの設定ファイル(JSON), 空はユーザーの設定ディレクトリのpulseinsight/profiles.json",
+				Destination: &option.profilesFile,
+			},
+			&cli.IntFlag{
+				Name:        "baudrate",
+				Aliases:     []string{"baud"},
+				Usage:       "ボーレート",
+				Destination: &option.baudrate,
+				Value:       9600,
+			},
+			&cli.StringFlag{
+				Name:        "estimate-baud",
+				Usage:       "測定値からボーレートを推定して使う(none, pulse: 最も短いパルスの幅, autocorrelation: エッジ間隔の自己相関)",
+				Destination: &option.estimateBaud,
+				Value:       EstimateBaudNone,
+			},
+			&cli.StringFlag{
+				Name:        "frame",
+				Usage:       "文字の形式(データビット数5-8, パリティN/E/O/M/S, ストップビット数1/1.5/2 の順, 例: 8N1, 7E1, 8O2)",
+				Destination: &option.frameFormat,
+				Value:       DefaultUartFormat,
+			},
+			&cli.IntFlag{
+				Name:        "databits",
+				Usage:       "データビット数(5-8), 0は--frameのまま",
+				Destination: &option.dataBits,
+			},
+			&cli.StringFlag{
+				Name:        "parity",
+				Usage:       "パリティ(none, even, odd, mark, space), 空は--frameのまま",
+				Destination: &option.parity,
+			},
+			&cli.Float64Flag{
+				Name:        "stopbits",
+				Usage:       "ストップビット数(1, 1.5, 2), 0は--frameのまま",
+				Destination: &option.stopBits,
+			},
+			&cli.IntFlag{
+				Name:        "width",
+				Aliases:     []string{"W", "Wpx"},
+				Usage:       "グラフの横ピクセル",
+				Destination: &option.graphWidth,
+				Value:       640 * 16,
+			},
 			&cli.IntFlag{
 				Name:        "height",
 				Aliases:     []string{"H", "Hpx"},
 				Usage:       "グラフの縦ピクセル",
-				Destination: &graphHeight,
+				Destination: &option.graphHeight,
 				Value:       640,
 			},
+			&cli.Float64Flag{
+				Name:        "frame-gap",
+				Usage:       "フレームの区切りとみなす無通信時間(文字数)",
+				Destination: &option.frameGap,
+				Value:       1.5,
+			},
+			&cli.Float64Flag{
+				Name:        "min-turnaround",
+				Usage:       "応答までの最小ターンアラウンド時間(s), 0の場合は3.5文字分",
+				Destination: &option.minTurnaround,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "de-threshold",
+				Usage:       "ドライバイネーブル(DE/RE)信号のしきい値(V)",
+				Destination: &option.deThreshold,
+				Value:       1.5,
+			},
+			&cli.Float64Flag{
+				Name:        "driven-threshold",
+				Usage:       "バスが駆動されているとみなすA-B間電圧差の大きさ(V)",
+				Destination: &option.drivenThreshold,
+				Value:       1.5,
+			},
+			&cli.StringFlag{
+				Name:        "idle-bias",
+				Usage:       "どのドライバも駆動していない時のA-B間電圧差(V), " + IdleBiasAuto + "の場合は測定値から決める",
+				Destination: &option.idleBias,
+				Value:       IdleBiasAuto,
+			},
+			&cli.Float64Flag{
+				Name:        "idle-band",
+				Usage:       "どのドライバも駆動していない時のA-B間電圧差の許容範囲(±V)",
+				Destination: &option.idleBand,
+				Value:       0.3,
+			},
+			&cli.StringFlag{
+				Name:        "bus-state-file",
+				Usage:       "バスの状態(mark, space, idle, transition)の区間を保存するCSVファイル",
+				Destination: &option.busStateFile,
+			},
+			&cli.Float64Flag{
+				Name:        "de-min-lead",
+				Usage:       "ドライバ有効からスタートビットまでの最小時間(s)",
+				Destination: &option.deMinLead,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "de-max-release",
+				Usage:       "ストップビット終了からドライバ無効までの最大時間(s), 0の場合は1ビット分",
+				Destination: &option.deMaxRelease,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "min-slew",
+				Usage:       "A,B間電圧差の最小スルーレート(V/us), 0の場合は制限なし",
+				Destination: &option.minSlew,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "max-slew",
+				Usage:       "A,B間電圧差の最大スルーレート(V/us), 0の場合は制限なし",
+				Destination: &option.maxSlew,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "max-transition",
+				Usage:       "最大遷移時間(ビット周期に対する比)",
+				Destination: &option.maxTransition,
+				Value:       0.3,
+			},
+			&cli.Float64Flag{
+				Name:        "bit-tolerance",
+				Usage:       "文字の中のパルスの長さとビット周期の整数倍との差の許容範囲(ビット周期に対する比)",
+				Destination: &option.bitTolerance,
+				Value:       0.25,
+			},
+			&cli.IntFlag{
+				Name:        "prbs",
+				Usage:       "PRBS7またはPRBS15パターンでビット誤り率試験をする(7, 15)",
+				Destination: &option.prbsOrder,
+				Value:       0,
+			},
+			&cli.StringFlag{
+				Name:        "events",
+				Usage:       "外部イベントログファイル(各行に時間(s),内容のCSV、または[{\"time\":秒,\"label\":内容}]のJSON)",
+				Destination: &option.eventsFile,
+			},
+			&cli.StringFlag{
+				Name:        "t0",
+				Usage:       "入力CSVの時間0の時刻(例 \"2025-06-01T12:34:56.789+09:00\"), 指定すると絶対時刻で表示する",
+				Destination: &option.t0,
+			},
+			&cli.IntFlag{
+				Name:        "address-byte",
+				Usage:       "フレーム内のアドレスの位置(0始まりのバイト数)",
+				Destination: &option.addressByte,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "utilization-window",
+				Usage:       "バス使用率の時間変化のグラフの区間(s), 0の場合はグラフを作らない",
+				Destination: &option.utilWindow,
+				Value:       0,
+			},
+			&cli.Float64Flag{
+				Name:        "poll-interval",
+				Usage:       "主局の要求(ポーリング)の予定の周期(s), 実際の要求の開始時間と比べてずれと抜けを表示する, 0の場合は調べない",
+				Destination: &option.pollInterval,
+				Value:       0,
+			},
+			&cli.StringFlag{
+				Name:        "crc",
+				Usage:       "フレームの誤り検出符号の種類(none, modbus)",
+				Destination: &option.crcKind,
+				Value:       CrcNone,
+			},
+			&cli.StringFlag{
+				Name:        "anomalies",
+				Usage:       "検出した異常の一覧を保存するファイル(.jsonの場合はJSON、それ以外はCSV)",
+				Destination: &option.anomalyFile,
+			},
+			&cli.BoolFlag{
+				Name:        "fail-on-error",
+				Usage:       "重大度errorの異常を検出したら終了コードを0以外にする",
+				Destination: &option.failOnError,
+			},
+			&cli.StringFlag{
+				Name:        "bad-rows",
+				Usage:       "入力CSVの列数が合わない行や数値でない行の扱い(skip:警告を出して読み飛ばす, abort:中止する)",
+				Destination: &option.badRows,
+				Value:       BadRowsSkip,
+			},
+			&cli.StringFlag{
+				Name:        "time-col",
+				Aliases:     []string{"col-time"},
+				Usage:       "時間の列をヘッダー行の列名か1始まりの列番号で選ぶ",
+				Destination: &option.columnNames[0],
+			},
+			&cli.StringFlag{
+				Name:        "a-col",
+				Aliases:     []string{"col-a"},
+				Usage:       "A線電圧の列をヘッダー行の列名か1始まりの列番号で選ぶ",
+				Destination: &option.columnNames[1],
+			},
+			&cli.StringFlag{
+				Name:        "b-col",
+				Aliases:     []string{"col-b"},
+				Usage:       "B線電圧の列をヘッダー行の列名か1始まりの列番号で選ぶ",
+				Destination: &option.columnNames[2],
+			},
+			&cli.IntFlag{
+				Name:        "skip-lines",
+				Usage:       "データ行の前のヘッダー行の数(0はヘッダー行無し)",
+				Value:       waveform.DefaultHeaderLines,
+				Destination: &option.skipLines,
+			},
+			&cli.StringFlag{
+				Name:        "delimiter",
+				Usage:       "CSVファイルの区切り文字(1文字か, tab, space, semicolon)",
+				Value:       ",",
+				Destination: &option.delimiter,
+			},
+			&cli.StringFlag{
+				Name:        "time-unit",
+				Usage:       "入力CSVの時間列の単位(s, ms, us, ns), 指定しない場合はヘッダー行から検出する",
+				Destination: &option.timeUnit,
+			},
+			&cli.StringFlag{
+				Name:        "voltage-unit",
+				Usage:       "入力CSVの電圧列の単位(V, mV), 指定しない場合はヘッダー行から検出する",
+				Destination: &option.voltageUnit,
+			},
+			&cli.Float64Flag{
+				Name:        "wav-full-scale",
+				Usage:       "WAVファイルのフルスケール(最大の値)に当たる電圧(V), 左右のチャンネルで違う場合は--a-scale, --b-scaleで合わせる",
+				Value:       1,
+				Destination: &option.wavFullScale,
+			},
+			&cli.StringFlag{
+				Name:        "b-file",
+				Usage:       "Tektronixの波形ファイル(.isf)のB線のファイル, 省略時はA線のファイル名のCH1をCH2にしたファイル",
+				Destination: &option.bFile,
+			},
+			&cli.Float64Flag{
+				Name:        "a-scale",
+				Usage:       "A線のプローブの減衰比(10:1プローブなら10)",
+				Destination: &option.aScale,
+				Value:       1,
+			},
+			&cli.Float64Flag{
+				Name:        "b-scale",
+				Usage:       "B線のプローブの減衰比(10:1プローブなら10)",
+				Destination: &option.bScale,
+				Value:       1,
+			},
+			&cli.BoolFlag{
+				Name:        "invert-a",
+				Usage:       "A線の極性を反転する(差動プローブの向きが逆の場合)",
+				Destination: &option.invertA,
+			},
+			&cli.BoolFlag{
+				Name:        "invert-b",
+				Usage:       "B線の極性を反転する(差動プローブの向きが逆の場合)",
+				Destination: &option.invertB,
+			},
+			&cli.Float64Flag{
+				Name:        "skew",
+				Usage:       "B線のA線に対する遅れ(s), B線をこの時間だけ早めて補正する",
+				Destination: &option.skew,
+				Value:       0,
+			},
+			&cli.StringFlag{
+				Name:        "filter",
+				Usage:       "ノイズ除去フィルタ(sma:移動平均, wavelet:ウェーブレット縮退, ema:指数移動平均, kalman:カルマンフィルタ)",
+				Destination: &option.filter,
+				Value:       FilterSma,
+			},
+			&cli.IntFlag{
+				Name:        "window",
+				Usage:       "移動平均の窓の大きさ(サンプル数), 0の場合はビット周期の1/8にする",
+				Destination: &option.smoothWindow,
+				Value:       0,
+			},
+			&cli.IntFlag{
+				Name:        "wavelet-levels",
+				Usage:       "ウェーブレット縮退の分解レベル",
+				Destination: &option.waveletLevels,
+				Value:       3,
+			},
+			&cli.Float64Flag{
+				Name:        "ema-alpha",
+				Usage:       "指数移動平均の係数(0より大きく1以下)",
+				Destination: &option.emaAlpha,
+				Value:       0.25,
+			},
+			&cli.Float64Flag{
+				Name:        "kalman-q",
+				Usage:       "カルマンフィルタのプロセス雑音の分散(V^2)",
+				Destination: &option.kalmanQ,
+				Value:       1e-4,
+			},
+			&cli.Float64Flag{
+				Name:        "kalman-r",
+				Usage:       "カルマンフィルタの観測雑音の分散(V^2)",
+				Destination: &option.kalmanR,
+				Value:       0.25,
+			},
+			&cli.BoolFlag{
+				Name:        "decode-filtered",
+				Usage:       "生の波形ではなくノイズ除去フィルタ適用後の波形を解析する",
+				Destination: &option.decodeFilter,
+			},
+			&cli.BoolFlag{
+				Name:        "strict",
+				Usage:       "最初のフレーミングエラー, パリティエラー, 誤り検出符号の誤りを表示して解析を打ち切る(適合試験向け)",
+				Destination: &option.strict,
+			},
+			&cli.BoolFlag{
+				Name:        "permissive",
+				Usage:       "ストップビットが0の場合は文字の中の立ち下がりから同期し直して続け, 直せない文字も残す(乱れた測定値からの回収向け)",
+				Destination: &option.permissive,
+			},
+			&cli.BoolFlag{
+				Name:        "correct",
+				Usage:       "ストップビットや誤り検出符号が合わない文字やフレームを、際どいビットを1つ反転して直してみる",
+				Destination: &option.correct,
+			},
+			&cli.StringFlag{
+				Name:        "soft-bits",
+				Usage:       "ビット毎の軟判定(しきい値からの距離, ビット区間での安定度, 確からしさ)を保存するCSVファイル",
+				Destination: &option.softBitsFile,
+			},
+			&cli.StringFlag{
+				Name:        "bit-features",
+				Usage:       "機械学習向けのビット毎の特徴量(幅, 平均電圧, 分散, エッジの傾き)を保存するCSVファイル(拡張子が.parquetの場合はParquet形式)",
+				Destination: &option.bitFeaturesFile,
+			},
+			&cli.StringFlag{
+				Name:        "input-type",
+				Usage:       "入力CSVの値の種類(" + InputAnalog + ":A線とB線の電圧, " + InputLogic + ":ロジックアナライザの論理レベル0/1)",
+				Value:       InputAnalog,
+				Destination: &option.inputType,
+			},
+			&cli.StringFlag{
+				Name:        "edge-detect",
+				Usage:       "エッジ検出の方式(level:電圧差のしきい値, derivative:電圧差の微分とゼロ交差)",
+				Destination: &option.edgeDetect,
+				Value:       EdgeLevel,
+			},
+			&cli.StringFlag{
+				Name:        "idle-level",
+				Usage:       "アイドルの向き(mark:A-B間電圧差が正, space:負になる配線でスタートビットをMarkとして復号する)",
+				Destination: &option.idleLevel,
+				Value:       IdleLevelMark,
+			},
+			&cli.Float64Flag{
+				Name:        "threshold",
+				Usage:       "復号のしきい値(MarkとSpaceを分けるA-B間電圧差の大きさ, V), 0の場合は1V(calibrateで決められる)",
+				Destination: &option.threshold,
+			},
+			&cli.Float64Flag{
+				Name:        "noise-floor",
+				Usage:       "波形整形の前に0にする(雑音とみなす)A-B間電圧差の大きさ(V), 0の場合は0にしない",
+				Destination: &option.noiseFloor,
+			},
+			&cli.StringFlag{
+				Name:        "resync",
+				Usage:       "取り込みが送信の途中で始まった場合に十分な無通信まで読み飛ばす(auto:Spaceで始まった場合, idle:始めの無通信が短い場合も, off:読み飛ばさない)",
+				Destination: &option.resync,
+				Value:       ResyncAuto,
+			},
+			&cli.Float64Flag{
+				Name:        "resync-idle",
+				Usage:       "--resyncで同期に使う無通信のビット数(0の場合は1文字の長さ)",
+				Destination: &option.resyncIdle,
+			},
+			&cli.IntFlag{
+				Name:        "tiles",
+				Usage:       "指定した幅(px)のタイル画像ピラミッドとHTMLビューアを作る(0:作らない)",
+				Destination: &option.tileWidth,
+			},
+			&cli.BoolFlag{
+				Name:        "export-filtered",
+				Usage:       "フィルタ後の行列を[入力ファイル名]_filtered.csvに書き出す",
+				Destination: &option.exportFiltered,
+			},
+			&cli.BoolFlag{
+				Name:        "export-reshaped",
+				Usage:       "整形後の行列を[入力ファイル名]_reshaped.csvに書き出す",
+				Destination: &option.exportReshaped,
+			},
+			&cli.BoolFlag{
+				Name:        "export-annotated",
+				Usage:       "入力の行列に復号した論理レベル, ビット番号, バイト値の列を加えて[入力ファイル名]_annotated.csvに書き出す",
+				Destination: &option.exportAnnotated,
+			},
+			&cli.BoolFlag{
+				Name:        "meta",
+				Usage:       "出力ファイルの横に入力ファイル、解析設定、検出した性質、結果の要約を書いた[基本名]_csv.meta.jsonを作る",
+				Destination: &option.meta,
+			},
+			&cli.StringFlag{
+				Name:        "output-prefix",
+				Usage:       "出力ファイルの基本名(例 out/capture で out/capture_csv_uart.png), 省略時は入力ファイルの名前(標準入力 - の場合は stdin)",
+				Destination: &option.outputPrefix,
+			},
+			&cli.BoolFlag{
+				Name:        "overwrite",
+				Usage:       "既に有る出力ファイルを警告せずに置き換える",
+				Destination: &option.overwrite,
+			},
+			&cli.BoolFlag{
+				Name:        "version-outputs",
+				Usage:       "既に有る出力ファイルは残して、時刻を付けた別の名前(例 [基本名]_20250102T150405_csv_uart.png)で出力する",
+				Destination: &option.versionOutputs,
+			},
+			&cli.BoolFlag{
+				Name:        "cache",
+				Usage:       "読み込みと波形整形の結果をキャッシュして、同じ入力と解析設定の再実行を速くする",
+				Destination: &option.cache,
+			},
+			&cli.StringFlag{
+				Name:        "cache-dir",
+				Usage:       "解析キャッシュのディレクトリ(既定はユーザーのキャッシュディレクトリ/pulseinsight)",
+				Destination: &option.cacheDir,
+			},
+			&cli.BoolFlag{
+				Name:        "stitch",
+				Usage:       "続けて測定した複数のCSVファイルを時間順につなげて1つとして解析する",
+				Destination: &option.stitch,
+			},
+			&cli.BoolFlag{
+				Name:        "segments",
+				Usage:       "時間が戻った所で複数の取り込み(セグメント)に分けて、セグメント毎に解析する",
+				Destination: &option.segments,
+			},
+			&cli.StringFlag{
+				Name:        "segment-col",
+				Usage:       "セグメント番号の列名(値が変わった所でセグメントに分ける)",
+				Destination: &option.segmentCol,
+			},
+			&cli.BoolFlag{
+				Name:        "follow",
+				Usage:       "測定ソフトが書き込み中のCSVファイルを追いかけて、書き足された行を復号して表示する(Ctrl-Cで終わる)",
+				Destination: &option.follow,
+			},
+			&cli.BoolFlag{
+				Name:        "stream",
+				Usage:       "CSVファイル全体を行列にせずに塊ごとにフィルタ、波形整形、復号へ流す(メモリに収まらない長い測定値向け、グラフは間引いた測定値で描く)",
+				Destination: &option.stream,
+			},
+			&cli.BoolFlag{
+				Name:        "live-frames",
+				Usage:       "CSVファイルを読み込みながら復号して、フレームを見つけ次第表示する(長いファイルの途中経過)",
+				Destination: &option.liveFrames,
+			},
+			&cli.StringFlag{
+				Name:        "cpuprofile",
+				Usage:       "CPUプロファイルを保存するファイル",
+				Destination: &profile.cpuprofile,
+			},
+			&cli.StringFlag{
+				Name:        "memprofile",
+				Usage:       "メモリプロファイルを保存するファイル",
+				Destination: &profile.memprofile,
+			},
+			&cli.StringFlag{
+				Name:        "trace",
+				Usage:       "実行トレースを保存するファイル",
+				Destination: &profile.trace,
+			},
+			&cli.StringFlag{
+				Name:        "pcap",
+				Usage:       "フレームをpcap形式(DLT_USER0)で保存するファイル",
+				Destination: &option.pcapFile,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "復号した結果(フレーム, 文字, ビットの時間と誤り)を保存する形式(json, csv, bin: 復号したバイトだけ)",
+				Destination: &option.decodeOutput,
+			},
+			&cli.StringFlag{
+				Name:        "out-file",
+				Usage:       "--outputの保存先, 空は[入力ファイル]_decoded.[形式], -は標準出力(解析の報告は標準エラー出力に書く)",
+				Destination: &option.outFile,
+			},
+			&cli.StringFlag{
+				Name:        "frames-file",
+				Usage:       "フレームの一覧を保存するJSONファイル(--referenceで比べる参照になる)",
+				Destination: &option.framesFile,
+			},
+			&cli.StringFlag{
+				Name:        "reference",
+				Usage:       "以前に--frames-fileで保存したフレームの一覧と比べて、増えた、無くなった、変わったフレームを表示する",
+				Destination: &option.referenceFile,
+			},
+			&cli.StringFlag{
+				Name:        "sequence",
+				Usage:       "通信の流れを保存するシーケンス図のファイル(拡張子.mmdはMermaid, .pumlはPlantUML)",
+				Destination: &option.sequenceFile,
+			},
+			&cli.StringFlag{
+				Name:        "payload-dir",
+				Usage:       "フレーム毎のペイロード(--crcを指定した場合は誤り検出符号を除く)を[番号]_[開始時刻].binとして保存するディレクトリ",
+				Destination: &option.payloadDir,
+			},
+			&cli.StringFlag{
+				Name:        "hex",
+				Usage:       "ペイロードを保存するファイル(拡張子.hexはIntel HEX, .srecはMotorola S-record)",
+				Destination: &option.hexFile,
+			},
+			&cli.StringFlag{
+				Name:        "hex-address",
+				Usage:       "ペイロードのアドレスを求める--whereと同じ書式の式(例: \"byte[2]<<8|byte[3]\"), 省略するとペイロードをつなげたバイト位置",
+				Destination: &option.hexAddress,
+			},
+			&cli.IntFlag{
+				Name:        "hex-skip",
+				Usage:       "ペイロードの先頭から除くバイト数(アドレスなどのプロトコルの欄)",
+				Destination: &option.hexSkip,
+			},
+			&cli.IntFlag{
+				Name:        "max-frames",
+				Usage:       "このフレーム数を復号した所で解析を打ち切る(0は打ち切らない)",
+				Destination: &option.maxFrames,
+			},
+			&cli.StringFlag{
+				Name:        "stop-after",
+				Usage:       "この式(--whereと同じ書式)に合うフレームを復号した所で解析を打ち切る",
+				Destination: &option.stopAfter,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Usage:       "1ファイルの解析時間の上限(例: 30s), 過ぎたら解析を止める(0は上限なし)",
+				Destination: &option.timeout,
+			},
+			&cli.StringFlag{
+				Name:        "trace-file",
+				Usage:       "解析の段(parse, filter, reshape, decode, plot)の時間をOpenTelemetryのトレース(OTLP/JSON)としてファイルに書き足す",
+				Destination: &option.traceFile,
+			},
+			&cli.StringFlag{
+				Name:        "eye-mask",
+				Usage:       "アイダイアグラムのマスク(JSONの多角形)に入ったビットを違反として合否を表示する",
+				Destination: &option.eyeMaskFile,
+			},
+			&cli.BoolFlag{
+				Name:        "dashboard",
+				Usage:       "波形の縮小図, アイダイアグラム, 異常の数と主な指標を1枚にまとめた画像(_dashboard.png)を作る",
+				Destination: &option.dashboard,
+			},
+			&cli.BoolFlag{
+				Name:        "frame-table",
+				Usage:       "UART通信のグラフの下にフレームの一覧表(番号, 時刻, バイト列, 状態)を描く",
+				Destination: &option.frameTable,
+			},
+			&cli.Float64Flag{
+				Name:        "compress-idle",
+				Usage:       "グラフの横軸でこの文字数より長い無通信時間を詰める(0で詰めない)",
+				Destination: &option.compressIdle,
+			},
+			&cli.StringFlag{
+				Name:        "charts",
+				Value:       DefaultCharts,
+				Usage:       "作るグラフのカンマ区切りの一覧(raw:測定値, filtered:フィルタ後, reshaped:波形整形後, uart:復号結果, stacked:A,B線とA-B間電圧差を縦に並べた測定値, diff:A-B間電圧差だけの測定値, timeline:フレームのタイムライン, heatmap:バイト値のヒートマップ, bytegap:バイト間の無通信時間, framegap:フレーム間の無通信時間, none:作らない)",
+				Destination: &option.charts,
+			},
+			&cli.IntFlag{
+				Name:        "plot-jobs",
+				Usage:       "同時に描くグラフの数(0はCPUの数, 1は順に描く)",
+				Destination: &option.plotWorkers,
+				Value:       0,
+			},
+			&cli.StringFlag{
+				Name:        "dump-code",
+				Usage:       "フレームをソースコードの配列として表示する(c:Cのuint8_t配列, go:Goの[]byte)",
+				Destination: &option.dumpCode,
+			},
+			&cli.StringFlag{
+				Name:        "traffic-matrix",
+				Usage:       "送信元と宛先の組ごとのフレーム数とバイト数を保存するCSVファイル",
+				Destination: &option.trafficFile,
+			},
+			&cli.StringFlag{
+				Name:        "where",
+				Usage:       "報告とグラフに使うフレームを絞り込む式(例: \"fc==3 && slave==12\", \"byte[0]==0x02\")",
+				Destination: &option.where,
+			},
+			// Wiresharkのextcapインターフェース
+			&cli.BoolFlag{
+				Name:        "extcap-interfaces",
+				Usage:       "extcap: インターフェースを列挙する",
+				Destination: &extcap.interfaces,
+			},
+			&cli.StringFlag{
+				Name:        "extcap-interface",
+				Usage:       "extcap: インターフェース",
+				Destination: &extcap.iface,
+			},
+			&cli.BoolFlag{
+				Name:        "extcap-dlts",
+				Usage:       "extcap: リンク層ヘッダー種別を列挙する",
+				Destination: &extcap.dlts,
+			},
+			&cli.BoolFlag{
+				Name:        "extcap-config",
+				Usage:       "extcap: 設定項目を列挙する",
+				Destination: &extcap.config,
+			},
+			&cli.StringFlag{
+				Name:        "extcap-version",
+				Usage:       "extcap: Wiresharkのバージョン",
+				Destination: &extcap.version,
+			},
+			&cli.BoolFlag{
+				Name:        "capture",
+				Usage:       "extcap: キャプチャする",
+				Destination: &extcap.capture,
+			},
+			&cli.StringFlag{
+				Name:        "fifo",
+				Usage:       "extcap: pcapを書き込むFIFO",
+				Destination: &extcap.fifo,
+			},
+			&cli.StringFlag{
+				Name:        "extcap-capture-filter",
+				Usage:       "extcap: キャプチャフィルタ(使わない)",
+				Destination: &extcap.captureFilter,
+			},
+			&cli.StringFlag{
+				Name:        "file",
+				Usage:       "extcap: 解析するCSVファイル",
+				Destination: &extcap.file,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			if option.profile != "" {
+				if err := applyProfile(c, option.profile, option.profilesFile); err != nil {
+					return cli.Exit(err, -1)
+				}
+			}
+			format, err := newUartFormat(option.frameFormat, option.dataBits, option.parity, option.stopBits)
+			if err != nil {
+				return cli.Exit(err, -1)
+			}
+			if format.idleSpace, err = parseIdleLevel(option.idleLevel); err != nil {
+				return cli.Exit(err, -1)
+			}
+			option.format = format
+			if option.decodeMode, err = newDecodeMode(option.strict, option.permissive); err != nil {
+				return cli.Exit(err, -1)
+			}
+			return profile.start()
+		},
+		After: func(c *cli.Context) error {
+			return profile.stop()
+		},
+		Action: func(c *cli.Context) error {
+			if !extcap.requested() {
+				return cli.ShowAppHelp(c)
+			}
+			option.provenance = newProvenance(c)
+			if err := runExtcap(c.Context, os.Stdout, extcap, option, c.App.Version); err != nil {
+				slog.Error("runExtcap", "err", err)
+				return err
+			}
+			return nil
 		},
 		Commands: []*cli.Command{
 			{
-				Name:  "csv",
-				Usage: "CSVファイルを解析する",
+				Name:      "csv",
+				Usage:     "CSVファイル(拡張子.srの場合はsigrokのセッションファイル, .vcdの場合はVCDファイル, .wavの場合はWAVファイル, .isf, .bin, .wfmの場合はオシロスコープの波形ファイル, -の場合は標準入力)を解析する",
+				ArgsUsage: "CSVファイル...",
 				Action: func(c *cli.Context) error {
-					csvfile := c.Args().First()
-					if len(csvfile) == 0 {
+					csvfiles := c.Args().Slice()
+					if len(csvfiles) == 0 {
 						return cli.Exit("ファイルが指定されていません", -1)
 					}
-					err := insightTheCsvFile(c.Args().First(), baudrate, graphWidth, graphHeight)
+					option.provenance = newProvenance(c)
+					if err := checkStdinOption(csvfiles, option); err != nil {
+						return cli.Exit(err, -1)
+					}
+					if option.follow {
+						if len(csvfiles) != 1 || option.stitch {
+							return cli.Exit("--followで追いかけるファイルは1つだけ", -1)
+						}
+						if isNonCsvInput(csvfiles[0], option) {
+							return cli.Exit("--followはCSVファイルだけに対応する(sigrok, VCD, WAV, オシロスコープの波形ファイルには対応していない)", -1)
+						}
+						if err := followCsvFile(c.Context, os.Stdout, csvfiles[0], option); err != nil {
+							slog.Error("followCsvFile", "err", err)
+							return err
+						}
+						return nil
+					}
+					if option.stitch {
+						option.stitchFiles = csvfiles[1:]
+						csvfiles = csvfiles[:1]
+					}
+					if option.outputPrefix != "" && len(csvfiles) != 1 {
+						return cli.Exit("--output-prefixは入力ファイルが1つの場合だけ", -1)
+					}
+					// 復号した結果を標準出力に書く場合は解析の報告を標準エラー出力に書く
+					report := io.Writer(os.Stdout)
+					if option.decodeOutput != DecodeOutputNone && option.outFile == DecodeOutputStdout {
+						report = os.Stderr
+					}
+					for _, csvfile := range csvfiles {
+						var err error
+						if option.segments || option.segmentCol != "" {
+							err = insightSegmentedCsvFile(c.Context, report, csvfile, option)
+						} else {
+							err = insightTheCsvFile(c.Context, report, csvfile, option)
+						}
+						if errors.Is(err, context.Canceled) {
+							return cli.Exit(err, ExitCanceled)
+						}
+						if err != nil {
+							slog.Error("insightTheCsvFile", "err", err)
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "vcd",
+				Usage:     "Value Change Dump(VCD)ファイルを拡張子によらず解析する(信号は--a-col, --b-colで選ぶ)",
+				ArgsUsage: "VCDファイル...",
+				Action: func(c *cli.Context) error {
+					option.vcdInput = true
+					return c.App.Command("csv").Action(c)
+				},
+			},
+			{
+				Name:      "wav",
+				Usage:     "2チャンネルのオーディオインターフェースで録音したステレオのWAVファイル(左:A線, 右:B線)を拡張子によらず解析する",
+				ArgsUsage: "WAVファイル...",
+				Action: func(c *cli.Context) error {
+					option.wavInput = true
+					return c.App.Command("csv").Action(c)
+				},
+			},
+			{
+				Name:      "live",
+				Usage:     "LXI/SCPI(TCP)でつないだオシロスコープから2つのチャンネルの波形を繰り返し取り込んで復号し, 見つけたフレームを表示する",
+				ArgsUsage: "ホスト名[:ポート]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "channels",
+						Usage: "A線とB線を測るチャンネル(数字だけの場合はCHANを付ける)",
+						Value: "1,2",
+					},
+					&cli.IntFlag{
+						Name:  "records",
+						Usage: "取り込む回数(0の場合はCtrl-Cまで)",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Usage: "取り込みの間隔",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "オシロスコープの応答を待つ時間",
+						Value: 10 * time.Second,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.Exit("オシロスコープのホスト名を1つ指定してください", -1)
+					}
+					channels, err := parseLiveChannels(c.String("channels"))
 					if err != nil {
-						slog.Error("insightTheCsvFile", "err", err)
+						return cli.Exit(err, -1)
+					}
+					if c.Int("records") < 0 {
+						return cli.Exit("取り込む回数は0以上を指定してください", -1)
+					}
+					live := LiveOption{
+						address:  c.Args().First(),
+						channels: channels,
+						records:  c.Int("records"),
+						interval: c.Duration("interval"),
+						timeout:  c.Duration("timeout"),
+					}
+					option.provenance = newProvenance(c)
+					if err := runLive(c.Context, os.Stdout, live, option); err != nil {
+						slog.Error("runLive", "err", err)
 						return err
 					}
 					return nil
 				},
 			},
+			{
+				Name:      "demo",
+				Usage:     "組み込みの測定例をディレクトリ(既定はカレントディレクトリ)に書き出して解析する",
+				ArgsUsage: "[出力ディレクトリ]",
+				Action: func(c *cli.Context) error {
+					dir := c.Args().First()
+					if len(dir) == 0 {
+						dir = "."
+					}
+					option.provenance = newProvenance(c)
+					if err := runDemo(c.Context, os.Stdout, dir, option); err != nil {
+						slog.Error("runDemo", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "doctor",
+				Usage:     "フォント、出力先への書き込み、入力ファイルの読み込みを調べて有効な設定を表示する",
+				ArgsUsage: "[CSVファイル]",
+				Action: func(c *cli.Context) error {
+					if err := runDoctor(os.Stdout, c, c.Args().First(), option); err != nil {
+						slog.Error("runDoctor", "err", err)
+						return cli.Exit(err, 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "completion",
+				Usage:     "シェル(bash, zsh, fish, powershell)の補完スクリプトを表示する",
+				ArgsUsage: "シェル",
+				Action: func(c *cli.Context) error {
+					if err := writeCompletion(os.Stdout, c.App, c.Args().First()); err != nil {
+						slog.Error("writeCompletion", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "aggregate",
+				Usage:     "繰り返した取り込みの解析の説明(--metaの.meta.json)を集めて, 誤り率, 振幅, 遷移の速さの推移を表示してグラフにする",
+				ArgsUsage: "解析の説明(.meta.json)...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "trend-file",
+						Usage: "推移のグラフを保存するPNGファイル",
+						Value: "trend.png",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return cli.Exit("解析の説明のファイルが指定されていません", -1)
+					}
+					if err := runAggregate(os.Stdout, c.Args().Slice(), c.String("trend-file")); err != nil {
+						slog.Error("runAggregate", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "bench",
+				Usage: "合成した測定値を解析して段階毎の経過時間とメモリ割り当てを測る",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "frames",
+						Usage: "合成するフレーム数",
+						Value: 2000,
+					},
+					&cli.IntFlag{
+						Name:  "count",
+						Usage: "解析する回数",
+						Value: 3,
+					},
+					&cli.BoolFlag{
+						Name:  "full",
+						Usage: "グラフを含めた全ての解析を測る(既定は読み込みから復号まで)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Int("frames") < 1 || c.Int("count") < 1 {
+						return cli.Exit("フレーム数と回数は1以上を指定してください", -1)
+					}
+					if err := runBench(c.Context, os.Stdout, option, c.Int("frames"), c.Int("count"), c.Bool("full")); err != nil {
+						slog.Error("runBench", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "stress",
+				Usage:     "測定値に雑音とジッタを段階的に加えて復号し直し、どこから復号に失敗するか(通信路の余裕)を調べる",
+				ArgsUsage: "CSVファイル",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "steps",
+						Usage: "劣化の段階数",
+						Value: 20,
+					},
+					&cli.IntFlag{
+						Name:  "trials",
+						Usage: "段階毎に乱数を変えて復号する回数",
+						Value: 3,
+					},
+					&cli.Float64Flag{
+						Name:  "noise-step",
+						Usage: "1段階で増やすA線とB線それぞれの雑音の標準偏差(V)",
+						Value: 0.1,
+					},
+					&cli.Float64Flag{
+						Name:  "jitter-step",
+						Usage: "1段階で増やすエッジの時間のずれの標準偏差(ビット周期に対する比)",
+						Value: 0.02,
+					},
+					&cli.Int64Flag{
+						Name:  "seed",
+						Usage: "乱数の種",
+						Value: 1,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.Exit("CSVファイルを1つ指定してください", -1)
+					}
+					stress := StressOption{
+						steps:      c.Int("steps"),
+						trials:     c.Int("trials"),
+						noiseStep:  c.Float64("noise-step"),
+						jitterStep: c.Float64("jitter-step"),
+						seed:       c.Int64("seed"),
+					}
+					if stress.steps < 1 || stress.trials < 1 {
+						return cli.Exit("段階数と回数は1以上を指定してください", -1)
+					}
+					if err := runStress(c.Context, os.Stdout, c.Args().First(), option, stress); err != nil {
+						slog.Error("runStress", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "calibrate",
+				Usage:     "既知のバイト列を取り込んだ測定値で復号のしきい値, スキュー, 移動平均の窓を探し, 最も良い設定をプロファイルに書く",
+				ArgsUsage: "CSVファイル",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "expect",
+						Usage: "測定値に含まれる既知のバイト列(16進数, 例: \"05 30 31 03\")",
+					},
+					&cli.StringFlag{
+						Name:  "expect-file",
+						Usage: "既知のバイト列を書いたバイナリファイル",
+					},
+					&cli.StringFlag{
+						Name:  "thresholds",
+						Usage: "試す復号のしきい値(V)のカンマ区切りの一覧",
+						Value: CalibrateThresholds,
+					},
+					&cli.Float64Flag{
+						Name:  "skew-range",
+						Usage: "試すB線のA線に対する遅れの範囲(±ビット周期に対する比)",
+						Value: 0.1,
+					},
+					&cli.IntFlag{
+						Name:  "skew-steps",
+						Usage: "スキューの片側の段階数(0はスキューを探さない)",
+						Value: 2,
+					},
+					&cli.StringFlag{
+						Name:  "windows",
+						Usage: "試す移動平均の窓(サンプル数, 0は生の波形を復号する)のカンマ区切りの一覧, 空はビット周期から決める",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "書き込むプロファイルの名前(--profiles-fileか既定の設定ファイルに書く)",
+						Value: "calibrated",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "探した結果を表示するだけでプロファイルを書き込まない",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.Exit("CSVファイルを1つ指定してください", -1)
+					}
+					expected, err := loadExpectedBytes(c.String("expect"), c.String("expect-file"))
+					if err != nil {
+						return cli.Exit(err, -1)
+					}
+					thresholds, err := parseCalibrateThresholds(c.String("thresholds"))
+					if err != nil {
+						return cli.Exit(err, -1)
+					}
+					windows, err := parseCalibrateWindows(c.String("windows"))
+					if err != nil {
+						return cli.Exit(err, -1)
+					}
+					calibrate := CalibrateOption{
+						expected:   expected,
+						thresholds: thresholds,
+						skewRange:  c.Float64("skew-range"),
+						skewSteps:  c.Int("skew-steps"),
+						windows:    windows,
+						name:       c.String("name"),
+						dryRun:     c.Bool("dry-run"),
+					}
+					if calibrate.skewSteps < 0 || calibrate.skewRange < 0 {
+						return cli.Exit("スキューの範囲と段階数は0以上を指定してください", -1)
+					}
+					if calibrate.name == "" {
+						return cli.Exit("プロファイルの名前を指定してください", -1)
+					}
+					if err := runCalibrate(c.Context, os.Stdout, c.Args().First(), option, calibrate); err != nil {
+						slog.Error("runCalibrate", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "eye",
+				Usage:     "A-B間電圧差をビット周期で折り返したアイダイアグラムを描き, アイの高さと幅を測る",
+				ArgsUsage: "CSVファイル",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "max-traces",
+						Usage: "重ねて描くビットの最大数(多い場合は等間隔に選ぶ, 測定は全てのビットで行う)",
+						Value: EyeMaxTraces,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.Exit("CSVファイルを1つ指定してください", -1)
+					}
+					eye := EyeOption{maxTraces: c.Int("max-traces")}
+					if eye.maxTraces < 1 {
+						return cli.Exit("重ねて描くビットの数は1以上を指定してください", -1)
+					}
+					if err := checkStdinOption(c.Args().Slice(), option); err != nil {
+						return cli.Exit(err, -1)
+					}
+					option.provenance = newProvenance(c)
+					if err := runEye(c.Context, os.Stdout, c.Args().First(), option, eye); err != nil {
+						slog.Error("runEye", "err", err)
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "selftest",
+				Usage: "組み込みの測定例を解析して正解ファイルと比べる",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "update",
+						Usage: "比べずに解析結果を正解ファイルとして指定したディレクトリに保存する",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := runSelftest(c.Context, os.Stdout, option, c.String("update")); err != nil {
+						slog.Error("runSelftest", "err", err)
+						return cli.Exit(err, 1)
+					}
+					return nil
+				},
+			},
 		},
 	}
+}
 
-	if err := app.Run(os.Args); err != nil {
+func main() {
+	ctx, stop := newSignalContext()
+	defer stop()
+	app := newApp()
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		// cli.Exitのエラーはurfave/cliが表示して終了するので, ここに来るのはそれ以外のエラー
 		slog.Error("app.Run", "err", err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		stop()
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,148 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 出力ファイルの横に解析の説明(入力ファイル, 解析設定, 検出した性質, 結果の要約)をJSONで書く(取り込みの保管庫が後から読んでも分かるように)
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 入力から検出した性質
+type MetaDetected struct {
+	Rows           int     `json:"rows"`                     // 行数(全二重は1対あたり)
+	SampleInterval float64 `json:"sampleInterval"`           // サンプリング間隔(s)
+	SampleRate     float64 `json:"sampleRate"`               // サンプリングレート(Sa/s)
+	Duplex         bool    `json:"duplex"`                   // 全二重(4線)か
+	BaudRate       int     `json:"baudRate"`                 // 解析に使ったボーレート(bps)
+	BaudEstimated  bool    `json:"baudEstimated"`            // ボーレートを測定値から推定したか
+	Format         string  `json:"format"`                   // 復号に使った文字の形式(例: 8N1)
+	Threshold      float64 `json:"threshold"`                // A-B間電圧差のしきい値(V)
+	OriginTime     float64 `json:"originTime"`               // 相対時間の基準にした入力CSVの時間(s)
+	CaptureStart   string  `json:"captureStart,omitempty"`   // 取り込みを始めた絶対時刻, 分からない場合は空
+	Duration       float64 `json:"duration"`                 // 取り込みの長さ(s)
+	StopBits       float64 `json:"stopBits,omitempty"`       // ストップビットの数, 続いた文字が無い場合は0
+	SmoothWindow   int     `json:"smoothWindow,omitempty"`   // 移動平均の窓の大きさ(サンプル数)
+	EarlyStopped   bool    `json:"earlyStopped,omitempty"`   // --max-frames, --stop-afterで打ち切ったか
+	FramesFiltered bool    `json:"framesFiltered,omitempty"` // --whereでフレームを絞り込んだか
+}
+
+// 結果の要約
+type MetaSummary struct {
+	Frames        int            `json:"frames"`        // フレーム数
+	Bytes         int            `json:"bytes"`         // バイト数
+	FramingErrors int            `json:"framingErrors"` // フレーミングエラーの数
+	ParityErrors  int            `json:"parityErrors"`  // パリティが合わない文字の数
+	Anomalies     map[string]int `json:"anomalies"`     // 異常の種類ごとの数
+	Errors        int            `json:"errors"`        // 重大度errorの異常の数
+	Warnings      int            `json:"warnings"`      // 重大度warningの異常の数
+	Truncated     bool           `json:"truncated"`     // 取り込みが文字の途中で終わった
+	// 論理レベルの入力と測れない場合は0
+	Amplitude      float64 `json:"amplitude,omitempty"`      // 文字の中のA-B間電圧差の絶対値の中央値(V)
+	SlewRate       float64 `json:"slewRate,omitempty"`       // A-B間電圧差のスルーレートの絶対値の中央値(V/s)
+	TransitionTime float64 `json:"transitionTime,omitempty"` // 遷移時間の中央値(s)
+}
+
+// 解析の説明
+type SessionMeta struct {
+	Provenance *Provenance  `json:"provenance,omitempty"`
+	Detected   MetaDetected `json:"detected"`
+	Summary    MetaSummary  `json:"summary"`
+}
+
+// 入力の行列から検出した性質を作る
+// 解析の途中で決まる値は呼び出し側で埋める
+func detectedProperties(matrix mat.Matrix, rxMatrix mat.Matrix, baudrate int, originTime float64, clock Clock) MetaDetected {
+	rows, _ := matrix.Dims()
+	detected := MetaDetected{
+		Rows:           rows,
+		SampleInterval: sampleInterval(matrix),
+		Duplex:         rxMatrix != nil,
+		BaudRate:       baudrate,
+		Threshold:      Threshould,
+		OriginTime:     originTime,
+	}
+	if detected.SampleInterval > 0 {
+		detected.SampleRate = 1 / detected.SampleInterval
+	}
+	if rows != 0 {
+		start := matrix.At(0, ColTime) - originTime
+		detected.Duration = matrix.At(rows-1, ColTime) - originTime - start
+		if clock.absolute {
+			detected.CaptureStart = clock.format(start)
+		}
+	}
+	return detected
+}
+
+// 結果の要約を作る
+func summarizeSession(traffic TrafficSummary, bits []UartBit, codes []UartCode, anomalies []AnomalyEvent) MetaSummary {
+	summary := MetaSummary{
+		Frames:    traffic.frames,
+		Bytes:     traffic.bytes,
+		Anomalies: map[string]int{},
+	}
+	for _, b := range bits {
+		if b.state == "X" {
+			summary.FramingErrors++
+		}
+	}
+	for _, c := range codes {
+		if c.parityError {
+			summary.ParityErrors++
+		}
+	}
+	for _, a := range anomalies {
+		summary.Anomalies[a.Kind]++
+		switch a.Severity {
+		case SeverityError:
+			summary.Errors++
+		case SeverityWarning:
+			summary.Warnings++
+		}
+	}
+	summary.Truncated = summary.Anomalies["truncated"] > 0
+	return summary
+}
+
+// 振幅と遷移の速さを結果の要約に加える(長い期間の傾向を見るため)
+func measureSignalSummary(summary *MetaSummary, matrix mat.Matrix, originTime float64, codes []UartCode, baudrate int) {
+	if levels := measureBusLevels(matrix, originTime, codes, baudrate); !math.IsNaN(levels.amplitude) {
+		summary.Amplitude = levels.amplitude
+	}
+	edges := measureEdges(matrix)
+	if len(edges) == 0 {
+		return
+	}
+	slews := make([]float64, len(edges))
+	transitions := make([]float64, len(edges))
+	for i, e := range edges {
+		slews[i] = math.Abs(e.slewDiff)
+		transitions[i] = e.transitionTime
+	}
+	summary.SlewRate = median(slews)
+	summary.TransitionTime = median(transitions)
+}
+
+// 解析の説明をJSONファイルに保存する
+func saveSessionMeta(savefilepath string, meta SessionMeta) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(meta); err != nil {
+		slog.Error("Encode", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 雑音の床(波形整形の前に, 大きさが小さいA,B間電圧差を0にする)
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 雑音の床で0にした行の数
+type NoiseGate struct {
+	floor   float64 // 雑音の床(V)
+	samples int     // 調べた行数
+	gated   int     // 0にした行数
+}
+
+// 1行のA,B間電圧差を調べて, 雑音の床より小さい場合は0にする
+func (g *NoiseGate) push(d float64) float64 {
+	if g.floor <= 0 {
+		return d
+	}
+	g.samples++
+	if math.Abs(d) < g.floor {
+		g.gated++
+		return 0
+	}
+	return d
+}
+
+// A,B間電圧差のうち雑音の床より小さいものを0にする
+func gateNoise(diff []float64, floor float64) NoiseGate {
+	gate := NoiseGate{floor: floor}
+	for i, d := range diff {
+		diff[i] = gate.push(d)
+	}
+	return gate
+}
+
+// 復号に使うA,B間電圧差
+// 雑音の床より小さいものを0にして, 復号のしきい値に合わせて拡大縮小する
+func decisionDiff(source mat.Matrix, option InsightOption) ([]float64, NoiseGate) {
+	diff := waveform.Differential(nil, source)
+	gate := gateNoise(diff, option.noiseFloor)
+	scaleDecisionDiff(diff, option.threshold)
+	return diff, gate
+}
+
+// 復号に使う測定値の雑音の床で0にした行を数える(全二重でなければrxはnil)
+func findNoiseGates(matrix *mat.Dense, rxMatrix *mat.Dense, filtered mat.Matrix, rxFiltered mat.Matrix, option InsightOption) (NoiseGate, *NoiseGate) {
+	decodeSource, rxDecodeSource := decodeSources(matrix, rxMatrix, filtered, rxFiltered, option)
+	_, tx := decisionDiff(decodeSource, option)
+	if rxDecodeSource == nil {
+		return tx, nil
+	}
+	_, rx := decisionDiff(rxDecodeSource, option)
+	return tx, &rx
+}
+
+// 0にした行の数と割合を表示する
+// 雑音の床を指定しない場合は表示しない
+func printNoiseGate(w io.Writer, label string, gate NoiseGate) {
+	if gate.floor <= 0 {
+		return
+	}
+	ratio := 0.0
+	if gate.samples > 0 {
+		ratio = float64(gate.gated) / float64(gate.samples)
+	}
+	fmt.Fprintf(w, "noise gate%s: |A-B| < %.3fV  %d of %d samples gated (%.2f%%)\n", label, gate.floor, gate.gated, gate.samples, ratio*100)
+}
@@ -0,0 +1,122 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// キャプチャの他のフレームと統計的に異なるフレーム(外れ値)の検出
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// 外れ値を調べるのに必要なフレーム数
+const OutlierMinFrames = 20
+
+// 外れ値とみなす中央値からの距離(MADを正規分布の標準偏差に換算した値の倍数)
+const OutlierZScore = 3.5
+
+// 珍しいアドレスとみなすフレームの割合
+const OutlierRareFraction = 0.05
+
+// 外れ値のフレーム
+type FrameOutlier struct {
+	frameIndex int       // フレーム番号(0始まり)
+	frame      UartFrame // フレーム
+	reasons    []string  // 外れ値とした理由
+}
+
+// 中央値と中央絶対偏差(MAD)
+func medianAbsoluteDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, deviations[len(deviations)/2]
+}
+
+// 中央値からの距離が外れ値とみなす距離を超えるか
+// MADが0(ほとんどが同じ値)の場合はminDeviationを超えたら外れ値とする
+func isOutlier(value float64, median float64, mad float64, minDeviation float64) bool {
+	return math.Abs(value-median) > math.Max(OutlierZScore*1.4826*mad, minDeviation)
+}
+
+// キャプチャの他のフレームと長さ、バイト間の無通信時間、アドレスが統計的に異なるフレームを探す
+// 長さは同じ送信元のフレームと比べて、珍しい長さを外れ値とする
+func findFrameOutliers(frames []UartFrame, charTime float64, addressByte int) []FrameOutlier {
+	outliers := []FrameOutlier{}
+	if len(frames) < OutlierMinFrames {
+		return outliers
+	}
+
+	// 送信元ごとのフレームの長さの出現回数
+	// 要求と応答のように長さが何通りかあるので中央値ではなく出現回数で調べる
+	lengths := map[string]map[int]int{}
+	talkers := map[string]int{}
+	for _, f := range frames {
+		talker := f.talker(addressByte)
+		if lengths[talker] == nil {
+			lengths[talker] = map[int]int{}
+		}
+		lengths[talker][len(f.codes)]++
+		talkers[talker]++
+	}
+
+	// フレーム内の最も長いバイト間の無通信時間
+	gapMedian, gapMad := medianAbsoluteDeviation(interByteGaps(frames))
+	// 半文字より短いずれは無視する
+	minGapDeviation := 0.5 * charTime
+
+	for i, f := range frames {
+		reasons := []string{}
+		talker := f.talker(addressByte)
+
+		if n := talkers[talker]; n >= OutlierMinFrames && float64(lengths[talker][len(f.codes)]) <= OutlierRareFraction*float64(n) {
+			reasons = append(reasons, fmt.Sprintf("length %d (%d of %d frames from %s)", len(f.codes), lengths[talker][len(f.codes)], n, talker))
+		}
+
+		maxGap := 0.0
+		for k := 1; k < len(f.codes); k++ {
+			maxGap = math.Max(maxGap, f.codes[k].startTime-f.codes[k-1].endTime)
+		}
+		if len(f.codes) > 1 && maxGap > gapMedian && isOutlier(maxGap, gapMedian, gapMad, minGapDeviation) {
+			reasons = append(reasons, fmt.Sprintf("inter-byte gap %.3fms (typical %.3fms)", maxGap*1e3, gapMedian*1e3))
+		}
+
+		// 全二重は通信方向で分けているのでアドレスは調べない
+		if f.direction == "" {
+			if n := talkers[talker]; float64(n) <= OutlierRareFraction*float64(len(frames)) {
+				reasons = append(reasons, fmt.Sprintf("rare address %s (%d of %d frames)", talker, n, len(frames)))
+			}
+		}
+
+		if len(reasons) != 0 {
+			outliers = append(outliers, FrameOutlier{frameIndex: i, frame: f, reasons: reasons})
+		}
+	}
+	return outliers
+}
+
+// 外れ値のフレームを表示する
+func printFrameOutliers(w io.Writer, clock Clock, frames []UartFrame, outliers []FrameOutlier) {
+	if len(frames) < OutlierMinFrames {
+		fmt.Fprintf(w, "frame outliers: skipped (%d frames, at least %d needed)\n", len(frames), OutlierMinFrames)
+		return
+	}
+	fmt.Fprintf(w, "frame outliers: %d of %d frames\n", len(outliers), len(frames))
+	for _, o := range outliers {
+		fmt.Fprintf(w, "  #%d %s", o.frameIndex+1, clock.format(o.frame.startTime))
+		for _, reason := range o.reasons {
+			fmt.Fprintf(w, "  %s", reason)
+		}
+		fmt.Fprintln(w)
+	}
+}
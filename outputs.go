@@ -0,0 +1,124 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 出力ファイルが既に有る場合の扱い(警告して置き換える, 黙って置き換える, 時刻を付けた別の版にする)
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 出力ファイルが既に有る場合の扱い
+const (
+	OutputWarn      = "warn"      // 警告して置き換える
+	OutputOverwrite = "overwrite" // 黙って置き換える
+	OutputVersion   = "version"   // 時刻を付けた別の名前にする
+)
+
+// 版の名前に付ける時刻の書式
+const OutputVersionFormat = "20060102T150405"
+
+// 出力ファイルの扱い
+type OutputPolicy struct {
+	mode  string // 扱い(OutputWarn, OutputOverwrite, OutputVersion)
+	stamp string // 版の名前に付ける時刻
+}
+
+// フラグから出力ファイルの扱いを決める
+func newOutputPolicy(overwrite bool, version bool, now time.Time) (OutputPolicy, error) {
+	policy := OutputPolicy{mode: OutputWarn, stamp: now.Format(OutputVersionFormat)}
+	switch {
+	case overwrite && version:
+		return policy, fmt.Errorf("--overwrite と --version-outputs は同時に指定できない")
+	case overwrite:
+		policy.mode = OutputOverwrite
+	case version:
+		policy.mode = OutputVersion
+	}
+	return policy, nil
+}
+
+// ファイルかディレクトリが有るか
+func outputExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// 版の名前
+// 拡張子の前に時刻を付け, 同じ秒に作った版が有れば番号も付ける
+func versionedName(base string, ext string, stamp string) string {
+	name := base + "_" + stamp + ext
+	for n := 2; outputExists(name); n++ {
+		name = fmt.Sprintf("%s_%s-%d%s", base, stamp, n, ext)
+	}
+	return name
+}
+
+// 出力ファイルの名前
+// 空の場合(出力しない)はそのまま返す
+func (p OutputPolicy) file(path string) string {
+	if path == "" || !outputExists(path) {
+		return path
+	}
+	switch p.mode {
+	case OutputWarn:
+		slog.Warn("overwrite", "file", path)
+	case OutputVersion:
+		ext := filepath.Ext(path)
+		return versionedName(strings.TrimSuffix(path, ext), ext, p.stamp)
+	}
+	return path
+}
+
+// 入力ファイルから名前を作る出力ファイル([基本名]_[拡張子]...)の基本名
+// 以前の出力が有れば, 版にする場合は基本名に時刻を付けて1回の解析の出力をまとめて別の名前にする
+func (p OutputPolicy) basename(basename string, ext string) string {
+	previous, _ := filepath.Glob(basename + "_" + ext[1:] + "*")
+	if len(previous) == 0 {
+		return basename
+	}
+	switch p.mode {
+	case OutputWarn:
+		slog.Warn("overwrite", "files", len(previous), "pattern", basename+"_"+ext[1:]+"*")
+	case OutputVersion:
+		versioned := basename + "_" + p.stamp
+		for n := 2; ; n++ {
+			if matches, _ := filepath.Glob(versioned + "_" + ext[1:] + "*"); len(matches) == 0 {
+				return versioned
+			}
+			versioned = fmt.Sprintf("%s_%s-%d", basename, p.stamp, n)
+		}
+	}
+	return basename
+}
+
+// 解析の設定で名前を指定した出力ファイル(復号した結果の出力ファイルを除く)
+func (option *InsightOption) namedOutputFiles() []*string {
+	return []*string{&option.anomalyFile, &option.pcapFile, &option.framesFile, &option.sequenceFile, &option.trafficFile, &option.softBitsFile, &option.bitFeaturesFile, &option.hexFile, &option.payloadDir, &option.busStateFile}
+}
+
+// 入力ファイルの拡張子を取り除いた基本名(標準入力と--output-prefixの場合は指定の基本名)と入力ファイルの拡張子を返す
+// 以前の出力が有る場合の扱いに従って基本名と指定した出力ファイルの名前を決めてoptionに入れる
+// セグメントの場合は基本名と指定した出力ファイルの名前にセグメント番号を付ける
+func (p OutputPolicy) resolveOutputs(csvfilepath string, option *InsightOption) (string, string) {
+	stem, ext := outputStem(csvfilepath, option.outputPrefix)
+	if option.segment != nil {
+		stem = fmt.Sprintf("%s_seg%d", stem, option.segment.index)
+		for _, file := range append(option.namedOutputFiles(), &option.outFile) {
+			*file = segmentFileName(*file, option.segment.index)
+		}
+	}
+	basename := p.basename(stem, ext)
+	for _, file := range option.namedOutputFiles() {
+		*file = p.file(*file)
+	}
+	if option.outFile != DecodeOutputStdout {
+		option.outFile = p.file(option.outFile)
+	}
+	return basename, ext
+}
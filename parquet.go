@@ -0,0 +1,225 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// Parquet形式のファイル(1つの行グループ, 列毎に1つのデータページ, PLAINエンコーディング, 圧縮なし, 全ての列が必須)
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquetファイルの先頭と末尾
+const ParquetMagic = "PAR1"
+
+// Parquetの物理型
+const (
+	ParquetInt32     = 1
+	ParquetDouble    = 5
+	ParquetByteArray = 6
+)
+
+// Parquetの定数(parquet.thrift)
+const (
+	parquetRequired     = 0 // FieldRepetitionType REQUIRED
+	parquetUtf8         = 0 // ConvertedType UTF8
+	parquetPlain        = 0 // Encoding PLAIN
+	parquetRle          = 3 // Encoding RLE
+	parquetUncompressed = 0 // CompressionCodec UNCOMPRESSED
+	parquetDataPage     = 0 // PageType DATA_PAGE
+	parquetVersion      = 1
+)
+
+// Parquetファイルの1列
+// 値はPLAINエンコーディングで加える
+type ParquetColumn struct {
+	name   string
+	kind   int32 // 物理型(ParquetDouble など)
+	values []byte
+}
+
+// 倍精度浮動小数点数の列
+func newParquetDoubleColumn(name string, values []float64) ParquetColumn {
+	c := ParquetColumn{name: name, kind: ParquetDouble, values: make([]byte, 0, 8*len(values))}
+	for _, v := range values {
+		c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+	}
+	return c
+}
+
+// 32ビット整数の列
+func newParquetInt32Column(name string, values []int32) ParquetColumn {
+	c := ParquetColumn{name: name, kind: ParquetInt32, values: make([]byte, 0, 4*len(values))}
+	for _, v := range values {
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(v))
+	}
+	return c
+}
+
+// UTF-8文字列の列
+func newParquetStringColumn(name string, values []string) ParquetColumn {
+	c := ParquetColumn{name: name, kind: ParquetByteArray}
+	for _, v := range values {
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+		c.values = append(c.values, v...)
+	}
+	return c
+}
+
+// Thrift Compact Protocolの型
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// Thrift Compact Protocolで構造体を書く
+type thriftWriter struct {
+	buf     []byte
+	last    int16   // 書いている構造体の前のフィールド番号
+	parents []int16 // 外側の構造体の前のフィールド番号
+}
+
+// フィールドの見出し
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|kind)
+	} else {
+		t.buf = append(t.buf, kind)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.appendString(s)
+}
+
+func (t *thriftWriter) appendString(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// リストの見出し, 続けて要素を書く
+func (t *thriftWriter) list(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|kind)
+	} else {
+		t.buf = append(t.buf, 0xf0|kind)
+		t.buf = binary.AppendUvarint(t.buf, uint64(size))
+	}
+}
+
+// 構造体のフィールドを始める
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// 構造体(一番外側かリストの要素)を始める
+func (t *thriftWriter) begin() {
+	t.parents = append(t.parents, t.last)
+	t.last = 0
+}
+
+// 構造体を終える
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.parents[len(t.parents)-1]
+	t.parents = t.parents[:len(t.parents)-1]
+}
+
+// 列をParquetファイルにしてwに書く
+// 列の値の数は全てrowsにする
+func writeParquet(w io.Writer, rows int, columns []ParquetColumn, createdBy string) error {
+	file := []byte(ParquetMagic)
+	offsets := make([]int64, len(columns))
+	sizes := make([]int64, len(columns))
+	for i, c := range columns {
+		var page thriftWriter
+		page.begin() // PageHeader
+		page.i32(1, parquetDataPage)
+		page.i32(2, int32(len(c.values)))
+		page.i32(3, int32(len(c.values)))
+		page.beginStruct(5) // DataPageHeader
+		page.i32(1, int32(rows))
+		page.i32(2, parquetPlain)
+		page.i32(3, parquetRle)
+		page.i32(4, parquetRle)
+		page.end()
+		page.end()
+		// 必須の列なので定義レベルと繰り返しレベルは書かない
+		offsets[i] = int64(len(file))
+		sizes[i] = int64(len(page.buf) + len(c.values))
+		file = append(append(file, page.buf...), c.values...)
+	}
+
+	var meta thriftWriter
+	meta.begin() // FileMetaData
+	meta.i32(1, parquetVersion)
+	meta.list(2, thriftStruct, 1+len(columns))
+	meta.begin() // 根のSchemaElement
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, c := range columns {
+		meta.begin()
+		meta.i32(1, c.kind)
+		meta.i32(3, parquetRequired)
+		meta.string(4, c.name)
+		if c.kind == ParquetByteArray {
+			meta.i32(6, parquetUtf8)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+	meta.list(4, thriftStruct, 1)
+	meta.begin() // RowGroup
+	meta.list(1, thriftStruct, len(columns))
+	total := int64(0)
+	for i, c := range columns {
+		meta.begin() // ColumnChunk
+		meta.i64(2, offsets[i])
+		meta.beginStruct(3) // ColumnMetaData
+		meta.i32(1, c.kind)
+		meta.list(2, thriftI32, 2)
+		meta.buf = binary.AppendVarint(meta.buf, parquetPlain)
+		meta.buf = binary.AppendVarint(meta.buf, parquetRle)
+		meta.list(3, thriftBinary, 1)
+		meta.appendString(c.name)
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, int64(rows))
+		meta.i64(6, sizes[i])
+		meta.i64(7, sizes[i])
+		meta.i64(9, offsets[i])
+		meta.end()
+		meta.end()
+		total += sizes[i]
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.end()
+	meta.string(6, createdBy)
+	meta.end()
+
+	file = append(file, meta.buf...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(meta.buf)))
+	file = append(file, ParquetMagic...)
+	_, err := w.Write(file)
+	return err
+}
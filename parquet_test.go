@@ -0,0 +1,46 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// 先頭と末尾のマジック, 末尾のメタデータの長さ, PLAINエンコーディングの値を調べる
+func TestWriteParquet(t *testing.T) {
+	columns := []ParquetColumn{
+		newParquetDoubleColumn("start", []float64{0.5, -1.25}),
+		newParquetInt32Column("bit", []int32{1, 0}),
+		newParquetStringColumn("state", []string{"IDLE", "START"}),
+	}
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, 2, columns, "pulseinsight"); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, []byte(ParquetMagic)) || !bytes.HasSuffix(file, []byte(ParquetMagic)) {
+		t.Fatalf("magic % x ... % x", file[:4], file[len(file)-4:])
+	}
+	metaLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if metaLength <= 0 || metaLength > len(file)-12 {
+		t.Fatalf("metadata length %d of %d bytes", metaLength, len(file))
+	}
+	meta := file[len(file)-8-metaLength : len(file)-8]
+	for _, name := range []string{"schema", "start", "bit", "state", "pulseinsight"} {
+		if !bytes.Contains(meta, []byte(name)) {
+			t.Errorf("metadata without %q", name)
+		}
+	}
+	doubles := binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.5))
+	doubles = binary.LittleEndian.AppendUint64(doubles, math.Float64bits(-1.25))
+	strings := []byte("\x04\x00\x00\x00IDLE\x05\x00\x00\x00START")
+	for _, values := range [][]byte{doubles, {1, 0, 0, 0, 0, 0, 0, 0}, strings} {
+		if !bytes.Contains(file[:len(file)-8-metaLength], values) {
+			t.Errorf("values % x not found", values)
+		}
+	}
+}
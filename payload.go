@@ -0,0 +1,57 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// フレーム毎のペイロードを別々のファイルに書き出す
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// 絶対時刻のファイル名の書式(ファイル名に使えない:を避ける)
+const PayloadTimeFormat = "20060102T150405.000000"
+
+// フレームのペイロード
+// 誤り検出符号を指定した場合は末尾の誤り検出符号を除く
+func framePayload(f UartFrame, crcKind string) []byte {
+	octets := make([]byte, 0, len(f.codes))
+	for _, c := range f.codes {
+		octets = append(octets, c.octet)
+	}
+	if crcKind == CrcModbus && len(octets) >= 3 {
+		octets = octets[:len(octets)-2]
+	}
+	return octets
+}
+
+// ペイロードのファイル名
+// [フレーム番号(1始まり)]_[開始時刻].bin, 全二重では通信方向も付ける
+func payloadFileName(index int, f UartFrame, clock Clock) string {
+	timestamp := fmt.Sprintf("%.6fs", f.startTime)
+	if clock.absolute {
+		timestamp = clock.relativeTime(f.startTime).UTC().Format(PayloadTimeFormat)
+	}
+	if f.direction != "" {
+		return fmt.Sprintf("%05d_%s_%s.bin", index+1, timestamp, f.direction)
+	}
+	return fmt.Sprintf("%05d_%s.bin", index+1, timestamp)
+}
+
+// フレーム毎のペイロードをディレクトリに保存する
+func savePayloads(dirpath string, clock Clock, frames []UartFrame, crcKind string) error {
+	if err := os.MkdirAll(dirpath, 0o755); err != nil {
+		slog.Error("MkdirAll", "err", err)
+		return err
+	}
+	for i, f := range frames {
+		savefilepath := filepath.Join(dirpath, payloadFileName(i, f, clock))
+		if err := os.WriteFile(savefilepath, framePayload(f, crcKind), 0o644); err != nil {
+			slog.Error("WriteFile", "err", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 解析の段(読み込み, 描画)をチャネルでつないで並行に動かす
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot/plotter"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 描画段
+// グラフの描画と保存をまとめて受け付け、解析と並行して実行する
+// グラフ同士は互いに独立しているので、workers個のゴルーチンで同時に描く
+type PlotStage struct {
+	jobs chan PlotJob
+	done chan struct{}
+	mu   sync.Mutex
+	err  error           // 最初に失敗した描画のエラー
+	ctx  context.Context // 終わったら残りの描画を取りやめる
+	span *TraceSpan      // 描画段のスパン, nilの場合はトレースしない
+	once sync.Once
+}
+
+// 描画段で実行する描画
+type PlotJob struct {
+	name string // 保存するファイル名(トレースのスパン名)
+	run  func() error
+}
+
+// 同時に描くグラフの数の既定値
+func defaultPlotWorkers() int {
+	return runtime.NumCPU()
+}
+
+// 描画段を始める
+// spanがnilでなければ描画ごとに子のスパンを作り, 全て終わった時にspanを終える
+// ctxが終わったら描画中のファイルは保存し, まだ始めていない描画は取りやめる
+// workersが1未満の場合はCPUの数だけ同時に描く
+func startPlotStage(ctx context.Context, span *TraceSpan, workers int) *PlotStage {
+	if workers < 1 {
+		workers = defaultPlotWorkers()
+	}
+	stage := &PlotStage{
+		jobs: make(chan PlotJob, 16),
+		done: make(chan struct{}),
+		ctx:  ctx,
+		span: span,
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range stage.jobs {
+				if err := checkCanceled(stage.ctx); err != nil {
+					stage.fail(err)
+					continue
+				}
+				jobSpan := stage.span.child(job.name)
+				err := job.run()
+				jobSpan.finish(err)
+				if err != nil {
+					stage.fail(err)
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(stage.done)
+		wg.Wait()
+		stage.span.finish(stage.err)
+	}()
+	return stage
+}
+
+// 最初に失敗した描画のエラーを残す
+func (stage *PlotStage) fail(err error) {
+	stage.mu.Lock()
+	defer stage.mu.Unlock()
+	if stage.err == nil {
+		stage.err = err
+	}
+}
+
+// 描画を頼む
+func (stage *PlotStage) submit(savefilepath string, job func() error) {
+	stage.jobs <- PlotJob{name: filepath.Base(savefilepath), run: job}
+}
+
+// 頼んだ描画が全て終わるのを待つ
+// 何度呼んでもよい
+func (stage *PlotStage) wait() error {
+	stage.once.Do(func() { close(stage.jobs) })
+	<-stage.done
+	return stage.err
+}
+
+// 引数は頼んだ時点の値を使うので、その後でchart.Optionを書き換えてもよい
+
+// 波形のグラフの保存を頼む
+// nameはグラフの名前(ChartRaw, ChartStacked など)
+func (stage *PlotStage) saveChart(savefilepath string, name string, graphWidth int, graphHeight int, option chart.Option, matrix mat.Matrix) {
+	stage.submit(savefilepath, func() error {
+		return chart.Save(savefilepath, name, graphWidth, graphHeight, option, matrix)
+	})
+}
+
+// ダッシュボードの保存を頼む
+func (stage *PlotStage) saveDashboard(savefilepath string, option chart.Option, eyeMask *EyeMask, matrix mat.Matrix, bits []UartBit, originTime float64, baudrate int, metrics []DashboardMetric) {
+	stage.submit(savefilepath, func() error {
+		return saveDashboard(savefilepath, option, eyeMask, matrix, bits, originTime, baudrate, metrics)
+	})
+}
+
+// タイムラインのグラフの保存を頼む
+func (stage *PlotStage) saveTimelineChart(savefilepath string, graphWidth int, graphHeight int, option chart.Option, frames []UartFrame, addressByte int) {
+	stage.submit(savefilepath, func() error {
+		return saveTimelineChart(savefilepath, graphWidth, graphHeight, option, frames, addressByte)
+	})
+}
+
+// バイト値のヒートマップの保存を頼む
+func (stage *PlotStage) saveByteHeatmap(savefilepath string, graphWidth int, graphHeight int, option chart.Option, heatmap ByteHeatmap) {
+	stage.submit(savefilepath, func() error {
+		return saveByteHeatmap(savefilepath, graphWidth, graphHeight, option, heatmap)
+	})
+}
+
+// 使用率のグラフの保存を頼む
+func (stage *PlotStage) saveUtilizationChart(savefilepath string, graphWidth int, graphHeight int, option chart.Option, xys plotter.XYs) {
+	stage.submit(savefilepath, func() error {
+		return saveUtilizationChart(savefilepath, graphWidth, graphHeight, option, xys)
+	})
+}
+
+// 無通信時間のヒストグラムの保存を頼む
+func (stage *PlotStage) saveGapHistogram(savefilepath string, graphWidth int, graphHeight int, option chart.Option, gaps []float64) {
+	stage.submit(savefilepath, func() error {
+		return saveGapHistogram(savefilepath, graphWidth, graphHeight, option, gaps)
+	})
+}
+
+// タイル画像ピラミッドの保存を頼む
+func (stage *PlotStage) saveTilePyramid(dirpath string, traces []TileTrace, tileWidth int, tileHeight int, provenance *Provenance) {
+	stage.submit(dirpath, func() error {
+		return saveTilePyramid(dirpath, traces, tileWidth, tileHeight, provenance)
+	})
+}
@@ -0,0 +1,48 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// UART通信のグラフにビットの境界を縦の補助線で示す(ビットの位置合わせを目で確かめる)
+package chart
+
+import (
+	"image/color"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// ビットの境界の補助線
+type BitGrid struct {
+	times     []float64      // 境界の時間(s)
+	LineStyle draw.LineStyle // 補助線の描き方
+}
+
+// 文字毎にスタートビットの始まりから最初のストップビットの終わりまで(bitsビット), ビット周期ごとの境界を求める
+func newBitGrid(codes []Code, bitPeriod float64, bits int) BitGrid {
+	times := make([]float64, 0, len(codes)*(bits+1))
+	for _, c := range codes {
+		for k := 0; k <= bits; k++ {
+			times = append(times, c.Time+float64(k)*bitPeriod)
+		}
+	}
+	return BitGrid{
+		times: times,
+		LineStyle: draw.LineStyle{
+			Color: color.Gray{Y: 0xd0},
+			Width: vg.Points(0.5),
+		},
+	}
+}
+
+// 補助線を描く(plot.Plotter)
+func (g BitGrid) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, _ := plt.Transforms(&c)
+	for _, t := range g.times {
+		x := trX(t)
+		if x < c.Min.X || x > c.Max.X {
+			continue
+		}
+		c.StrokeLine2(g.LineStyle, x, c.Min.Y, x, c.Max.Y)
+	}
+}
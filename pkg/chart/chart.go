@@ -0,0 +1,307 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// RS485/422バスの測定値と復号したビット, 文字の波形のグラフを描く
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 行列の列
+const (
+	ColTime         = waveform.ColTime  // 列1番目:時間(s)
+	ColWireA        = waveform.ColWireA // 列2番目:RS485/422バスA線電圧(V)
+	ColWireB        = waveform.ColWireB // 列3番目:RS485/422バスB線電圧(V)
+	ColDriverEnable = 3                 // 半二重でDE列付きの場合の列4番目:ドライバイネーブル(DE/RE)信号電圧(V)
+)
+
+// 波形のグラフの名前
+const (
+	Raw      = "raw"      // 測定値
+	Filtered = "filtered" // フィルタ後
+	Reshaped = "reshaped" // 波形整形後
+	Uart     = "uart"     // 復号したビットとバイト
+	Stacked  = "stacked"  // A線, B線, A-B間電圧差を縦に並べた測定値
+	Diff     = "diff"     // A-B間電圧差としきい値の測定値
+)
+
+// 値を示すビット
+type Bit struct {
+	Time  float64 // 始まりの時間(s)
+	Label string  // 示す値
+	Idle  bool    // アイドル(重なる場合は他のビットを優先する)
+}
+
+// 値を示す文字
+type Code struct {
+	Time  float64 // 始まりの時間(s)
+	Label string  // 示す値
+	Rx    bool    // 全二重の受信方向(送信方向と重ならないように下げて色を変える)
+}
+
+// 縦線で示す外部イベント
+type Event struct {
+	Time  float64 `json:"time"`  // 入力CSVの時間列と同じ時間軸の時間(s)
+	Label string  `json:"label"` // イベントの内容
+}
+
+// グラフの設定
+type Option struct {
+	Title        string
+	XLabel       string
+	YLabel       string
+	Bits         []Bit
+	Codes        []Code
+	BitPeriod    float64                 // ビットの境界の補助線を引くビット周期(s), 0の場合は引かない
+	CodeBits     int                     // 補助線を引く1文字のビット数(スタートビットから最初のストップビットまで)
+	IdleSpace    bool                    // アイドルがSpace(A-B間電圧差が負)の配線
+	CompressIdle float64                 // 横軸で詰める無通信時間の下限(s), 0の場合は詰めない
+	FrameTable   []FrameTableRow         // グラフの下に描くフレームの一覧表, 空の場合は描かない
+	RxMatrix     mat.Matrix              // 全二重の場合のRX対(時間,A,B), 半二重ではnil
+	Events       []Event                 // 縦線で示す外部イベント
+	Runts        []float64               // 印を付けるラントの時間(s)(横軸に合わせる)
+	XToTime      func(float64) time.Time // 横軸を絶対時刻で表示する場合の変換, 秒で表示する場合はnil
+	PngTexts     []PngText               // 画像に埋め込むテキスト(来歴など), 空の場合は埋め込まない
+	Threshold    float64                 // しきい値の横線(V), 0の場合はwaveform.Threshold
+}
+
+// アイドルの向きの符号
+func (o Option) idleSign() float64 {
+	if o.IdleSpace {
+		return -1
+	}
+	return 1
+}
+
+// ドライバイネーブルの列が有るか
+func HasDriverEnable(matrix mat.Matrix) bool {
+	_, cols := matrix.Dims()
+	return cols == ColDriverEnable+1
+}
+
+// 電線1本分の折れ線グラフを追加する
+func AddWireLine(p *plot.Plot, matrix mat.Matrix, col int, name string, lineColor color.Color) {
+	rows, _ := matrix.Dims()
+	xys := make(plotter.XYs, rows)
+	for row := range xys {
+		xys[row].X = matrix.At(row, ColTime)
+		xys[row].Y = matrix.At(row, col)
+	}
+	// 折れ線グラフを作成
+	if line, points, err := plotter.NewLinePoints(xys); err != nil {
+		slog.Error("NewLine", "err", err)
+	} else {
+		points.Shape = draw.CrossGlyph{}
+		line.Color = lineColor
+		p.Add(line, points)
+		p.Legend.Add(name, line) // 凡例
+	}
+}
+
+// グラフを保存する
+// nameは波形のグラフの名前(Raw, Stacked など)
+func Save(savefilepath string, name string, graphWidth int, graphHeight int, option Option, matrix mat.Matrix) error {
+	// 描けないグラフの場合は空のファイルを作らない
+	if _, err := drawerOf(name); err != nil {
+		return err
+	}
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	if err := WritePNG(f, name, graphWidth, graphHeight, option, matrix); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		slog.Error("Close", "err", err)
+		return err
+	}
+	return nil
+}
+
+// グラフをキャンバスに描く関数
+type Drawer func(graphWidth int, graphHeight int, option Option, matrix mat.Matrix) (*vgimg.Canvas, error)
+
+// グラフの名前に対応する描く関数
+func drawerOf(name string) (Drawer, error) {
+	switch name {
+	case Raw, Filtered, Reshaped, Uart:
+		return Draw, nil
+	case Stacked:
+		return DrawStacked, nil
+	case Diff:
+		return DrawDifferential, nil
+	}
+	return nil, fmt.Errorf("グラフ \"%s\" は描けない", name)
+}
+
+// 測定値(時間, A線電圧, B線電圧の行列)のグラフをキャンバスに描く
+// ビットと文字の値のラベル, ビットの境界の補助線, フレームの一覧表は設定に有る場合だけ描く
+func Draw(graphWidth int, graphHeight int, option Option, matrix mat.Matrix) (*vgimg.Canvas, error) {
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// 横軸を絶対時刻で表示する
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+
+	// 補助線
+	//	p.Add(plotter.NewGrid())
+
+	// 凡例の位置を右下に設定
+	p.Legend.Top = false
+	p.Legend.Left = false
+	p.Legend.Padding = vg.Points(5)
+
+	_, cols := matrix.Dims()
+
+	if cols < 3 {
+		slog.Error("列数が不足")
+		return nil, errors.New("列数が不足している")
+	}
+
+	// ビットの境界の補助線は波形の下に描く
+	if option.BitPeriod > 0 && len(option.Codes) != 0 {
+		p.Add(newBitGrid(option.Codes, option.BitPeriod, option.CodeBits))
+	}
+
+	if option.RxMatrix == nil {
+		// A線電圧
+		AddWireLine(p, matrix, ColWireA, "A線", colornames.Darkmagenta)
+		// B線電圧
+		AddWireLine(p, matrix, ColWireB, "B線", colornames.Darkcyan)
+		// ドライバイネーブル
+		if HasDriverEnable(matrix) {
+			AddWireLine(p, matrix, ColDriverEnable, "DE", colornames.Goldenrod)
+		}
+	} else {
+		// 全二重の場合は送信対と受信対を重ねて表示する
+		AddWireLine(p, matrix, ColWireA, "TX A線", colornames.Darkmagenta)
+		AddWireLine(p, matrix, ColWireB, "TX B線", colornames.Darkcyan)
+		AddWireLine(p, option.RxMatrix, ColWireA, "RX A線", colornames.Orangered)
+		AddWireLine(p, option.RxMatrix, ColWireB, "RX B線", colornames.Royalblue)
+	}
+
+	// 各々ビットの値
+	// 密集している場合はアイドル以外のビットを優先して重ならないものだけを描く
+	if len(option.Bits) != 0 {
+		labelPoints := make(plotter.XYs, len(option.Bits))
+		labelTexts := make([]string, len(option.Bits))
+		priority := make([]int, len(option.Bits))
+		for i, v := range option.Bits {
+			labelPoints[i].X = v.Time
+			labelPoints[i].Y = 0
+			labelTexts[i] = v.Label
+			if !v.Idle {
+				priority[i] = 1
+			}
+		}
+		// データポイントにラベルを追加
+		labels, err := newPlacedLabels(labelPoints, labelTexts, priority, 1)
+		if err != nil {
+			slog.Error("newPlacedLabels", "err", err)
+			return nil, err
+		}
+		// ラベルの回転を設定
+		for i := range labels.TextStyle {
+			labels.TextStyle[i].Rotation = -math.Pi / 2 // 右90度回転
+		}
+		// ラベルを追加する
+		p.Add(labels)
+	}
+
+	// 文字の値
+	// 重なる場合は段をずらして引き出し線でつなぐ
+	if len(option.Codes) != 0 {
+		labelPoints := make(plotter.XYs, len(option.Codes))
+		labelTexts := make([]string, len(option.Codes))
+		for i, v := range option.Codes {
+			labelPoints[i].X = v.Time
+			labelPoints[i].Y = -1
+			if v.Rx {
+				labelPoints[i].Y = -1.5 // 受信方向は送信方向と重ならないように下げる
+			}
+			labelTexts[i] = v.Label
+		}
+		// データポイントにラベルを追加
+		labels, err := newPlacedLabels(labelPoints, labelTexts, nil, CodeLabelRows)
+		if err != nil {
+			slog.Error("newPlacedLabels", "err", err)
+			return nil, err
+		}
+		// ラベル
+		for i := range labels.TextStyle {
+			labels.TextStyle[i].Font.Size = 22
+			labels.TextStyle[i].Color = colornames.Darkgreen
+			if option.Codes[i].Rx {
+				labels.TextStyle[i].Color = colornames.Darkorange
+			}
+		}
+		// ラベルを追加する
+		p.Add(labels)
+	}
+
+	// 外部イベントを縦線で示す
+	if err := AddEventMarkers(p, option.Events); err != nil {
+		return nil, err
+	}
+
+	// ラントを印で示す
+	if err := AddRuntMarkers(p, option.Runts); err != nil {
+		return nil, err
+	}
+
+	// 横軸の単位を表示範囲に合わせる
+	ScaleTimeAxis(p, option)
+
+	// 長い無通信時間を詰める
+	if option.CompressIdle > 0 {
+		spans := ActiveSpans(matrix, option.idleSign())
+		if option.RxMatrix != nil {
+			spans = append(spans, ActiveSpans(option.RxMatrix, option.idleSign())...)
+		}
+		CompressIdleTime(p, spans, option.CompressIdle)
+	}
+
+	// フレームの一覧表をグラフの下に付ける
+	if len(option.FrameTable) != 0 {
+		tableHeight := frameTableHeight(option.FrameTable)
+		canvas := vgimg.New(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight))+tableHeight)
+		dc := draw.New(canvas)
+		dc.SetColor(colornames.Snow)
+		dc.Fill(dc.Rectangle.Path())
+		p.Draw(draw.Crop(dc, 0, 0, tableHeight, 0))
+		drawFrameTable(draw.Crop(dc, 0, 0, 0, -vg.Points(float64(graphHeight))), option.FrameTable)
+		return canvas, nil
+	}
+
+	canvas := vgimg.New(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)))
+	p.Draw(draw.New(canvas))
+	return canvas, nil
+}
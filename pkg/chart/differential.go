@@ -0,0 +1,107 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号に使うA-B間電圧差だけを差動通信のしきい値と共に示すグラフ
+package chart
+
+import (
+	"image/color"
+	"log/slog"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// A-B間電圧差の折れ線グラフを追加する
+func addDifferentialLine(p *plot.Plot, matrix mat.Matrix, name string, lineColor color.Color) {
+	diff := waveform.Differential(nil, matrix)
+	xys := make(plotter.XYs, len(diff))
+	for row := range xys {
+		xys[row].X = matrix.At(row, ColTime)
+		xys[row].Y = diff[row]
+	}
+	if line, err := plotter.NewLine(xys); err != nil {
+		slog.Error("NewLine", "err", err)
+	} else {
+		line.Color = lineColor
+		p.Add(line)
+		p.Legend.Add(name, line) // 凡例
+	}
+}
+
+// 復号のしきい値(0の場合は差動通信のしきい値)を横線で示す
+func AddThresholdLines(p *plot.Plot, threshold float64) {
+	if threshold <= 0 {
+		threshold = waveform.Threshold
+	}
+	for _, v := range []float64{threshold, -threshold} {
+		threshold := plotter.NewFunction(func(float64) float64 { return v })
+		threshold.Color = colornames.Gray
+		threshold.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+		p.Add(threshold)
+	}
+}
+
+// A-B間電圧差のグラフをキャンバスに描く
+// 全二重の場合は受信対も重ねる
+func DrawDifferential(graphWidth int, graphHeight int, option Option, matrix mat.Matrix) (*vgimg.Canvas, error) {
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// 横軸を絶対時刻で表示する
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+
+	// 凡例の位置を右下に設定
+	p.Legend.Top = false
+	p.Legend.Left = false
+	p.Legend.Padding = vg.Points(5)
+
+	if option.RxMatrix == nil {
+		addDifferentialLine(p, matrix, "A-B", colornames.Darkgreen)
+	} else {
+		addDifferentialLine(p, matrix, "TX A-B", colornames.Darkgreen)
+		addDifferentialLine(p, option.RxMatrix, "RX A-B", colornames.Darkorange)
+	}
+	AddThresholdLines(p, option.Threshold)
+
+	// 外部イベントを縦線で示す
+	if err := AddEventMarkers(p, option.Events); err != nil {
+		return nil, err
+	}
+
+	// ラントを印で示す
+	if err := AddRuntMarkers(p, option.Runts); err != nil {
+		return nil, err
+	}
+
+	// 横軸の単位を表示範囲に合わせる
+	ScaleTimeAxis(p, option)
+
+	// 長い無通信時間を詰める
+	if option.CompressIdle > 0 {
+		spans := ActiveSpans(matrix, option.idleSign())
+		if option.RxMatrix != nil {
+			spans = append(spans, ActiveSpans(option.RxMatrix, option.idleSign())...)
+		}
+		CompressIdleTime(p, spans, option.CompressIdle)
+	}
+
+	canvas := vgimg.New(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)))
+	p.Draw(draw.New(canvas))
+	return canvas, nil
+}
@@ -0,0 +1,79 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// UART通信のグラフの下にフレームの一覧表を描く(報告書にそのまま貼れる1枚の画像にする)
+package chart
+
+import (
+	"image/color"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/text"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// 表の1行
+type FrameTableRow struct {
+	Index  string // フレーム番号(1始まり)
+	Time   string // 開始時刻
+	Octets string // バイト列(16進数)
+	Status string // 誤り検出符号とフレーミングエラーの結果
+	Failed bool   // 異常が有る
+}
+
+// 表の文字の書式
+func frameTableTextStyle() text.Style {
+	return text.Style{
+		Color:   color.Black,
+		Font:    font.From(plot.DefaultFont, vg.Points(11)),
+		Handler: plot.DefaultTextHandler,
+	}
+}
+
+// 表の高さ(見出しの行を含む)
+func frameTableHeight(rows []FrameTableRow) vg.Length {
+	return vg.Length(len(rows)+2) * frameTableTextStyle().FontExtents().Height
+}
+
+// 表を描く
+func drawFrameTable(c draw.Canvas, rows []FrameTableRow) {
+	style := frameTableTextStyle()
+	lineHeight := style.FontExtents().Height
+	header := []string{"frame", "time", "bytes", "status"}
+	cells := func(r FrameTableRow) []string { return []string{r.Index, r.Time, r.Octets, r.Status} }
+
+	// 列の幅は一番長い文字列に合わせる
+	widths := make([]vg.Length, len(header))
+	for k, h := range header {
+		widths[k] = style.Width(h)
+	}
+	for _, r := range rows {
+		for k, s := range cells(r) {
+			widths[k] = max(widths[k], style.Width(s))
+		}
+	}
+
+	gap := vg.Points(12)
+	drawRow := func(y vg.Length, values []string, textColor color.Color) {
+		x := c.Min.X + gap
+		style.Color = textColor
+		for k, s := range values {
+			c.FillText(style, vg.Point{X: x, Y: y}, s)
+			x += widths[k] + gap
+		}
+	}
+	y := c.Max.Y - lineHeight
+	drawRow(y, header, colornames.Dimgray)
+	c.StrokeLine2(draw.LineStyle{Color: colornames.Dimgray, Width: vg.Points(0.5)}, c.Min.X+gap, y-vg.Points(2), c.Max.X-gap, y-vg.Points(2))
+	for _, r := range rows {
+		y -= lineHeight
+		textColor := color.Color(color.Black)
+		if r.Failed {
+			textColor = colornames.Red
+		}
+		drawRow(y, cells(r), textColor)
+	}
+}
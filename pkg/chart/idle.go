@@ -0,0 +1,173 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// グラフの時間軸で長い無通信時間を詰めて表示する(まばらな通信のグラフが空白だらけにならないように)
+package chart
+
+import (
+	"image/color"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 目盛りの数字の最小の間隔(横軸の長さに対する比)
+const IdleTickSpacing = 0.06
+
+// 詰めた時間軸
+// cutsの区間を取り除いて, 残りの区間をつなげて表示する
+type IdleCompression struct {
+	cuts [][2]float64 // 取り除く区間(s)(始まりの順, 重なりは無い)
+}
+
+// 信号が有る(A-B間電圧差がアイドルと反対の向きの)区間
+// signはアイドルの向きの符号
+func ActiveSpans(matrix mat.Matrix, sign float64) [][2]float64 {
+	spans := [][2]float64{}
+	diff := waveform.Differential(nil, matrix)
+	begin := -1
+	for r := range diff {
+		if sign*diff[r] < -waveform.Threshold {
+			if begin < 0 {
+				begin = r
+			}
+		} else if begin >= 0 {
+			spans = append(spans, [2]float64{matrix.At(begin, ColTime), matrix.At(r, ColTime)})
+			begin = -1
+		}
+	}
+	if begin >= 0 {
+		spans = append(spans, [2]float64{matrix.At(begin, ColTime), matrix.At(len(diff)-1, ColTime)})
+	}
+	return spans
+}
+
+// 信号が有る区間の間の無通信時間がminIdleより長い所を, minIdleの長さまで詰める
+// 前後にminIdle/2ずつ残して真ん中を取り除く
+func newIdleCompression(spans [][2]float64, xMin float64, xMax float64, minIdle float64) IdleCompression {
+	sorted := append([][2]float64{}, spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+
+	cuts := [][2]float64{}
+	end := xMin
+	addGap := func(begin float64) {
+		if begin-end > minIdle {
+			cuts = append(cuts, [2]float64{end + minIdle/2, begin - minIdle/2})
+		}
+	}
+	for _, s := range sorted {
+		addGap(s[0])
+		end = max(end, s[1])
+	}
+	addGap(xMax)
+	return IdleCompression{cuts: cuts}
+}
+
+// 詰めた時間軸の座標
+func (ic IdleCompression) compress(x float64) float64 {
+	removed := 0.0
+	for _, c := range ic.cuts {
+		if x <= c[0] {
+			break
+		}
+		removed += min(x, c[1]) - c[0]
+	}
+	return x - removed
+}
+
+// 詰めた時間軸の縮尺(plot.Normalizer)
+func (ic IdleCompression) Normalize(min, max, x float64) float64 {
+	lo, hi := ic.compress(min), ic.compress(max)
+	return (ic.compress(x) - lo) / (hi - lo)
+}
+
+// 詰めた時間軸の目盛り
+// 残した区間ごとに目盛りを求め, 数字が詰まる所は数字を省く
+type IdleCompressedTicks struct {
+	ticker      plot.Ticker // 区間ごとの目盛りを決める
+	compression IdleCompression
+}
+
+// 目盛りを決める
+func (t IdleCompressedTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := []plot.Tick{}
+	begin := min
+	segments := append(append([][2]float64{}, t.compression.cuts...), [2]float64{max, max})
+	for _, c := range segments {
+		if c[0] > begin {
+			for _, tick := range t.ticker.Ticks(begin, c[0]) {
+				// 補助目盛りは区間ごとに付けると詰まり過ぎるので省く
+				if tick.Label != "" && tick.Value >= begin && tick.Value <= c[0] {
+					ticks = append(ticks, tick)
+				}
+			}
+		}
+		begin = c[1]
+	}
+
+	// 目盛りの数字が重ならないように間引く
+	last := -1.0
+	for i := range ticks {
+		at := t.compression.Normalize(min, max, ticks[i].Value)
+		if last >= 0 && at-last < IdleTickSpacing {
+			ticks[i].Label = ""
+			continue
+		}
+		last = at
+	}
+	return ticks
+}
+
+// 詰めた所の印(plot.Plotter)
+type IdleBreakMarkers struct {
+	compression IdleCompression
+	LineStyle   draw.LineStyle
+}
+
+// 詰めた所に縦の波線を描く
+func (m IdleBreakMarkers) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, _ := plt.Transforms(&c)
+	const amplitude, step = vg.Length(3), vg.Length(6)
+	for _, cut := range m.compression.cuts {
+		x := trX(cut[0])
+		if x <= c.Min.X || x >= c.Max.X {
+			continue
+		}
+		path := vg.Path{}
+		path.Move(vg.Point{X: x, Y: c.Min.Y})
+		k := 0
+		for y := c.Min.Y + step; y < c.Max.Y; y += step {
+			k++
+			dx := amplitude
+			if k%2 == 0 {
+				dx = -amplitude
+			}
+			path.Line(vg.Point{X: x + dx, Y: y})
+		}
+		c.SetLineStyle(m.LineStyle)
+		c.Stroke(path)
+	}
+}
+
+// 横軸の無通信時間を詰める
+// グラフに全ての要素を加えて横軸の目盛りを決めた後に呼ぶ
+func CompressIdleTime(p *plot.Plot, spans [][2]float64, minIdle float64) {
+	if minIdle <= 0 || p.X.Max <= p.X.Min {
+		return
+	}
+	compression := newIdleCompression(spans, p.X.Min, p.X.Max, minIdle)
+	if len(compression.cuts) == 0 {
+		return
+	}
+	p.X.Scale = compression
+	p.X.Tick.Marker = IdleCompressedTicks{ticker: p.X.Tick.Marker, compression: compression}
+	p.Add(IdleBreakMarkers{
+		compression: compression,
+		LineStyle:   draw.LineStyle{Color: color.Gray{Y: 0xa0}, Width: vg.Points(1)},
+	})
+}
@@ -0,0 +1,110 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 密集したラベルが重ならないように間引いたり段をずらしたりして配置する
+package chart
+
+import (
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// 文字の値のラベルをずらす段数の上限
+const CodeLabelRows = 3
+
+// ラベル同士の最小の間隔
+const LabelPadding = vg.Length(2)
+
+// 1段に置いたラベルの横方向の範囲(左端の順に並べる, 重なりは無い)
+type LabelSpans [][2]vg.Length
+
+// [lo,hi]が既に置いたラベルと重ならないか
+func (s LabelSpans) free(lo, hi vg.Length) bool {
+	// hiがloより右にある最初の範囲だけを調べればよい
+	k := sort.Search(len(s), func(k int) bool { return s[k][1] > lo })
+	return k == len(s) || s[k][0] >= hi
+}
+
+// [lo,hi]を加える
+func (s LabelSpans) insert(lo, hi vg.Length) LabelSpans {
+	k := sort.Search(len(s), func(k int) bool { return s[k][0] > lo })
+	s = append(s, [2]vg.Length{})
+	copy(s[k+1:], s[k:])
+	s[k] = [2]vg.Length{lo, hi}
+	return s
+}
+
+// 重ならないように配置するラベル
+// 描画する時の横軸の縮尺でラベルの幅を測り, 優先度の高い順に置いていく
+// 重なる場合はrows段まで上にずらし(ずらしたラベルには引き出し線を引く), それでも重なる場合は描かない
+type PlacedLabels struct {
+	*plotter.Labels
+	priority []int          // ラベルの優先度(大きいほど先に置く), nilの場合は全て同じ
+	rows     int            // ずらす段数の上限(1の場合は間引くだけ)
+	leader   draw.LineStyle // 引き出し線の描き方(色はラベルの色)
+}
+
+// ラベルを作る
+func newPlacedLabels(xys plotter.XYs, texts []string, priority []int, rows int) (*PlacedLabels, error) {
+	labels, err := plotter.NewLabels(plotter.XYLabels{XYs: xys, Labels: texts})
+	if err != nil {
+		return nil, err
+	}
+	return &PlacedLabels{
+		Labels:   labels,
+		priority: priority,
+		rows:     max(rows, 1),
+		leader:   draw.LineStyle{Width: vg.Points(0.5)},
+	}, nil
+}
+
+// ラベルを描く(plot.Plotter)
+func (l *PlacedLabels) Plot(c draw.Canvas, p *plot.Plot) {
+	trX, trY := p.Transforms(&c)
+
+	order := make([]int, len(l.Labels.Labels))
+	for i := range order {
+		order[i] = i
+	}
+	if l.priority != nil {
+		sort.SliceStable(order, func(a, b int) bool { return l.priority[order[a]] > l.priority[order[b]] })
+	}
+
+	// 置き場所の高さ(データの縦軸の値)ごとに段を分ける
+	spans := map[float64][]LabelSpans{}
+	for _, i := range order {
+		xy := l.XYs[i]
+		anchor := vg.Point{X: trX(xy.X), Y: trY(xy.Y)}
+		if !c.Contains(anchor) {
+			continue
+		}
+		text := l.Labels.Labels[i]
+		style := l.TextStyle[i]
+		box := style.Rectangle(text)
+		lo, hi := anchor.X+box.Min.X-LabelPadding, anchor.X+box.Max.X+LabelPadding
+		rows, ok := spans[xy.Y]
+		if !ok {
+			rows = make([]LabelSpans, l.rows)
+			spans[xy.Y] = rows
+		}
+		for row := range rows {
+			if !rows[row].free(lo, hi) {
+				continue
+			}
+			rows[row] = rows[row].insert(lo, hi)
+			at := anchor.Add(l.Offset)
+			if row > 0 {
+				at.Y += vg.Length(row) * box.Size().Y
+				leader := l.leader
+				leader.Color = style.Color
+				c.StrokeLine2(leader, anchor.X, anchor.Y, at.X, at.Y)
+			}
+			c.FillText(style, at, text)
+			break
+		}
+	}
+}
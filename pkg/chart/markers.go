@@ -0,0 +1,76 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 外部イベントとラントの位置をグラフに印で示す
+package chart
+
+import (
+	"image/color"
+	"log/slog"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// 外部イベントを縦線とラベルでグラフに追加する
+// 縦線はそれまでに追加したデータの縦軸の範囲に引く
+func AddEventMarkers(p *plot.Plot, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	labelPoints := make([]plotter.XY, len(events))
+	labelTexts := make([]string, len(events))
+	for i, e := range events {
+		marker, err := plotter.NewLine(plotter.XYs{{X: e.Time, Y: p.Y.Min}, {X: e.Time, Y: p.Y.Max}})
+		if err != nil {
+			slog.Error("NewLine", "err", err)
+			return err
+		}
+		marker.Color = colornames.Red
+		marker.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+		p.Add(marker)
+		labelPoints[i] = plotter.XY{X: e.Time, Y: p.Y.Max}
+		labelTexts[i] = e.Label
+	}
+	labels, err := plotter.NewLabels(plotter.XYLabels{
+		XYs:    labelPoints,
+		Labels: labelTexts,
+	})
+	if err != nil {
+		slog.Error("NewLabels", "err", err)
+		return err
+	}
+	for i := range labels.TextStyle {
+		labels.TextStyle[i].Color = colornames.Red
+	}
+	p.Add(labels)
+	return nil
+}
+
+// ラント(時間)の位置をグラフの上端に印で示す
+// 印はそれまでに追加したデータの縦軸の上端に描く
+func AddRuntMarkers(p *plot.Plot, runts []float64) error {
+	if len(runts) == 0 {
+		return nil
+	}
+	xys := make(plotter.XYs, len(runts))
+	for i, t := range runts {
+		xys[i] = plotter.XY{X: t, Y: p.Y.Max}
+	}
+	scatter, err := plotter.NewScatter(xys)
+	if err != nil {
+		slog.Error("NewScatter", "err", err)
+		return err
+	}
+	scatter.GlyphStyle = draw.GlyphStyle{
+		Color:  color.Color(colornames.Orangered),
+		Radius: vg.Points(4),
+		Shape:  draw.PyramidGlyph{},
+	}
+	p.Add(scatter)
+	p.Legend.Add("ラント", scatter)
+	return nil
+}
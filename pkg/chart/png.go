@@ -0,0 +1,96 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 描画したキャンバスをPNG画像にする(来歴などのテキストを埋め込む)
+package chart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// PNG画像のシグネチャ
+const PngSignature = "\x89PNG\r\n\x1a\n"
+
+// PNG画像に埋め込むテキスト(iTXtチャンク)
+type PngText struct {
+	Keyword string
+	Text    string
+}
+
+// PNGのiTXtチャンクを作る
+func pngTextChunk(t PngText) []byte {
+	data := []byte(t.Keyword)
+	data = append(data, 0, 0, 0) // 区切り, 圧縮しない, 圧縮方式
+	data = append(data, 0, 0)    // 言語タグなし, 翻訳したキーワードなし
+	data = append(data, t.Text...)
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, "iTXt"...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// PNG画像のIHDRチャンクの後にテキストを書き込む
+func EmbedPngTexts(png []byte, texts []PngText) ([]byte, error) {
+	// シグネチャ(8) + IHDRチャンク(長さ4 + 種類4 + データ13 + CRC4)
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(png) < ihdrEnd || !bytes.HasPrefix(png, []byte(PngSignature)) || string(png[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("PNG画像ではない")
+	}
+	var buf bytes.Buffer
+	buf.Write(png[:ihdrEnd])
+	for _, t := range texts {
+		buf.Write(pngTextChunk(t))
+	}
+	buf.Write(png[ihdrEnd:])
+	return buf.Bytes(), nil
+}
+
+// 描画したキャンバスをPNG画像にしてwに書く
+// テキストが無い場合は埋め込まない
+func WriteCanvas(w io.Writer, canvas *vgimg.Canvas, texts []PngText) error {
+	var buf bytes.Buffer
+	if _, err := (vgimg.PngCanvas{Canvas: canvas}).WriteTo(&buf); err != nil {
+		slog.Error("WriteTo", "err", err)
+		return err
+	}
+	png := buf.Bytes()
+	if len(texts) != 0 {
+		var err error
+		if png, err = EmbedPngTexts(png, texts); err != nil {
+			slog.Error("EmbedPngTexts", "err", err)
+			return err
+		}
+	}
+	if _, err := w.Write(png); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 描画したキャンバスをPNG画像ファイルに保存する
+func SaveCanvas(savefilepath string, canvas *vgimg.Canvas, texts []PngText) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	if err := WriteCanvas(f, canvas, texts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		slog.Error("Close", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 波形のグラフをファイルを介さずに描く(Web UI, TUI, 組み込み先のアプリケーションから使う)
+package chart
+
+import (
+	"image"
+	"io"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// グラフを画像に描く
+// 大きさはポイント(1/72インチ)で指定し, 画像はvgimg.DefaultDPIの解像度で描く
+// フレームの一覧表を付ける場合はその分だけ縦に伸びる
+func Render(name string, graphWidth int, graphHeight int, option Option, matrix mat.Matrix) (image.Image, error) {
+	drawer, err := drawerOf(name)
+	if err != nil {
+		return nil, err
+	}
+	canvas, err := drawer(graphWidth, graphHeight, option, matrix)
+	if err != nil {
+		return nil, err
+	}
+	return canvas.Image(), nil
+}
+
+// グラフをPNG画像にしてwに書く
+// option.PngTextsが有れば埋め込む
+func WritePNG(w io.Writer, name string, graphWidth int, graphHeight int, option Option, matrix mat.Matrix) error {
+	drawer, err := drawerOf(name)
+	if err != nil {
+		return err
+	}
+	canvas, err := drawer(graphWidth, graphHeight, option, matrix)
+	if err != nil {
+		return err
+	}
+	return WriteCanvas(w, canvas, option.PngTexts)
+}
@@ -0,0 +1,70 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+package chart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// 短い矩形波(時間, A線電圧, B線電圧)
+func squareWave() mat.Matrix {
+	data := []float64{}
+	for i := 0; i < 100; i++ {
+		a, b := 2.5, -2.5
+		if (i/10)%2 == 1 {
+			a, b = -2.5, 2.5
+		}
+		data = append(data, float64(i)*1e-5, a, b)
+	}
+	return mat.NewDense(len(data)/3, 3, data)
+}
+
+// ファイルを介さずにPNG画像を書ける
+func TestWritePNG(t *testing.T) {
+	option := Option{
+		Title:    "test",
+		PngTexts: []PngText{{Keyword: "Software", Text: "pulseinsight"}},
+	}
+	for _, name := range []string{Raw, Stacked, Diff} {
+		var buf bytes.Buffer
+		if err := WritePNG(&buf, name, 320, 240, option, squareWave()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte(PngSignature)) {
+			t.Errorf("%s: PNG画像のシグネチャで始まらない", name)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("iTXtSoftware")) {
+			t.Errorf("%s: テキストが埋め込まれていない", name)
+		}
+	}
+}
+
+// 画像の大きさは指定した大きさ(ポイント)を解像度で換算した大きさになる
+func TestRender(t *testing.T) {
+	img, err := Render(Raw, 360, 240, Option{}, squareWave())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantX, wantY := 360*vgimg.DefaultDPI/72, 240*vgimg.DefaultDPI/72
+	if size := img.Bounds().Size(); size.X != wantX || size.Y != wantY {
+		t.Errorf("大きさ %v", size)
+	}
+}
+
+// 描けないグラフは断る
+func TestUnknownChart(t *testing.T) {
+	var buf bytes.Buffer
+	err := WritePNG(&buf, "unknown", 320, 240, Option{}, squareWave())
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("err %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d バイト書いた", buf.Len())
+	}
+}
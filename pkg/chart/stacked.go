@@ -0,0 +1,105 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// A線, B線, A-B間電圧差を縦に並べて時間軸を揃えたグラフ(片側だけの駆動の異常を見る)
+package chart
+
+import (
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// 目盛りの数字を表示しない目盛り
+type UnlabeledTicks struct {
+	ticker plot.Ticker // 目盛りの位置を決める
+}
+
+// 目盛りを決める
+func (t UnlabeledTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := t.ticker.Ticks(min, max)
+	for i := range ticks {
+		ticks[i].Label = ""
+	}
+	return ticks
+}
+
+// 積み重ねたグラフの1段
+func newStackedPanel(option Option, yLabelText string) *plot.Plot {
+	p := plot.New()
+	p.Y.Label.Text = yLabelText
+	p.BackgroundColor = colornames.Snow
+	p.Legend.Top = false
+	p.Legend.Left = false
+	p.Legend.Padding = vg.Points(5)
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+	return p
+}
+
+// A線, B線, A-B間電圧差を上から順に縦に並べたグラフをキャンバスに描く
+// 全二重の場合は各段に受信対も重ねる
+func DrawStacked(graphWidth int, graphHeight int, option Option, matrix mat.Matrix) (*vgimg.Canvas, error) {
+	a := newStackedPanel(option, "A線(V)")
+	b := newStackedPanel(option, "B線(V)")
+	d := newStackedPanel(option, "A-B(V)")
+	a.Title.Text = option.Title
+
+	if option.RxMatrix == nil {
+		AddWireLine(a, matrix, ColWireA, "A線", colornames.Darkmagenta)
+		AddWireLine(b, matrix, ColWireB, "B線", colornames.Darkcyan)
+		addDifferentialLine(d, matrix, "A-B", colornames.Darkgreen)
+	} else {
+		AddWireLine(a, matrix, ColWireA, "TX A線", colornames.Darkmagenta)
+		AddWireLine(a, option.RxMatrix, ColWireA, "RX A線", colornames.Orangered)
+		AddWireLine(b, matrix, ColWireB, "TX B線", colornames.Darkcyan)
+		AddWireLine(b, option.RxMatrix, ColWireB, "RX B線", colornames.Royalblue)
+		addDifferentialLine(d, matrix, "TX A-B", colornames.Darkgreen)
+		addDifferentialLine(d, option.RxMatrix, "RX A-B", colornames.Darkorange)
+	}
+	AddThresholdLines(d, option.Threshold)
+
+	// 時間軸を揃える
+	panels := []*plot.Plot{a, b, d}
+	xMin, xMax := a.X.Min, a.X.Max
+	for _, p := range panels {
+		xMin, xMax = min(xMin, p.X.Min), max(xMax, p.X.Max)
+	}
+	for _, p := range panels {
+		p.X.Min, p.X.Max = xMin, xMax
+		if err := AddEventMarkers(p, option.Events); err != nil {
+			return nil, err
+		}
+	}
+	// 横軸の見出しと目盛りの数字は一番下の段だけに付ける
+	d.X.Label.Text = option.XLabel
+	ScaleTimeAxis(d, option)
+	for _, p := range panels[:2] {
+		p.X.Tick.Marker = UnlabeledTicks{ticker: d.X.Tick.Marker}
+	}
+	// 長い無通信時間を詰める
+	if option.CompressIdle > 0 {
+		spans := ActiveSpans(matrix, option.idleSign())
+		if option.RxMatrix != nil {
+			spans = append(spans, ActiveSpans(option.RxMatrix, option.idleSign())...)
+		}
+		for _, p := range panels {
+			CompressIdleTime(p, spans, option.CompressIdle)
+		}
+	}
+
+	canvas := vgimg.New(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)))
+	dc := draw.New(canvas)
+	dc.SetColor(colornames.Snow)
+	dc.Fill(dc.Rectangle.Path())
+	tiles := draw.Tiles{Rows: len(panels), Cols: 1, PadX: vg.Millimeter, PadY: vg.Millimeter}
+	canvases := plot.Align([][]*plot.Plot{{a}, {b}, {d}}, tiles, dc)
+	for i, p := range panels {
+		p.Draw(canvases[i][0])
+	}
+	return canvas, nil
+}
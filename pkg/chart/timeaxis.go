@@ -0,0 +1,79 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// グラフの時間軸を表示範囲に合わせてµs, ms, sで表示する
+package chart
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/plot"
+)
+
+// 時間軸の単位
+type TimeAxisUnit struct {
+	name  string  // 単位の名前
+	scale float64 // 秒からの倍率
+}
+
+// 表示範囲が短い順に並べた単位
+var TimeAxisUnits = []TimeAxisUnit{
+	{name: "µs", scale: 1e6},
+	{name: "ms", scale: 1e3},
+	{name: "s", scale: 1},
+}
+
+// 起点をずらした時間軸の目盛り
+// 目盛りの値は秒のまま, ラベルだけ(秒-offset)×scaleで表示する
+type TimeAxisTicks struct {
+	scale  float64 // 秒からの倍率
+	offset float64 // 起点(s)
+}
+
+// 目盛りを決める
+func (t TimeAxisTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := plot.DefaultTicks{}.Ticks((min-t.offset)*t.scale, (max-t.offset)*t.scale)
+	for i := range ticks {
+		ticks[i].Value = ticks[i].Value/t.scale + t.offset
+	}
+	return ticks
+}
+
+// 表示範囲に合わせて時間軸の単位と起点を決める
+// 表示範囲の長さが1000未満になる最も小さい単位を選び,
+// 表示範囲の長さに比べて始まりが遠い(目盛りの桁数が多くなる)場合は起点をずらす
+// 起点の小数点以下の桁数も返す
+func timeAxisScale(begin, end float64) (TimeAxisUnit, float64, int) {
+	span := end - begin
+	unit := TimeAxisUnits[len(TimeAxisUnits)-1]
+	for _, u := range TimeAxisUnits {
+		if span*u.scale < 1000 {
+			unit = u
+			break
+		}
+	}
+	offset, digits := 0.0, 0
+	if span > 0 && math.Abs(begin) > 10*span {
+		// 起点は表示範囲の長さの桁で切り捨てる
+		step := math.Pow(10, math.Floor(math.Log10(span)))
+		offset = math.Floor(begin/step) * step
+		digits = max(0, -int(math.Floor(math.Log10(span))))
+	}
+	return unit, offset, digits
+}
+
+// 相対時間の横軸を表示範囲に合わせた単位で表示する
+// グラフに全ての要素を加えた後(横軸の範囲が決まった後)に呼ぶ
+// 絶対時刻で表示する場合は何もしない
+func ScaleTimeAxis(p *plot.Plot, option Option) {
+	if option.XToTime != nil || p.X.Max <= p.X.Min {
+		return
+	}
+	unit, offset, digits := timeAxisScale(p.X.Min, p.X.Max)
+	p.X.Tick.Marker = TimeAxisTicks{scale: unit.scale, offset: offset}
+	p.X.Label.Text = fmt.Sprintf("時間(%s)", unit.name)
+	if offset != 0 {
+		p.X.Label.Text += fmt.Sprintf("  %+.*fs", digits, offset)
+	}
+}
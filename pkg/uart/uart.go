@@ -0,0 +1,294 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// RS485/422バスの波形からUARTの文字を復号する
+package uart
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/bits"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// パリティ
+const (
+	ParityNone  = "none"  // パリティ無し
+	ParityEven  = "even"  // 偶数パリティ
+	ParityOdd   = "odd"   // 奇数パリティ
+	ParityMark  = "mark"  // 常に1
+	ParitySpace = "space" // 常に0
+)
+
+// 復号の設定
+type Config struct {
+	Baudrate int     // ボーレート(Decodeで使う)
+	DataBits int     // データビット数(5-8)
+	Parity   string  // パリティ(ParityNone, ParityEven, ParityOdd, ParityMark, ParitySpace)
+	StopBits float64 // ストップビット数(1, 1.5, 2)
+	// ストップビットが0の場合に同期し直して続ける
+	// 文字の中に立ち下がりがあればそこをスタートビットとして読み直し、無ければFramingErrorを付けて文字を残す
+	Permissive bool
+	// アイドルがSpace(A-B間電圧差が負)の配線
+	// スタートビットはMark, データビットの1はSpaceとして復号する
+	IdleSpace bool
+}
+
+// 8N1(9600bps)
+var DefaultConfig = Config{Baudrate: 9600, DataBits: 8, Parity: ParityNone, StopBits: 1}
+
+// データに対するパリティビットの正しい値
+func (c Config) ParityBit(octet uint8) uint8 {
+	ones := uint8(bits.OnesCount8(octet) & 1)
+	switch c.Parity {
+	case ParityEven:
+		return ones
+	case ParityOdd:
+		return ones ^ 1
+	case ParityMark:
+		return 1
+	}
+	return 0
+}
+
+// 復号したビット
+// Stateは受信機の状態(IDLE, START, Bit#n, PARITY, PE: パリティが合わない, STOP, X: フレーミングエラー)
+type Bit struct {
+	StartTime float64
+	EndTime   float64
+	State     string
+	Value     int
+}
+
+// 復号した文字
+type Code struct {
+	StartTime    float64
+	EndTime      float64
+	Octet        byte
+	ParityError  bool // パリティが合わない
+	FramingError bool // ストップビットが0(Config.Permissiveの場合だけ残す)
+}
+
+// 測定値(時間, A線電圧, B線電圧の行列)を波形整形して復号する
+// 時間は最初のスタートビット開始時間との相対時間にする
+func Decode(samples mat.Matrix, config Config) ([]Bit, []Code, error) {
+	if config.Baudrate <= 0 {
+		return nil, nil, fmt.Errorf("ボーレート %d には対応していない", config.Baudrate)
+	}
+	diff := waveform.Differential(nil, samples)
+	originTime, _ := waveform.FindLevelTime(samples, diff, config.IdleSpace)
+	reshaped, err := waveform.Reshape(samples, diff, config.Baudrate, originTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	return DecodeReshaped(reshaped, config)
+}
+
+// 波形整形した波形(waveform.Reshape)を復号する
+// パリティが合わない文字は復号した上でParityErrorを付ける
+func DecodeReshaped(reshaped mat.Matrix, config Config) ([]Bit, []Code, error) {
+	rows, cols := reshaped.Dims()
+
+	if cols != 3 {
+		slog.Warn("期待している列数と違う")
+	}
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	for r := 0; r+1 < rows; r += 2 {
+		startTime := reshaped.At(r, waveform.ColTime)
+		startA := reshaped.At(r, waveform.ColWireA)
+		startB := reshaped.At(r, waveform.ColWireB)
+		diff := startA - startB
+		endTime := reshaped.At(r+1, waveform.ColTime)
+		endA := reshaped.At(r+1, waveform.ColWireA)
+		endB := reshaped.At(r+1, waveform.ColWireB)
+		if startA != endA || startB != endB {
+			return nil, nil, errors.New("データ不一致")
+		}
+		if diff > waveform.Threshold {
+			// Mark
+			decoder.Push(waveform.Run{StartTime: startTime, EndTime: endTime, Mark: true})
+		} else if diff < -waveform.Threshold {
+			// Space
+			decoder.Push(waveform.Run{StartTime: startTime, EndTime: endTime, Mark: false})
+		}
+	}
+	return decoder.Bits(), decoder.Codes(), nil
+}
+
+// 少しずつ流す復号
+// 波形整形した区間を1つずつ加えてDecodeReshapedと同じビットと文字を作る
+type Decoder struct {
+	config        Config
+	dataStates    []string // データビットの状態
+	lastDataState string
+	state         string // 状態
+	octet         uint8  // コード
+	received      int    // 受け取ったデータビットの数
+	parityError   bool   // パリティが合わない
+	startTime     float64
+	startSignal   int            // スタートビットのビットの番号
+	pending       []waveform.Run // スタートビットからの区間(Config.Permissiveの場合に同期し直すのに使う)
+	signal        []Bit
+	codes         []Code
+}
+
+// 復号を始める
+func NewDecoder(config Config) (*Decoder, error) {
+	if config.DataBits < 1 || config.DataBits > 8 {
+		return nil, fmt.Errorf("データビット数 %d には対応していない", config.DataBits)
+	}
+	dataStates := make([]string, config.DataBits)
+	for n := range dataStates {
+		dataStates[n] = fmt.Sprintf("Bit#%d", n)
+	}
+	return &Decoder{
+		config:        config,
+		dataStates:    dataStates,
+		lastDataState: dataStates[len(dataStates)-1],
+		state:         "IDLE",
+		signal:        []Bit{},
+		codes:         []Code{},
+	}, nil
+}
+
+// 復号したビット
+func (d *Decoder) Bits() []Bit {
+	return d.signal
+}
+
+// 復号した文字
+func (d *Decoder) Codes() []Code {
+	return d.codes
+}
+
+// 状態移行
+func (d *Decoder) shiftState(bit uint8) {
+	bit &= 1
+	switch {
+	case d.state == "IDLE":
+		if bit == 0 { // バスアイドル状態からA線が下降したら開始
+			d.state = "START"
+		}
+		d.octet = 0 // 初期化
+
+	case d.state == "START":
+		d.state = d.dataStates[0]
+		d.octet = bit // Bit#0
+		d.received = 1
+		d.parityError = false
+
+	case d.state == "STOP":
+		if bit == 1 {
+			d.state = "IDLE"
+		} else {
+			d.state = "START"
+		}
+
+	case d.state == "X":
+		if bit == 1 && d.config.Permissive {
+			// 文字は残してあるので次のスタートビットを待つ
+			d.state = "IDLE"
+		} else if bit == 1 {
+			d.state = "STOP"
+		} else {
+			d.state = "START"
+		}
+
+	case d.received < d.config.DataBits:
+		d.state = d.dataStates[d.received]
+		d.octet |= bit << d.received // Bit#n
+		d.received++
+
+	case d.state == d.lastDataState && d.config.Parity != ParityNone:
+		// パリティビット
+		d.state = "PARITY"
+		if bit != d.config.ParityBit(d.octet) {
+			d.state = "PE"
+			d.parityError = true
+		}
+
+	default:
+		// データビットかパリティビットの次はストップビット
+		// ストップビットが複数でも受信機と同じく最初の1ビットだけを調べる
+		if bit == 1 {
+			d.state = "STOP"
+		} else {
+			d.state = "X"
+		}
+	}
+}
+
+// 波形整形した区間を1つ加える
+// Config.IdleSpaceの場合はMarkとSpaceを入れ替えて復号する
+func (d *Decoder) Push(run waveform.Run) {
+	if d.config.IdleSpace {
+		run.Mark = !run.Mark
+	}
+	d.push(run)
+}
+
+// 論理レベル(Markが1)の区間を1つ加える
+func (d *Decoder) push(run waveform.Run) {
+	value := 0
+	if run.Mark {
+		// Logical: 1
+		value = 1
+	}
+	d.shiftState(uint8(value))
+	d.signal = append(d.signal, Bit{run.StartTime, run.EndTime, d.state, value})
+
+	switch {
+	case d.state == "START":
+		d.startTime = run.StartTime
+		d.startSignal = len(d.signal) - 1
+		if d.config.Permissive {
+			d.pending = append(d.pending[:0], run)
+		}
+	case d.state == "STOP":
+		d.codes = append(d.codes, Code{StartTime: d.startTime, EndTime: run.EndTime, Octet: d.octet, ParityError: d.parityError})
+		d.pending = d.pending[:0]
+	case d.state == "X" && d.config.Permissive:
+		d.pending = append(d.pending, run)
+		if k := nextFallingEdge(d.pending); k >= 0 {
+			// 誤ったスタートビットをXにして, 立ち下がりまでを無通信として読み直す
+			d.signal = d.signal[:d.startSignal+1]
+			d.signal[d.startSignal].State = "X"
+			for _, r := range d.pending[1:k] {
+				value := 0
+				if r.Mark {
+					value = 1
+				}
+				d.signal = append(d.signal, Bit{r.StartTime, r.EndTime, "IDLE", value})
+			}
+			d.state = "IDLE"
+			rest := append([]waveform.Run{}, d.pending[k:]...)
+			d.pending = d.pending[:0]
+			for _, r := range rest {
+				d.push(r)
+			}
+			return
+		}
+		d.codes = append(d.codes, Code{StartTime: d.startTime, EndTime: run.EndTime, Octet: d.octet, ParityError: d.parityError, FramingError: true})
+		d.pending = d.pending[:0]
+	case len(d.pending) > 0:
+		d.pending = append(d.pending, run)
+	}
+}
+
+// スタートビットからの(論理レベルの)区間で, Markの次がSpaceになる(立ち下がり)最初の区間
+// 無ければ-1
+func nextFallingEdge(runs []waveform.Run) int {
+	for i := 1; i < len(runs); i++ {
+		if runs[i-1].Mark && !runs[i].Mark {
+			return i
+		}
+	}
+	return -1
+}
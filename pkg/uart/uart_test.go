@@ -0,0 +1,287 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+package uart
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 1ビットあたりのサンプル数(オシロスコープの測定値と同じく十分に多くする)
+const samplesPerBit = 100
+
+// 合成した波形(時間, A線電圧, B線電圧)
+// bitsの前後に2文字分の無通信(Mark)を置く
+func synthesize(baudrate int, bits []uint8) mat.Matrix {
+	idle := make([]uint8, 20)
+	for i := range idle {
+		idle[i] = 1
+	}
+	levels := append(append(append([]uint8{}, idle...), bits...), idle...)
+	interval := 1 / float64(baudrate*samplesPerBit)
+	data := make([]float64, 0, len(levels)*samplesPerBit*3)
+	for i, level := range levels {
+		a, b := 2.5, -2.5
+		if level == 0 {
+			a, b = -2.5, 2.5
+		}
+		for s := 0; s < samplesPerBit; s++ {
+			data = append(data, float64(i*samplesPerBit+s)*interval, a, b)
+		}
+	}
+	return mat.NewDense(len(data)/3, 3, data)
+}
+
+// configの形式の文字のビット列(スタートビット, データビット, パリティビット, ストップビット)
+// parityErrorの場合はパリティビットを反転する
+func frameBits(config Config, octet uint8, parityError bool) []uint8 {
+	bits := []uint8{0}
+	for n := 0; n < config.DataBits; n++ {
+		bits = append(bits, (octet>>n)&1)
+	}
+	if config.Parity != ParityNone {
+		p := config.ParityBit(octet)
+		if parityError {
+			p ^= 1
+		}
+		bits = append(bits, p)
+	}
+	for n := 0; n < int(config.StopBits+0.5); n++ {
+		bits = append(bits, 1)
+	}
+	return bits
+}
+
+// 復号した文字のバイト列
+func octets(codes []Code) []byte {
+	b := make([]byte, len(codes))
+	for i, c := range codes {
+		b[i] = c.Octet
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		data   []byte
+	}{
+		{"8N1", DefaultConfig, []byte{0x05, 0x30, 0x31, 0xff, 0x00, 0x55, 0xaa}},
+		{"8E1", Config{Baudrate: 9600, DataBits: 8, Parity: ParityEven, StopBits: 1}, []byte{0x01, 0x03, 0x7f, 0x80}},
+		{"7O2", Config{Baudrate: 19200, DataBits: 7, Parity: ParityOdd, StopBits: 2}, []byte("Hello")},
+		{"8N2 250k", Config{Baudrate: 250000, DataBits: 8, Parity: ParityNone, StopBits: 2}, []byte{0x00, 0x10, 0xfe}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bits []uint8
+			for _, b := range tt.data {
+				bits = append(bits, frameBits(tt.config, b, false)...)
+			}
+			_, codes, err := Decode(synthesize(tt.config.Baudrate, bits), tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := octets(codes); !bytes.Equal(got, tt.data) {
+				t.Errorf("got % x, want % x", got, tt.data)
+			}
+			for i, c := range codes {
+				if c.ParityError {
+					t.Errorf("code #%d: unexpected parity error", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeParityError(t *testing.T) {
+	config := Config{Baudrate: 9600, DataBits: 8, Parity: ParityEven, StopBits: 1}
+	bits := append(frameBits(config, 0x41, false), frameBits(config, 0x42, true)...)
+	decoded, codes, err := Decode(synthesize(config.Baudrate, bits), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := octets(codes); !bytes.Equal(got, []byte{0x41, 0x42}) {
+		t.Fatalf("got % x", got)
+	}
+	if codes[0].ParityError || !codes[1].ParityError {
+		t.Errorf("parity errors %v %v, want false true", codes[0].ParityError, codes[1].ParityError)
+	}
+	pe := 0
+	for _, b := range decoded {
+		if b.State == "PE" {
+			pe++
+		}
+	}
+	if pe != 1 {
+		t.Errorf("%d PE bits, want 1", pe)
+	}
+}
+
+func TestDecodeFramingError(t *testing.T) {
+	config := DefaultConfig
+	// ストップビットが0の文字の後に正しい文字
+	broken := frameBits(config, 0x12, false)
+	broken[len(broken)-1] = 0
+	bits := append(append(broken, 1, 1), frameBits(config, 0x34, false)...)
+	decoded, codes, err := Decode(synthesize(config.Baudrate, bits), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	framing := 0
+	for _, b := range decoded {
+		if b.State == "X" {
+			framing++
+		}
+	}
+	if framing == 0 {
+		t.Error("framing error was not detected")
+	}
+	if len(codes) == 0 || codes[len(codes)-1].Octet != 0x34 {
+		t.Errorf("got % x, want the last code 34", octets(codes))
+	}
+}
+
+func TestDecodeIdle(t *testing.T) {
+	_, codes, err := Decode(synthesize(9600, nil), DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 0 {
+		t.Errorf("got % x from an idle bus", octets(codes))
+	}
+}
+
+func TestDecodeIdleSpace(t *testing.T) {
+	config := DefaultConfig
+	config.IdleSpace = true
+	data := []byte{0x05, 0x30, 0xff, 0x00}
+	var bits []uint8
+	for _, b := range data {
+		bits = append(bits, frameBits(config, b, false)...)
+	}
+	// A線とB線を入れ替えてアイドルをSpaceにする
+	samples := mat.DenseCopyOf(synthesize(config.Baudrate, bits))
+	a, b := mat.Col(nil, waveform.ColWireA, samples), mat.Col(nil, waveform.ColWireB, samples)
+	samples.SetCol(waveform.ColWireA, b)
+	samples.SetCol(waveform.ColWireB, a)
+	_, codes, err := Decode(samples, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := octets(codes); !bytes.Equal(got, data) {
+		t.Errorf("got % x, want % x", got, data)
+	}
+}
+
+func TestDecodeBelowThreshold(t *testing.T) {
+	samples := mat.DenseCopyOf(synthesize(9600, frameBits(DefaultConfig, 0x55, false)))
+	// A,B間電圧差をしきい値より小さくする
+	for _, col := range []int{waveform.ColWireA, waveform.ColWireB} {
+		v := mat.Col(nil, col, samples)
+		for i := range v {
+			v[i] *= 0.1
+		}
+		samples.SetCol(col, v)
+	}
+	if _, _, err := Decode(samples, DefaultConfig); !errors.Is(err, waveform.ErrNoRuns) {
+		t.Errorf("got %v, want %v", err, waveform.ErrNoRuns)
+	}
+}
+
+func TestDecodeBadConfig(t *testing.T) {
+	if _, _, err := Decode(synthesize(9600, nil), Config{DataBits: 8}); err == nil {
+		t.Error("baud rate 0 was accepted")
+	}
+	if _, _, err := DecodeReshaped(mat.NewDense(2, 3, []float64{0, 1, -1, 0, 1, -1}), Config{DataBits: 9}); err == nil {
+		t.Error("9 data bits were accepted")
+	}
+}
+
+func TestDecodePermissiveResync(t *testing.T) {
+	config := DefaultConfig
+	data := []byte{0x55, 0x0f, 0xf0, 0x33, 0x41, 0x42, 0x43, 0x44}
+	var bits []uint8
+	for _, b := range data {
+		bits = append(bits, frameBits(config, b, false)...)
+	}
+	// 最初の文字の途中から測り始めた
+	bits = bits[5:]
+
+	permissive := config
+	permissive.Permissive = true
+	_, codes, err := Decode(synthesize(config.Baudrate, bits), permissive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0x0fの文字で同期し直す
+	if got, want := octets(codes), data[1:]; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestDecodePermissiveKeepsFramingError(t *testing.T) {
+	config := DefaultConfig
+	config.Permissive = true
+	// 立ち下がりの無い文字(0x00)のストップビットが0
+	broken := frameBits(config, 0x00, false)
+	broken[len(broken)-1] = 0
+	bits := append(append(broken, 1, 1), frameBits(config, 0x34, false)...)
+	_, codes, err := Decode(synthesize(config.Baudrate, bits), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := octets(codes); !bytes.Equal(got, []byte{0x00, 0x34}) {
+		t.Fatalf("got % x, want 00 34", got)
+	}
+	if !codes[0].FramingError || codes[1].FramingError {
+		t.Errorf("framing errors %v %v, want true false", codes[0].FramingError, codes[1].FramingError)
+	}
+}
+
+func TestDecoderStream(t *testing.T) {
+	config := DefaultConfig
+	config.Permissive = true
+	data := []byte{0x55, 0x0f, 0xf0, 0x33, 0x41, 0x42}
+	var bits []uint8
+	for _, b := range data {
+		bits = append(bits, frameBits(config, b, false)...)
+	}
+	samples := synthesize(config.Baudrate, bits[5:])
+	wantBits, wantCodes, err := Decode(samples, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1行ずつ波形整形して区間を1つずつ復号する
+	diff := waveform.Differential(nil, samples)
+	originTime, _ := waveform.FindStartbitTime(samples, diff)
+	reshaper := waveform.NewReshaper(config.Baudrate, originTime)
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []waveform.Run
+	for r := range diff {
+		runs = reshaper.Push(runs[:0], samples.At(r, waveform.ColTime), diff[r])
+		for _, run := range runs {
+			decoder.Push(run)
+		}
+	}
+	for _, run := range reshaper.Flush(runs[:0]) {
+		decoder.Push(run)
+	}
+	if !reflect.DeepEqual(decoder.Bits(), wantBits) {
+		t.Errorf("got %d bits, want %d bits", len(decoder.Bits()), len(wantBits))
+	}
+	if !reflect.DeepEqual(decoder.Codes(), wantCodes) {
+		t.Errorf("got % x, want % x", octets(decoder.Codes()), octets(wantCodes))
+	}
+}
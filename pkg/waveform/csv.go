@@ -0,0 +1,280 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定値のCSVファイルの読み込み
+package waveform
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 不正な行の扱い
+const (
+	BadRowsSkip  = "skip"  // 警告を出して読み飛ばす
+	BadRowsAbort = "abort" // 解析を中止する
+)
+
+// 読み込み段からチャネルで渡す塊の行数
+const CsvChunkRows = 4096
+
+// ヘッダー行の数
+const (
+	DefaultHeaderLines = 2  // オシロスコープのCSV(列名の行と単位の行)
+	NoHeader           = -1 // ヘッダー行が無い
+)
+
+// CSVファイルの読み込み方
+type LoadOptions struct {
+	BadRows     string // 不正な行の扱い(BadRowsSkip, BadRowsAbort), 空の場合はBadRowsSkip
+	HeaderLines int    // 読み飛ばすヘッダー行の数, 0の場合はDefaultHeaderLines, 無い場合はNoHeader
+	Delimiter   rune   // 区切り文字, 0の場合はカンマ
+	// nilでなければ、読み込んだ塊ごとに新しく加えた行(行優先)を渡す
+	OnRows func(header [][]string, data []float64, cols int) error
+}
+
+// 読み込み段で読んだCSVの1行
+type csvRecord struct {
+	line   int // ファイルの行番号
+	record []string
+	err    error
+}
+
+// 読み込み段
+// CSVファイルの残りの行を塊にして読み進め、チャネルに流す
+// 途中で止める場合はstopを呼ぶ
+func readCsvRecords(reader *csv.Reader, firstLine int) (<-chan []csvRecord, func()) {
+	chunks := make(chan []csvRecord, 2)
+	quit := make(chan struct{})
+	// 読んだ行の欄は塊毎にまとめた領域に写すので、CSVリーダーの行のスライスは使い回す
+	reader.ReuseRecord = true
+	go func() {
+		defer close(chunks)
+		chunk := make([]csvRecord, 0, CsvChunkRows)
+		var fields []string
+		for line := firstLine; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if fields == nil {
+				fields = make([]string, 0, CsvChunkRows*len(record))
+			}
+			start := len(fields)
+			fields = append(fields, record...)
+			chunk = append(chunk, csvRecord{line, fields[start:len(fields):len(fields)], err})
+			if len(chunk) == CsvChunkRows {
+				select {
+				case chunks <- chunk:
+				case <-quit:
+					return
+				}
+				chunk = make([]csvRecord, 0, CsvChunkRows)
+				fields = nil
+			}
+		}
+		if len(chunk) > 0 {
+			select {
+			case chunks <- chunk:
+			case <-quit:
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(quit) })
+		// 読み込み段が終わるのを待つ
+		for range chunks {
+		}
+	}
+	return chunks, stop
+}
+
+// 測定値のCSVファイルを読み込んで、行列と読み飛ばしたヘッダー行(options.HeaderLines行)を返す
+// 列数は最初のデータ行に合わせ、列が足りない行や数値でない値がある行はoptions.BadRowsに従って扱う
+// ctxが終わったら読み込みを止めてctx.Err()を返す
+func LoadCSV(ctx context.Context, filePath string, options LoadOptions) (*mat.Dense, [][]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return loadCSV(ctx, f, options, true)
+}
+
+// LoadCSVと同じく、rから(標準入力など)測定値のCSVを読み込む
+func LoadCSVFrom(ctx context.Context, r io.Reader, options LoadOptions) (*mat.Dense, [][]string, error) {
+	return loadCSV(ctx, r, options, true)
+}
+
+// 測定値のCSVファイルを行列にせずに少しずつ読んで、塊ごとの行をoptions.OnRowsに渡す
+// 渡した行の領域は次の塊で使い回すので、残す場合は写す
+// 読み飛ばしたヘッダー行を返す
+func StreamCSV(ctx context.Context, filePath string, options LoadOptions) ([][]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return StreamCSVFrom(ctx, f, options)
+}
+
+// StreamCSVと同じく、rから(標準入力など)測定値のCSVを少しずつ読む
+func StreamCSVFrom(ctx context.Context, r io.Reader, options LoadOptions) ([][]string, error) {
+	if options.OnRows == nil {
+		return nil, errors.New("行を渡す先が無い")
+	}
+	_, header, err := loadCSV(ctx, r, options, false)
+	return header, err
+}
+
+// keepの場合は読んだ行を全て残して行列にする
+func loadCSV(ctx context.Context, r io.Reader, options LoadOptions, keep bool) (*mat.Dense, [][]string, error) {
+	// CSVリーダーを作成
+	reader := csv.NewReader(r)
+	// 列数が揃っていない行も読み込む
+	reader.FieldsPerRecord = -1
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	}
+	headerLines := options.HeaderLines
+	switch {
+	case headerLines == 0:
+		headerLines = DefaultHeaderLines
+	case headerLines < 0:
+		headerLines = 0
+	}
+
+	// ヘッダー行と名前が書かれた行を読み飛ばす
+	header := [][]string{}
+	var skipLines int
+	for skipLines = 0; skipLines < headerLines; skipLines++ {
+		record, err := reader.Read()
+		header = append(header, record)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// ファイルの大きさ(行数の見積もりに使う, パイプなどで分からない場合は0)
+	var fileSize int64
+	if f, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			fileSize = info.Size()
+		}
+	}
+
+	// データを格納するスライスを作成
+	var data []float64
+	var values []float64 // 1行分の値
+	rows := 0
+	cols := 0
+	badRowCount := 0
+
+	// 不正な行
+	handleBadRow := func(line int, err error) error {
+		if options.BadRows == BadRowsAbort {
+			slog.Error("bad row", "row", line, "err", err)
+			return fmt.Errorf("%d行目: %w", line, err)
+		}
+		slog.Warn("skip row", "row", line, "err", err)
+		badRowCount++
+		return nil
+	}
+
+	// 残りの行を読み込んでスライスに変換
+	// CSVの字句解析は読み込み段で、数値への変換と並行して進める
+	chunks, stop := readCsvRecords(reader, skipLines+1)
+	defer stop()
+	for chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		start := len(data)
+		for _, r := range chunk {
+			line, record, err := r.line, r.record, r.err
+			if err != nil {
+				if err := handleBadRow(line, err); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			if cols == 0 {
+				cols = len(record)
+				// 最初のデータ行の長さからファイル全体の行数を見積もって、スライスを一度に確保する
+				// 残さない場合は1つの塊の分だけ確保する
+				lineLength := int64(len(record))
+				for _, field := range record {
+					lineLength += int64(len(field))
+				}
+				if keep {
+					data = make([]float64, 0, int(fileSize/lineLength+1)*cols)
+				} else {
+					data = make([]float64, 0, CsvChunkRows*cols)
+				}
+				values = make([]float64, cols)
+			}
+			// 余分な列は空の場合(末尾のカンマなど)だけ切り捨てる
+			if len(record) > cols && strings.TrimSpace(strings.Join(record[cols:], "")) == "" {
+				record = record[:cols]
+			}
+			if len(record) != cols {
+				if err := handleBadRow(line, fmt.Errorf("列数が%dではなく%d", cols, len(record))); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			var parseErr error
+			for c, value := range record {
+				if value == "" {
+					slog.Warn("assigned to Zero", "row", line, "column", 1+c)
+					// 空カラムには0を割り当てる
+					values[c] = 0.0
+				} else if values[c], parseErr = strconv.ParseFloat(strings.TrimSpace(value), 64); parseErr != nil {
+					break
+				}
+			}
+			if parseErr != nil {
+				if err := handleBadRow(line, parseErr); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			data = append(data, values...)
+			rows++
+		}
+		if options.OnRows != nil && len(data) > start {
+			if err := options.OnRows(header, data[start:], cols); err != nil {
+				return nil, nil, err
+			}
+		}
+		if !keep {
+			data = data[:0]
+		}
+	}
+
+	if badRowCount > 0 {
+		slog.Warn("skipped rows", "count", badRowCount)
+	}
+	if rows == 0 {
+		return nil, nil, errors.New("データ行がない")
+	}
+
+	if !keep {
+		return nil, header, nil
+	}
+
+	// 行列を作成
+	return mat.NewDense(rows, cols, data), header, nil
+}
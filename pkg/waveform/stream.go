@@ -0,0 +1,187 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定値を少しずつ流して処理する平滑化, 波形整形, 間引き(全体を行列にしない長い測定値向け)
+package waveform
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// 波形整形した1区間(MarkかSpaceの続く区間, 長さは最長で1ビット)
+type Run struct {
+	StartTime float64
+	EndTime   float64
+	Mark      bool
+}
+
+// 少しずつ流す波形整形
+// 1行ずつ加えてReshapeと同じ区間を作る
+type Reshaper struct {
+	period     float64 // 周期T
+	originTime float64 // 基準時間
+	inRun      bool    // 区間の途中
+	run        Run     // 途中の区間
+}
+
+// 波形整形を始める
+// 区間の時間はoriginTimeとの相対時間にする
+func NewReshaper(baudrate int, originTime float64) *Reshaper {
+	return &Reshaper{period: 1 / float64(baudrate), originTime: originTime}
+}
+
+// 1行(時間, A,B間電圧差)を加えて, 終わった区間をdstに加える
+// 区間を終わらせた行は次の区間に含めない(Reshapeと同じ)
+func (s *Reshaper) Push(dst []Run, t float64, d float64) []Run {
+	t -= s.originTime
+	if s.inRun {
+		same := (s.run.Mark && d > Threshold) || (!s.run.Mark && d < -Threshold)
+		if s.run.EndTime-s.run.StartTime < s.period && same {
+			s.run.EndTime = t
+			return dst
+		}
+		s.inRun = false
+		return append(dst, s.run)
+	}
+	if d > Threshold || d < -Threshold {
+		s.inRun = true
+		s.run = Run{StartTime: t, EndTime: t, Mark: d > Threshold}
+	}
+	// 閾値以下はノイズなので追加しない
+	return dst
+}
+
+// 途中の区間を終わらせてdstに加える
+func (s *Reshaper) Flush(dst []Run) []Run {
+	if s.inRun {
+		s.inRun = false
+		dst = append(dst, s.run)
+	}
+	return dst
+}
+
+// 少しずつ流す移動平均フィルタ
+// Smoothと同じく, 窓の大きさの行を読んだ後から窓の次の行の時間で平均を出す
+type Smoother struct {
+	window int
+	cols   int
+	rows   int         // 加えた行数
+	sums   []float64   // 列毎の先頭からの合計
+	ring   [][]float64 // 直近のwindow+1行分の列毎の合計
+}
+
+// 移動平均フィルタを始める
+func NewSmoother(windowSize int, cols int) *Smoother {
+	ring := make([][]float64, windowSize+1)
+	for i := range ring {
+		ring[i] = make([]float64, cols)
+	}
+	return &Smoother{window: windowSize, cols: cols, sums: make([]float64, cols), ring: ring}
+}
+
+// 行(行優先)を加えて, フィルタ後の行をdstに加える
+func (s *Smoother) Push(dst []float64, data []float64) []float64 {
+	scale := 1 / float64(s.window)
+	for r := 0; r+s.cols <= len(data); r += s.cols {
+		row := data[r : r+s.cols]
+		// ring[rows % (window+1)]は先頭からrows行の合計
+		copy(s.ring[s.rows%len(s.ring)], s.sums)
+		if s.rows >= s.window {
+			oldest := s.ring[(s.rows-s.window)%len(s.ring)]
+			dst = append(dst, row[ColTime])
+			for c := ColWireA; c < s.cols; c++ {
+				dst = append(dst, (s.sums[c]-oldest[c])*scale)
+			}
+		}
+		for c := ColWireA; c < s.cols; c++ {
+			s.sums[c] += row[c]
+		}
+		s.rows++
+	}
+	return dst
+}
+
+// 少しずつ流す間引き
+// 一定の行数の区切り毎にA,B間電圧差が最小と最大の2行を時間順に残す
+// 区切りの数がlimitに達したら隣り合う区切りをまとめて, 区切りの行数を倍にする
+type Decimator struct {
+	limit   int
+	cols    int
+	stride  int       // 区切りの行数
+	buckets []float64 // 終わった区切りの2行ずつ(行優先)
+	current []float64 // 途中の区切りの2行
+	scratch []float64 // 途中の区切りの2行と加えた行
+	count   int       // 途中の区切りの行数
+}
+
+// 間引きを始める
+// 隣り合う区切りをまとめるのでlimitは偶数に切り上げる
+func NewDecimator(limit int, cols int) *Decimator {
+	return &Decimator{limit: max(limit+limit%2, 2), cols: cols, stride: 1}
+}
+
+// 区切りの行数
+func (d *Decimator) Stride() int {
+	return d.stride
+}
+
+// 行のうちA,B間電圧差が最小と最大の2行を時間順にdstに加える
+func (d *Decimator) extremes(dst []float64, rows []float64) []float64 {
+	n := len(rows) / d.cols
+	lo, hi := 0, 0
+	for r := 1; r < n; r++ {
+		row := rows[r*d.cols:]
+		if row[ColWireA]-row[ColWireB] < rows[lo*d.cols+ColWireA]-rows[lo*d.cols+ColWireB] {
+			lo = r
+		}
+		if row[ColWireA]-row[ColWireB] > rows[hi*d.cols+ColWireA]-rows[hi*d.cols+ColWireB] {
+			hi = r
+		}
+	}
+	first, second := min(lo, hi), max(lo, hi)
+	dst = append(dst, rows[first*d.cols:(first+1)*d.cols]...)
+	return append(dst, rows[second*d.cols:(second+1)*d.cols]...)
+}
+
+// 行(行優先)を加える
+func (d *Decimator) Push(data []float64) {
+	for r := 0; r+d.cols <= len(data); r += d.cols {
+		row := data[r : r+d.cols]
+		if d.count == 0 {
+			d.current = append(append(d.current[:0], row...), row...)
+		} else {
+			d.scratch = append(append(d.scratch[:0], d.current...), row...)
+			d.current = d.extremes(d.current[:0], d.scratch)
+		}
+		d.count++
+		if d.count < d.stride {
+			continue
+		}
+		d.buckets = append(d.buckets, d.current...)
+		d.count = 0
+		if len(d.buckets)/(2*d.cols) >= d.limit {
+			// 隣り合う区切りの4行から2行を残す
+			size := 2 * d.cols
+			merged := d.buckets[:0]
+			for i := 0; i+2*size <= len(d.buckets); i += 2 * size {
+				pair := append([]float64{}, d.buckets[i:i+2*size]...)
+				merged = d.extremes(merged, pair)
+			}
+			d.buckets = merged
+			d.stride *= 2
+		}
+	}
+}
+
+// 間引いた行列
+// 行が無い場合はnil
+func (d *Decimator) Matrix() *mat.Dense {
+	data := append([]float64{}, d.buckets...)
+	if d.count > 0 {
+		data = append(data, d.current...)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return mat.NewDense(len(data)/d.cols, d.cols, data)
+}
@@ -0,0 +1,136 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// RS485/422バスの測定値(時間, A線電圧, B線電圧の行列)の平滑化と波形整形
+package waveform
+
+import (
+	"errors"
+	"log/slog"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// 行列の列
+const (
+	ColTime   = 0   // 列1番目:時間(s)
+	ColWireA  = 1   // 列2番目:RS485/422バスA線電圧(V)
+	ColWireB  = 2   // 列3番目:RS485/422バスB線電圧(V)
+	Threshold = 1.0 // 差動通信のしきい値(V)
+)
+
+// A,B間電圧差がしきい値を一度も越えない(波形整形する区間が無い)
+var ErrNoRuns = errors.New("A,B間電圧差がしきい値を越える区間が無い")
+
+// 移動平均フィルタを掛ける
+// 時間列以外の全ての列(半二重はA,B線、全二重はTX,RX対のA,B線)に掛ける
+func Smooth(original mat.Matrix, windowSize int) (mat.Matrix, error) {
+	rows, cols := original.Dims()
+
+	if rows < windowSize {
+		return nil, errors.New("データ数が不足している")
+	}
+
+	if cols < 3 || cols > 5 {
+		slog.Warn("期待している列数と違う")
+	}
+
+	matrix := mat.NewDense(rows-windowSize, cols, nil)
+	// 時間は窓の次の行
+	matrix.SetCol(ColTime, mat.Col(nil, ColTime, original)[windowSize:])
+
+	// 累積和の差で窓の合計を求める
+	// sums[k]は先頭からk行の合計
+	column := make([]float64, rows)
+	sums := make([]float64, rows+1)
+	average := make([]float64, rows-windowSize)
+	for c := ColWireA; c < cols; c++ {
+		floats.CumSum(sums[1:], mat.Col(column, c, original))
+		floats.SubTo(average, sums[windowSize:rows], sums[:rows-windowSize])
+		floats.Scale(1/float64(windowSize), average)
+		matrix.SetCol(c, average)
+	}
+	return matrix, nil
+}
+
+// A,B間電圧差
+// 差動伝送なのでA,B間電圧差が正(A線+,B線-)の時にMark、負(A線-,B線+)の時にSpace
+// dstがnilの場合は新しいスライスに、それ以外はdstに書き込む
+func Differential(dst []float64, matrix mat.Matrix) []float64 {
+	rows, _ := matrix.Dims()
+	if dst == nil {
+		dst = make([]float64, rows)
+	}
+	// 密行列は列を取り出さずに連続した行データから直接求める
+	if dense, ok := matrix.(*mat.Dense); ok {
+		raw := dense.RawMatrix()
+		for r := range dst {
+			row := raw.Data[r*raw.Stride : r*raw.Stride+raw.Cols]
+			dst[r] = row[ColWireA] - row[ColWireB]
+		}
+		return dst
+	}
+	mat.Col(dst, ColWireA, matrix)
+	return floats.SubTo(dst, dst, mat.Col(nil, ColWireB, matrix))
+}
+
+// スタートビット開始時間を検出する
+// diffはA,B間電圧差
+func FindStartbitTime(matrix mat.Matrix, diff []float64) (float64, bool) {
+	return FindLevelTime(matrix, diff, false)
+}
+
+// 最初にMark(markがfalseの場合はSpace)になった時間を検出する
+// アイドルがSpaceの配線ではMarkになった時間がスタートビット開始時間になる
+// diffはA,B間電圧差
+func FindLevelTime(matrix mat.Matrix, diff []float64, mark bool) (float64, bool) {
+	for r, d := range diff {
+		if (mark && d > Threshold) || (!mark && d < -Threshold) {
+			return matrix.At(r, ColTime), true
+		}
+	}
+	return 0, false
+}
+
+// 波形整形
+// MarkとSpaceの続く区間を、開始と終了の2行(時間, A線, B線)の組にする. 1組の長さは最長で1ビット
+// 各々の時間はoriginTime(通常はスタートビット開始時間)との相対時間にする
+// diffはA,B間電圧差
+func Reshape(original mat.Matrix, diff []float64, baudrate int, originTime float64) (mat.Matrix, error) {
+	rows, _ := original.Dims()
+
+	// データを格納するスライスを作成
+	// 1回の継続時間は最長で1ビット分なので、測定時間のビット数の2倍(開始と終了)の行を見込んで確保する
+	var data []float64
+	if rows > 0 {
+		bits := int((original.At(rows-1, ColTime)-original.At(0, ColTime))*float64(baudrate)) + 1
+		data = make([]float64, 0, 2*bits*3)
+	}
+
+	reshaper := NewReshaper(baudrate, originTime)
+	var runs []Run
+	for r := 0; r < rows; r++ {
+		runs = reshaper.Push(runs[:0], original.At(r, ColTime), diff[r])
+		data = appendRuns(data, runs)
+	}
+	data = appendRuns(data, reshaper.Flush(runs[:0]))
+	if len(data) == 0 {
+		return nil, ErrNoRuns
+	}
+
+	newMatrix := mat.NewDense(len(data)/3, 3, data)
+	return newMatrix, nil
+}
+
+// 区間を開始と終了の2行(時間, A線, B線)にしてdataに加える
+func appendRuns(data []float64, runs []Run) []float64 {
+	for _, run := range runs {
+		if run.Mark {
+			data = append(data, run.StartTime, 1, -1, run.EndTime, 1, -1)
+		} else {
+			data = append(data, run.StartTime, -1, 1, run.EndTime, -1, 1)
+		}
+	}
+	return data
+}
@@ -0,0 +1,110 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 主局の周期的な要求(ポーリング)の予定からのずれ
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// 表示する抜けた要求の数
+const PollMaxMissedListed = 10
+
+// ポーリングの予定と実際
+type PollSchedule struct {
+	interval float64   // 予定の周期(s)
+	starts   []float64 // 主局の要求の開始時間
+	jitters  []float64 // 予定の時刻からのずれ(s)
+	missed   []float64 // 要求の無かった予定の時刻
+	extra    int       // 同じ予定の時刻に重なった要求の数
+	slots    int       // 最後の要求の予定の時刻が最初から何周期目か
+}
+
+// 主局の要求の開始時間
+// 全二重はTX対のフレーム、半二重は従局の応答を除いたフレーム
+// アドレスの無いフレームがあって主局と従局を分けられない場合は全てのフレームを要求とみなす
+func masterPollTimes(frames []UartFrame, addressByte int) []float64 {
+	starts := []float64{}
+	messages, err := sequenceMessages(frames, addressByte)
+	if err != nil {
+		for _, f := range frames {
+			starts = append(starts, f.startTime)
+		}
+		return starts
+	}
+	for _, m := range messages {
+		if m.from == SequenceMaster || m.from == DirectionTx {
+			starts = append(starts, m.frame.startTime)
+		}
+	}
+	return starts
+}
+
+// 最初の要求を起点にinterval毎の予定の時刻と比べる
+// 各々の要求は最も近い予定の時刻に割り当て、要求の無かった予定の時刻を抜けとする
+func analyzePollSchedule(starts []float64, interval float64) PollSchedule {
+	schedule := PollSchedule{interval: interval, starts: starts}
+	if len(starts) == 0 {
+		return schedule
+	}
+	origin := starts[0]
+	last := -1
+	for _, t := range starts {
+		slot := int(math.Round((t - origin) / interval))
+		if slot == last {
+			schedule.extra++
+			continue
+		}
+		for s := last + 1; s < slot; s++ {
+			schedule.missed = append(schedule.missed, origin+float64(s)*interval)
+		}
+		schedule.jitters = append(schedule.jitters, t-(origin+float64(slot)*interval))
+		last = slot
+	}
+	schedule.slots = last
+	return schedule
+}
+
+// ポーリングの予定からのずれを表示する
+func printPollSchedule(w io.Writer, clock Clock, schedule PollSchedule) {
+	fmt.Fprintf(w, "poll schedule: %d polls  interval %.3fms", len(schedule.starts), schedule.interval*1e3)
+	if schedule.slots > 0 {
+		// 最初と最後の要求の間の平均の周期
+		fmt.Fprintf(w, "  measured %.3fms", (schedule.starts[len(schedule.starts)-1]-schedule.starts[0])/float64(schedule.slots)*1e3)
+	}
+	fmt.Fprintln(w)
+	if len(schedule.jitters) > 1 {
+		mean, std := stat.MeanStdDev(schedule.jitters, nil)
+		lo, hi := schedule.jitters[0], schedule.jitters[0]
+		for _, j := range schedule.jitters {
+			lo, hi = min(lo, j), max(hi, j)
+		}
+		fmt.Fprintf(w, "  jitter: mean %+.3fms  std %.3fms  min %+.3fms  max %+.3fms  peak-to-peak %.3fms\n", mean*1e3, std*1e3, lo*1e3, hi*1e3, (hi-lo)*1e3)
+	}
+	fmt.Fprintf(w, "  missed polls: %d", len(schedule.missed))
+	if schedule.extra > 0 {
+		fmt.Fprintf(w, "  extra polls: %d", schedule.extra)
+	}
+	fmt.Fprintln(w)
+	for i, t := range schedule.missed {
+		if i == PollMaxMissedListed {
+			fmt.Fprintf(w, "    ...(%d more)\n", len(schedule.missed)-i)
+			break
+		}
+		fmt.Fprintf(w, "    missed %s\n", clock.format(t))
+	}
+}
+
+// 抜けた要求
+func missedPollAnomalies(schedule PollSchedule) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, t := range schedule.missed {
+		anomalies = append(anomalies, AnomalyEvent{Time: t, Kind: "missed-poll", Severity: SeverityWarning, Detail: fmt.Sprintf("周期 %.3fms の要求が無い", schedule.interval*1e3)})
+	}
+	return anomalies
+}
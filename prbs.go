@@ -0,0 +1,118 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// PRBS(擬似ランダムビット列)によるビット誤り率試験
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// PRBSの生成多項式のタップ
+// PRBS7: x^7+x^6+1, PRBS15: x^15+x^14+1
+var prbsTaps = map[int][2]int{
+	7:  {7, 6},
+	15: {15, 14},
+}
+
+// ビット誤り
+type PrbsError struct {
+	bitIndex  int     // 受信ビット列の先頭からの位置
+	codeIndex int     // 何番目のコードか
+	bitInCode int     // コードの何ビット目か
+	time      float64 // ビットの開始時間
+}
+
+// ビット誤り率試験の結果
+type PrbsResult struct {
+	order    int  // PRBSの次数
+	locked   bool // パターンに同期できたか
+	lockBit  int  // 同期した受信ビット列の位置
+	compared int  // 比較したビット数
+	errors   []PrbsError
+}
+
+// 受信したコードのデータビット(dataBitsビット)を送信順(LSBファースト)に並べる
+func codesToBits(codes []UartCode, dataBits int) []uint8 {
+	bits := make([]uint8, 0, dataBits*len(codes))
+	for _, c := range codes {
+		for i := 0; i < dataBits; i++ {
+			bits = append(bits, (c.octet>>i)&1)
+		}
+	}
+	return bits
+}
+
+// 受信ビット列をPRBSパターンに同期させて誤りを数える
+// dataBitsは1文字のデータビット数(5-8)
+func runBert(codes []UartCode, order int, baudrate int, dataBits int) PrbsResult {
+	result := PrbsResult{order: order}
+	taps, ok := prbsTaps[order]
+	if !ok {
+		return result
+	}
+	bits := codesToBits(codes, dataBits)
+
+	// 次数の2倍のビットが生成多項式どおりに続いたところで同期したとみなす
+	lockLength := 2 * order
+	for start := 0; start+order+lockLength <= len(bits); start++ {
+		matched := true
+		for i := start + order; i < start+order+lockLength; i++ {
+			if bits[i] != bits[i-taps[0]]^bits[i-taps[1]] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result.locked = true
+			result.lockBit = start
+			break
+		}
+	}
+	if !result.locked {
+		return result
+	}
+
+	// 同期後は受信ビットではなく参照パターンから次のビットを作って比べる
+	reference := append([]uint8{}, bits[result.lockBit:result.lockBit+order]...)
+	for i := result.lockBit + order; i < len(bits); i++ {
+		n := len(reference)
+		expected := reference[n-taps[0]] ^ reference[n-taps[1]]
+		reference = append(reference, expected)
+		result.compared++
+		if bits[i] != expected {
+			code := codes[i/dataBits]
+			result.errors = append(result.errors, PrbsError{
+				bitIndex:  i,
+				codeIndex: i / dataBits,
+				bitInCode: i % dataBits,
+				time:      code.startTime + float64(1+i%dataBits)/float64(baudrate),
+			})
+		}
+	}
+	return result
+}
+
+// ビット誤り率試験の結果を表示する
+func printBert(w io.Writer, clock Clock, label string, result PrbsResult) {
+	if !result.locked {
+		fmt.Fprintf(w, "prbs%d%s: not locked\n", result.order, label)
+		return
+	}
+	ber := 0.0
+	if result.compared > 0 {
+		ber = float64(len(result.errors)) / float64(result.compared)
+	}
+	fmt.Fprintf(w, "prbs%d%s: locked at bit %d  %d bits compared  %d errors  BER %.3e\n",
+		result.order, label, result.lockBit, result.compared, len(result.errors), ber)
+	// 誤りが多すぎる場合は先頭だけ表示する
+	const maxListing = 100
+	for i, e := range result.errors {
+		if i == maxListing {
+			fmt.Fprintf(w, "  ... %d more errors\n", len(result.errors)-maxListing)
+			break
+		}
+		fmt.Fprintf(w, "  bit %d  code #%d bit#%d  %s\n", e.bitIndex, e.codeIndex+1, e.bitInCode, clock.format(e.time))
+	}
+}
@@ -0,0 +1,108 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// オシロスコープの機種固有のヘッダー(プリアンブル)からサンプリング間隔を読んで時間列を確かめる
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// サンプリング間隔が書かれた欄の名前(Rigol/Siglent: Increment, Tektronix: Sample Interval)
+var preambleIntervalKeys = []string{"increment", "sample interval", "sampling interval"}
+
+// 最初のサンプルの時間が書かれた欄の名前(Rigol: Start)
+var preambleStartKeys = []string{"start"}
+
+// ヘッダーに書かれたサンプリング情報
+type Preamble struct {
+	interval    float64 // サンプリング間隔(s)
+	start       float64 // 最初のサンプルの時間(s)
+	intervalKey string  // サンプリング間隔が書かれた欄の名前
+}
+
+// 欄の名前が候補のどれかか
+func isPreambleKey(field string, keys []string) bool {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(field), ":"))
+	for _, key := range keys {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ヘッダーから名前の欄の値を探す
+// 名前の行の下の行の同じ列(Rigol)か、同じ行の右隣(Tektronix等)に値がある
+func findPreambleValue(header [][]string, keys []string) (float64, string, bool) {
+	for r, record := range header {
+		for c, field := range record {
+			if !isPreambleKey(field, keys) {
+				continue
+			}
+			candidates := []string{}
+			if c+1 < len(record) {
+				candidates = append(candidates, record[c+1])
+			}
+			if r+1 < len(header) && c < len(header[r+1]) {
+				candidates = append(candidates, header[r+1][c])
+			}
+			for _, s := range candidates {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+					return v, strings.TrimSpace(field), true
+				}
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// ヘッダーからサンプリング情報を探す
+func findPreamble(header [][]string) (Preamble, bool) {
+	interval, key, ok := findPreambleValue(header, preambleIntervalKeys)
+	if !ok || interval <= 0 {
+		return Preamble{}, false
+	}
+	start, _, _ := findPreambleValue(header, preambleStartKeys)
+	return Preamble{interval: interval, start: start, intervalKey: key}, true
+}
+
+// ヘッダーのサンプリング情報で時間列を確かめる
+// 時間列が無い(全て同じ値)か0始まりの番号の場合は、サンプリング間隔から時間列を作り直す
+// それ以外で時間列の間隔がサンプリング間隔と1%以上違う場合は警告する
+func applyPreamble(w io.Writer, matrix *mat.Dense, preamble Preamble) {
+	rows, _ := matrix.Dims()
+	if rows < 2 {
+		return
+	}
+	constant, indices := true, true
+	for r := 1; r < rows; r++ {
+		t := matrix.At(r, ColTime)
+		constant = constant && t == matrix.At(0, ColTime)
+		indices = indices && t == matrix.At(r-1, ColTime)+1
+	}
+
+	switch {
+	case constant:
+		for r := 0; r < rows; r++ {
+			matrix.Set(r, ColTime, preamble.start+float64(r)*preamble.interval)
+		}
+		fmt.Fprintf(w, "time column: reconstructed from \"%s\" %gs\n", preamble.intervalKey, preamble.interval)
+	case indices && preamble.interval != 1:
+		for r := 0; r < rows; r++ {
+			matrix.Set(r, ColTime, preamble.start+matrix.At(r, ColTime)*preamble.interval)
+		}
+		fmt.Fprintf(w, "time column: sample indices scaled by \"%s\" %gs\n", preamble.intervalKey, preamble.interval)
+	default:
+		if interval := sampleInterval(matrix); math.Abs(interval-preamble.interval) > 0.01*preamble.interval {
+			slog.Warn("sample interval mismatch", "header", preamble.interval, "time column", interval)
+		}
+	}
+}
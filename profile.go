@@ -0,0 +1,82 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// CPUプロファイル, メモリプロファイル, 実行トレースの保存
+package main
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// プロファイルの保存先
+type ProfileOption struct {
+	cpuprofile string // CPUプロファイルを保存するファイル
+	memprofile string // メモリプロファイルを保存するファイル
+	trace      string // 実行トレースを保存するファイル
+	cpuFile    *os.File
+	traceFile  *os.File
+}
+
+// CPUプロファイルと実行トレースを取り始める
+func (p *ProfileOption) start() error {
+	if p.cpuprofile != "" {
+		file, err := os.Create(p.cpuprofile)
+		if err != nil {
+			slog.Error("Create", "err", err)
+			return err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			slog.Error("StartCPUProfile", "err", err)
+			return err
+		}
+		p.cpuFile = file
+	}
+	if p.trace != "" {
+		file, err := os.Create(p.trace)
+		if err != nil {
+			slog.Error("Create", "err", err)
+			return err
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			slog.Error("trace.Start", "err", err)
+			return err
+		}
+		p.traceFile = file
+	}
+	return nil
+}
+
+// CPUプロファイルと実行トレースを止めて、メモリプロファイルを保存する
+func (p *ProfileOption) stop() error {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		p.cpuFile.Close()
+		p.cpuFile = nil
+	}
+	if p.traceFile != nil {
+		trace.Stop()
+		p.traceFile.Close()
+		p.traceFile = nil
+	}
+	if p.memprofile != "" {
+		file, err := os.Create(p.memprofile)
+		if err != nil {
+			slog.Error("Create", "err", err)
+			return err
+		}
+		defer file.Close()
+		// 最新の割り当て状況にする
+		runtime.GC()
+		if err := pprof.Lookup("allocs").WriteTo(file, 0); err != nil {
+			slog.Error("WriteTo", "err", err)
+			return err
+		}
+	}
+	return nil
+}
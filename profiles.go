@@ -0,0 +1,166 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 名前を付けた解析の設定(プロファイル)
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// プロファイル(フラグの名前と値)
+type AnalysisProfile map[string]string
+
+// 組み込みのプロファイル
+var builtinProfiles = map[string]AnalysisProfile{
+	"modbus-9600": {
+		"baudrate":  "9600",
+		"frame":     "8E1",
+		"crc":       CrcModbus,
+		"frame-gap": "3.5",
+	},
+	"modbus-19200": {
+		"baudrate":  "19200",
+		"frame":     "8E1",
+		"crc":       CrcModbus,
+		"frame-gap": "3.5",
+	},
+	"dmx": {
+		"baudrate": "250000",
+		"frame":    "8N2",
+	},
+}
+
+// プロファイルの設定ファイルの場所
+// 空の場合はユーザーの設定ディレクトリのpulseinsight/profiles.json
+func profilesFilePath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userConfigDir, "pulseinsight", "profiles.json"), nil
+}
+
+// 設定ファイルのプロファイルを組み込みのプロファイルに加える
+// 同じ名前のプロファイルは設定ファイルの方を使う
+// 設定ファイルの値は文字列, 数値, 真偽値のどれか
+func loadProfiles(path string) (map[string]AnalysisProfile, error) {
+	profiles := make(map[string]AnalysisProfile, len(builtinProfiles))
+	for name, profile := range builtinProfiles {
+		profiles[name] = profile
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return nil, err
+	}
+	var file map[string]map[string]any
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("設定ファイル \"%s\": %w", path, err)
+	}
+	for name, values := range file {
+		profile := make(AnalysisProfile, len(values))
+		for flag, value := range values {
+			switch v := value.(type) {
+			case string:
+				profile[flag] = v
+			case float64:
+				profile[flag] = strconv.FormatFloat(v, 'g', -1, 64)
+			case bool:
+				profile[flag] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("設定ファイル \"%s\": プロファイル \"%s\" の %s の値 %v には対応していない", path, name, flag, value)
+			}
+		}
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+// プロファイルの名前の一覧
+func profileNames(profiles map[string]AnalysisProfile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// プロファイルの値をコマンドラインで指定しなかったフラグに設定する
+// コマンドラインで指定したフラグはプロファイルより優先する
+func applyProfile(c *cli.Context, name string, profilesFile string) error {
+	path, err := profilesFilePath(profilesFile)
+	if err != nil {
+		slog.Error("profilesFilePath", "err", err)
+		return err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("プロファイル \"%s\" は無い(%s)", name, strings.Join(profileNames(profiles), ", "))
+	}
+	flags := make([]string, 0, len(profile))
+	for flag := range profile {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		if c.IsSet(flag) {
+			continue
+		}
+		if err := c.Set(flag, profile[flag]); err != nil {
+			return fmt.Errorf("プロファイル \"%s\" の --%s=%s: %w", name, flag, profile[flag], err)
+		}
+	}
+	return nil
+}
+
+// プロファイルを設定ファイルに書き込む
+// 設定ファイルの他のプロファイルは残し, 同じ名前のプロファイルは置き換える
+func saveProfile(path string, name string, values map[string]any) error {
+	file := map[string]json.RawMessage{}
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(content, &file); err != nil {
+			return fmt.Errorf("設定ファイル \"%s\": %w", path, err)
+		}
+	}
+	profile, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	file[name] = profile
+	content, err = json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
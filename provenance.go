@@ -0,0 +1,125 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 出力ファイルに埋め込む来歴(ツールのバージョン, コマンドライン, 入力ファイルのハッシュ, 解析設定)
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"pulseinsight/pkg/chart"
+)
+
+// PNGのテキストチャンクのキーワード
+const ProvenancePngKeyword = "pulseinsight:provenance"
+
+// 解析結果の来歴
+type Provenance struct {
+	Tool        string            `json:"tool"`
+	Version     string            `json:"version"`
+	CommandLine []string          `json:"commandLine"`
+	InputFile   string            `json:"inputFile,omitempty"`
+	InputSha256 string            `json:"inputSha256,omitempty"`
+	Parameters  map[string]string `json:"parameters"`
+	CreatedAt   string            `json:"createdAt"`
+}
+
+// 全てのフラグの有効な値
+func effectiveParameters(c *cli.Context) map[string]string {
+	parameters := map[string]string{}
+	for _, f := range c.App.Flags {
+		name := f.Names()[0]
+		parameters[name] = fmt.Sprint(c.Value(name))
+	}
+	return parameters
+}
+
+// コマンドラインから来歴を作る
+func newProvenance(c *cli.Context) *Provenance {
+	return &Provenance{
+		Tool:        c.App.Name,
+		Version:     c.App.Version,
+		CommandLine: os.Args,
+		Parameters:  effectiveParameters(c),
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+}
+
+// ファイルのSHA-256
+func fileSha256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 入力ファイルを加えた来歴
+// 標準入力の場合は読み込んだ後に呼ぶ
+func (p Provenance) withInput(filePath string) (*Provenance, error) {
+	if isStdin(filePath) {
+		p.InputFile = filePath
+		p.InputSha256 = stdinSha256()
+		return &p, nil
+	}
+	sum, err := fileSha256(filePath)
+	if err != nil {
+		return nil, err
+	}
+	p.InputFile = filePath
+	p.InputSha256 = sum
+	return &p, nil
+}
+
+// PNG画像に埋め込む来歴のテキスト
+// 来歴が無い場合はnil
+func (p *Provenance) pngTexts() []chart.PngText {
+	if p == nil {
+		return nil
+	}
+	text, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("Marshal", "err", err)
+		return nil
+	}
+	return []chart.PngText{
+		{Keyword: "Software", Text: p.Tool + " " + p.Version},
+		{Keyword: ProvenancePngKeyword, Text: string(text)},
+	}
+}
+
+// PNG画像ファイルにテキスト(来歴)を書き込む
+// テキストが無い場合は何もしない
+func embedPngFileTexts(savefilepath string, texts []chart.PngText) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	png, err := os.ReadFile(savefilepath)
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return err
+	}
+	if png, err = chart.EmbedPngTexts(png, texts); err != nil {
+		slog.Error("EmbedPngTexts", "err", err)
+		return err
+	}
+	if err := os.WriteFile(savefilepath, png, 0o644); err != nil {
+		slog.Error("WriteFile", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定結果から受信側の設定(ボーレート, パリティ, ストップビット)とバスの終端, バイアスの助言をまとめる
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 設定したボーレートと推定したボーレートの差の許容範囲(比)
+const RecommendBaudTolerance = 0.02
+
+// RS-485の受信しきい値(V), 無通信時のA-B間電圧差はこれ以上にする(フェイルセーフのバイアス)
+const Rs485ReceiverThreshold = 0.2
+
+// RS-485の最小差動出力電圧(V)
+const Rs485MinDifferential = 1.5
+
+// 終端抵抗が無い場合に多い差動電圧(V)
+const UnterminatedDifferential = 4.5
+
+// 推奨する設定の1項目
+type Recommendation struct {
+	name   string // 項目
+	value  string // 推奨値
+	reason string // 根拠にした測定値
+}
+
+// バスの電圧の測定値
+type BusLevels struct {
+	idle      float64 // 無通信時のA-B間電圧差の中央値(V)
+	amplitude float64 // 文字の中のA-B間電圧差の絶対値の中央値(V)
+	idleRows  int     // 無通信とみなした行数
+}
+
+// 中央値
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// 無通信時と文字の中のA-B間電圧差を測る
+// 文字の前後1ビットは遷移を含むので除く
+func measureBusLevels(matrix mat.Matrix, originTime float64, codes []UartCode, baudrate int) BusLevels {
+	period := 1 / float64(baudrate)
+	rows, _ := matrix.Dims()
+	idle, active := []float64{}, []float64{}
+	k := 0
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime) - originTime
+		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
+		for k < len(codes) && codes[k].endTime+period < t {
+			k++
+		}
+		switch {
+		case k < len(codes) && t >= codes[k].startTime && t <= codes[k].endTime:
+			if math.Abs(d) > Threshould {
+				active = append(active, math.Abs(d))
+			}
+		case k == len(codes) || t < codes[k].startTime-period:
+			idle = append(idle, d)
+		}
+	}
+	levels := BusLevels{idle: math.NaN(), amplitude: math.NaN(), idleRows: len(idle)}
+	if len(idle) != 0 {
+		levels.idle = median(idle)
+	}
+	if len(active) != 0 {
+		levels.amplitude = median(active)
+	}
+	return levels
+}
+
+// 推奨する設定を決める
+// formatは復号に使った文字の形式
+// framingErrorsはストップビットが0だった数, parityErrorsはchars文字の中でパリティが合わなかった数, disturbancesはラントとグリッチの数
+func recommendSettings(baudrate int, format UartFormat, estimate *BaudEstimate, stopLengths []float64, framingErrors int, parityErrors int, chars int, levels BusLevels, disturbances int) []Recommendation {
+	recommendations := []Recommendation{}
+
+	// ボーレート
+	baud := Recommendation{name: "baud rate", value: fmt.Sprintf("%d bps", baudrate), reason: "configured"}
+	if estimate != nil {
+		baud.reason = fmt.Sprintf("measured %.1f bps from %d edges", estimate.raw, estimate.edges)
+		if math.Abs(estimate.raw-float64(baudrate)) > RecommendBaudTolerance*float64(baudrate) {
+			baud.value = fmt.Sprintf("%d bps", estimate.baudrate)
+			baud.reason += fmt.Sprintf(", differs from configured %d bps", baudrate)
+		}
+	}
+	recommendations = append(recommendations, baud)
+
+	// データビットとパリティ
+	// 設定より多いビットが送られていると, 余分なビットをストップビットと見て誤る
+	parity := Recommendation{name: "parity", value: fmt.Sprintf("%s (%d data bits)", format.parity, format.dataBits), reason: "no framing errors"}
+	if format.parity != ParityNone {
+		parity.reason = "no parity errors"
+	}
+	switch {
+	case parityErrors > 0 && 2*parityErrors > chars:
+		// 大半が合わないのはパリティの設定違い
+		parity.value = oppositeParity(format.parity)
+		parity.reason = fmt.Sprintf("%d of %d parity errors: sender may use %s parity", parityErrors, chars, oppositeParity(format.parity))
+	case parityErrors > 0:
+		parity.value = "check"
+		parity.reason = fmt.Sprintf("%d of %d parity errors: noise or bit errors on the bus", parityErrors, chars)
+	case framingErrors > 0 && format.parity == ParityNone:
+		parity.value = "check"
+		parity.reason = fmt.Sprintf("%d framing errors: parity or %d data bits may be in use", framingErrors, format.dataBits+1)
+	case framingErrors > 0:
+		parity.value = "check"
+		parity.reason = fmt.Sprintf("%d framing errors: parity may be none or data bits may differ", framingErrors)
+	}
+	recommendations = append(recommendations, parity)
+
+	// ストップビット
+	stop := Recommendation{name: "stop bits", value: "1", reason: "no back-to-back bytes, assumed"}
+	if len(stopLengths) != 0 {
+		stopBits := estimateStopBits(stopLengths)
+		stop.value = fmt.Sprintf("%g", stopBits)
+		stop.reason = fmt.Sprintf("shortest stop+idle %.2f bits in %d byte gaps", slices.Min(stopLengths), len(stopLengths))
+	}
+	recommendations = append(recommendations, stop)
+
+	// バイアス(フェイルセーフ)
+	bias := Recommendation{name: "bias", value: "unknown", reason: "no idle samples"}
+	// アイドルがSpaceの配線では無通信時のA-B間電圧差が負の向きに十分か調べる
+	if levels.idleRows != 0 && format.idleSpace {
+		bias.value = "ok"
+		bias.reason = fmt.Sprintf("idle A-B %+.2fV <= %+.2fV", levels.idle, -Rs485ReceiverThreshold)
+		if levels.idle > -Rs485ReceiverThreshold {
+			bias.value = "add bias resistors"
+			bias.reason = fmt.Sprintf("idle A-B %+.2fV > %+.2fV, receivers may see noise as data", levels.idle, -Rs485ReceiverThreshold)
+		}
+	} else if levels.idleRows != 0 {
+		bias.value = "ok"
+		bias.reason = fmt.Sprintf("idle A-B %+.2fV >= %+.2fV", levels.idle, Rs485ReceiverThreshold)
+		if levels.idle < Rs485ReceiverThreshold {
+			bias.value = "add bias resistors"
+			bias.reason = fmt.Sprintf("idle A-B %+.2fV < %+.2fV, receivers may see noise as data", levels.idle, Rs485ReceiverThreshold)
+		}
+	}
+	recommendations = append(recommendations, bias)
+
+	// 終端
+	termination := Recommendation{name: "termination", value: "unknown", reason: "no bytes"}
+	if !math.IsNaN(levels.amplitude) {
+		termination.value = "ok"
+		termination.reason = fmt.Sprintf("amplitude %.2fV", levels.amplitude)
+		switch {
+		case levels.amplitude > UnterminatedDifferential:
+			termination.value = "add 120Ω at both ends"
+			termination.reason += fmt.Sprintf(" > %.1fV, typical of an unterminated bus", UnterminatedDifferential)
+		case levels.amplitude < Rs485MinDifferential:
+			termination.value = "check"
+			termination.reason += fmt.Sprintf(" < %.1fV, too many terminators or a weak driver", Rs485MinDifferential)
+		}
+		if disturbances > 0 {
+			if termination.value == "ok" {
+				termination.value = "check"
+			}
+			termination.reason += fmt.Sprintf(", %d runts/glitches suggest reflections", disturbances)
+		}
+	}
+	recommendations = append(recommendations, termination)
+	return recommendations
+}
+
+// 通信方向の測定値から推奨する設定を決める
+func recommendFor(matrix mat.Matrix, originTime float64, bits []UartBit, codes []UartCode, frames []UartFrame, direction string, runts []RuntPulse, baudrate int, format UartFormat) []Recommendation {
+	var estimate *BaudEstimate
+	if e, err := estimateBaudrate(matrix, EstimateBaudPulse); err == nil {
+		estimate = &e
+	}
+	framingErrors := 0
+	for _, b := range bits {
+		if b.state == "X" {
+			framingErrors++
+		}
+	}
+	parityErrors := 0
+	for _, c := range codes {
+		if c.parityError {
+			parityErrors++
+		}
+	}
+	disturbances := len(runts) + len(glitchAnomalies(matrix, originTime, baudrate))
+	levels := measureBusLevels(matrix, originTime, codes, baudrate)
+	return recommendSettings(baudrate, format, estimate, measureStopBits(frames, baudrate, direction, format), framingErrors, parityErrors, len(codes), levels, disturbances)
+}
+
+// 推奨する設定を書く
+func printRecommendations(w io.Writer, label string, recommendations []Recommendation) {
+	fmt.Fprintf(w, "recommended settings%s:\n", label)
+	for _, r := range recommendations {
+		fmt.Fprintf(w, "  %-12s %-22s (%s)\n", r.name, r.value, r.reason)
+	}
+}
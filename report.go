@@ -0,0 +1,231 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号したビット, 文字, フレームからの報告(通常の解析と少しずつ流す解析で共通)
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 復号した文字を表示する
+// 全二重の場合は通信方向が変わるごとに区切って表示する
+func printDecodedCodes(w io.Writer, clock Clock, codes []UartCode, duplex bool, option InsightOption) {
+	if duplex {
+		dumpDuplexCodes(w, clock, codes)
+	} else {
+		bytes := []byte{}
+		for _, v := range codes {
+			bytes = append(bytes, v.octet)
+		}
+		if len(bytes) > 0 {
+			stdoutDumper := hex.Dumper(w)
+			binary.Write(stdoutDumper, binary.LittleEndian, bytes)
+			stdoutDumper.Close()
+		}
+	}
+	// パリティが合わない文字
+	if option.format.parity != ParityNone {
+		fmt.Fprintf(w, "parity errors (%s): %d\n", option.format, len(parityAnomalies(codes)))
+	}
+	// 同期し直して残したストップビットが0の文字
+	if option.decodeMode == DecodePermissive {
+		kept := 0
+		for _, v := range codes {
+			if v.framingError {
+				kept++
+			}
+		}
+		fmt.Fprintf(w, "permissive: %d characters kept with framing errors\n", kept)
+	}
+}
+
+// 取り込みの終わりで途切れた文字とフレームを表示する
+// captureEndは基準時間からの相対時間
+func reportTruncations(w io.Writer, clock Clock, txBits []UartBit, rxBits []UartBit, duplex bool, captureEnd float64, baudrate int, option InsightOption) []AnomalyEvent {
+	gap := option.frameGap * option.format.charTime(baudrate)
+	if duplex {
+		txTruncation := findTruncation(txBits, captureEnd, gap)
+		printTruncation(w, clock, " "+DirectionTx, txTruncation, option.format)
+		rxTruncation := findTruncation(rxBits, captureEnd, gap)
+		printTruncation(w, clock, " "+DirectionRx, rxTruncation, option.format)
+		return append(truncationAnomalies(txTruncation), truncationAnomalies(rxTruncation)...)
+	}
+	truncation := findTruncation(txBits, captureEnd, gap)
+	printTruncation(w, clock, "", truncation, option.format)
+	return truncationAnomalies(truncation)
+}
+
+// 復号した結果を機械可読な形式で保存し, --strictの場合は最初の復号の誤りで打ち切る
+// prefixは出力ファイルの基本名(入力ファイルの拡張子付き)
+func saveDecodedAndCheckStrict(w io.Writer, prefix string, clock Clock, bits []UartBit, txBits []UartBit, rxBits []UartBit, codes []UartCode, frames []UartFrame, allFrames []UartFrame, baudrate int, option InsightOption) error {
+	if option.decodeOutput != DecodeOutputNone {
+		outFile := option.outFile
+		if outFile == "" {
+			outFile = prefix + "_decoded." + option.decodeOutput
+		}
+		output := DecodeOutput{
+			Provenance: option.provenance,
+			Baudrate:   baudrate,
+			Format:     option.format.String(),
+			Frames:     decodedFrames(frames, txBits, rxBits, clock, option.crcKind, option.segment),
+		}
+		if err := saveDecodeOutput(outFile, option.decodeOutput, output); err != nil {
+			slog.Error("saveDecodeOutput", "err", err)
+			return err
+		}
+		if outFile != DecodeOutputStdout {
+			fmt.Fprintf(w, "decoded output: %d frames \"%s\"\n", len(frames), outFile)
+		}
+	}
+
+	// 最初の復号の誤りで打ち切る
+	if option.decodeMode == DecodeStrict {
+		if first, found := firstDecodeError(bits, codes, allFrames, option.crcKind); found {
+			fmt.Fprintf(w, "strict: first %s error at %s (%s)\n", first.Kind, clock.format(first.Time), first.Detail)
+			return cli.Exit("復号の誤りで解析を打ち切った(--strict)", 1)
+		}
+	}
+	return nil
+}
+
+// ストップビットの数と文字間の無通信時間を表示する
+func reportStopBits(w io.Writer, allFrames []UartFrame, duplex bool, baudrate int, format UartFormat) {
+	if duplex {
+		printStopBits(w, " "+DirectionTx, measureStopBits(allFrames, baudrate, DirectionTx, format), baudrate, format)
+		printStopBits(w, " "+DirectionRx, measureStopBits(allFrames, baudrate, DirectionRx, format), baudrate, format)
+	} else {
+		printStopBits(w, "", measureStopBits(allFrames, baudrate, "", format), baudrate, format)
+	}
+}
+
+// フレームからの報告とグラフ
+// タイムライン, 統計的に異なるフレーム, 通信量, 使用率, ポーリングの周期, ヒートマップ, 無通信時間, 外部イベントの順に作る
+// captureStart, captureEndは基準時間からの相対時間
+type FrameReportInput struct {
+	prefix       string // 出力ファイルの基本名(入力ファイルの拡張子付き)
+	clock        Clock
+	frames       []UartFrame
+	codes        []UartCode
+	captureStart float64
+	captureEnd   float64
+	originTime   float64
+	duplex       bool
+	events       []ExternalEvent
+	chartOption  chart.Option // グラフの設定(題と縦軸の名前はグラフ毎に変える)
+	charts       ChartSelection
+	baudrate     int
+}
+
+// フレームからの報告とグラフを作って, 通信量の統計と検出した異常を返す
+func reportFrames(ctx context.Context, w io.Writer, plots *PlotStage, report FrameReportInput, option InsightOption) (TrafficSummary, []AnomalyEvent, error) {
+	graphWidth, graphHeight := option.graphWidth, option.graphHeight
+	frames := report.frames
+	clock := report.clock
+	chartOption := report.chartOption
+	anomalies := []AnomalyEvent{}
+
+	// フレームのタイムライン
+	chartOption.Title = "フレームのタイムライン"
+	chartOption.YLabel = "送信元"
+	if report.charts[ChartTimeline] {
+		plots.saveTimelineChart(report.prefix+"_timeline.png", graphWidth, graphHeight, chartOption, frames, option.addressByte)
+	}
+
+	// 統計的に異なるフレーム
+	outliers := findFrameOutliers(frames, option.format.charTime(report.baudrate), option.addressByte)
+	printFrameOutliers(w, clock, frames, outliers)
+	anomalies = append(anomalies, outlierAnomalies(outliers)...)
+
+	if err := checkCanceled(ctx); err != nil {
+		return TrafficSummary{}, nil, err
+	}
+
+	// 通信量の統計
+	traffic := summarizeTraffic(frames, report.captureEnd-report.captureStart, option.addressByte)
+	printTrafficSummary(w, traffic)
+	if messages, err := sequenceMessages(frames, option.addressByte); err != nil {
+		fmt.Fprintf(w, "traffic matrix: skipped (%v)\n", err)
+		if option.trafficFile != "" {
+			slog.Error("sequenceMessages", "err", err)
+			return TrafficSummary{}, nil, err
+		}
+	} else {
+		links := trafficMatrix(messages)
+		printTrafficMatrix(w, links)
+		// 半二重は従局ごとの統計
+		if !report.duplex {
+			printDeviceStats(w, summarizeDevices(messages, option.crcKind))
+		}
+		if option.trafficFile != "" {
+			if err := saveTrafficMatrix(option.trafficFile, links); err != nil {
+				slog.Error("saveTrafficMatrix", "err", err)
+				return TrafficSummary{}, nil, err
+			}
+		}
+	}
+	if option.utilWindow > 0 && report.charts.any() {
+		chartOption.Title = "バス使用率"
+		chartOption.YLabel = "使用率(%)"
+		xys := utilizationOverTime(report.codes, report.captureStart, report.captureEnd, option.utilWindow)
+		plots.saveUtilizationChart(report.prefix+"_utilization.png", graphWidth, graphHeight, chartOption, xys)
+	}
+
+	// 主局の周期的な要求の予定からのずれ
+	if option.pollInterval > 0 {
+		schedule := analyzePollSchedule(masterPollTimes(frames, option.addressByte), option.pollInterval)
+		printPollSchedule(w, clock, schedule)
+		anomalies = append(anomalies, missedPollAnomalies(schedule)...)
+	}
+
+	// バイト値のヒートマップ
+	chartOption.Title = "バイト値のヒートマップ"
+	chartOption.YLabel = "バイト値"
+	heatmap := countByteValues(frames, report.captureStart, report.captureEnd, graphWidth/HeatmapBinWidth)
+	if report.charts[ChartHeatmap] {
+		plots.saveByteHeatmap(report.prefix+"_heatmap.png", graphWidth, graphHeight, chartOption, heatmap)
+	}
+
+	// 無通信時間のヒストグラム
+	histogramOption := chart.Option{
+		Title:    "バイト間の無通信時間",
+		XLabel:   "時間(ms)",
+		YLabel:   "度数",
+		PngTexts: option.provenance.pngTexts(),
+	}
+	if report.charts[ChartByteGap] {
+		plots.saveGapHistogram(report.prefix+"_bytegap.png", 2*graphHeight, graphHeight, histogramOption, interByteGaps(frames))
+	}
+	histogramOption.Title = "フレーム間の無通信時間"
+	if report.charts[ChartFrameGap] {
+		plots.saveGapHistogram(report.prefix+"_framegap.png", 2*graphHeight, graphHeight, histogramOption, interFrameGaps(frames))
+	}
+
+	// 外部イベントとフレームの対応
+	if len(report.events) != 0 {
+		printTimeline(w, clock, frames, shiftEvents(report.events, report.originTime))
+	}
+	return traffic, anomalies, nil
+}
+
+// ビット誤り率試験の結果を表示する
+func reportBert(w io.Writer, clock Clock, codes []UartCode, duplex bool, baudrate int, option InsightOption) {
+	if option.prbsOrder == 0 {
+		return
+	}
+	if duplex {
+		printBert(w, clock, " "+DirectionTx, runBert(codesOfDirection(codes, DirectionTx), option.prbsOrder, baudrate, option.format.dataBits))
+		printBert(w, clock, " "+DirectionRx, runBert(codesOfDirection(codes, DirectionRx), option.prbsOrder, baudrate, option.format.dataBits))
+	} else {
+		printBert(w, clock, "", runBert(codes, option.prbsOrder, baudrate, option.format.dataBits))
+	}
+}
@@ -0,0 +1,157 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 送信の途中で始まった取り込み(十分な無通信まで読み飛ばして同期し, 読み飛ばした長さを報告する)
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 取り込みの始めの読み飛ばし方
+const (
+	ResyncAuto = "auto" // 取り込みがSpaceで始まった場合に読み飛ばす
+	ResyncIdle = "idle" // 取り込みの始めの無通信が短い場合も読み飛ばす
+	ResyncOff  = "off"  // 読み飛ばさない
+)
+
+// 取り込みの始めの読み飛ばし
+type Resync struct {
+	skipped      bool    // 読み飛ばした
+	found        bool    // 読み飛ばした後に十分な無通信が見つかった
+	captureStart float64 // 取り込みの始め(入力CSVの時間)
+	resumeTime   float64 // 復号を始めた時間(入力CSVの時間)
+	edges        int     // 読み飛ばした立ち下がりの数
+	idleTime     float64 // 同期に使う無通信の長さ(s)
+}
+
+// 取り込みの始めを1行ずつ調べて, 送信の途中なら十分な無通信まで読み飛ばす
+// 文字の中でMark(とノイズ)が続くのは1文字の長さより短いので, 1文字以上の無通信の後の立ち下がりはスタートビットになる
+type Resyncer struct {
+	mode     string
+	sign     float64 // アイドルの向きの符号
+	result   Resync
+	started  bool    // 最初の行を読んだ
+	sawMark  bool    // 最初のSpaceの前にMarkがあった
+	waiting  bool    // 無通信を待っている
+	decoding bool    // 復号している
+	inIdle   bool    // 無通信の途中
+	idleFrom float64 // 無通信の始まり
+}
+
+// 取り込みの始めを調べ始める
+// 同期に使う無通信はoption.resyncIdleビット, 0の場合は1文字の長さ
+func newResyncer(option InsightOption) *Resyncer {
+	idleTime := option.format.charTime(option.baudrate)
+	if option.resyncIdle > 0 {
+		idleTime = option.resyncIdle / float64(option.baudrate)
+	}
+	return &Resyncer{mode: option.resync, sign: option.format.idleSign(), result: Resync{idleTime: idleTime}, decoding: option.resync == ResyncOff}
+}
+
+// 1行(時間, A,B間電圧差)を加えて, 復号に使う行ならtrue
+// 読み飛ばす行はノイズ(A,B間電圧差0)として扱う
+// アイドルがSpaceの配線ではMarkとSpaceを入れ替えて調べる
+func (s *Resyncer) push(t float64, d float64) bool {
+	d *= s.sign
+	if !s.started {
+		s.started = true
+		s.result.captureStart = t
+	}
+	if s.decoding {
+		return true
+	}
+	space := d < -waveform.Threshold
+	if !s.waiting {
+		// 最初のSpaceまでは, 読み飛ばすか決められないがMarkとノイズなので復号に使う
+		if !space {
+			s.sawMark = s.sawMark || d > waveform.Threshold
+			return true
+		}
+		switch {
+		case s.mode == ResyncAuto && s.sawMark,
+			s.mode == ResyncIdle && t-s.result.captureStart >= s.result.idleTime:
+			s.decoding = true
+			return true
+		}
+		s.waiting = true
+		s.result.skipped = true
+	}
+	if space {
+		if s.inIdle || s.result.edges == 0 {
+			s.result.edges++
+		}
+		s.inIdle = false
+		return false
+	}
+	if !s.inIdle {
+		s.inIdle, s.idleFrom = true, t
+	}
+	if t-s.idleFrom < s.result.idleTime {
+		return false
+	}
+	s.decoding = true
+	s.result.found = true
+	s.result.resumeTime = t
+	return true
+}
+
+// 調べた結果
+func (s *Resyncer) resync() Resync {
+	return s.result
+}
+
+// 取り込みの始めの読み飛ばす行のA,B間電圧差を0にする
+func resyncDiff(matrix mat.Matrix, diff []float64, option InsightOption) Resync {
+	resyncer := newResyncer(option)
+	for r, d := range diff {
+		if !resyncer.push(matrix.At(r, ColTime), d) {
+			diff[r] = 0
+		}
+	}
+	return resyncer.resync()
+}
+
+// 復号に使う測定値の取り込みの始めの読み飛ばしを調べる(全二重でなければrxはnil)
+func findResyncs(matrix *mat.Dense, rxMatrix *mat.Dense, filtered mat.Matrix, rxFiltered mat.Matrix, option InsightOption) (Resync, *Resync) {
+	decodeSource, rxDecodeSource := decodeSources(matrix, rxMatrix, filtered, rxFiltered, option)
+	diff, _ := decisionDiff(decodeSource, option)
+	tx := resyncDiff(decodeSource, diff, option)
+	if rxDecodeSource == nil {
+		return tx, nil
+	}
+	rxDiff, _ := decisionDiff(rxDecodeSource, option)
+	rx := resyncDiff(rxDecodeSource, rxDiff, option)
+	return tx, &rx
+}
+
+// 読み飛ばした長さを表示する
+func printResync(w io.Writer, clock Clock, label string, resync Resync) {
+	switch {
+	case !resync.skipped:
+		fmt.Fprintf(w, "resync%s: none\n", label)
+	case resync.found:
+		fmt.Fprintf(w, "resync%s: capture starts within a transmission, skipped %.3fms (%d falling edges) until %.3fms idle at %s\n",
+			label, (resync.resumeTime-resync.captureStart)*1e3, resync.edges, resync.idleTime*1e3, clock.format(resync.resumeTime-clock.originTime))
+	default:
+		fmt.Fprintf(w, "resync%s: capture starts within a transmission and never idles for %.3fms, nothing decoded (%d falling edges skipped)\n",
+			label, resync.idleTime*1e3, resync.edges)
+	}
+}
+
+// 読み飛ばした取り込みの始め
+func resyncAnomalies(resync Resync, originTime float64) []AnomalyEvent {
+	if !resync.skipped {
+		return []AnomalyEvent{}
+	}
+	detail := fmt.Sprintf("取り込みが送信の途中で始まったので%.3fms読み飛ばした(立ち下がり%d回)", (resync.resumeTime-resync.captureStart)*1e3, resync.edges)
+	if !resync.found {
+		detail = fmt.Sprintf("取り込みが送信の途中で始まり, 無通信が%.3fms続かないので復号しなかった", resync.idleTime*1e3)
+	}
+	return []AnomalyEvent{{Time: resync.captureStart - originTime, Kind: "resync", Severity: SeverityWarning, Detail: detail}}
+}
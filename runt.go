@@ -0,0 +1,114 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 片方のしきい値だけを越えて戻るパルス(ラント)の検出と分類(バスの衝突や弱いドライバの手掛かり)
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ラントの分類
+const (
+	RuntShort = "short" // 半ビットより短い(バスの衝突や反射)
+	RuntWeak  = "weak"  // 半ビット以上続く(ドライバの駆動が弱い)
+)
+
+// ラント
+// MarkかSpaceからしきい値の間に入り, 0Vを越えたが反対のしきい値に届かずに元に戻ったパルス
+type RuntPulse struct {
+	startTime float64 // しきい値の間に入った時間(入力CSVの時間)
+	endTime   float64 // 元のしきい値を越えて戻った時間(入力CSVの時間)
+	fromMark  bool    // Markからのパルス(falseはSpaceから)
+	peak      float64 // A-B間電圧差の最も反対側に振れた値(V)
+	class     string  // 分類(RuntShort, RuntWeak)
+}
+
+// ラントを探す
+func detectRunts(matrix mat.Matrix, baudrate int) []RuntPulse {
+	runts := []RuntPulse{}
+	rows, _ := matrix.Dims()
+	halfBit := 0.5 / float64(baudrate)
+	// 直前のしきい値を越えた向き(1:Mark, -1:Space)
+	level := 0
+	inside := false // しきい値の間に入っている
+	var runt RuntPulse
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime)
+		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
+		current := 0
+		if d > Threshould {
+			current = 1
+		} else if d < -Threshould {
+			current = -1
+		}
+		switch {
+		case current == 0 && level != 0:
+			if !inside {
+				inside = true
+				runt = RuntPulse{startTime: t, fromMark: level == 1, peak: d}
+			}
+			if level == 1 {
+				runt.peak = min(runt.peak, d)
+			} else {
+				runt.peak = max(runt.peak, d)
+			}
+		case current != 0:
+			// 同じ向きに戻り, 0Vを越えていたらラント
+			if inside && current == level && runt.peak*float64(level) < 0 {
+				runt.endTime = t
+				runt.class = RuntShort
+				if runt.endTime-runt.startTime >= halfBit {
+					runt.class = RuntWeak
+				}
+				runts = append(runts, runt)
+			}
+			inside = false
+			level = current
+		}
+	}
+	return runts
+}
+
+// グラフに印を付けるラントの真ん中の時間(基準時間からの相対時間)
+func runtTimes(runts []RuntPulse, originTime float64) []float64 {
+	times := make([]float64, len(runts))
+	for i, r := range runts {
+		times[i] = (r.startTime+r.endTime)/2 - originTime
+	}
+	return times
+}
+
+// ラントの説明
+func (r RuntPulse) String() string {
+	from := "space"
+	if r.fromMark {
+		from = "mark"
+	}
+	return fmt.Sprintf("from %s  peak %+.3fV  %.3fus  %s", from, r.peak, (r.endTime-r.startTime)*1e6, r.class)
+}
+
+// ラントを書く
+func printRunts(w io.Writer, clock Clock, label string, runts []RuntPulse) {
+	fmt.Fprintf(w, "runt pulses%s: %d\n", label, len(runts))
+	for _, r := range runts {
+		fmt.Fprintf(w, "  %s  %s\n", clock.format(r.startTime-clock.originTime), r)
+	}
+}
+
+// ラント
+func runtAnomalies(runts []RuntPulse, originTime float64) []AnomalyEvent {
+	anomalies := []AnomalyEvent{}
+	for _, r := range runts {
+		anomalies = append(anomalies, AnomalyEvent{
+			Time:     r.startTime - originTime,
+			Kind:     "runt",
+			Severity: SeverityWarning,
+			Detail:   r.String(),
+		})
+	}
+	return anomalies
+}
@@ -0,0 +1,296 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// LXI/SCPI(TCP)でつないだオシロスコープから波形を繰り返し取り込んで復号する(簡易プロトコルアナライザ)
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SCPIの既定のポート(LXIのソケット接続)
+const ScpiPort = "5025"
+
+// オシロスコープからの取り込みの設定
+type LiveOption struct {
+	address  string        // ホスト名[:ポート]
+	channels []string      // A線とB線を測るチャンネル(:WAV:SOURに渡す名前)
+	records  int           // 取り込む回数(0の場合はCtrl-Cまで)
+	interval time.Duration // 取り込みの間隔
+	timeout  time.Duration // 1回の問い合わせの待ち時間
+}
+
+// "1,2"や"CHAN1,CHAN2"をチャンネル名にする
+// 数字だけの場合はCHANを付ける
+func parseLiveChannels(s string) ([]string, error) {
+	channels := []string{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if _, err := strconv.Atoi(field); err == nil {
+			field = "CHAN" + field
+		}
+		if field == "" {
+			return nil, fmt.Errorf("チャンネル \"%s\" を読めない", s)
+		}
+		channels = append(channels, field)
+	}
+	if len(channels) != 2 {
+		return nil, fmt.Errorf("チャンネルはA線とB線の2つを指定する(\"%s\")", s)
+	}
+	return channels, nil
+}
+
+// SCPIの接続
+type ScpiConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// オシロスコープにつなぐ
+// ポートを省いた場合はScpiPort
+func dialScpi(ctx context.Context, address string, timeout time.Duration) (*ScpiConn, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, ScpiPort)
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &ScpiConn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// 接続を閉じる
+func (s *ScpiConn) Close() error {
+	return s.conn.Close()
+}
+
+// コマンドを送る
+func (s *ScpiConn) command(cmd string) error {
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := io.WriteString(s.conn, cmd+"\n"); err != nil {
+		return fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+	}
+	return nil
+}
+
+// 問い合わせて1行の応答を読む
+func (s *ScpiConn) query(cmd string) (string, error) {
+	if err := s.command(cmd); err != nil {
+		return "", err
+	}
+	text, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+	}
+	return strings.TrimRight(text, "\r\n"), nil
+}
+
+// 問い合わせてIEEE 488.2のブロック(#NLLL...データ, #0は改行まで)の応答を読む
+// ブロックでない応答は1行として読む
+func (s *ScpiConn) queryBlock(cmd string) ([]byte, error) {
+	if err := s.command(cmd); err != nil {
+		return nil, err
+	}
+	first, err := s.reader.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+	}
+	if first[0] != '#' {
+		text, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+		}
+		return []byte(strings.TrimRight(text, "\r\n")), nil
+	}
+	s.reader.Discard(2)
+	digits := int(first[1] - '0')
+	if digits < 0 || digits > 9 {
+		return nil, fmt.Errorf("SCPI \"%s\": ブロックの長さを読めない", cmd)
+	}
+	if digits == 0 {
+		text, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+		}
+		return []byte(strings.TrimRight(text, "\r\n")), nil
+	}
+	header := make([]byte, digits)
+	if _, err := io.ReadFull(s.reader, header); err != nil {
+		return nil, fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+	}
+	length, err := strconv.Atoi(string(header))
+	if err != nil {
+		return nil, fmt.Errorf("SCPI \"%s\": ブロックの長さ \"%s\" を読めない", cmd, header)
+	}
+	block := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, block); err != nil {
+		return nil, fmt.Errorf("SCPI \"%s\": %w", cmd, err)
+	}
+	// ブロックの後の改行
+	if b, err := s.reader.Peek(1); err == nil && b[0] == '\n' {
+		s.reader.Discard(1)
+	}
+	return block, nil
+}
+
+// 波形の時間軸(:WAV:PRE?の応答の5から7番目)
+type ScpiPreamble struct {
+	xIncrement float64 // 点の間隔(s)
+	xOrigin    float64 // 基準点の時間(s)
+	xReference float64 // 基準点
+}
+
+// :WAV:PRE?の応答を読む
+// format,type,points,count,xincrement,xorigin,xreference,yincrement,yorigin,yreference
+func parseScpiPreamble(text string) (ScpiPreamble, error) {
+	fields := strings.Split(text, ",")
+	if len(fields) < 7 {
+		return ScpiPreamble{}, fmt.Errorf("波形の設定 \"%s\" を読めない", text)
+	}
+	values := make([]float64, 3)
+	for i := range values {
+		v, err := strconv.ParseFloat(strings.TrimSpace(fields[4+i]), 64)
+		if err != nil {
+			return ScpiPreamble{}, fmt.Errorf("波形の設定 \"%s\" を読めない", text)
+		}
+		values[i] = v
+	}
+	if values[0] <= 0 {
+		return ScpiPreamble{}, fmt.Errorf("波形の点の間隔が%gs", values[0])
+	}
+	return ScpiPreamble{xIncrement: values[0], xOrigin: values[1], xReference: values[2]}, nil
+}
+
+// i番目の点の時間
+func (p ScpiPreamble) time(i int) float64 {
+	return (float64(i)-p.xReference)*p.xIncrement + p.xOrigin
+}
+
+// ASCII形式の波形(カンマ区切りの電圧)を読む
+func parseScpiAscii(block []byte) ([]float64, error) {
+	values := []float64{}
+	for _, field := range strings.Split(string(block), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("波形の値 \"%s\" を読めない", field)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// 1つのチャンネルの波形を読む
+func (s *ScpiConn) readWaveform(channel string) (ScpiPreamble, []float64, error) {
+	for _, cmd := range []string{":WAV:SOUR " + channel, ":WAV:FORM ASC"} {
+		if err := s.command(cmd); err != nil {
+			return ScpiPreamble{}, nil, err
+		}
+	}
+	text, err := s.query(":WAV:PRE?")
+	if err != nil {
+		return ScpiPreamble{}, nil, err
+	}
+	preamble, err := parseScpiPreamble(text)
+	if err != nil {
+		return ScpiPreamble{}, nil, err
+	}
+	block, err := s.queryBlock(":WAV:DATA?")
+	if err != nil {
+		return ScpiPreamble{}, nil, err
+	}
+	values, err := parseScpiAscii(block)
+	if err != nil {
+		return ScpiPreamble{}, nil, err
+	}
+	return preamble, values, nil
+}
+
+// 取り込みを止めてA線とB線の波形を読み, 取り込みを再開する
+// 行(時間, A線電圧, B線電圧)を行優先で返す
+func (s *ScpiConn) readRecord(channels []string) ([]float64, error) {
+	if err := s.command(":STOP"); err != nil {
+		return nil, err
+	}
+	preamble, a, err := s.readWaveform(channels[0])
+	if err != nil {
+		return nil, err
+	}
+	_, b, err := s.readWaveform(channels[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.command(":RUN"); err != nil {
+		return nil, err
+	}
+	points := min(len(a), len(b))
+	data := make([]float64, 0, 3*points)
+	for i := 0; i < points; i++ {
+		data = append(data, preamble.time(i), a[i], b[i])
+	}
+	return data, nil
+}
+
+// オシロスコープから波形を繰り返し取り込んで復号し, フレームを見つけ次第表示する
+// 取り込みの間の通信は見えない
+// ctxが終わったら(Ctrl-C)終わる
+func runLive(ctx context.Context, w io.Writer, live LiveOption, option InsightOption) error {
+	scpi, err := dialScpi(ctx, live.address, live.timeout)
+	if err != nil {
+		slog.Error("dialScpi", "err", err)
+		return err
+	}
+	defer scpi.Close()
+
+	idn, err := scpi.query("*IDN?")
+	if err != nil {
+		slog.Error("query", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "live \"%s\" %s (Ctrl-Cで終わる)\n", live.address, idn)
+	for record := 1; live.records == 0 || record <= live.records; record++ {
+		if ctx.Err() != nil {
+			break
+		}
+		// 取り込みを止めた時刻を時間0とみなす(おおよその時刻)
+		clock := Clock{t0: time.Now(), absolute: true}
+		data, err := scpi.readRecord(live.channels)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Error("readRecord", "err", err)
+			return err
+		}
+		buffer := FollowBuffer{header: [][]string{{"Time", live.channels[0], live.channels[1]}, {"s", "V", "V"}}, data: data, cols: 3}
+		points := buffer.rows()
+		span := 0.0
+		if points >= 2 {
+			span = data[len(data)-3] - data[0]
+		}
+		fmt.Fprintf(w, "record %d: %d points %.3fms\n", record, points, span*1e3)
+		if err := buffer.decode(w, clock, option, true); err != nil {
+			slog.Error("decode", "err", err)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(live.interval):
+		}
+	}
+	fmt.Fprintln(w, "live done")
+	return nil
+}
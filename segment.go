@@ -0,0 +1,153 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 複数の取り込み(セグメント)を含むCSVファイルをセグメント毎に分けて解析する
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 1つのセグメント
+type InputSegment struct {
+	index  int        // 1始まりのセグメント番号
+	count  int        // CSVファイルのセグメントの数
+	matrix *mat.Dense // 補正済みの解析する行列
+	header [][]string
+}
+
+// 時間が前の行より戻った所で区切った行の範囲([開始, 終了))
+func splitByTimeReset(matrix mat.Matrix) [][2]int {
+	rows, _ := matrix.Dims()
+	ranges := [][2]int{}
+	start := 0
+	for r := 1; r < rows; r++ {
+		if matrix.At(r, ColTime) < matrix.At(r-1, ColTime) {
+			ranges = append(ranges, [2]int{start, r})
+			start = r
+		}
+	}
+	if start < rows {
+		ranges = append(ranges, [2]int{start, rows})
+	}
+	return ranges
+}
+
+// 列colの値が前の行と変わった所で区切った行の範囲([開始, 終了))
+func splitByColumn(matrix mat.Matrix, col int) [][2]int {
+	rows, _ := matrix.Dims()
+	ranges := [][2]int{}
+	start := 0
+	for r := 1; r < rows; r++ {
+		if matrix.At(r, col) != matrix.At(r-1, col) {
+			ranges = append(ranges, [2]int{start, r})
+			start = r
+		}
+	}
+	if start < rows {
+		ranges = append(ranges, [2]int{start, rows})
+	}
+	return ranges
+}
+
+// 列colを除いた行列とヘッダー行
+func removeColumn(matrix mat.Matrix, header [][]string, col int) (*mat.Dense, [][]string) {
+	rows, cols := matrix.Dims()
+	removed := mat.NewDense(rows, cols-1, nil)
+	for c, to := 0, 0; c < cols; c++ {
+		if c == col {
+			continue
+		}
+		removed.SetCol(to, mat.Col(nil, c, matrix))
+		to++
+	}
+	removedHeader := make([][]string, len(header))
+	for i, record := range header {
+		for c, field := range record {
+			if c != col {
+				removedHeader[i] = append(removedHeader[i], field)
+			}
+		}
+	}
+	return removed, removedHeader
+}
+
+// CSVファイルを読み込んでセグメントに分け, セグメント毎に補正する
+// option.segmentColを指定した場合はその列の値の変わり目, 指定しない場合は時間が戻った所で区切る
+func prepareSegments(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) ([]InputSegment, error) {
+	matrix, header, err := loadInputMatrix(ctx, w, csvfilepath, option)
+	if err != nil {
+		slog.Error("loadInputMatrix", "err", err)
+		return nil, err
+	}
+
+	var ranges [][2]int
+	if option.segmentCol != "" {
+		col, ok := findColumn(header, option.segmentCol)
+		if !ok {
+			return nil, fmt.Errorf("セグメントの列名 \"%s\" がヘッダー行にない", option.segmentCol)
+		}
+		if col <= ColWireB {
+			return nil, fmt.Errorf("セグメントの列 \"%s\" は時間, A線, B線の列と重なっている", option.segmentCol)
+		}
+		ranges = splitByColumn(matrix, col)
+		matrix, header = removeColumn(matrix, header, col)
+	} else {
+		ranges = splitByTimeReset(matrix)
+	}
+
+	segments := []InputSegment{}
+	for i, rg := range ranges {
+		if rg[1]-rg[0] < 2 {
+			fmt.Fprintf(w, "segment #%d: %d rows, skipped\n", i+1, rg[1]-rg[0])
+			continue
+		}
+		_, cols := matrix.Dims()
+		part := mat.DenseCopyOf(matrix.Slice(rg[0], rg[1], 0, cols))
+		if part, err = conditionInputMatrix(part, option); err != nil {
+			slog.Error("conditionInputMatrix", "err", err)
+			return nil, err
+		}
+		segments = append(segments, InputSegment{index: i + 1, count: len(ranges), matrix: part, header: header})
+	}
+	return segments, nil
+}
+
+// セグメント毎の出力ファイルの名前
+// 拡張子の前にセグメント番号を付ける
+func segmentFileName(name string, index int) string {
+	if name == "" || name == DecodeOutputStdout {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_seg%d%s", strings.TrimSuffix(name, ext), index, ext)
+}
+
+// CSVファイルをセグメントに分けて, セグメント毎に解析する
+func insightSegmentedCsvFile(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption) error {
+	if len(option.stitchFiles) != 0 || option.liveFrames {
+		return fmt.Errorf("セグメントに分ける解析は--stitchと--live-framesには対応していない")
+	}
+	segments, err := prepareSegments(ctx, w, csvfilepath, option)
+	if err != nil {
+		slog.Error("prepareSegments", "err", err)
+		return err
+	}
+	fmt.Fprintf(w, "input file \"%s\": %d segments\n", csvfilepath, len(segments))
+	for _, segment := range segments {
+		segmented := option
+		segmented.segment = &segment
+		if err := insightTheCsvFile(ctx, w, csvfilepath, segmented); err != nil {
+			slog.Error("insightTheCsvFile", "segment", segment.index, "err", err)
+			return err
+		}
+	}
+	return nil
+}
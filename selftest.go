@@ -0,0 +1,119 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 組み込みの測定例を解析して、解析結果を正解ファイルと比べる自己診断
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 自己診断に使う測定例(NAME.csv)と正解ファイル(NAME.golden)
+//
+//go:embed selftest/*.csv selftest/*.golden
+var selftestFiles embed.FS
+
+// 自己診断の測定例を置いたディレクトリ
+const SelftestDir = "selftest"
+
+// 測定例を解析した結果
+// 一時ディレクトリのパスは取り除く
+func selftestOutput(ctx context.Context, name string, option InsightOption) ([]byte, error) {
+	data, err := selftestFiles.ReadFile(path.Join(SelftestDir, name+".csv"))
+	if err != nil {
+		slog.Error("ReadFile", "err", err)
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "pulseinsight-selftest")
+	if err != nil {
+		slog.Error("MkdirTemp", "err", err)
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	csvfilepath := filepath.Join(dir, name+".csv")
+	if err := os.WriteFile(csvfilepath, data, 0o644); err != nil {
+		slog.Error("WriteFile", "err", err)
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := insightTheCsvFile(ctx, &buf, csvfilepath, option); err != nil {
+		slog.Error("insightTheCsvFile", "err", err)
+		return nil, err
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte(dir+string(filepath.Separator)), nil), nil
+}
+
+// 最初に異なる行
+func firstDifference(got []byte, want []byte) (int, string, string) {
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < max(len(gotLines), len(wantLines)); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return i + 1, g, w
+		}
+	}
+	return 0, "", ""
+}
+
+// 組み込みの測定例を全て解析して正解ファイルと比べる
+// updateDirを指定した場合は比べずに解析結果を正解ファイルとしてそこに保存する
+func runSelftest(ctx context.Context, w io.Writer, option InsightOption, updateDir string) error {
+	// グラフの大きさは解析結果に関係しないので小さくして速くする
+	option.graphWidth, option.graphHeight = 640, 300
+
+	csvfiles, err := fs.Glob(selftestFiles, path.Join(SelftestDir, "*.csv"))
+	if err != nil {
+		slog.Error("Glob", "err", err)
+		return err
+	}
+	failed := 0
+	for _, csvfile := range csvfiles {
+		name := strings.TrimSuffix(path.Base(csvfile), ".csv")
+		got, err := selftestOutput(ctx, name, option)
+		if err != nil {
+			return err
+		}
+		if updateDir != "" {
+			goldenfile := filepath.Join(updateDir, name+".golden")
+			if err := os.WriteFile(goldenfile, got, 0o644); err != nil {
+				slog.Error("WriteFile", "err", err)
+				return err
+			}
+			fmt.Fprintf(w, "update %s\n", goldenfile)
+			continue
+		}
+		want, err := selftestFiles.ReadFile(path.Join(SelftestDir, name+".golden"))
+		if err != nil {
+			slog.Error("ReadFile", "err", err)
+			return err
+		}
+		if line, g, x := firstDifference(got, want); line != 0 {
+			failed++
+			fmt.Fprintf(w, "FAIL %s\n  line %d\n  got:  %s\n  want: %s\n", name, line, g, x)
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s\n", name)
+	}
+	if failed != 0 {
+		return fmt.Errorf("自己診断で%d件の測定例が正解と異なる", failed)
+	}
+	return nil
+}
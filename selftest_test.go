@@ -0,0 +1,142 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 組み込みの測定例による結合テスト
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// 既定の設定で組み込みの測定例を解析して正解ファイルと比べる
+func TestSelftest(t *testing.T) {
+	app := newApp()
+	// 失敗してもテストを終了させない
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	if err := app.Run([]string{"pulseinsight", "selftest"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFirstDifference(t *testing.T) {
+	if line, _, _ := firstDifference([]byte("a\nb\n"), []byte("a\nb\n")); line != 0 {
+		t.Errorf("same text: line %d", line)
+	}
+	line, got, want := firstDifference([]byte("a\nb\nc\n"), []byte("a\nx\nc\n"))
+	if line != 2 || got != "b" || want != "x" {
+		t.Errorf("line %d got %q want %q", line, got, want)
+	}
+	if line, _, _ := firstDifference([]byte("a\n"), []byte("a\nb\n")); line != 2 {
+		t.Errorf("shorter text: line %d", line)
+	}
+}
+
+// --charts noneではグラフを1枚も作らない
+func TestChartsNone(t *testing.T) {
+	data, err := selftestFiles.ReadFile(path.Join(SelftestDir, "halfduplex.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	csvfilepath := filepath.Join(dir, "halfduplex.csv")
+	if err := os.WriteFile(csvfilepath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := newApp()
+	app.Writer = io.Discard
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	args := []string{"pulseinsight", "--charts", "none", "--utilization-window", "0.01", "--dashboard", "csv", csvfilepath}
+	if err := app.Run(args); err != nil {
+		t.Fatal(err)
+	}
+	pngs, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pngs) != 0 {
+		t.Errorf("--charts none wrote %v", pngs)
+	}
+}
+
+// --streamの段を並行に流しても, 復号したフレームは通常の解析と同じになる
+func TestStreamStages(t *testing.T) {
+	data, err := selftestFiles.ReadFile(path.Join(SelftestDir, "halfduplex.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	csvfilepath := filepath.Join(dir, "halfduplex.csv")
+	if err := os.WriteFile(csvfilepath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	frames := map[string]string{}
+	for _, mode := range []string{"whole", "stream"} {
+		outFile := filepath.Join(dir, mode+".json")
+		args := []string{"pulseinsight", "--charts", "none", "--decode-filtered", "--output", "json", "--out-file", outFile}
+		if mode == "stream" {
+			args = append(args, "--stream")
+		}
+		app := newApp()
+		app.Writer = io.Discard
+		app.ExitErrHandler = func(*cli.Context, error) {}
+		if err := app.Run(append(args, "csv", csvfilepath)); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		b, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var output struct {
+			Frames json.RawMessage `json:"frames"`
+		}
+		if err := json.Unmarshal(b, &output); err != nil {
+			t.Fatal(err)
+		}
+		frames[mode] = string(output.Frames)
+	}
+	if frames["stream"] != frames["whole"] {
+		t.Errorf("stream %s\nwhole %s", frames["stream"], frames["whole"])
+	}
+}
+
+// 7ビットの文字でもデータビットだけをPRBSのビット列にする
+func TestBert7Bit(t *testing.T) {
+	const dataBits = 7
+	const baudrate = 9600
+	// PRBS7を10文字分, 1つ目の誤りは8文字目の2ビット目
+	bits := []uint8{1, 1, 1, 1, 1, 1, 1}
+	for len(bits) < 10*dataBits {
+		n := len(bits)
+		bits = append(bits, bits[n-7]^bits[n-6])
+	}
+	errorBit := 7*dataBits + 1
+	bits[errorBit] ^= 1
+	codes := make([]UartCode, 10)
+	for i := range codes {
+		codes[i].startTime = float64(i)
+		for j := 0; j < dataBits; j++ {
+			codes[i].octet |= bits[i*dataBits+j] << j
+		}
+	}
+	result := runBert(codes, 7, baudrate, dataBits)
+	if !result.locked || result.lockBit != 0 {
+		t.Fatalf("locked %v at bit %d", result.locked, result.lockBit)
+	}
+	if result.compared != len(bits)-7 {
+		t.Errorf("compared %d bits, want %d", result.compared, len(bits)-7)
+	}
+	if len(result.errors) != 1 {
+		t.Fatalf("%d errors, want 1", len(result.errors))
+	}
+	e := result.errors[0]
+	if e.bitIndex != errorBit || e.codeIndex != 7 || e.bitInCode != 1 || e.time != 7+2.0/baudrate {
+		t.Errorf("error %+v", e)
+	}
+}
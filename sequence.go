@@ -0,0 +1,158 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 通信の流れをシーケンス図(Mermaid, PlantUML)に書き出す
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// シーケンス図の形式
+const (
+	SequenceMermaid  = "mermaid"
+	SequencePlantUML = "plantuml"
+)
+
+// シーケンス図の1つのメッセージに表示する最大のバイト数
+const SequenceMaxBytes = 16
+
+// 半二重の主局の名前
+const SequenceMaster = "master"
+
+// シーケンス図の1つのメッセージ
+type SequenceMessage struct {
+	from  string
+	to    string
+	frame UartFrame
+}
+
+// 拡張子からシーケンス図の形式を決める
+func sequenceFormat(savefilepath string) (string, error) {
+	switch strings.ToLower(filepath.Ext(savefilepath)) {
+	case ".mmd", ".mermaid":
+		return SequenceMermaid, nil
+	case ".puml", ".plantuml", ".pu":
+		return SequencePlantUML, nil
+	default:
+		return "", fmt.Errorf("シーケンス図の拡張子 \"%s\" には対応していない(.mmd, .puml)", filepath.Ext(savefilepath))
+	}
+}
+
+// フレームの送信元と宛先を決める
+// 全二重は通信方向(TXからRX, RXからTX)、半二重はアドレスで主局と従局を分ける
+// 半二重では主局の要求に同じアドレスのフレームが続いた場合を従局の応答とみなす
+func sequenceMessages(frames []UartFrame, addressByte int) ([]SequenceMessage, error) {
+	messages := make([]SequenceMessage, 0, len(frames))
+	request := ""
+	for _, f := range frames {
+		switch f.direction {
+		case DirectionTx:
+			messages = append(messages, SequenceMessage{from: DirectionTx, to: DirectionRx, frame: f})
+			continue
+		case DirectionRx:
+			messages = append(messages, SequenceMessage{from: DirectionRx, to: DirectionTx, frame: f})
+			continue
+		}
+		addr, ok := f.address(addressByte)
+		if !ok {
+			return nil, fmt.Errorf("アドレス(%dバイト目)の無いフレームがあるのでシーケンス図を作れない", addressByte)
+		}
+		slave := fmt.Sprintf("0x%02X", addr)
+		if request == slave {
+			messages = append(messages, SequenceMessage{from: slave, to: SequenceMaster, frame: f})
+			request = ""
+		} else {
+			messages = append(messages, SequenceMessage{from: SequenceMaster, to: slave, frame: f})
+			request = slave
+		}
+	}
+	return messages, nil
+}
+
+// メッセージの表示
+func (m SequenceMessage) label(clock Clock) string {
+	octets := make([]byte, 0, SequenceMaxBytes)
+	for _, code := range m.frame.codes[:min(len(m.frame.codes), SequenceMaxBytes)] {
+		octets = append(octets, code.octet)
+	}
+	label := fmt.Sprintf("%s % x", clock.format(m.frame.startTime), octets)
+	if len(m.frame.codes) > SequenceMaxBytes {
+		label += fmt.Sprintf(" ...(%d bytes)", len(m.frame.codes))
+	}
+	return label
+}
+
+// シーケンス図の参加者(出てきた順, 主局を先頭にする)
+func sequenceParticipants(messages []SequenceMessage) []string {
+	participants := []string{}
+	seen := map[string]bool{}
+	for _, m := range messages {
+		for _, name := range []string{m.from, m.to} {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if name == SequenceMaster {
+				participants = append([]string{name}, participants...)
+			} else {
+				participants = append(participants, name)
+			}
+		}
+	}
+	return participants
+}
+
+// シーケンス図を保存する
+func saveSequenceDiagram(savefilepath string, clock Clock, messages []SequenceMessage) error {
+	format, err := sequenceFormat(savefilepath)
+	if err != nil {
+		slog.Error("sequenceFormat", "err", err)
+		return err
+	}
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	switch format {
+	case SequenceMermaid:
+		fmt.Fprintln(w, "sequenceDiagram")
+		for _, name := range sequenceParticipants(messages) {
+			fmt.Fprintf(w, "    participant %s as %s\n", sequenceAlias(name), name)
+		}
+		for _, m := range messages {
+			fmt.Fprintf(w, "    %s->>%s: %s\n", sequenceAlias(m.from), sequenceAlias(m.to), m.label(clock))
+		}
+	case SequencePlantUML:
+		fmt.Fprintln(w, "@startuml")
+		for _, name := range sequenceParticipants(messages) {
+			fmt.Fprintf(w, "participant \"%s\" as %s\n", name, sequenceAlias(name))
+		}
+		for _, m := range messages {
+			fmt.Fprintf(w, "%s -> %s : %s\n", sequenceAlias(m.from), sequenceAlias(m.to), m.label(clock))
+		}
+		fmt.Fprintln(w, "@enduml")
+	}
+	if err := w.Flush(); err != nil {
+		slog.Error("Flush", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 参加者の識別子(0x01のように数字で始まる名前は識別子に使えないのでs01にする)
+func sequenceAlias(name string) string {
+	if strings.HasPrefix(name, "0x") {
+		return "s" + name[2:]
+	}
+	return name
+}
@@ -0,0 +1,213 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// sigrok / PulseView のセッションファイル(.sr)の読み込み
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// セッションファイルの拡張子
+const SigrokExt = ".sr"
+
+// セッションファイルの[device 1]の設定
+type SigrokDevice struct {
+	samplerate float64           // サンプリングレート(Hz)
+	analog     map[string]string // アナログチャンネルの番号(analogNのN) -> チャンネル名
+}
+
+// metadataの[device 1]の節を読む
+func parseSigrokMetadata(r io.Reader) (SigrokDevice, error) {
+	device := SigrokDevice{analog: map[string]string{}}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		if section != "device 1" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "samplerate":
+			rate, err := parseSigrokSamplerate(value)
+			if err != nil {
+				return device, err
+			}
+			device.samplerate = rate
+		case strings.HasPrefix(key, "analog"):
+			if _, err := strconv.Atoi(key[len("analog"):]); err == nil {
+				device.analog[key[len("analog"):]] = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return device, err
+	}
+	if device.samplerate <= 0 {
+		return device, fmt.Errorf("セッションファイルにサンプリングレートがない")
+	}
+	return device, nil
+}
+
+// サンプリングレートの表記(例: "1 MHz", "500 kHz")をHzにする
+func parseSigrokSamplerate(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, fmt.Errorf("サンプリングレート \"%s\" を読めない", s)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("サンプリングレート \"%s\" を読めない", s)
+	}
+	unit := "Hz"
+	if len(fields) == 2 {
+		unit = fields[1]
+	}
+	scale, ok := map[string]float64{"Hz": 1, "kHz": 1e3, "MHz": 1e6, "GHz": 1e9}[unit]
+	if !ok {
+		return 0, fmt.Errorf("サンプリングレートの単位 \"%s\" には対応していない", unit)
+	}
+	return value * scale, nil
+}
+
+// アナログチャンネルの測定値
+// analog-1-N-1, analog-1-N-2, ...の順につなげたリトルエンディアンのfloat32
+func readSigrokAnalog(archive *zip.Reader, index string) ([]float64, error) {
+	prefix := "analog-1-" + index + "-"
+	type chunk struct {
+		number int
+		file   *zip.File
+	}
+	chunks := []chunk{}
+	for _, f := range archive.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(f.Name, prefix))
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, chunk{n, f})
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].number < chunks[j].number })
+
+	samples := []float64{}
+	for _, c := range chunks {
+		r, err := c.file.Open()
+		if err != nil {
+			slog.Error("Open", "err", err)
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			slog.Error("ReadAll", "err", err)
+			return nil, err
+		}
+		for i := 0; i+4 <= len(data); i += 4 {
+			samples = append(samples, float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))))
+		}
+	}
+	return samples, nil
+}
+
+// セッションファイルを時間(s), A線電圧(V), B線電圧(V)の行列にする
+// A線とB線はチャンネル名で選び, 空の場合は番号の小さい順に最初の2つのアナログチャンネル
+func loadSigrokSession(filePath string, aName string, bName string) (*mat.Dense, [][]string, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		slog.Error("OpenReader", "err", err)
+		return nil, nil, err
+	}
+	defer archive.Close()
+
+	metadata, err := archive.Open("metadata")
+	if err != nil {
+		return nil, nil, fmt.Errorf("セッションファイルにmetadataがない: %w", err)
+	}
+	device, err := parseSigrokMetadata(metadata)
+	metadata.Close()
+	if err != nil {
+		slog.Error("parseSigrokMetadata", "err", err)
+		return nil, nil, err
+	}
+
+	// チャンネルを番号順に並べる
+	indexes := make([]string, 0, len(device.analog))
+	for index := range device.analog {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		a, _ := strconv.Atoi(indexes[i])
+		b, _ := strconv.Atoi(indexes[j])
+		return a < b
+	})
+	if len(indexes) < 2 {
+		return nil, nil, fmt.Errorf("セッションファイルのアナログチャンネルが%d個しかない(A線とB線の2つが必要)", len(indexes))
+	}
+	selected := []string{indexes[0], indexes[1]}
+	for i, name := range []string{aName, bName} {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, index := range indexes {
+			if strings.EqualFold(device.analog[index], name) {
+				selected[i], found = index, true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("チャンネル名 \"%s\" がセッションファイルにない", name)
+		}
+	}
+	if selected[0] == selected[1] {
+		return nil, nil, fmt.Errorf("A線とB線に同じチャンネル \"%s\" が選ばれている", device.analog[selected[0]])
+	}
+
+	a, err := readSigrokAnalog(&archive.Reader, selected[0])
+	if err != nil {
+		slog.Error("readSigrokAnalog", "err", err)
+		return nil, nil, err
+	}
+	b, err := readSigrokAnalog(&archive.Reader, selected[1])
+	if err != nil {
+		slog.Error("readSigrokAnalog", "err", err)
+		return nil, nil, err
+	}
+	rows := min(len(a), len(b))
+	if rows == 0 {
+		return nil, nil, fmt.Errorf("セッションファイルに測定値がない")
+	}
+	matrix := mat.NewDense(rows, 3, nil)
+	for r := 0; r < rows; r++ {
+		matrix.Set(r, ColTime, float64(r)/device.samplerate)
+		matrix.Set(r, ColWireA, a[r])
+		matrix.Set(r, ColWireB, b[r])
+	}
+	header := [][]string{{"Time", device.analog[selected[0]], device.analog[selected[1]]}, {"s", "V", "V"}}
+	return matrix, header, nil
+}
@@ -0,0 +1,40 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// A線とB線の間の時間のずれ(スキュー)の補正
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// B線がA線よりskew秒遅れて記録されているとして、B線をskew秒早める
+// 各行の時間にskewを足した時間のB線電圧を前後の行から直線補間して求め、範囲外は端の値にする
+func applySkew(matrix *mat.Dense, skew float64) {
+	if skew == 0 {
+		return
+	}
+	times := mat.Col(nil, ColTime, matrix)
+	wireB := mat.Col(nil, ColWireB, matrix)
+	rows := len(times)
+	corrected := make([]float64, rows)
+
+	// 時間は昇順なので補間する区間を前から順に進める
+	k := 0
+	for r := 0; r < rows; r++ {
+		t := times[r] + skew
+		for k+1 < rows && times[k+1] <= t {
+			k++
+		}
+		switch {
+		case t <= times[0]:
+			corrected[r] = wireB[0]
+		case k+1 >= rows:
+			corrected[r] = wireB[rows-1]
+		default:
+			ratio := (t - times[k]) / (times[k+1] - times[k])
+			corrected[r] = wireB[k] + ratio*(wireB[k+1]-wireB[k])
+		}
+	}
+	matrix.SetCol(ColWireB, corrected)
+}
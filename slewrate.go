@@ -0,0 +1,122 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// エッジごとのスルーレート(dV/dt)と遷移時間の検査
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// エッジ
+// A,B間電圧差が一方のしきい値を離れてから反対側のしきい値を超えるまで
+type Edge struct {
+	startTime      float64 // 遷移開始時間(しきい値を離れる直前の入力CSVの時間)
+	endTime        float64 // 遷移終了時間(反対側のしきい値を超えた時間)
+	rising         bool    // Space->Markの遷移か
+	slewA          float64 // A線のスルーレート(V/s)
+	slewB          float64 // B線のスルーレート(V/s)
+	slewDiff       float64 // A,B間電圧差のスルーレート(V/s)
+	transitionTime float64 // 遷移時間(s)
+	violation      string  // 違反内容, 違反なしは空
+}
+
+// スルーレートの制限
+type SlewLimit struct {
+	minSlew       float64 // 最小スルーレート(V/s), 0の場合は制限なし
+	maxSlew       float64 // 最大スルーレート(V/s), 0の場合は制限なし
+	maxTransition float64 // 最大遷移時間(s)
+}
+
+// A,B間電圧差がしきい値を横切るエッジを全て測定する
+func measureEdges(matrix mat.Matrix) []Edge {
+	rows, _ := matrix.Dims()
+	edges := []Edge{}
+	// 直前にしきい値を超えていた行と、その向き(1:Mark, -1:Space)
+	lastRow := -1
+	lastLevel := 0
+	for r := 0; r < rows; r++ {
+		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
+		level := 0
+		if d > Threshould {
+			level = 1
+		} else if d < -Threshould {
+			level = -1
+		} else {
+			continue
+		}
+		if lastLevel != 0 && level != lastLevel {
+			t1 := matrix.At(lastRow, ColTime)
+			t2 := matrix.At(r, ColTime)
+			dt := t2 - t1
+			if dt > 0 {
+				edges = append(edges, Edge{
+					startTime:      t1,
+					endTime:        t2,
+					rising:         level > 0,
+					slewA:          (matrix.At(r, ColWireA) - matrix.At(lastRow, ColWireA)) / dt,
+					slewB:          (matrix.At(r, ColWireB) - matrix.At(lastRow, ColWireB)) / dt,
+					slewDiff:       (d - (matrix.At(lastRow, ColWireA) - matrix.At(lastRow, ColWireB))) / dt,
+					transitionTime: dt,
+				})
+			}
+		}
+		lastRow = r
+		lastLevel = level
+	}
+	return edges
+}
+
+// エッジを制限と比べる
+func checkSlewLimit(edges []Edge, limit SlewLimit) {
+	for i := range edges {
+		e := &edges[i]
+		slew := math.Abs(e.slewDiff)
+		switch {
+		case limit.minSlew > 0 && slew < limit.minSlew:
+			e.violation = "スルーレートが遅い"
+		case limit.maxSlew > 0 && slew > limit.maxSlew:
+			e.violation = "スルーレートが速い"
+		case e.transitionTime > limit.maxTransition:
+			e.violation = "遷移時間が長い"
+		}
+	}
+}
+
+// スルーレートを表示する
+func printSlewRate(w io.Writer, clock Clock, label string, edges []Edge) {
+	if len(edges) == 0 {
+		fmt.Fprintf(w, "slew rate%s: no edges\n", label)
+		return
+	}
+	minSlew, maxSlew := math.Inf(1), 0.0
+	maxTransition := 0.0
+	violations := 0
+	for _, e := range edges {
+		slew := math.Abs(e.slewDiff)
+		minSlew = math.Min(minSlew, slew)
+		maxSlew = math.Max(maxSlew, slew)
+		maxTransition = math.Max(maxTransition, e.transitionTime)
+		if e.violation != "" {
+			violations++
+		}
+	}
+	fmt.Fprintf(w, "slew rate%s: %d edges  A-B %.3f..%.3f V/us  max transition %.3fus\n",
+		label, len(edges), minSlew*1e-6, maxSlew*1e-6, maxTransition*1e6)
+	for _, e := range edges {
+		if e.violation == "" {
+			continue
+		}
+		direction := "falling"
+		if e.rising {
+			direction = "rising"
+		}
+		fmt.Fprintf(w, "  %s %-7s  A %.3f  B %.3f  A-B %.3f V/us  transition %.3fus  VIOLATION: %s\n",
+			clock.format(e.startTime-clock.originTime), direction, e.slewA*1e-6, e.slewB*1e-6, e.slewDiff*1e-6, e.transitionTime*1e6, e.violation)
+	}
+	fmt.Fprintf(w, "slew rate violations%s: %d\n", label, violations)
+}
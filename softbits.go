@@ -0,0 +1,136 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ビット毎の軟判定(確からしさ)
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// 弱いビットとみなす確からしさ
+const SoftBitWeakConfidence = 0.5
+
+// 1ビットの軟判定
+type SoftBit struct {
+	bit        UartBit
+	direction  string  // 全二重の場合の通信方向, 半二重では空
+	level      float64 // ビットの中央の半分の区間のA,B間電圧差の平均(V)
+	margin     float64 // しきい値からの距離(しきい値から典型的なビットの強さまでを0-1にしたもの)
+	stability  float64 // ビットの区間で判定と同じ側でしきい値を超えている標本の割合(0-1)
+	confidence float64 // 確からしさ(marginとstabilityの積)
+}
+
+// 解析したビット列の軟判定
+// idleSignはアイドルの向きの符号(アイドルがSpaceの配線ではデータビットの1がSpaceになる)
+func softDecideBits(source BitWaveform, direction string, idleSign float64) []SoftBit {
+	softBits := make([]SoftBit, 0, len(source.bits))
+	for _, b := range source.bits {
+		// 判定の向き(1:Mark, -1:Space)
+		sign := idleSign
+		if b.bit == 0 {
+			sign = -idleSign
+		}
+		quarter := (b.endTime - b.startTime) / 4
+		level := source.mean(b.startTime+quarter, b.endTime-quarter)
+
+		margin := 0.0
+		if source.typical > Threshould {
+			margin = (sign*level - Threshould) / (source.typical - Threshould)
+		} else if sign*level > Threshould {
+			margin = 1
+		}
+		margin = math.Max(0, math.Min(margin, 1))
+
+		begin := sort.SearchFloat64s(source.times, b.startTime)
+		end := min(sort.SearchFloat64s(source.times, b.endTime)+1, len(source.diff))
+		stable := 0
+		for _, d := range source.diff[begin:max(begin, end)] {
+			if sign*d > Threshould {
+				stable++
+			}
+		}
+		stability := 0.0
+		if end > begin {
+			stability = float64(stable) / float64(end-begin)
+		}
+
+		softBits = append(softBits, SoftBit{
+			bit:        b,
+			direction:  direction,
+			level:      level,
+			margin:     margin,
+			stability:  stability,
+			confidence: margin * stability,
+		})
+	}
+	return softBits
+}
+
+// 軟判定の要約を表示する
+func printSoftBits(w io.Writer, clock Clock, softBits []SoftBit) {
+	if len(softBits) == 0 {
+		fmt.Fprintln(w, "soft bits: 0 bits")
+		return
+	}
+	weakest := softBits[0]
+	weak := 0
+	sum := 0.0
+	for _, s := range softBits {
+		sum += s.confidence
+		if s.confidence < SoftBitWeakConfidence {
+			weak++
+		}
+		if s.confidence < weakest.confidence {
+			weakest = s
+		}
+	}
+	fmt.Fprintf(w, "soft bits: %d bits  mean confidence %.2f  weak bits (<%.2f): %d\n",
+		len(softBits), sum/float64(len(softBits)), SoftBitWeakConfidence, weak)
+	fmt.Fprintf(w, "  weakest %s %s  bit %d  level %.3fV  confidence %.2f\n",
+		clock.format(weakest.bit.startTime), weakest.bit.state, weakest.bit.bit, weakest.level, weakest.confidence)
+}
+
+// 軟判定をCSVファイルに保存する
+func saveSoftBits(savefilepath string, clock Clock, softBits []SoftBit) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"start", "end", "timestamp", "direction", "state", "bit", "level", "margin", "stability", "confidence"})
+	for _, s := range softBits {
+		timestamp := ""
+		if clock.absolute {
+			timestamp = clock.format(s.bit.startTime)
+		}
+		writer.Write([]string{
+			strconv.FormatFloat(s.bit.startTime, 'g', -1, 64),
+			strconv.FormatFloat(s.bit.endTime, 'g', -1, 64),
+			timestamp,
+			s.direction,
+			s.bit.state,
+			strconv.Itoa(s.bit.bit),
+			strconv.FormatFloat(s.level, 'g', 6, 64),
+			strconv.FormatFloat(s.margin, 'f', 3, 64),
+			strconv.FormatFloat(s.stability, 'f', 3, 64),
+			strconv.FormatFloat(s.confidence, 'f', 3, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
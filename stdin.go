@@ -0,0 +1,89 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 標準入力からの測定値の読み込み(`csv -`)
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 標準入力から読む場合の入力ファイルの名前
+const StdinPath = "-"
+
+// 標準入力から読む場合の既定の出力ファイルの基本名
+const StdinOutputPrefix = "stdin"
+
+// 標準入力は1回しか読めないので, 読みながら来歴に使うSHA-256を求める
+var stdinInput = struct {
+	opened bool
+	hash   hash.Hash
+}{hash: sha256.New()}
+
+// 入力ファイルの名前が標準入力を表す
+func isStdin(csvfilepath string) bool {
+	return csvfilepath == StdinPath
+}
+
+// 標準入力を開く
+// 2回目はエラー
+func openStdin() (io.Reader, error) {
+	if stdinInput.opened {
+		return nil, errors.New("標準入力は1回しか読めない")
+	}
+	stdinInput.opened = true
+	return io.TeeReader(os.Stdin, stdinInput.hash), nil
+}
+
+// 読み込んだ標準入力のSHA-256
+func stdinSha256() string {
+	return hex.EncodeToString(stdinInput.hash.Sum(nil))
+}
+
+// 入力ファイルの名前から, 出力ファイルの基本名(拡張子を除いた名前)と拡張子を決める
+// 標準入力と--output-prefixを指定した場合は, 基本名をoutputPrefix(既定はStdinOutputPrefix)にする
+// 拡張子が無い場合は.csvとみなす
+func outputStem(csvfilepath string, outputPrefix string) (string, string) {
+	ext := filepath.Ext(csvfilepath)
+	stem := strings.TrimSuffix(csvfilepath, ext)
+	if isStdin(csvfilepath) {
+		ext, stem = "", StdinOutputPrefix
+	}
+	if outputPrefix != "" {
+		stem = outputPrefix
+	}
+	if ext == "" {
+		ext = ".csv"
+	}
+	return stem, ext
+}
+
+// 標準入力から読む場合に使えない指定
+func checkStdinOption(csvfiles []string, option InsightOption) error {
+	stdin := 0
+	for _, csvfile := range csvfiles {
+		if isStdin(csvfile) {
+			stdin++
+		}
+	}
+	switch {
+	case stdin == 0:
+		return nil
+	case stdin > 1:
+		return errors.New("標準入力は1回しか読めない")
+	case option.follow:
+		return errors.New("--followは標準入力には対応していない")
+	case option.cache:
+		return errors.New("--cacheは標準入力には対応していない")
+	case option.vcdInput || option.wavInput:
+		return errors.New("標準入力から読めるのはCSVだけ")
+	}
+	return nil
+}
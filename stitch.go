@@ -0,0 +1,78 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 続けて測定した複数のCSVファイル(セグメント)を1つの時間軸につなげる
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 最初のファイルの後ろにCSVファイルをつなげる
+// 全てのファイルのヘッダーに時刻がある場合は時刻の差で、それ以外は前のファイルの最後のサンプルの次に続くように時間をずらす
+func stitchInputFiles(ctx context.Context, w io.Writer, first *mat.Dense, firstHeader [][]string, csvfilepaths []string, option InsightOption) (*mat.Dense, error) {
+	matrices := []*mat.Dense{first}
+	headers := [][][]string{firstHeader}
+	for _, csvfilepath := range csvfilepaths {
+		matrix, header, err := loadInputMatrix(ctx, w, csvfilepath, option)
+		if err != nil {
+			slog.Error("loadInputMatrix", "err", err)
+			return nil, err
+		}
+		if _, cols := matrix.Dims(); cols != first.RawMatrix().Cols {
+			return nil, fmt.Errorf("\"%s\" の列数が%dではなく%d", csvfilepath, first.RawMatrix().Cols, cols)
+		}
+		matrices = append(matrices, matrix)
+		headers = append(headers, header)
+	}
+
+	// ヘッダーの時刻
+	t0s := []time.Time{}
+	for _, header := range headers {
+		if t0, ok := findHeaderTime(header); ok {
+			t0s = append(t0s, t0)
+		}
+	}
+	absolute := len(t0s) == len(headers)
+
+	// 時間をずらしてつなげる
+	totalRows := 0
+	for _, m := range matrices {
+		rows, _ := m.Dims()
+		totalRows += rows
+	}
+	stitched := mat.NewDense(totalRows, first.RawMatrix().Cols, nil)
+	row := 0
+	for i, m := range matrices {
+		rows, cols := m.Dims()
+		offset := 0.0
+		switch {
+		case i == 0:
+		case absolute:
+			offset = t0s[i].Sub(t0s[0]).Seconds()
+		default:
+			previous := stitched.At(row-1, ColTime)
+			offset = previous + sampleInterval(matrices[i-1]) - m.At(0, ColTime)
+		}
+		if i > 0 {
+			if start := m.At(0, ColTime) + offset; start <= stitched.At(row-1, ColTime) {
+				slog.Warn("overlapping segment", "file", csvfilepaths[i-1], "start", start, "previous end", stitched.At(row-1, ColTime))
+			}
+			fmt.Fprintf(w, "stitch \"%s\" offset %+.6fs\n", csvfilepaths[i-1], offset)
+		}
+		for r := 0; r < rows; r++ {
+			stitched.Set(row, ColTime, m.At(r, ColTime)+offset)
+			for c := 1; c < cols; c++ {
+				stitched.Set(row, c, m.At(r, c))
+			}
+			row++
+		}
+	}
+	return stitched, nil
+}
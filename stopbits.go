@@ -0,0 +1,81 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// フレーム内の続いた文字の間の長さからストップビットの数と文字間の無通信時間を測る
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ストップビットの数を決める時に許す測定値の不足(ビット)
+const StopBitTolerance = 0.1
+
+// ストップビットの数の候補(多い順)
+var stopBitCandidates = []float64{2, 1.5, 1}
+
+// フレーム内の続いた文字のストップビットの始まりから次のスタートビットまでの長さ(ビット)
+// directionが空でなければその通信方向のフレームだけを測る
+func measureStopBits(frames []UartFrame, baudrate int, direction string, format UartFormat) []float64 {
+	period := 1 / float64(baudrate)
+	lengths := []float64{}
+	for _, f := range frames {
+		if direction != "" && f.direction != direction {
+			continue
+		}
+		for i := 0; i+1 < len(f.codes); i++ {
+			// スタートビット, データビット, パリティビットの後がストップビット
+			stopStart := f.codes[i].startTime + float64(format.stopBitIndex())*period
+			length := (f.codes[i+1].startTime - stopStart) / period
+			// 次の文字がストップビットより前に始まるのは復号が乱れた所なので除く
+			if length < 0 {
+				continue
+			}
+			lengths = append(lengths, length)
+		}
+	}
+	return lengths
+}
+
+// 送信側のストップビットの数
+// 一番短い長さに収まる最も多い候補とする
+func estimateStopBits(lengths []float64) float64 {
+	shortest := lengths[0]
+	for _, v := range lengths {
+		shortest = min(shortest, v)
+	}
+	for _, stopBits := range stopBitCandidates {
+		if shortest >= stopBits-StopBitTolerance {
+			return stopBits
+		}
+	}
+	// 1ビットより短いのはフレーミングエラーなので1とする
+	return 1
+}
+
+// ストップビットの数と文字間の無通信時間を書く
+// formatは設定した文字の形式で, 測ったストップビットの数が足りなければ書き添える
+func printStopBits(w io.Writer, label string, lengths []float64, baudrate int, format UartFormat) {
+	if len(lengths) == 0 {
+		fmt.Fprintf(w, "stop bits%s: unknown (no back-to-back bytes)\n", label)
+		return
+	}
+	sorted := append([]float64{}, lengths...)
+	sort.Float64s(sorted)
+	stopBits := estimateStopBits(sorted)
+	extra, maxExtra := 0.0, 0.0
+	for _, v := range sorted {
+		extra += max(0, v-stopBits)
+		maxExtra = max(maxExtra, v-stopBits)
+	}
+	measured := UartFormat{dataBits: format.dataBits, parity: format.parity, stopBits: stopBits}
+	fmt.Fprintf(w, "stop bits%s: %g (%s)  %d byte gaps  stop+idle %.2f/%.2f/%.2f bits min/median/max\n",
+		label, stopBits, measured, len(sorted), sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1])
+	if stopBits < format.stopBits {
+		fmt.Fprintf(w, "  stop bits%s: fewer than configured %s\n", label, format)
+	}
+	fmt.Fprintf(w, "  inter-character idle%s: mean %.2f bits  max %.2f bits (%.1fus)\n",
+		label, extra/float64(len(sorted)), maxExtra, maxExtra/float64(baudrate)*1e6)
+}
@@ -0,0 +1,720 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 全体を行列にせずに, CSVファイルの行を塊ごとにフィルタ, 波形整形, 復号へ流す解析(長い測定値向け)
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/chart"
+	"pulseinsight/pkg/uart"
+	"pulseinsight/pkg/waveform"
+)
+
+// グラフに使う間引いた測定値の区切りの数
+const StreamChartBuckets = 1 << 15
+
+// 基準時間(最初のスタートビット開始時間)が決まるまで溜めておく行数の上限
+// 溜めきってもスタートビットが無い場合は基準時間を0に決める(スタートビットが無い取り込みと同じ)
+const StreamPendingMaxRows = 1 << 20
+
+// 少しずつ流す解析の1対(半二重はA,B線, 全二重はTX対とRX対)
+type StreamPair struct {
+	raw      *waveform.Decimator // 間引いた測定値
+	filtered *waveform.Decimator // 間引いたフィルタ後の測定値, 作らない場合はnil
+	reshaper *waveform.Reshaper  // 基準時間が決まるまではnil
+	resync   *Resyncer           // 取り込みの始めの読み飛ばし
+	gain     float64             // 復号のしきい値に合わせたA,B間電圧差の倍率
+	gate     NoiseGate           // 雑音の床で0にした行
+	decoder  *uart.Decoder
+	runs     []waveform.Run
+}
+
+// 少しずつ流す解析
+type StreamAnalysis struct {
+	option       InsightOption
+	header       [][]string         // 列を選んだ後のヘッダー行
+	scales       []float64          // 列毎の単位の換算係数
+	smoother     *waveform.Smoother // フィルタを使わない場合はnil
+	pairs        []*StreamPair
+	pending      []float64 // 基準時間が決まるまでの解析する行(時間, 対毎のA,B間電圧差)
+	originTime   float64
+	originFound  bool
+	rows         int // 読んだ行数
+	chunks       int // 読んだ塊の数
+	captureStart float64
+	captureEnd   float64
+	filteredRows []float64
+	filterRows   [][]float64 // フィルタ段の塊の中の対毎の行(時間, A線, B線, ...)
+	decodeRows   [][]float64 // 復号段の塊の中の対毎の行
+}
+
+// 少しずつ流す解析の段に渡す塊
+type StreamChunk struct {
+	header [][]string
+	data   []float64 // 行優先の行
+	cols   int
+}
+
+// 少しずつ流す解析の段
+// 読み込み段(CSVの読み込み)の塊をチャネルでフィルタ段に, フィルタ段の塊を復号段に渡して並行に進める
+type StreamStages struct {
+	chunks chan StreamChunk // 読み込み段からフィルタ段へ
+	done   chan struct{}    // 復号段が終わると閉じる
+	mu     sync.Mutex
+	err    error // 最初に失敗した段のエラー
+	once   sync.Once
+}
+
+// 少しずつ流す解析で使えない(測定値の全体が要る)指定
+func checkStreamOption(csvfilepath string, option InsightOption, charts ChartSelection) error {
+	unsupported := []struct {
+		used bool
+		name string
+	}{
+		{isNonCsvInput(csvfilepath, option), "sigrok, VCD, WAV, オシロスコープの波形ファイル"},
+		{option.inputType != InputAnalog, "論理レベルの入力"},
+		{option.estimateBaud != EstimateBaudNone, "ボーレートの推定"},
+		{option.edgeDetect != EdgeLevel, "微分によるエッジ検出"},
+		{option.skew != 0, "--skew"},
+		{len(option.stitchFiles) != 0 || option.segment != nil, "--stitch, --segments"},
+		{option.cache, "--cache"},
+		{option.liveFrames, "--live-frames"},
+		{option.maxFrames > 0 || option.stopAfter != "", "--max-frames, --stop-after"},
+		{option.exportFiltered || option.exportReshaped || option.exportAnnotated, "--export-filtered, --export-reshaped, --export-annotated"},
+		{option.tileWidth > 0, "--tiles"},
+		{option.dashboard || option.eyeMaskFile != "", "--dashboard, --eye-mask"},
+		{option.correct || option.softBitsFile != "" || option.bitFeaturesFile != "", "--correct, --soft-bits, --bit-features"},
+		{option.busStateFile != "", "--bus-state-file"},
+		{option.meta, "--meta"},
+	}
+	for _, u := range unsupported {
+		if u.used {
+			return fmt.Errorf("--streamは%sには対応していない", u.name)
+		}
+	}
+	if (option.decodeFilter || charts[ChartFiltered]) && option.filter != FilterSma {
+		return fmt.Errorf("--streamのフィルタは%sだけに対応している(--charts から %s を除くとフィルタを使わない)", FilterSma, ChartFiltered)
+	}
+	return nil
+}
+
+// 少しずつ流す解析を始める
+func newStreamAnalysis(option InsightOption) *StreamAnalysis {
+	return &StreamAnalysis{option: option}
+}
+
+// 最初の塊で列, 単位, 全二重かどうか, フィルタを決めて, 最初の塊を補正する
+func (s *StreamAnalysis) start(w io.Writer, header [][]string, matrix *mat.Dense, charts ChartSelection) error {
+	_, cols := matrix.Dims()
+	scales, err := unitScales(header, cols, s.option.timeUnit, s.option.voltageUnit)
+	if err != nil {
+		return err
+	}
+	s.header, s.scales = header, scales
+	s.condition(matrix)
+
+	pairCols := cols
+	pairs := 1
+	if isDuplex(matrix) {
+		pairCols, pairs = 3, 2
+	}
+	for range pairs {
+		pair := &StreamPair{raw: waveform.NewDecimator(StreamChartBuckets, pairCols), resync: newResyncer(s.option), gain: decisionGain(s.option.threshold), gate: NoiseGate{floor: s.option.noiseFloor}}
+		if charts[ChartFiltered] {
+			pair.filtered = waveform.NewDecimator(StreamChartBuckets, pairCols)
+		}
+		config := s.option.format.uartConfig(0)
+		config.Permissive = s.option.decodeMode == DecodePermissive
+		if pair.decoder, err = uart.NewDecoder(config); err != nil {
+			return err
+		}
+		s.pairs = append(s.pairs, pair)
+	}
+
+	if s.option.decodeFilter || charts[ChartFiltered] {
+		if s.option.smoothWindow == 0 {
+			// 最初の塊のサンプリング間隔から決める
+			s.option.smoothWindow = autoSmoothingWindow(matrix, s.option.baudrate)
+			fmt.Fprintf(w, "smoothing window: %d samples (auto)\n", s.option.smoothWindow)
+		}
+		s.smoother = waveform.NewSmoother(s.option.smoothWindow, cols)
+	}
+	return nil
+}
+
+// 時間を秒に, 電圧をボルトに揃え, プローブの減衰比と極性を補正する
+func (s *StreamAnalysis) condition(matrix *mat.Dense) {
+	aScale, bScale := s.option.aScale, s.option.bScale
+	if s.option.invertA {
+		aScale = -aScale
+	}
+	if s.option.invertB {
+		bScale = -bScale
+	}
+	raw := matrix.RawMatrix()
+	for r := 0; r < raw.Rows; r++ {
+		row := raw.Data[r*raw.Stride : r*raw.Stride+raw.Cols]
+		for c, scale := range s.scales {
+			if scale != 1 {
+				row[c] *= scale
+			}
+		}
+		if aScale != 1 {
+			row[ColWireA] *= aScale
+		}
+		if bScale != 1 {
+			row[ColWireB] *= bScale
+		}
+	}
+}
+
+// 行(行優先)を対毎の行に分けてdstに入れる
+// dstは段毎に持ち, 対毎のスライスを使い回す
+func (s *StreamAnalysis) splitPairs(dst [][]float64, data []float64, cols int) [][]float64 {
+	for len(dst) < len(s.pairs) {
+		dst = append(dst, nil)
+	}
+	for i := range dst {
+		dst[i] = dst[i][:0]
+	}
+	for r := 0; r+cols <= len(data); r += cols {
+		row := data[r : r+cols]
+		if len(s.pairs) == 1 {
+			dst[0] = append(dst[0], row...)
+			continue
+		}
+		dst[0] = append(dst[0], row[ColTime], row[ColWireA], row[ColWireB])
+		dst[1] = append(dst[1], row[ColTime], row[ColRxWireA], row[ColRxWireB])
+	}
+	return dst
+}
+
+// 段を始める
+// spanがnilでなければ段毎に子のスパン(filter, decode)を作る
+func (s *StreamAnalysis) startStages(w io.Writer, charts ChartSelection, span *TraceSpan) *StreamStages {
+	stages := &StreamStages{chunks: make(chan StreamChunk, 2), done: make(chan struct{})}
+	sources := make(chan StreamChunk, 2)
+	// フィルタ段
+	go func() {
+		defer close(sources)
+		filterSpan := span.child("filter")
+		var err error
+		for chunk := range stages.chunks {
+			// 失敗した後は読み込み段が止まるまで読み捨てる
+			if err != nil {
+				continue
+			}
+			var source StreamChunk
+			if source, err = s.filter(w, chunk, charts); err != nil {
+				stages.fail(err)
+				continue
+			}
+			sources <- source
+		}
+		filterSpan.finish(err)
+	}()
+	// 復号段
+	go func() {
+		defer close(stages.done)
+		decodeSpan := span.child("decode")
+		for source := range sources {
+			s.decode(w, source)
+		}
+		decodeSpan.finish(nil)
+	}()
+	return stages
+}
+
+// 最初に失敗した段のエラーを残す
+func (stages *StreamStages) fail(err error) {
+	stages.mu.Lock()
+	defer stages.mu.Unlock()
+	if stages.err == nil {
+		stages.err = err
+	}
+}
+
+// 失敗した段のエラー, 失敗していなければnil
+func (stages *StreamStages) failed() error {
+	stages.mu.Lock()
+	defer stages.mu.Unlock()
+	return stages.err
+}
+
+// 読み込んだ塊をフィルタ段に渡す
+// 読み込み段はdataを使い回すので写して渡す
+// 段が失敗していたらそのエラーを返して読み込みを止める
+func (stages *StreamStages) push(header [][]string, data []float64, cols int) error {
+	if err := stages.failed(); err != nil {
+		return err
+	}
+	stages.chunks <- StreamChunk{header: header, data: append([]float64(nil), data...), cols: cols}
+	return nil
+}
+
+// 全ての塊を渡し終えたので, 復号段が終わるのを待つ
+// 何度呼んでもよい
+func (stages *StreamStages) wait() error {
+	stages.once.Do(func() { close(stages.chunks) })
+	<-stages.done
+	return stages.failed()
+}
+
+// フィルタ段
+// 塊の列を選んで補正し, 間引いてグラフに残し, フィルタを掛けて復号する行を返す
+func (s *StreamAnalysis) filter(w io.Writer, chunk StreamChunk, charts ChartSelection) (StreamChunk, error) {
+	header, data, cols := chunk.header, chunk.data, chunk.cols
+	matrix := mat.NewDense(len(data)/cols, cols, data)
+	// 列名で列を選ぶ
+	if s.option.columnNames != (ColumnNames{}) {
+		var err error
+		if matrix, header, err = selectColumns(matrix, header, s.option.columnNames); err != nil {
+			slog.Error("selectColumns", "err", err)
+			return StreamChunk{}, err
+		}
+	}
+	if s.chunks == 0 {
+		if err := s.start(w, header, matrix, charts); err != nil {
+			return StreamChunk{}, err
+		}
+	} else {
+		s.condition(matrix)
+	}
+	s.chunks++
+	raw := matrix.RawMatrix()
+	data, cols = raw.Data[:raw.Rows*raw.Stride], raw.Stride
+	if s.rows == 0 {
+		s.captureStart = data[ColTime]
+	}
+	s.rows += raw.Rows
+	s.captureEnd = data[len(data)-cols+ColTime]
+
+	// 間引いてグラフに残す
+	s.filterRows = s.splitPairs(s.filterRows, data, cols)
+	for i, pair := range s.pairs {
+		pair.raw.Push(s.filterRows[i])
+	}
+
+	// フィルタを掛ける
+	// フィルタ後の行は次の塊で使い回すので, 復号する場合は写して渡す
+	source := StreamChunk{header: header, data: data, cols: cols}
+	if s.smoother != nil {
+		s.filteredRows = s.smoother.Push(s.filteredRows[:0], data)
+		s.filterRows = s.splitPairs(s.filterRows, s.filteredRows, cols)
+		for i, pair := range s.pairs {
+			if pair.filtered != nil {
+				pair.filtered.Push(s.filterRows[i])
+			}
+		}
+		if s.option.decodeFilter {
+			source.data = append([]float64(nil), s.filteredRows...)
+		}
+	}
+	return source, nil
+}
+
+// 復号段
+// 塊の行を波形整形して復号する
+// 取り込みが送信の途中で始まった場合は十分な無通信まで読み飛ばす
+// 基準時間(最初のスタートビット開始時間)が決まるまでは行を溜めておく(StreamPendingMaxRows行まで)
+func (s *StreamAnalysis) decode(w io.Writer, source StreamChunk) {
+	s.decodeRows = s.splitPairs(s.decodeRows, source.data, source.cols)
+	pairCols := source.cols
+	if len(s.pairs) == 2 {
+		pairCols = 3
+	}
+	rows := s.decodeRows[0]
+	for r := 0; r+pairCols <= len(rows); r += pairCols {
+		t := rows[r+ColTime]
+		if s.originFound {
+			for i, pair := range s.pairs {
+				pair.push(t, pair.difference(s.decodeRows[i], r))
+			}
+			continue
+		}
+		s.pending = append(s.pending, t)
+		for i, pair := range s.pairs {
+			d := pair.difference(s.decodeRows[i], r)
+			s.pending = append(s.pending, d)
+			if s.option.format.idleSign()*d < -waveform.Threshold {
+				s.originFound = true
+				s.originTime = t
+			}
+		}
+		if !s.originFound && len(s.pending) >= StreamPendingMaxRows*(1+len(s.pairs)) {
+			fmt.Fprintf(w, "stream: no start bit in the first %d rows, origin set to 0\n", StreamPendingMaxRows)
+			s.originFound = true
+			s.originTime = 0
+		}
+		if s.originFound {
+			s.replay()
+		}
+	}
+}
+
+// 塊の中の対の行rowsのr番目(行優先の位置)の行のA,B間電圧差
+// 雑音の床より小さい行と読み飛ばす行は0にする
+func (pair *StreamPair) difference(rows []float64, r int) float64 {
+	d := pair.gate.push(rows[r+ColWireA]-rows[r+ColWireB]) * pair.gain
+	if !pair.resync.push(rows[r+ColTime], d) {
+		return 0
+	}
+	return d
+}
+
+// 1行を波形整形して, 終わった区間を復号する
+func (pair *StreamPair) push(t float64, d float64) {
+	pair.runs = pair.reshaper.Push(pair.runs[:0], t, d)
+	for _, run := range pair.runs {
+		pair.decoder.Push(run)
+	}
+}
+
+// 基準時間が決まったので, 溜めておいた行を波形整形して復号する
+func (s *StreamAnalysis) replay() {
+	for _, pair := range s.pairs {
+		pair.reshaper = waveform.NewReshaper(s.option.baudrate, s.originTime)
+	}
+	stride := 1 + len(s.pairs)
+	for r := 0; r+stride <= len(s.pending); r += stride {
+		for i, pair := range s.pairs {
+			pair.push(s.pending[r], s.pending[r+1+i])
+		}
+	}
+	s.pending = nil
+}
+
+// 残りの区間を復号して, 対毎のビットと文字を返す
+// スタートビットが無かった場合は基準時間を0にする
+func (s *StreamAnalysis) finish() ([][]UartBit, [][]UartCode) {
+	if !s.originFound {
+		s.originFound = true
+		s.replay()
+	}
+	bits := [][]UartBit{}
+	codes := [][]UartCode{}
+	for _, pair := range s.pairs {
+		for _, run := range pair.reshaper.Flush(pair.runs[:0]) {
+			pair.decoder.Push(run)
+		}
+		b, c := fromDecoded(pair.decoder.Bits(), pair.decoder.Codes())
+		bits = append(bits, b)
+		codes = append(codes, c)
+	}
+	return bits, codes
+}
+
+// 復号したビットから波形整形後の行列(グラフに使う)を作る
+// 復号したビットは波形整形した区間と1つずつ対応する
+func reshapedFromBits(bits []UartBit) mat.Matrix {
+	if len(bits) == 0 {
+		return mat.NewDense(2, 3, []float64{0, 1, -1, 0, 1, -1})
+	}
+	data := make([]float64, 0, len(bits)*6)
+	for _, b := range bits {
+		a := float64(2*b.bit - 1)
+		data = append(data, b.startTime, a, -a, b.endTime, a, -a)
+	}
+	return mat.NewDense(len(data)/3, 3, data)
+}
+
+// CSVファイルを少しずつ読んで解析する
+// 測定値は間引いてグラフにだけ使い, 報告は復号したビットと文字から作る
+func streamTheCsvFile(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption, outputs OutputPolicy, span *TraceSpan) error {
+	baudrate := option.baudrate
+	graphWidth := option.graphWidth
+	graphHeight := option.graphHeight
+
+	charts, err := parseChartSelection(option.charts)
+	if err != nil {
+		return err
+	}
+	if err := checkStreamOption(csvfilepath, option, charts); err != nil {
+		return err
+	}
+
+	// グラフの描画と保存は描画段で解析と並行して進める
+	plots := startPlotStage(ctx, span.child("plot"), option.plotWorkers)
+	defer plots.wait()
+
+	// 外部イベント
+	events := []ExternalEvent{}
+	if option.eventsFile != "" {
+		if events, err = loadEvents(option.eventsFile); err != nil {
+			slog.Error("loadEvents", "err", err)
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "input file \"%s\" (stream)\n", csvfilepath)
+
+	// 読み込みながらフィルタ, 波形整形, 復号する
+	parseSpan := span.child("parse")
+	options, err := csvLoadOptions(option)
+	if err != nil {
+		parseSpan.finish(err)
+		return err
+	}
+	stream := newStreamAnalysis(option)
+	stages := stream.startStages(w, charts, span)
+	defer stages.wait()
+	options.OnRows = stages.push
+	if isStdin(csvfilepath) {
+		var r io.Reader
+		if r, err = openStdin(); err == nil {
+			_, err = waveform.StreamCSVFrom(ctx, r, options)
+		}
+	} else {
+		_, err = waveform.StreamCSV(ctx, csvfilepath, options)
+	}
+	if ctx.Err() != nil {
+		err = checkCanceled(ctx)
+	}
+	parseSpan.finish(err)
+	if err != nil {
+		slog.Error("StreamCSV", "err", err)
+		return err
+	}
+	// フィルタ段と復号段が終わるのを待つ
+	if err := stages.wait(); err != nil {
+		return err
+	}
+	if stream.rows == 0 {
+		return errors.New("データ行がない")
+	}
+
+	// 出力ファイルに埋め込む来歴に入力ファイルを加える(標準入力は読み込んだ後でハッシュが決まる)
+	if option.provenance != nil {
+		if option.provenance, err = option.provenance.withInput(csvfilepath); err != nil {
+			slog.Error("withInput", "err", err)
+			return err
+		}
+	}
+
+	pairBits, pairCodes := stream.finish()
+	option.smoothWindow = stream.option.smoothWindow
+	originTime := stream.originTime
+	duplex := len(stream.pairs) == 2
+	fmt.Fprintf(w, "stream: %d rows in %d chunks, charts from every %d rows\n", stream.rows, stream.chunks, stream.pairs[0].raw.Stride())
+
+	// 時間の表示
+	var clock Clock
+	if option.t0 != "" {
+		if clock.t0, err = parseT0(option.t0); err != nil {
+			slog.Error("parseT0", "err", err)
+			return err
+		}
+		clock.absolute = true
+	} else {
+		clock.t0, clock.absolute = findHeaderTime(stream.header)
+	}
+	clock.originTime = originTime
+
+	// 入力ファイル拡張子を取り除いた基本名(標準入力と--output-prefixの場合は指定の基本名)と指定した出力ファイルの名前
+	basename, ext := outputs.resolveOutputs(csvfilepath, &option)
+
+	// 復号したビットと文字
+	uartBitValues, uartCodes := pairBits[0], pairCodes[0]
+	txUartBitValues := uartBitValues
+	var rxUartBitValues []UartBit
+	if duplex {
+		rxUartBitValues = pairBits[1]
+		uartBitValues = append(append([]UartBit{}, uartBitValues...), rxUartBitValues...)
+		uartCodes = mergeUartCodes(uartCodes, pairCodes[1])
+	}
+
+	// 間引いた測定値のグラフ
+	chartOption := chart.Option{
+		Title:        "A,B線電圧の時間変化",
+		XLabel:       "時間(s)",
+		YLabel:       "電圧(V)",
+		Events:       events,
+		CompressIdle: option.compressIdle * option.format.charTime(baudrate),
+		CodeBits:     option.format.stopBitIndex() + 1,
+		IdleSpace:    option.format.idleSpace,
+		Threshold:    option.threshold,
+		PngTexts:     option.provenance.pngTexts(),
+	}
+	if clock.absolute {
+		chartOption.XLabel = "時刻"
+		chartOption.XToTime = clock.captureTime
+	}
+	matrix := stream.pairs[0].raw.Matrix()
+	if duplex {
+		chartOption.RxMatrix = stream.pairs[1].raw.Matrix()
+	}
+	if charts[ChartRaw] {
+		plots.saveChart(basename+"_"+ext[1:]+"_voltage.png", ChartRaw, graphWidth, graphHeight, chartOption, matrix)
+	}
+	if charts[ChartStacked] {
+		stackedOption := chartOption
+		stackedOption.Title = "A,B線とA-B間電圧差の時間変化"
+		// 3段に分けるので高さを3倍にする
+		plots.saveChart(basename+"_"+ext[1:]+"_stacked.png", ChartStacked, graphWidth, graphHeight*3, stackedOption, matrix)
+	}
+	if charts[ChartDiff] {
+		diffOption := chartOption
+		diffOption.Title = "A-B間電圧差の時間変化"
+		plots.saveChart(basename+"_"+ext[1:]+"_diff.png", ChartDiff, graphWidth, graphHeight, diffOption, matrix)
+	}
+	if filtered := stream.pairs[0].filtered; filtered != nil && filtered.Matrix() != nil {
+		filteredOption := chartOption
+		filteredOption.Title = filterTitles[option.filter]
+		if duplex {
+			filteredOption.RxMatrix = stream.pairs[1].filtered.Matrix()
+		}
+		plots.saveChart(basename+"_"+ext[1:]+"_filtered.png", ChartFiltered, graphWidth, graphHeight, filteredOption, filtered.Matrix())
+	}
+
+	// 波形整形後と復号したビットのグラフ
+	reshaped := reshapedFromBits(txUartBitValues)
+	if duplex {
+		chartOption.RxMatrix = reshapedFromBits(rxUartBitValues)
+	}
+	chartOption.YLabel = "[1,-1]正規化"
+	chartOption.Events = shiftEvents(events, originTime)
+	if clock.absolute {
+		chartOption.XToTime = clock.relativeTime
+	}
+	if charts[ChartReshaped] {
+		chartOption.Title = "波形整形後"
+		plots.saveChart(basename+"_"+ext[1:]+"_reshaped.png", ChartReshaped, graphWidth, graphHeight, chartOption, reshaped)
+	}
+	// 報告とグラフに使うフレームを絞り込む
+	// ターンアラウンドとストップビットは前後のフレームが要るので全てのフレームで測る
+	allFrames := groupFrames(uartCodes, option.format.charTime(baudrate), option.frameGap)
+	frames, err := whereFrames(w, allFrames, option)
+	if err != nil {
+		return err
+	}
+	if charts[ChartUart] {
+		chartOption.Title = "UART通信"
+		shownCodes, shownBits := uartCodes, uartBitValues
+		if option.where != "" {
+			shownCodes = frameCodes(frames)
+			shownBits = codeBits(uartBitValues, shownCodes)
+		}
+		chartOption.Bits = chartBits(shownBits)
+		chartOption.Codes = chartCodes(shownCodes)
+		chartOption.BitPeriod = 1 / float64(baudrate)
+		if option.frameTable {
+			chartOption.FrameTable = frameTableRows(frames, uartBitValues, clock, option.crcKind)
+		}
+		plots.saveChart(basename+"_"+ext[1:]+"_uart.png", ChartUart, graphWidth, graphHeight, chartOption, reshaped)
+	}
+
+	// 表示
+	printDecodedCodes(w, clock, uartCodes, duplex, option)
+
+	// 雑音の床で0にした行
+	if duplex {
+		printNoiseGate(w, " "+DirectionTx, stream.pairs[0].gate)
+		printNoiseGate(w, " "+DirectionRx, stream.pairs[1].gate)
+	} else {
+		printNoiseGate(w, "", stream.pairs[0].gate)
+	}
+
+	// 送信の途中で始まった取り込みの読み飛ばし
+	resyncs := resyncAnomalies(stream.pairs[0].resync.resync(), originTime)
+	if duplex {
+		printResync(w, clock, " "+DirectionTx, stream.pairs[0].resync.resync())
+		printResync(w, clock, " "+DirectionRx, stream.pairs[1].resync.resync())
+		resyncs = append(resyncs, resyncAnomalies(stream.pairs[1].resync.resync(), originTime)...)
+	} else {
+		printResync(w, clock, "", stream.pairs[0].resync.resync())
+	}
+
+	// 取り込みの終わりで途切れた文字とフレーム
+	captureStart, captureEnd := stream.captureStart-originTime, stream.captureEnd-originTime
+	truncations := reportTruncations(w, clock, txUartBitValues, rxUartBitValues, duplex, captureEnd, baudrate, option)
+
+	// 復号した結果を機械可読な形式で保存する
+	if err := saveDecodedAndCheckStrict(w, basename+"_"+ext[1:], clock, uartBitValues, txUartBitValues, rxUartBitValues, uartCodes, frames, allFrames, baudrate, option); err != nil {
+		return err
+	}
+
+	// ターンアラウンド
+	// 測定値の全体が無いのでドライバイネーブルの列は使わない
+	minTurnaround := option.minTurnaround
+	if minTurnaround == 0 {
+		minTurnaround = 3.5 * option.format.charTime(baudrate)
+	}
+	turnarounds := analyzeTurnaround(nil, originTime, allFrames, minTurnaround)
+	printTurnaround(w, clock, allFrames, turnarounds)
+
+	// ストップビットの数と文字間の無通信時間
+	reportStopBits(w, allFrames, duplex, baudrate, option.format)
+
+	// フレームの保存
+	if err := saveFrameOutputs(w, clock, frames, option); err != nil {
+		return err
+	}
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+
+	// 検出した異常
+	anomalies := framingAnomalies(uartBitValues)
+	anomalies = append(anomalies, parityAnomalies(uartCodes)...)
+	anomalies = append(anomalies, turnaroundAnomalies(turnarounds)...)
+	anomalies = append(anomalies, resyncs...)
+	anomalies = append(anomalies, truncations...)
+	if option.crcKind != CrcNone {
+		crcErrors := crcAnomalies(frames, option.crcKind)
+		fmt.Fprintf(w, "crc errors: %d\n", len(crcErrors))
+		anomalies = append(anomalies, crcErrors...)
+	}
+	fmt.Fprintln(w, "stream: waveform measurements skipped (glitches, bit length, duty asymmetry, runts, bus states, slew rate)")
+
+	// フレームからの報告とグラフ
+	_, frameAnomalies, err := reportFrames(ctx, w, plots, FrameReportInput{
+		prefix:       basename + "_" + ext[1:],
+		clock:        clock,
+		frames:       frames,
+		codes:        uartCodes,
+		captureStart: captureStart,
+		captureEnd:   captureEnd,
+		originTime:   originTime,
+		duplex:       duplex,
+		events:       events,
+		chartOption:  chartOption,
+		charts:       charts,
+		baudrate:     baudrate,
+	}, option)
+	if err != nil {
+		return err
+	}
+	anomalies = append(anomalies, frameAnomalies...)
+
+	// ビット誤り率試験
+	reportBert(w, clock, uartCodes, duplex, baudrate, option)
+
+	// 異常の一覧
+	if option.anomalyFile != "" {
+		if err := saveAnomalies(option.anomalyFile, clock, anomalies, option.provenance); err != nil {
+			slog.Error("saveAnomalies", "err", err)
+			return err
+		}
+	}
+	// 描画段が終わるのを待つ
+	if err := plots.wait(); err != nil {
+		slog.Error("plot", "err", err)
+		return err
+	}
+
+	if option.failOnError && hasErrorAnomaly(anomalies) {
+		return cli.Exit("重大な異常を検出した", 1)
+	}
+	return nil
+}
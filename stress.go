@@ -0,0 +1,264 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 測定値に雑音とジッタを少しずつ加えて復号し直し、どこから復号に失敗するか(通信路の余裕)を調べる
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+
+	"pulseinsight/pkg/waveform"
+)
+
+// 劣化のさせ方
+type StressOption struct {
+	steps      int     // 劣化の段階数
+	trials     int     // 段階毎に乱数を変えて復号する回数
+	noiseStep  float64 // 1段階で増やすA線とB線それぞれの雑音の標準偏差(V)
+	jitterStep float64 // 1段階で増やすエッジの時間のずれの標準偏差(ビット周期に対する比)
+	seed       int64   // 乱数の種
+}
+
+// 1段階の結果
+type StressLevel struct {
+	noise         float64 // 雑音の標準偏差(V)
+	jitter        float64 // ジッタの標準偏差(ビット周期に対する比)
+	bytes         int     // 復号したバイト数(全ての試行の平均)
+	byteErrors    float64 // 元の復号と違うバイト数(全ての試行の平均)
+	framingErrors float64 // ストップビットが0の文字の数(全ての試行の平均)
+	failed        int     // 失敗した試行の数
+}
+
+// A線とB線に正規分布の雑音を加える
+func addStressNoise(matrix *mat.Dense, sigma float64, random *rand.Rand) {
+	if sigma <= 0 {
+		return
+	}
+	rows, _ := matrix.Dims()
+	for r := 0; r < rows; r++ {
+		matrix.Set(r, ColWireA, matrix.At(r, ColWireA)+random.NormFloat64()*sigma)
+		matrix.Set(r, ColWireB, matrix.At(r, ColWireB)+random.NormFloat64()*sigma)
+	}
+}
+
+// エッジ(A,B間電圧差がしきい値を越えて反対側に移った行)をずらす
+// エッジを遅らせる場合は前の値を, 早める場合は後の値を引き延ばす
+func addStressJitter(matrix *mat.Dense, sigma float64, baudrate int, random *rand.Rand) {
+	rows, _ := matrix.Dims()
+	if sigma <= 0 || rows < 2 {
+		return
+	}
+	dt := (matrix.At(rows-1, ColTime) - matrix.At(0, ColTime)) / float64(rows-1)
+	if dt <= 0 {
+		return
+	}
+	diff := waveform.Differential(nil, matrix)
+	edges := []int{}
+	level := 0
+	for r := range diff {
+		current := level
+		if diff[r] > Threshould {
+			current = 1
+		} else if diff[r] < -Threshould {
+			current = -1
+		}
+		if current != level && level != 0 {
+			edges = append(edges, r)
+		}
+		level = current
+	}
+
+	original := mat.DenseCopyOf(matrix)
+	copyRow := func(to int, from int) {
+		matrix.Set(to, ColWireA, original.At(from, ColWireA))
+		matrix.Set(to, ColWireB, original.At(from, ColWireB))
+	}
+	for k, e := range edges {
+		// 隣のエッジを越えないようにずらす
+		lower, upper := 1, rows-1
+		if k > 0 {
+			lower = edges[k-1] + 1
+		}
+		if k+1 < len(edges) {
+			upper = edges[k+1] - 1
+		}
+		shift := int(math.Round(random.NormFloat64() * sigma / float64(baudrate) / dt))
+		moved := max(lower, min(e+shift, upper))
+		for r := e; r < moved; r++ {
+			copyRow(r, e-1)
+		}
+		for r := moved; r < e; r++ {
+			copyRow(r, e)
+		}
+	}
+}
+
+// 元の復号と違うバイト数
+// 1バイト欠けただけで後ろが全て違うと数えないように, 挿入と削除と置換の最小回数(編集距離)を数える
+// 計算量を抑えるため, ずれが長さの差より16バイト以上大きくなる並べ方は調べない
+func countByteErrors(reference []byte, decoded []byte) int {
+	n, m := len(reference), len(decoded)
+	band := max(n-m, m-n) + 16
+	const far = math.MaxInt / 2
+	previous := make([]int, m+1)
+	current := make([]int, m+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= n; i++ {
+		for j := range current {
+			current[j] = far
+		}
+		if i <= band {
+			current[0] = i
+		}
+		for j := max(1, i-band); j <= min(m, i+band); j++ {
+			cost := 1
+			if reference[i-1] == decoded[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j-1]+cost, previous[j]+1, current[j-1]+1)
+		}
+		previous, current = current, previous
+	}
+	return previous[m]
+}
+
+// グラフを描かずに復号してバイト列とフレーミングエラーの数を返す
+func stressDecode(matrix *mat.Dense, option InsightOption) ([]byte, int, error) {
+	filtered, err := applyFilter(matrix, option)
+	if err != nil {
+		slog.Error("applyFilter", "err", err)
+		return nil, 0, err
+	}
+	return decodeOctets(matrix, filtered, option)
+}
+
+// フィルタ後の測定値を受け取って, グラフを描かずに復号してバイト列とフレーミングエラーの数を返す
+func decodeOctets(matrix *mat.Dense, filtered mat.Matrix, option InsightOption) ([]byte, int, error) {
+	reshaped, _, _, err := decodeWaveforms(matrix, nil, filtered, nil, option)
+	if err != nil {
+		slog.Error("decodeWaveforms", "err", err)
+		return nil, 0, err
+	}
+	bits, codes, err := analyzePulses(reshaped, option.format, option.decodeMode)
+	if err != nil {
+		slog.Error("analyzePulses", "err", err)
+		return nil, 0, err
+	}
+	octets := make([]byte, len(codes))
+	for i, c := range codes {
+		octets[i] = c.octet
+	}
+	framingErrors := 0
+	for _, b := range bits {
+		if b.state == "X" {
+			framingErrors++
+		}
+	}
+	return octets, framingErrors, nil
+}
+
+// 雑音とジッタを加えてtrials回復号し, 元の復号と比べる
+// 元の復号と違うバイトがあるか, フレーミングエラーが元より増えた試行を失敗とする
+func stressLevel(matrix *mat.Dense, reference []byte, referenceFraming int, noise float64, jitter float64, option InsightOption, stress StressOption, random *rand.Rand) (StressLevel, error) {
+	level := StressLevel{noise: noise, jitter: jitter}
+	bytes := 0
+	for i := 0; i < stress.trials; i++ {
+		degraded := mat.DenseCopyOf(matrix)
+		addStressJitter(degraded, jitter, option.baudrate, random)
+		addStressNoise(degraded, noise, random)
+		decoded, framingErrors, err := stressDecode(degraded, option)
+		if err != nil {
+			return level, err
+		}
+		byteErrors := countByteErrors(reference, decoded)
+		bytes += len(decoded)
+		level.byteErrors += float64(byteErrors)
+		level.framingErrors += float64(framingErrors)
+		if byteErrors != 0 || framingErrors > referenceFraming {
+			level.failed++
+		}
+	}
+	level.bytes = bytes / stress.trials
+	level.byteErrors /= float64(stress.trials)
+	level.framingErrors /= float64(stress.trials)
+	return level, nil
+}
+
+// 段階毎の結果と, 失敗しなかった最も強い劣化を表示する
+func printStressSweep(w io.Writer, name string, unit string, levels []StressLevel, trials int, value func(StressLevel) float64) {
+	fmt.Fprintf(w, "%s sweep:\n", name)
+	fmt.Fprintf(w, "  %10s %8s %12s %15s %7s\n", name+"("+unit+")", "bytes", "byte errors", "framing errors", "failed")
+	margin := -1
+	for i, l := range levels {
+		fmt.Fprintf(w, "  %10.3f %8d %12.1f %15.1f %3d/%d\n", value(l), l.bytes, l.byteErrors, l.framingErrors, l.failed, trials)
+		if l.failed == 0 && margin == i-1 {
+			margin = i
+		}
+	}
+	switch {
+	case margin < 0:
+		fmt.Fprintf(w, "  %s margin: fails without degradation\n", name)
+	case margin == len(levels)-1:
+		fmt.Fprintf(w, "  %s margin: > %.3f%s (no failure)\n", name, value(levels[margin]), unit)
+	default:
+		fmt.Fprintf(w, "  %s margin: %.3f%s (fails from %.3f%s)\n", name, value(levels[margin]), unit, value(levels[margin+1]), unit)
+	}
+}
+
+// 測定値に雑音とジッタを段階的に加えて復号し直す
+// 雑音だけを増やす場合とジッタだけを増やす場合を別々に調べる
+// 全二重の場合は送信対だけを調べる
+func runStress(ctx context.Context, w io.Writer, csvfilepath string, option InsightOption, stress StressOption) error {
+	matrix, _, err := prepareInputMatrix(ctx, io.Discard, csvfilepath, option)
+	if err != nil {
+		slog.Error("prepareInputMatrix", "err", err)
+		return err
+	}
+	if isDuplex(matrix) {
+		matrix, _ = splitDuplex(matrix)
+		fmt.Fprintln(w, "stress: full duplex, TX pair only")
+	}
+	if option.filter == FilterSma && option.smoothWindow == 0 {
+		option.smoothWindow = autoSmoothingWindow(matrix, option.baudrate)
+	}
+
+	reference, framingErrors, err := stressDecode(matrix, option)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "stress: \"%s\"  baseline %d bytes  %d framing errors  %d steps x %d trials\n",
+		csvfilepath, len(reference), framingErrors, stress.steps, stress.trials)
+	if len(reference) == 0 {
+		return fmt.Errorf("元の測定値から1バイトも復号できない")
+	}
+
+	random := rand.New(rand.NewSource(stress.seed))
+	noiseLevels := []StressLevel{}
+	jitterLevels := []StressLevel{}
+	for step := 0; step <= stress.steps; step++ {
+		l, err := stressLevel(matrix, reference, framingErrors, float64(step)*stress.noiseStep, 0, option, stress, random)
+		if err != nil {
+			return err
+		}
+		noiseLevels = append(noiseLevels, l)
+	}
+	for step := 0; step <= stress.steps; step++ {
+		l, err := stressLevel(matrix, reference, framingErrors, 0, float64(step)*stress.jitterStep, option, stress, random)
+		if err != nil {
+			return err
+		}
+		jitterLevels = append(jitterLevels, l)
+	}
+	printStressSweep(w, "noise", "V", noiseLevels, stress.trials, func(l StressLevel) float64 { return l.noise })
+	printStressSweep(w, "jitter", "UI", jitterLevels, stress.trials, func(l StressLevel) float64 { return l.jitter })
+	return nil
+}
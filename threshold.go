@@ -0,0 +1,31 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号のしきい値(波形整形でMarkとSpaceを分けるA-B間電圧差)
+package main
+
+// 復号のしきい値(V)
+// 0の場合は差動通信のしきい値(Threshould)
+func decisionThreshold(threshold float64) float64 {
+	if threshold > 0 {
+		return threshold
+	}
+	return Threshould
+}
+
+// 復号のしきい値に合わせたA,B間電圧差の倍率
+// 波形整形は差動通信のしきい値(Threshould)で分けるので, しきい値を変える代わりにA,B間電圧差を拡大縮小する
+func decisionGain(threshold float64) float64 {
+	return Threshould / decisionThreshold(threshold)
+}
+
+// A,B間電圧差を復号のしきい値に合わせて拡大縮小する
+func scaleDecisionDiff(diff []float64, threshold float64) {
+	gain := decisionGain(threshold)
+	if gain == 1 {
+		return
+	}
+	for i := range diff {
+		diff[i] *= gain
+	}
+}
@@ -0,0 +1,296 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 長い波形を拡大縮小して見るためのタイル画像ピラミッドとHTMLビューア
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image/color"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// タイルに描く波形
+type TileTrace struct {
+	matrix    mat.Matrix
+	col       int
+	name      string
+	lineColor color.Color
+}
+
+// タイル画像ピラミッドの構成
+// レベルzは全体を横に2^z枚のタイルに分け、最も深いレベルで1サンプルが1ピクセル以上になる
+type TilePyramid struct {
+	StartTime  float64      `json:"startTime"`
+	EndTime    float64      `json:"endTime"`
+	TileWidth  int          `json:"tileWidth"`
+	TileHeight int          `json:"tileHeight"`
+	MaxLevel   int          `json:"maxLevel"`
+	Traces     []TileLegend `json:"traces"`
+	Provenance *Provenance  `json:"provenance,omitempty"`
+}
+
+// ビューアに表示する凡例
+type TileLegend struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// タイル画像ピラミッドを作る
+func newTilePyramid(traces []TileTrace, tileWidth int, tileHeight int) TilePyramid {
+	matrix := traces[0].matrix
+	rows, _ := matrix.Dims()
+	pyramid := TilePyramid{
+		StartTime:  matrix.At(0, ColTime),
+		EndTime:    matrix.At(rows-1, ColTime),
+		TileWidth:  tileWidth,
+		TileHeight: tileHeight,
+	}
+	for tiles := (rows + tileWidth - 1) / tileWidth; 1<<pyramid.MaxLevel < tiles; {
+		pyramid.MaxLevel++
+	}
+	for _, t := range traces {
+		r, g, b, _ := t.lineColor.RGBA()
+		pyramid.Traces = append(pyramid.Traces, TileLegend{
+			Name:  t.name,
+			Color: fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8),
+		})
+	}
+	return pyramid
+}
+
+// 時間範囲の波形をピクセルごとの最小値と最大値に間引く
+func decimateTrace(t TileTrace, begin float64, end float64, pixels int) plotter.XYs {
+	rows, _ := t.matrix.Dims()
+	xys := plotter.XYs{}
+	// タイルの境目で線が途切れないように範囲外の1サンプルも含める
+	first := max(0, findRowAtTime(t.matrix, begin)-1)
+	last := min(rows-1, findRowAtTime(t.matrix, end)+1)
+	if last-first+1 <= 2*pixels {
+		for r := first; r <= last; r++ {
+			xys = append(xys, plotter.XY{X: t.matrix.At(r, ColTime), Y: t.matrix.At(r, t.col)})
+		}
+		return xys
+	}
+	width := (end - begin) / float64(pixels)
+	for r := first; r <= last; {
+		pixel := math.Floor((t.matrix.At(r, ColTime) - begin) / width)
+		lo, hi := r, r
+		for r++; r <= last && math.Floor((t.matrix.At(r, ColTime)-begin)/width) == pixel; r++ {
+			if t.matrix.At(r, t.col) < t.matrix.At(lo, t.col) {
+				lo = r
+			}
+			if t.matrix.At(r, t.col) > t.matrix.At(hi, t.col) {
+				hi = r
+			}
+		}
+		// 時間順に並べる
+		lo, hi = min(lo, hi), max(lo, hi)
+		xys = append(xys, plotter.XY{X: t.matrix.At(lo, ColTime), Y: t.matrix.At(lo, t.col)})
+		if hi != lo {
+			xys = append(xys, plotter.XY{X: t.matrix.At(hi, ColTime), Y: t.matrix.At(hi, t.col)})
+		}
+	}
+	return xys
+}
+
+// 時間以降の最初の行を探す
+func findRowAtTime(matrix mat.Matrix, t float64) int {
+	rows, _ := matrix.Dims()
+	lo, hi := 0, rows
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if matrix.At(mid, ColTime) < t {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// タイル1枚を保存する
+func saveTile(savefilepath string, pyramid TilePyramid, traces []TileTrace, begin float64, end float64, yMin float64, yMax float64) error {
+	p := plot.New()
+	p.BackgroundColor = colornames.Snow
+	p.HideAxes()
+	p.X.Padding, p.Y.Padding = 0, 0
+	p.X.Min, p.X.Max = begin, end
+	p.Y.Min, p.Y.Max = yMin, yMax
+
+	for _, t := range traces {
+		line, err := plotter.NewLine(decimateTrace(t, begin, end, pyramid.TileWidth))
+		if err != nil {
+			slog.Error("NewLine", "err", err)
+			return err
+		}
+		line.Color = t.lineColor
+		p.Add(line)
+	}
+
+	canvas := vgimg.NewWith(vgimg.UseWH(vg.Length(pyramid.TileWidth), vg.Length(pyramid.TileHeight)), vgimg.UseDPI(72))
+	p.Draw(draw.New(canvas))
+
+	var buf bytes.Buffer
+	if _, err := (vgimg.PngCanvas{Canvas: canvas}).WriteTo(&buf); err != nil {
+		slog.Error("WriteTo", "err", err)
+		return err
+	}
+	png := buf.Bytes()
+	// 来歴を埋め込む
+	if pyramid.Provenance != nil {
+		var err error
+		if png, err = chart.EmbedPngTexts(png, pyramid.Provenance.pngTexts()); err != nil {
+			slog.Error("EmbedPngTexts", "err", err)
+			return err
+		}
+	}
+	if err := os.WriteFile(savefilepath, png, 0o644); err != nil {
+		slog.Error("WriteFile", "err", err)
+		return err
+	}
+	return nil
+}
+
+// タイル画像ピラミッドとHTMLビューアをディレクトリに保存する
+// タイルはディレクトリ/レベル/番号.pngに置く
+func saveTilePyramid(dirpath string, traces []TileTrace, tileWidth int, tileHeight int, provenance *Provenance) error {
+	pyramid := newTilePyramid(traces, tileWidth, tileHeight)
+	pyramid.Provenance = provenance
+
+	// 全てのタイルで縦軸を揃える
+	yMin, yMax := math.Inf(1), math.Inf(-1)
+	for _, t := range traces {
+		rows, _ := t.matrix.Dims()
+		for r := 0; r < rows; r++ {
+			yMin = min(yMin, t.matrix.At(r, t.col))
+			yMax = max(yMax, t.matrix.At(r, t.col))
+		}
+	}
+	margin := max((yMax-yMin)*0.05, 0.1)
+	yMin, yMax = yMin-margin, yMax+margin
+
+	duration := pyramid.EndTime - pyramid.StartTime
+	for level := 0; level <= pyramid.MaxLevel; level++ {
+		levelDir := filepath.Join(dirpath, fmt.Sprint(level))
+		if err := os.MkdirAll(levelDir, 0o755); err != nil {
+			slog.Error("MkdirAll", "err", err)
+			return err
+		}
+		tiles := 1 << level
+		for i := 0; i < tiles; i++ {
+			begin := pyramid.StartTime + duration*float64(i)/float64(tiles)
+			end := pyramid.StartTime + duration*float64(i+1)/float64(tiles)
+			tileFile := filepath.Join(levelDir, fmt.Sprintf("%d.png", i))
+			if err := saveTile(tileFile, pyramid, traces, begin, end, yMin, yMax); err != nil {
+				return err
+			}
+		}
+	}
+
+	file, err := os.Create(filepath.Join(dirpath, "index.html"))
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer file.Close()
+	if err := tileViewerTemplate.Execute(file, pyramid); err != nil {
+		slog.Error("Execute", "err", err)
+		return err
+	}
+	return nil
+}
+
+// タイル画像ピラミッドのビューア
+// ホイールで拡大縮小し、横スクロールで移動する。タイルは見える範囲だけ読み込む
+var tileViewerTemplate = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pulseinsight</title>
+<style>
+body { margin: 0; font-family: sans-serif; }
+#bar { padding: 4px 8px; background: #eee; }
+#bar span { margin-right: 1em; }
+#view { overflow-x: auto; overflow-y: hidden; width: 100vw; }
+#strip { position: relative; }
+#strip img { position: absolute; top: 0; }
+</style>
+</head>
+<body>
+<div id="bar">
+<button id="out">-</button> <button id="in">+</button>
+<span id="level"></span><span id="range"></span>
+{{range .Traces}}<span style="color: {{.Color}}">━ {{.Name}}</span>{{end}}
+</div>
+<div id="view"><div id="strip"></div></div>
+<script>
+const pyramid = {{.}};
+const view = document.getElementById("view");
+const strip = document.getElementById("strip");
+strip.style.height = view.style.height = pyramid.tileHeight + "px";
+let level = 0;
+
+function show(newLevel, center) {
+  level = Math.max(0, Math.min(pyramid.maxLevel, newLevel));
+  const tiles = 1 << level;
+  strip.replaceChildren();
+  strip.style.width = tiles * pyramid.tileWidth + "px";
+  for (let i = 0; i < tiles; i++) {
+    const img = document.createElement("img");
+    img.loading = "lazy";
+    img.width = pyramid.tileWidth;
+    img.height = pyramid.tileHeight;
+    img.style.left = i * pyramid.tileWidth + "px";
+    img.src = level + "/" + i + ".png";
+    strip.appendChild(img);
+  }
+  view.scrollLeft = center * strip.scrollWidth - view.clientWidth / 2;
+  update();
+}
+
+function center() {
+  return (view.scrollLeft + view.clientWidth / 2) / strip.scrollWidth;
+}
+
+function update() {
+  const duration = pyramid.endTime - pyramid.startTime;
+  const begin = pyramid.startTime + duration * view.scrollLeft / strip.scrollWidth;
+  const end = pyramid.startTime + duration * (view.scrollLeft + view.clientWidth) / strip.scrollWidth;
+  document.getElementById("level").textContent = "level " + level + "/" + pyramid.maxLevel;
+  document.getElementById("range").textContent = begin.toFixed(6) + "s .. " + end.toFixed(6) + "s";
+}
+
+document.getElementById("in").onclick = () => show(level + 1, center());
+document.getElementById("out").onclick = () => show(level - 1, center());
+view.addEventListener("scroll", update);
+view.addEventListener("wheel", (e) => {
+  if (e.ctrlKey || Math.abs(e.deltaY) > Math.abs(e.deltaX)) {
+    e.preventDefault();
+    const rect = view.getBoundingClientRect();
+    const at = (view.scrollLeft + e.clientX - rect.left) / strip.scrollWidth;
+    const offset = (e.clientX - rect.left) - view.clientWidth / 2;
+    const next = Math.max(0, Math.min(pyramid.maxLevel, level + (e.deltaY < 0 ? 1 : -1)));
+    show(next, at - offset / ((1 << next) * pyramid.tileWidth));
+  }
+}, { passive: false });
+show(0, 0.5);
+</script>
+</body>
+</html>
+`))
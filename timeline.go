@@ -0,0 +1,101 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 送信元ごとのフレームの占有時間を示すタイムライン(ガントチャート)
+package main
+
+import (
+	"log/slog"
+	"sort"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// タイムラインのグラフを保存する
+func saveTimelineChart(savefilepath string, graphWidth int, graphHeight int, option chart.Option, frames []UartFrame, addressByte int) error {
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// 横軸を絶対時刻で表示する
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+
+	// 送信元ごとに行を割り当てる
+	talkers := []string{}
+	rowOf := map[string]int{}
+	for _, f := range frames {
+		name := f.talker(addressByte)
+		if _, ok := rowOf[name]; !ok {
+			rowOf[name] = len(talkers)
+			talkers = append(talkers, name)
+		}
+	}
+	sort.Strings(talkers)
+	ticks := make([]plot.Tick, len(talkers))
+	for i, name := range talkers {
+		rowOf[name] = i
+		ticks[i] = plot.Tick{Value: float64(i), Label: name}
+	}
+	p.Y.Tick.Marker = plot.ConstantTicks(ticks)
+	p.Y.Min = -1
+	p.Y.Max = float64(len(talkers))
+
+	// フレームの占有時間を帯で示す
+	for _, f := range frames {
+		row := float64(rowOf[f.talker(addressByte)])
+		bar, err := plotter.NewPolygon(plotter.XYs{
+			{X: f.startTime, Y: row - 0.35},
+			{X: f.endTime, Y: row - 0.35},
+			{X: f.endTime, Y: row + 0.35},
+			{X: f.startTime, Y: row + 0.35},
+		})
+		if err != nil {
+			slog.Error("NewPolygon", "err", err)
+			return err
+		}
+		bar.Color = colornames.Steelblue
+		bar.LineStyle.Color = colornames.Darkblue
+		p.Add(bar)
+	}
+
+	// 外部イベントを縦線で示す
+	if err := chart.AddEventMarkers(p, option.Events); err != nil {
+		return err
+	}
+
+	// 横軸の単位を表示範囲に合わせる
+	chart.ScaleTimeAxis(p, option)
+
+	// 長い無通信時間を詰める
+	if option.CompressIdle > 0 {
+		spans := make([][2]float64, len(frames))
+		for i, f := range frames {
+			spans[i] = [2]float64{f.startTime, f.endTime}
+		}
+		chart.CompressIdleTime(p, spans, option.CompressIdle)
+	}
+
+	// プロットを画像ファイルに保存
+	if err := p.Save(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)), savefilepath); err != nil {
+		slog.Error("Save", "err", err)
+		return err
+	}
+	// 来歴を埋め込む
+	if err := embedPngFileTexts(savefilepath, option.PngTexts); err != nil {
+		return err
+	}
+
+	return nil
+}
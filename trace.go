@@ -0,0 +1,224 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 解析の段(読み込み, フィルタ, 波形整形, 復号, 描画)の時間をOpenTelemetryのトレースとして書き出す
+// トレースはOTLP/JSON(OpenTelemetry Collectorのotlpjsonfileレシーバーで読める1行1リクエストの形式)で書く
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// トレースのサービス名
+const TraceServiceName = "pulseinsight"
+
+// 親のトレースを指定する環境変数(W3C Trace Contextのtraceparentの書式)
+const TraceParentEnv = "TRACEPARENT"
+
+// OTLPのスパンの種類(SPAN_KIND_INTERNAL)とステータス(STATUS_CODE_ERROR)
+const (
+	TraceSpanKindInternal = 1
+	TraceStatusError      = 2
+)
+
+// トレース
+// 解析するCSVファイル毎に作り, スパンは複数のゴルーチンから作ってよい
+type Tracer struct {
+	mu      sync.Mutex
+	traceID string       // トレースID(16進数32文字)
+	parent  string       // 親のスパンID(TRACEPARENTで指定した場合), 空の場合は無い
+	spans   []*TraceSpan // 作ったスパン
+}
+
+// スパン
+// nilの場合は何もしない(トレースを書き出さない場合)
+type TraceSpan struct {
+	tracer     *Tracer
+	name       string
+	spanID     string // スパンID(16進数16文字)
+	parentID   string // 親のスパンID, 空の場合はルート
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+// 16進数のランダムなID
+func randomTraceID(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// トレースを始める
+// 環境変数TRACEPARENTが有れば, そのトレースの子にする
+func newTracer() *Tracer {
+	tracer := &Tracer{traceID: randomTraceID(16)}
+	if fields := strings.Split(os.Getenv(TraceParentEnv), "-"); len(fields) == 4 && len(fields[1]) == 32 && len(fields[2]) == 16 {
+		tracer.traceID, tracer.parent = fields[1], fields[2]
+	}
+	return tracer
+}
+
+// ルートのスパンを始める
+func (t *Tracer) start(name string) *TraceSpan {
+	if t == nil {
+		return nil
+	}
+	return t.newSpan(name, t.parent)
+}
+
+func (t *Tracer) newSpan(name string, parentID string) *TraceSpan {
+	span := &TraceSpan{
+		tracer:     t,
+		name:       name,
+		spanID:     randomTraceID(8),
+		parentID:   parentID,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return span
+}
+
+// 子のスパンを始める
+func (s *TraceSpan) child(name string) *TraceSpan {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(name, s.spanID)
+}
+
+// 属性を付ける
+func (s *TraceSpan) setAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	s.attributes[key] = value
+	s.tracer.mu.Unlock()
+}
+
+// スパンを終える
+// errがnilでなければ失敗として記録する
+func (s *TraceSpan) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	s.end, s.err = time.Now(), err
+	s.tracer.mu.Unlock()
+}
+
+// OTLP/JSONの属性
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := []string{}
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := []otlpAttribute{}
+	for _, key := range keys {
+		a := otlpAttribute{Key: key}
+		a.Value.StringValue = attributes[key]
+		list = append(list, a)
+	}
+	return list
+}
+
+// OTLP/JSONのスパン
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// トレースをOTLP/JSONの1行にする
+// 終わっていないスパンは今の時刻で終える
+func (t *Tracer) marshal(version string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	spans := []otlpSpan{}
+	for _, s := range t.spans {
+		end := s.end
+		if end.IsZero() {
+			end = now
+		}
+		span := otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              TraceSpanKindInternal,
+			StartTimeUnixNano: fmt.Sprintf("%d", s.start.UnixNano()),
+			EndTimeUnixNano:   fmt.Sprintf("%d", end.UnixNano()),
+			Attributes:        otlpAttributes(s.attributes),
+		}
+		if s.err != nil {
+			span.Status = &otlpStatus{Code: TraceStatusError, Message: s.err.Error()}
+		}
+		spans = append(spans, span)
+	}
+	service := otlpAttribute{Key: "service.name"}
+	service.Value.StringValue = TraceServiceName
+	request := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttribute{service}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": TraceServiceName, "version": version},
+				"spans": spans,
+			}},
+		}},
+	}
+	return json.Marshal(request)
+}
+
+// トレースをファイルの末尾に1行で書き足す
+func (t *Tracer) save(savefilepath string, version string) error {
+	line, err := t.marshal(version)
+	if err != nil {
+		slog.Error("marshal", "err", err)
+		return err
+	}
+	f, err := os.OpenFile(savefilepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		slog.Error("OpenFile", "err", err)
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return f.Close()
+}
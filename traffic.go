@@ -0,0 +1,214 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 通信量の統計
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"pulseinsight/pkg/chart"
+)
+
+// 通信量の統計
+type TrafficSummary struct {
+	duration     float64            // キャプチャの長さ(s)
+	bytes        int                // バイト数
+	frames       int                // フレーム数
+	busyTime     map[string]float64 // 通信方向ごとのバスを占有した時間(s), 半二重は空文字列
+	talkerFrames map[string]int     // 送信元ごとのフレーム数
+}
+
+// 通信量を集計する
+func summarizeTraffic(frames []UartFrame, duration float64, addressByte int) TrafficSummary {
+	summary := TrafficSummary{
+		duration:     duration,
+		frames:       len(frames),
+		busyTime:     map[string]float64{},
+		talkerFrames: map[string]int{},
+	}
+	for _, f := range frames {
+		summary.talkerFrames[f.talker(addressByte)]++
+		for _, c := range f.codes {
+			summary.bytes++
+			summary.busyTime[c.direction] += c.endTime - c.startTime
+		}
+	}
+	return summary
+}
+
+// 通信量の統計を表示する
+func printTrafficSummary(w io.Writer, summary TrafficSummary) {
+	fmt.Fprintf(w, "traffic: duration %.6fs  %d bytes  %d frames\n", summary.duration, summary.bytes, summary.frames)
+	if summary.duration <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "  %.1f bytes/s  %.1f frames/s\n",
+		float64(summary.bytes)/summary.duration, float64(summary.frames)/summary.duration)
+
+	directions := make([]string, 0, len(summary.busyTime))
+	for direction := range summary.busyTime {
+		directions = append(directions, direction)
+	}
+	sort.Strings(directions)
+	for _, direction := range directions {
+		label := ""
+		if direction != "" {
+			label = " " + direction
+		}
+		fmt.Fprintf(w, "  utilization%s %.2f%%\n", label, 100*summary.busyTime[direction]/summary.duration)
+	}
+
+	talkers := make([]string, 0, len(summary.talkerFrames))
+	for talker := range summary.talkerFrames {
+		talkers = append(talkers, talker)
+	}
+	sort.Strings(talkers)
+	for _, talker := range talkers {
+		fmt.Fprintf(w, "  %s  %d frames\n", talker, summary.talkerFrames[talker])
+	}
+}
+
+// 送信元から宛先への通信量
+type TrafficLink struct {
+	from   string // 送信元(全二重は通信方向, 半二重は主局かアドレス)
+	to     string // 宛先
+	frames int    // フレーム数
+	bytes  int    // バイト数
+}
+
+// 送信元と宛先の組ごとに通信量を集計する
+func trafficMatrix(messages []SequenceMessage) []TrafficLink {
+	index := map[[2]string]int{}
+	links := []TrafficLink{}
+	for _, m := range messages {
+		key := [2]string{m.from, m.to}
+		i, ok := index[key]
+		if !ok {
+			i = len(links)
+			index[key] = i
+			links = append(links, TrafficLink{from: m.from, to: m.to})
+		}
+		links[i].frames++
+		links[i].bytes += len(m.frame.codes)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].from != links[j].from {
+			return links[i].from < links[j].from
+		}
+		return links[i].to < links[j].to
+	})
+	return links
+}
+
+// 送信元と宛先の組ごとの通信量を表示する
+func printTrafficMatrix(w io.Writer, links []TrafficLink) {
+	fmt.Fprintf(w, "traffic matrix: %d links\n", len(links))
+	fmt.Fprintf(w, "  %-8s    %-8s %8s %10s\n", "from", "to", "frames", "bytes")
+	for _, l := range links {
+		fmt.Fprintf(w, "  %-8s -> %-8s %8d %10d\n", l.from, l.to, l.frames, l.bytes)
+	}
+}
+
+// 送信元と宛先の組ごとの通信量をCSVファイルに保存する
+func saveTrafficMatrix(savefilepath string, links []TrafficLink) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"from", "to", "frames", "bytes"})
+	for _, l := range links {
+		writer.Write([]string{l.from, l.to, strconv.Itoa(l.frames), strconv.Itoa(l.bytes)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
+
+// 区間ごとのバス使用率(%)
+// 区間startTimeからendTimeまでをwindow秒ごとに区切る
+func utilizationOverTime(codes []UartCode, startTime float64, endTime float64, window float64) plotter.XYs {
+	if window <= 0 || endTime <= startTime {
+		return plotter.XYs{}
+	}
+	n := int((endTime-startTime)/window) + 1
+	busy := make([]float64, n)
+	for _, c := range codes {
+		// 区間をまたぐバイトは区間ごとに分けて足す
+		for t := c.startTime; t < c.endTime; {
+			i := int((t - startTime) / window)
+			if i < 0 || i >= n {
+				break
+			}
+			windowEnd := startTime + float64(i+1)*window
+			end := min(c.endTime, windowEnd)
+			busy[i] += end - t
+			t = end
+		}
+	}
+	xys := make(plotter.XYs, n)
+	for i := range xys {
+		xys[i].X = startTime + (float64(i)+0.5)*window
+		xys[i].Y = 100 * busy[i] / window
+	}
+	return xys
+}
+
+// バス使用率の時間変化のグラフを保存する
+func saveUtilizationChart(savefilepath string, graphWidth int, graphHeight int, option chart.Option, xys plotter.XYs) error {
+	p := plot.New()
+
+	p.Title.Text = option.Title
+	p.X.Label.Text = option.XLabel
+	p.Y.Label.Text = option.YLabel
+
+	// 背景色
+	p.BackgroundColor = colornames.Snow
+
+	// 横軸を絶対時刻で表示する
+	if option.XToTime != nil {
+		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05.000000", Time: option.XToTime}
+	}
+
+	line, err := plotter.NewLine(xys)
+	if err != nil {
+		slog.Error("NewLine", "err", err)
+		return err
+	}
+	line.Color = colornames.Darkgreen
+	p.Add(line)
+	p.Y.Min = 0
+
+	// 横軸の単位を表示範囲に合わせる
+	chart.ScaleTimeAxis(p, option)
+
+	// プロットを画像ファイルに保存
+	if err := p.Save(vg.Points(float64(graphWidth)), vg.Points(float64(graphHeight)), savefilepath); err != nil {
+		slog.Error("Save", "err", err)
+		return err
+	}
+	// 来歴を埋め込む
+	if err := embedPngFileTexts(savefilepath, option.PngTexts); err != nil {
+		return err
+	}
+
+	return nil
+}
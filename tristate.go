@@ -0,0 +1,247 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// A-B間電圧差の大きさと無通信時のバイアスからバスの状態(Mark, Space, 駆動されていない無通信)をサンプル毎に分ける(ドライバイネーブルの振る舞いを調べる)
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// バスの状態
+const (
+	BusMark       = "mark"       // ドライバがMarkを駆動している
+	BusSpace      = "space"      // ドライバがSpaceを駆動している
+	BusIdle       = "idle"       // どのドライバも駆動していない(バイアス抵抗の電圧)
+	BusTransition = "transition" // どれにも当てはまらない(遷移の途中)
+)
+
+// 集計と表示に使うバスの状態の順番
+var busStates = []string{BusMark, BusSpace, BusIdle, BusTransition}
+
+// --idle-biasで無通信時のバイアスを測定値から決める指定
+const IdleBiasAuto = "auto"
+
+// 駆動されていない期間とみなす最短の長さ(ビット)
+// これより短い期間は遷移の途中でバイアスの電圧を横切っただけとみなす
+const UndrivenMinBits = 1.0
+
+// バスの状態を分ける条件
+type BusStateLimit struct {
+	driven   float64 // 駆動されているとみなすA-B間電圧差の大きさ(V)
+	idleBias float64 // 駆動されていない時のA-B間電圧差(V), NaNの場合は駆動されていない状態を分けない
+	idleBand float64 // 駆動されていない時のA-B間電圧差の許容範囲(±V)
+	auto     bool    // idleBiasを測定値から決めた
+}
+
+// バスの状態が続いた区間
+type BusSegment struct {
+	startTime float64 // 始まり(基準時間からの相対時間)
+	endTime   float64 // 終わり(基準時間からの相対時間)
+	state     string  // バスの状態
+}
+
+// 無通信時のバイアスの指定を解釈する
+// IdleBiasAutoの場合はNaNを返し, 測定値から決める
+func parseIdleBias(s string) (float64, error) {
+	if s == IdleBiasAuto {
+		return math.NaN(), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("無通信時のバイアス \"%s\" には対応していない(%sか電圧)", s, IdleBiasAuto)
+	}
+	return v, nil
+}
+
+// 駆動されていない時のA-B間電圧差を測定値から決める
+// 駆動されている大きさに届かないサンプルの中央値とし, 1ビット分のサンプルも無ければNaN
+func detectIdleBias(matrix mat.Matrix, driven float64, baudrate int) float64 {
+	rows, _ := matrix.Dims()
+	weak := []float64{}
+	for r := 0; r < rows; r++ {
+		d := matrix.At(r, ColWireA) - matrix.At(r, ColWireB)
+		if math.Abs(d) < driven {
+			weak = append(weak, d)
+		}
+	}
+	interval := sampleInterval(matrix)
+	if interval <= 0 || float64(len(weak))*interval < UndrivenMinBits/float64(baudrate) {
+		return math.NaN()
+	}
+	return median(weak)
+}
+
+// バスの状態を分ける条件を決める
+// idleBiasがNaNの場合は測定値から決める
+func newBusStateLimit(matrix mat.Matrix, driven float64, idleBias float64, idleBand float64, baudrate int) BusStateLimit {
+	limit := BusStateLimit{driven: driven, idleBias: idleBias, idleBand: idleBand}
+	if math.IsNaN(idleBias) {
+		limit.idleBias = detectIdleBias(matrix, driven, baudrate)
+		limit.auto = true
+	}
+	return limit
+}
+
+// 1サンプルのバスの状態
+func (limit BusStateLimit) classify(d float64) string {
+	switch {
+	case d >= limit.driven:
+		return BusMark
+	case d <= -limit.driven:
+		return BusSpace
+	case !math.IsNaN(limit.idleBias) && math.Abs(d-limit.idleBias) <= limit.idleBand:
+		return BusIdle
+	}
+	return BusTransition
+}
+
+// サンプル毎にバスの状態を分けて, 同じ状態が続いた区間にまとめる
+// UndrivenMinBitsより短い駆動されていない区間は遷移の途中とする
+func classifyBusStates(matrix mat.Matrix, originTime float64, limit BusStateLimit, baudrate int) []BusSegment {
+	rows, _ := matrix.Dims()
+	segments := []BusSegment{}
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime) - originTime
+		state := limit.classify(matrix.At(r, ColWireA) - matrix.At(r, ColWireB))
+		if len(segments) != 0 && segments[len(segments)-1].state == state {
+			segments[len(segments)-1].endTime = t
+			continue
+		}
+		// 区間の終わりは次の区間の始まりまで延ばす
+		if len(segments) != 0 {
+			segments[len(segments)-1].endTime = t
+		}
+		segments = append(segments, BusSegment{startTime: t, endTime: t, state: state})
+	}
+
+	// 短い駆動されていない区間を遷移の途中にして, 隣の遷移の途中とつなげる
+	minIdle := UndrivenMinBits / float64(baudrate)
+	merged := []BusSegment{}
+	for _, s := range segments {
+		if s.state == BusIdle && s.endTime-s.startTime < minIdle {
+			s.state = BusTransition
+		}
+		if len(merged) != 0 && merged[len(merged)-1].state == s.state {
+			merged[len(merged)-1].endTime = s.endTime
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// バスが駆動されていた期間
+// 駆動されていない区間に挟まれた期間をドライバが有効だった期間とみなす
+func drivenIntervals(segments []BusSegment) []EnableInterval {
+	intervals := []EnableInterval{}
+	driven := false
+	for _, s := range segments {
+		if s.state == BusIdle {
+			driven = false
+			continue
+		}
+		if !driven {
+			intervals = append(intervals, EnableInterval{onTime: s.startTime, offTime: s.endTime})
+			driven = true
+		}
+		intervals[len(intervals)-1].offTime = s.endTime
+	}
+	return intervals
+}
+
+// 駆動されていない区間が有るか
+func hasUndriven(segments []BusSegment) bool {
+	for _, s := range segments {
+		if s.state == BusIdle {
+			return true
+		}
+	}
+	return false
+}
+
+// バスの状態ごとの時間の割合と駆動されていない区間を書く
+func printBusStates(w io.Writer, label string, segments []BusSegment, limit BusStateLimit) {
+	total := 0.0
+	durations := map[string]float64{}
+	undriven := []float64{}
+	for _, s := range segments {
+		durations[s.state] += s.endTime - s.startTime
+		total += s.endTime - s.startTime
+		if s.state == BusIdle {
+			undriven = append(undriven, s.endTime-s.startTime)
+		}
+	}
+	ratios := []string{}
+	for _, state := range busStates {
+		ratio := 0.0
+		if total > 0 {
+			ratio = 100 * durations[state] / total
+		}
+		ratios = append(ratios, fmt.Sprintf("%s %.1f%%", state, ratio))
+	}
+	fmt.Fprintf(w, "bus states%s: %s\n", label, strings.Join(ratios, "  "))
+	how := "configured"
+	if limit.auto {
+		how = IdleBiasAuto
+	}
+	if math.IsNaN(limit.idleBias) {
+		fmt.Fprintf(w, "  driven >= %.2fV  idle bias: not found (%s)\n", limit.driven, how)
+	} else {
+		fmt.Fprintf(w, "  driven >= %.2fV  idle bias %+.2fV ±%.2fV (%s)\n", limit.driven, limit.idleBias, limit.idleBand, how)
+	}
+	if len(undriven) != 0 {
+		sort.Float64s(undriven)
+		fmt.Fprintf(w, "  undriven periods%s: %d  %.3f/%.3f ms min/max\n", label, len(undriven), undriven[0]*1e3, undriven[len(undriven)-1]*1e3)
+	}
+}
+
+// バスの状態の区間をCSVファイルに保存する
+// segmentsは通信方向(半二重では空)ごとの区間
+func saveBusStates(savefilepath string, clock Clock, segments map[string][]BusSegment) error {
+	f, err := os.Create(savefilepath)
+	if err != nil {
+		slog.Error("Create", "err", err)
+		return err
+	}
+	defer f.Close()
+
+	directions := []string{}
+	for direction := range segments {
+		directions = append(directions, direction)
+	}
+	sort.Strings(directions)
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"start", "end", "timestamp", "direction", "state"})
+	for _, direction := range directions {
+		for _, s := range segments[direction] {
+			timestamp := ""
+			if clock.absolute {
+				timestamp = clock.format(s.startTime)
+			}
+			writer.Write([]string{
+				strconv.FormatFloat(s.startTime, 'g', -1, 64),
+				strconv.FormatFloat(s.endTime, 'g', -1, 64),
+				timestamp,
+				direction,
+				s.state,
+			})
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Write", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 文字やフレームの途中で終わった取り込み(捨てずに, 読めたビットの数と共に報告する)
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 取り込みの終わりで途切れた文字
+type TruncatedChar struct {
+	startTime float64 // スタートビットの開始(基準時間からの相対時間)
+	dataBits  int     // 読めたデータビットの数
+	octet     byte    // 読めたデータビットの値
+	parity    bool    // パリティビットまで読めた
+}
+
+// 取り込みの終わり
+type Truncation struct {
+	char       *TruncatedChar // 途切れた文字, 無ければnil
+	lastEnd    float64        // 最後に読み終えた文字の終わり, 無ければ負
+	captureEnd float64        // 取り込みの終わり(基準時間からの相対時間)
+	frameOpen  bool           // 最後の文字の後, フレームの区切りの無通信を待たずに取り込みが終わった
+}
+
+// 復号したビットから取り込みの終わりで途切れた文字とフレームを探す
+// gapはフレームの区切りとみなす無通信時間(s)
+func findTruncation(bits []UartBit, captureEnd float64, gap float64) Truncation {
+	truncation := Truncation{lastEnd: -1, captureEnd: captureEnd}
+	var pending *TruncatedChar
+	for _, b := range bits {
+		switch {
+		case b.state == "START":
+			pending = &TruncatedChar{startTime: b.startTime}
+		case pending == nil:
+		case strings.HasPrefix(b.state, "Bit#"):
+			pending.octet |= byte(b.bit&1) << pending.dataBits
+			pending.dataBits++
+		case b.state == "PARITY" || b.state == "PE":
+			pending.parity = true
+		default:
+			// STOP, X, IDLEで文字が終わった
+			pending = nil
+			if b.state == "STOP" || b.state == "X" {
+				truncation.lastEnd = b.endTime
+			}
+		}
+	}
+	truncation.char = pending
+	truncation.frameOpen = pending != nil || (truncation.lastEnd >= 0 && captureEnd-truncation.lastEnd < gap)
+	return truncation
+}
+
+// 途切れた文字とフレームを表示する
+func printTruncation(w io.Writer, clock Clock, label string, truncation Truncation, format UartFormat) {
+	switch {
+	case truncation.char != nil:
+		c := truncation.char
+		parity := ""
+		if c.parity {
+			parity = " + parity"
+		}
+		fmt.Fprintf(w, "truncation%s: capture ends within a character started at %s: start + %d/%d data bits%s (0x%02x so far)\n",
+			label, clock.format(c.startTime), c.dataBits, format.dataBits, parity, c.octet)
+	case truncation.frameOpen:
+		fmt.Fprintf(w, "truncation%s: capture ends %.3fms after the last character, before the frame gap (the last frame may be incomplete)\n",
+			label, (truncation.captureEnd-truncation.lastEnd)*1e3)
+	default:
+		fmt.Fprintf(w, "truncation%s: none\n", label)
+	}
+}
+
+// 途切れた文字
+// フレームの区切りの前に終わっただけの場合は, 短い後の無通信で止めた取り込みと見分けられないので異常にしない
+func truncationAnomalies(truncation Truncation) []AnomalyEvent {
+	if c := truncation.char; c != nil {
+		return []AnomalyEvent{{Time: c.startTime, Kind: "truncated", Severity: SeverityWarning, Detail: fmt.Sprintf("取り込みが文字の途中で終わった(データビット%dビット, 0x%02x)", c.dataBits, c.octet)}}
+	}
+	return []AnomalyEvent{}
+}
@@ -0,0 +1,83 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 半二重の応答(ターンアラウンド)時間の解析
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ターンアラウンド
+type Turnaround struct {
+	frameEndTime   float64 // 前のフレームの終了時間
+	releaseTime    float64 // 前のフレームを送信したドライバがバスを開放した時間
+	released       bool    // 次のフレームの開始までにバスが開放されたか
+	replyStartTime float64 // 次のフレームの開始時間
+	violation      string  // 違反内容, 違反なしは空
+}
+
+// フレーム間の応答時間を測定する
+// matrixは半二重の入力(時間,A,B)で、時間はoriginTimeを引いてフレームの時間と合わせる
+// 全二重の場合はドライバを開放しないのでmatrixにnilを渡してバス開放の検査をしない
+func analyzeTurnaround(matrix mat.Matrix, originTime float64, frames []UartFrame, minTurnaround float64) []Turnaround {
+	turnarounds := []Turnaround{}
+	for i := 0; i+1 < len(frames); i++ {
+		ta := Turnaround{
+			frameEndTime:   frames[i].endTime,
+			replyStartTime: frames[i+1].startTime,
+		}
+		if matrix != nil {
+			ta.releaseTime, ta.released = findDriverRelease(matrix, originTime, ta.frameEndTime, ta.replyStartTime)
+		}
+		if ta.replyStartTime-ta.frameEndTime < minTurnaround {
+			ta.violation = "応答が早すぎる"
+		}
+		if matrix != nil && !ta.released {
+			ta.violation = "ドライバ開放前に次のフレームが始まった(バス衝突の可能性)"
+		}
+		turnarounds = append(turnarounds, ta)
+	}
+	return turnarounds
+}
+
+// fromTimeからtoTimeまでの間で、A,B間電圧差がしきい値以下(アイドルバイアス)に戻った時間を探す
+func findDriverRelease(matrix mat.Matrix, originTime float64, fromTime float64, toTime float64) (float64, bool) {
+	rows, _ := matrix.Dims()
+	for r := 0; r < rows; r++ {
+		t := matrix.At(r, ColTime) - originTime
+		if t < fromTime {
+			continue
+		}
+		if t >= toTime {
+			break
+		}
+		if math.Abs(matrix.At(r, ColWireA)-matrix.At(r, ColWireB)) < Threshould {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ターンアラウンドを表示する
+func printTurnaround(w io.Writer, clock Clock, frames []UartFrame, turnarounds []Turnaround) {
+	fmt.Fprintf(w, "turnaround: %d frames\n", len(frames))
+	violations := 0
+	for i, ta := range turnarounds {
+		fmt.Fprintf(w, "  #%d -> #%d  end %s  reply %s  gap %.3fms",
+			i+1, i+2, clock.format(ta.frameEndTime), clock.format(ta.replyStartTime), (ta.replyStartTime-ta.frameEndTime)*1e3)
+		if ta.released {
+			fmt.Fprintf(w, "  release %.3fms", (ta.releaseTime-ta.frameEndTime)*1e3)
+		}
+		if ta.violation != "" {
+			violations++
+			fmt.Fprintf(w, "  VIOLATION: %s", ta.violation)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "turnaround violations: %d\n", violations)
+}
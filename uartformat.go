@@ -0,0 +1,180 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 文字の形式(データビット数, パリティ, ストップビット数, アイドルの向き)
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pulseinsight/pkg/uart"
+)
+
+// パリティ
+const (
+	ParityNone  = uart.ParityNone  // パリティ無し
+	ParityEven  = uart.ParityEven  // 偶数パリティ
+	ParityOdd   = uart.ParityOdd   // 奇数パリティ
+	ParityMark  = uart.ParityMark  // 常に1
+	ParitySpace = uart.ParitySpace // 常に0
+)
+
+// 既定の文字の形式
+const DefaultUartFormat = "8N1"
+
+// アイドルの向き
+const (
+	IdleLevelMark  = "mark"  // アイドルがMark(A-B間電圧差が正)
+	IdleLevelSpace = "space" // アイドルがSpace(A-B間電圧差が負)の配線
+)
+
+// 文字の形式
+type UartFormat struct {
+	dataBits int     // データビット数(5-8)
+	parity   string  // パリティ(ParityNone, ParityEven, ParityOdd, ParityMark, ParitySpace)
+	stopBits float64 // ストップビット数(1, 1.5, 2)
+	// アイドルがSpaceの配線(スタートビットはMark, データビットの1はSpaceになる)
+	// 文字の形式の表記(8N1など)には含めない
+	idleSpace bool
+}
+
+// 8E1 のような表記の文字
+var uartFormatPattern = regexp.MustCompile(`^([5-8])([NEOMS])(1|1\.5|2)$`)
+
+// パリティの表記の文字
+var parityLetters = map[string]string{
+	"N": ParityNone,
+	"E": ParityEven,
+	"O": ParityOdd,
+	"M": ParityMark,
+	"S": ParitySpace,
+}
+
+// 8N1, 7E2, 8N1.5 のような表記を読む
+func parseUartFormat(text string) (UartFormat, error) {
+	m := uartFormatPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(text)))
+	if m == nil {
+		return UartFormat{}, fmt.Errorf("文字の形式 \"%s\" には対応していない(例: 8N1, 7E1, 8O2)", text)
+	}
+	dataBits, _ := strconv.Atoi(m[1])
+	stopBits, _ := strconv.ParseFloat(m[3], 64)
+	return UartFormat{dataBits: dataBits, parity: parityLetters[m[2]], stopBits: stopBits}, nil
+}
+
+// 文字の形式を決める
+// frameの表記を元に, 0や空でないdataBits, parity, stopBitsで置き換える
+func newUartFormat(frame string, dataBits int, parity string, stopBits float64) (UartFormat, error) {
+	format, err := parseUartFormat(frame)
+	if err != nil {
+		return UartFormat{}, err
+	}
+	if dataBits != 0 {
+		if dataBits < 5 || dataBits > 8 {
+			return UartFormat{}, fmt.Errorf("データビット数 %d には対応していない(5-8)", dataBits)
+		}
+		format.dataBits = dataBits
+	}
+	if parity != "" {
+		parity = strings.ToLower(parity)
+		switch parity {
+		case ParityNone, ParityEven, ParityOdd, ParityMark, ParitySpace:
+			format.parity = parity
+		default:
+			return UartFormat{}, fmt.Errorf("パリティ \"%s\" には対応していない(%s, %s, %s, %s, %s)", parity, ParityNone, ParityEven, ParityOdd, ParityMark, ParitySpace)
+		}
+	}
+	if stopBits != 0 {
+		if stopBits != 1 && stopBits != 1.5 && stopBits != 2 {
+			return UartFormat{}, fmt.Errorf("ストップビット数 %g には対応していない(1, 1.5, 2)", stopBits)
+		}
+		format.stopBits = stopBits
+	}
+	return format, nil
+}
+
+// アイドルの向き(IdleLevelMark, IdleLevelSpace)を読む
+// アイドルがSpaceの場合はtrue
+func parseIdleLevel(text string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case IdleLevelMark:
+		return false, nil
+	case IdleLevelSpace:
+		return true, nil
+	}
+	return false, fmt.Errorf("アイドルの向き \"%s\" には対応していない(%s, %s)", text, IdleLevelMark, IdleLevelSpace)
+}
+
+// アイドルの向きの符号(Mark:1, Space:-1)
+// A,B間電圧差に掛けるとアイドルが正になる
+func (f UartFormat) idleSign() float64 {
+	if f.idleSpace {
+		return -1
+	}
+	return 1
+}
+
+// 8N1 のような表記
+func (f UartFormat) String() string {
+	return fmt.Sprintf("%d%s%g", f.dataBits, f.parityLetter(), f.stopBits)
+}
+
+// パリティの表記の文字
+func (f UartFormat) parityLetter() string {
+	for letter, parity := range parityLetters {
+		if parity == f.parity {
+			return letter
+		}
+	}
+	return "?"
+}
+
+// パリティビットの数
+func (f UartFormat) parityBits() int {
+	if f.parity == ParityNone {
+		return 0
+	}
+	return 1
+}
+
+// 文字の中の最初のストップビットの番号(スタートビットを0とする)
+func (f UartFormat) stopBitIndex() int {
+	return 1 + f.dataBits + f.parityBits()
+}
+
+// 1文字(スタートビット, データビット, パリティビット, ストップビット)のビット数
+func (f UartFormat) bitsPerChar() float64 {
+	return float64(f.stopBitIndex()) + f.stopBits
+}
+
+// 1文字の時間(s)
+func (f UartFormat) charTime(baudrate int) float64 {
+	return f.bitsPerChar() / float64(baudrate)
+}
+
+// データに対するパリティビットの正しい値
+func (f UartFormat) parityBit(octet uint8) uint8 {
+	return f.uartConfig(0).ParityBit(octet)
+}
+
+// 復号の設定(pkg/uart)
+func (f UartFormat) uartConfig(baudrate int) uart.Config {
+	return uart.Config{Baudrate: baudrate, DataBits: f.dataBits, Parity: f.parity, StopBits: f.stopBits, IdleSpace: f.idleSpace}
+}
+
+// パリティが合わない場合に疑う反対のパリティ
+func oppositeParity(parity string) string {
+	switch parity {
+	case ParityEven:
+		return ParityOdd
+	case ParityOdd:
+		return ParityEven
+	case ParityMark:
+		return ParitySpace
+	case ParitySpace:
+		return ParityMark
+	}
+	return ParityNone
+}
@@ -0,0 +1,128 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ヘッダー行の単位による換算
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// 時間の単位と秒への換算係数
+var timeUnits = map[string]float64{
+	"s":       1,
+	"sec":     1,
+	"second":  1,
+	"seconds": 1,
+	"ms":      1e-3,
+	"us":      1e-6,
+	"µs":      1e-6,
+	"μs":      1e-6,
+	"ns":      1e-9,
+}
+
+// 電圧の単位とボルトへの換算係数
+var voltageUnits = map[string]float64{
+	"v":     1,
+	"volt":  1,
+	"volts": 1,
+	"mv":    1e-3,
+}
+
+// "(ms)"や"[mV]"のような括弧の中の単位
+var unitInBrackets = regexp.MustCompile(`[(\[]\s*([^()\[\]]+?)\s*[)\]]`)
+
+// ヘッダー行のcol列目から単位を探す
+// 見つからない場合は空文字列
+func detectUnit(header [][]string, col int, units map[string]float64) string {
+	for _, record := range header {
+		if col >= len(record) {
+			continue
+		}
+		field := strings.TrimSpace(record[col])
+		candidates := []string{field}
+		for _, m := range unitInBrackets.FindAllStringSubmatch(field, -1) {
+			candidates = append(candidates, m[1])
+		}
+		for _, candidate := range candidates {
+			unit := strings.ToLower(candidate)
+			if _, ok := units[unit]; ok {
+				return unit
+			}
+		}
+	}
+	return ""
+}
+
+// 時間列を秒に、それ以外の列をボルトに換算する
+// 単位の指定(timeUnit, voltageUnit)が空の場合はヘッダー行から検出した単位を使う
+func applyUnits(matrix *mat.Dense, header [][]string, timeUnit string, voltageUnit string) error {
+	_, cols := matrix.Dims()
+	scales, err := unitScales(header, cols, timeUnit, voltageUnit)
+	if err != nil {
+		return err
+	}
+	for c, scale := range scales {
+		if scale != 1 {
+			col := mat.Col(nil, c, matrix)
+			for r := range col {
+				col[r] *= scale
+			}
+			matrix.SetCol(c, col)
+		}
+	}
+	return nil
+}
+
+// 列毎の秒かボルトへの換算係数
+// 単位の指定(timeUnit, voltageUnit)が空の場合はヘッダー行から検出した単位を使う
+func unitScales(header [][]string, cols int, timeUnit string, voltageUnit string) ([]float64, error) {
+	scales := make([]float64, cols)
+	for c := 0; c < cols; c++ {
+		scales[c] = 1
+		units := voltageUnits
+		specified := voltageUnit
+		if c == ColTime {
+			units = timeUnits
+			specified = timeUnit
+		}
+		specified = strings.ToLower(specified)
+		if _, ok := units[specified]; specified != "" && !ok {
+			return nil, fmt.Errorf("単位 \"%s\" には対応していない", specified)
+		}
+
+		detected := detectUnit(header, c, units)
+		unit := detected
+		if specified != "" {
+			if detected != "" && units[detected] != units[specified] {
+				slog.Warn("unit conflict", "column", c+1, "header", detected, "flag", specified)
+			}
+			unit = specified
+		}
+
+		if scale, ok := units[unit]; ok && scale != 1 {
+			slog.Info("scale column", "column", c+1, "unit", unit)
+			scales[c] = scale
+		}
+	}
+	return scales, nil
+}
+
+// プローブの減衰比(10:1プローブなら10、極性を反転する場合は負)をA線とB線の電圧に掛ける
+func applyProbeScale(matrix *mat.Dense, aScale float64, bScale float64) {
+	for c, scale := range map[int]float64{ColWireA: aScale, ColWireB: bScale} {
+		if scale == 1 {
+			continue
+		}
+		col := mat.Col(nil, c, matrix)
+		for r := range col {
+			col[r] *= scale
+		}
+		matrix.SetCol(c, col)
+	}
+}
@@ -0,0 +1,95 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 解析の設定を調べる
+package main
+
+import (
+	"fmt"
+)
+
+// 解析を始める前に設定を調べて, 作る波形のグラフを返す
+// 通常の解析と少しずつ流す解析で共通
+func validateInsightOption(option InsightOption) (ChartSelection, error) {
+	if _, ok := prbsTaps[option.prbsOrder]; option.prbsOrder != 0 && !ok {
+		return nil, fmt.Errorf("PRBS%dには対応していない", option.prbsOrder)
+	}
+	if option.badRows != BadRowsSkip && option.badRows != BadRowsAbort {
+		return nil, fmt.Errorf("不正な行の扱い \"%s\" には対応していない", option.badRows)
+	}
+	if option.crcKind != CrcNone && option.crcKind != CrcModbus {
+		return nil, fmt.Errorf("誤り検出符号 \"%s\" には対応していない", option.crcKind)
+	}
+	if option.edgeDetect != EdgeLevel && option.edgeDetect != EdgeDerivative {
+		return nil, fmt.Errorf("エッジ検出の方式 \"%s\" には対応していない", option.edgeDetect)
+	}
+	if option.resync != ResyncAuto && option.resync != ResyncIdle && option.resync != ResyncOff {
+		return nil, fmt.Errorf("読み飛ばし方 \"%s\" には対応していない", option.resync)
+	}
+	if option.resyncIdle < 0 {
+		return nil, fmt.Errorf("同期に使う無通信のビット数は0以上を指定する")
+	}
+	if option.threshold < 0 {
+		return nil, fmt.Errorf("復号のしきい値は0以上を指定する")
+	}
+	if option.noiseFloor < 0 {
+		return nil, fmt.Errorf("雑音の床は0以上を指定する")
+	}
+	if option.wavFullScale <= 0 {
+		return nil, fmt.Errorf("WAVファイルのフルスケールの電圧は正の値を指定する")
+	}
+	if _, err := parseIdleBias(option.idleBias); err != nil {
+		return nil, err
+	}
+	if option.inputType != InputAnalog && option.inputType != InputLogic {
+		return nil, fmt.Errorf("入力の種類 \"%s\" には対応していない", option.inputType)
+	}
+	if option.inputType == InputLogic && (option.decodeFilter || option.edgeDetect != EdgeLevel) {
+		return nil, fmt.Errorf("論理レベルの入力はフィルタ後の波形の解析と微分によるエッジ検出には対応していない")
+	}
+	if option.liveFrames && (option.inputType == InputLogic || option.estimateBaud != EstimateBaudNone) {
+		return nil, fmt.Errorf("--live-framesは論理レベルの入力とボーレートの推定には対応していない")
+	}
+	if option.where != "" {
+		if _, err := parseWhere(option.where); err != nil {
+			return nil, err
+		}
+	}
+	if option.stopAfter != "" {
+		if _, err := parseWhere(option.stopAfter); err != nil {
+			return nil, err
+		}
+	}
+	if option.maxFrames < 0 {
+		return nil, fmt.Errorf("打ち切るフレーム数 %d には対応していない", option.maxFrames)
+	}
+	if err := checkDecodeOutput(option.decodeOutput); err != nil {
+		return nil, err
+	}
+	if option.dumpCode != DumpCodeNone && option.dumpCode != DumpCodeC && option.dumpCode != DumpCodeGo {
+		return nil, fmt.Errorf("ソースコードの言語 \"%s\" には対応していない", option.dumpCode)
+	}
+	charts, err := parseChartSelection(option.charts)
+	if err != nil {
+		return nil, err
+	}
+	if option.hexFile != "" {
+		if _, err := hexFormat(option.hexFile); err != nil {
+			return nil, err
+		}
+	}
+	if option.hexAddress != "" {
+		if _, err := parseWhere(option.hexAddress); err != nil {
+			return nil, err
+		}
+	}
+	if option.sequenceFile != "" {
+		if _, err := sequenceFormat(option.sequenceFile); err != nil {
+			return nil, err
+		}
+	}
+	if option.estimateBaud != EstimateBaudNone && option.estimateBaud != EstimateBaudPulse && option.estimateBaud != EstimateBaudAutocorrelation {
+		return nil, fmt.Errorf("ボーレートの推定方法 \"%s\" には対応していない", option.estimateBaud)
+	}
+	return charts, nil
+}
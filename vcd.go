@@ -0,0 +1,305 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// ロジックアナライザやシミュレータが書き出したValue Change Dump(.vcd)の読み込み
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// VCDファイルの拡張子
+const VcdExt = ".vcd"
+
+// VCDファイルの時間の単位と秒への換算係数
+var vcdTimeUnits = map[string]float64{
+	"s":  1,
+	"ms": 1e-3,
+	"us": 1e-6,
+	"ns": 1e-9,
+	"ps": 1e-12,
+	"fs": 1e-15,
+}
+
+// VCDファイルの信号
+type VcdVar struct {
+	kind  string // wire, real など
+	size  int    // ビット数
+	id    string // 値の変化に使う識別子
+	name  string // 信号名
+	scope string // 階層(.区切り)
+}
+
+// 信号名か階層付きの信号名(上の階層は省ける)が合うか
+func (v VcdVar) matches(name string) bool {
+	full := strings.ToLower(v.name)
+	if v.scope != "" {
+		full = strings.ToLower(v.scope + "." + v.name)
+	}
+	name = strings.ToLower(name)
+	return full == name || strings.HasSuffix(full, "."+name)
+}
+
+// アナログの値(real)の信号か
+func (v VcdVar) isReal() bool {
+	return v.kind == "real" || v.kind == "realtime"
+}
+
+// "1ns"や"10 ps"のような時間の単位を秒にする
+func parseVcdTimescale(s string) (float64, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("時間の単位 \"%s\" を読めない", s)
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return 0, fmt.Errorf("時間の単位 \"%s\" を読めない", s)
+	}
+	scale, ok := vcdTimeUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("時間の単位 \"%s\" には対応していない", s)
+	}
+	return float64(n) * scale, nil
+}
+
+// 信号を名前で選ぶ
+// 名前が空の場合はcandidateに合う信号を定義の順に選ぶ
+func selectVcdVars(vars []VcdVar, names []string, candidate func(VcdVar) bool) ([]VcdVar, error) {
+	selected := make([]VcdVar, len(names))
+	used := map[string]bool{}
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, v := range vars {
+			if v.matches(name) {
+				selected[i], found = v, true
+				used[v.id] = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("信号名 \"%s\" がVCDファイルにない", name)
+		}
+	}
+	for i := range selected {
+		if selected[i].id != "" {
+			continue
+		}
+		for _, v := range vars {
+			if !used[v.id] && candidate(v) {
+				selected[i] = v
+				used[v.id] = true
+				break
+			}
+		}
+		if selected[i].id == "" {
+			return nil, fmt.Errorf("VCDファイルに%d本目の信号がない", i+1)
+		}
+	}
+	return selected, nil
+}
+
+// VCDファイルを読み込んで, 選んだ信号が変化した時刻ごとの行(時間, 信号...)にする
+// logicの場合は1ビットの信号線を1本(aNameとbNameを両方指定した場合は2本)選び, 0/1の値にする
+// logicでない場合はA線とB線の2本を選び, 実数の信号は電圧(V), 1ビットの信号は1をLogicDifferential(V)にする
+// x, zは直前の値を保つ
+func loadVcdFile(filePath string, aName string, bName string, logic bool) (*mat.Dense, [][]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		slog.Error("Open", "err", err)
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	scanner.Split(bufio.ScanWords)
+
+	// 定義部
+	timescale := 1e-9
+	vars := []VcdVar{}
+	scope := []string{}
+	// $endまでの語
+	section := func() []string {
+		words := []string{}
+		for scanner.Scan() && scanner.Text() != "$end" {
+			words = append(words, scanner.Text())
+		}
+		return words
+	}
+definitions:
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "$timescale":
+			if timescale, err = parseVcdTimescale(strings.Join(section(), "")); err != nil {
+				return nil, nil, err
+			}
+		case "$scope":
+			if words := section(); len(words) >= 2 {
+				scope = append(scope, words[1])
+			}
+		case "$upscope":
+			section()
+			if len(scope) > 0 {
+				scope = scope[:len(scope)-1]
+			}
+		case "$var":
+			words := section()
+			if len(words) < 4 {
+				return nil, nil, fmt.Errorf("VCDファイルの$varを読めない: %s", strings.Join(words, " "))
+			}
+			size, _ := strconv.Atoi(words[1])
+			vars = append(vars, VcdVar{kind: words[0], size: size, id: words[2], name: words[3], scope: strings.Join(scope, ".")})
+		case "$enddefinitions":
+			section()
+			break definitions
+		default:
+			if strings.HasPrefix(scanner.Text(), "$") {
+				section()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Scan", "err", err)
+		return nil, nil, err
+	}
+
+	// 信号を選ぶ
+	var selected []VcdVar
+	if logic {
+		names := []string{aName}
+		if aName != "" && bName != "" {
+			names = append(names, bName)
+		}
+		selected, err = selectVcdVars(vars, names, func(v VcdVar) bool { return !v.isReal() && v.size == 1 })
+	} else {
+		selected, err = selectVcdVars(vars, []string{aName, bName}, func(v VcdVar) bool { return v.isReal() })
+		if err != nil && aName == "" && bName == "" {
+			// 実数の信号が無ければA線とB線を1ビットの信号で測ったものとみなす
+			selected, err = selectVcdVars(vars, []string{aName, bName}, func(v VcdVar) bool { return !v.isReal() && v.size == 1 })
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	index := map[string]int{}
+	for i, v := range selected {
+		if !v.isReal() && v.size != 1 {
+			return nil, nil, fmt.Errorf("信号 \"%s\" は%dビット(1ビットか実数の信号だけに対応)", v.name, v.size)
+		}
+		if logic && v.isReal() {
+			return nil, nil, fmt.Errorf("信号 \"%s\" は実数(論理レベルの入力は1ビットの信号)", v.name)
+		}
+		index[v.id] = i
+	}
+
+	// 値の変化
+	values := make([]float64, len(selected))
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	data := []float64{}
+	now, changed, undefined := int64(-1), false, 0
+	flush := func() {
+		if !changed {
+			return
+		}
+		changed = false
+		for _, v := range values {
+			if math.IsNaN(v) {
+				return
+			}
+		}
+		data = append(data, float64(now)*timescale)
+		data = append(data, values...)
+	}
+	set := func(id string, value float64) {
+		if i, ok := index[id]; ok {
+			if !selected[i].isReal() && !logic {
+				value *= LogicDifferential
+			}
+			values[i] = value
+			changed = true
+		}
+	}
+	for scanner.Scan() {
+		word := scanner.Text()
+		switch {
+		case word == "":
+		case word[0] == '#':
+			t, err := strconv.ParseInt(word[1:], 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("VCDファイルの時刻 \"%s\" を読めない", word)
+			}
+			if t != now {
+				flush()
+				now = t
+			}
+		case word[0] == '$':
+			// $dumpvars, $dumpall などの中の値の変化はそのまま読む
+		case word[0] == '0' || word[0] == '1':
+			set(word[1:], float64(word[0]-'0'))
+		case strings.ContainsRune("xXzZ", rune(word[0])):
+			if _, ok := index[word[1:]]; ok {
+				undefined++
+			}
+		case word[0] == 'b' || word[0] == 'B':
+			if !scanner.Scan() {
+				break
+			}
+			id := scanner.Text()
+			if v, err := strconv.ParseUint(word[1:], 2, 64); err == nil {
+				set(id, float64(v))
+			} else if _, ok := index[id]; ok {
+				undefined++
+			}
+		case word[0] == 'r' || word[0] == 'R':
+			if !scanner.Scan() {
+				break
+			}
+			id := scanner.Text()
+			if v, err := strconv.ParseFloat(word[1:], 64); err == nil {
+				set(id, v)
+			}
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		slog.Error("Scan", "err", err)
+		return nil, nil, err
+	}
+	if undefined > 0 {
+		slog.Warn("x/z values kept the previous value", "count", undefined)
+	}
+	cols := 1 + len(selected)
+	// 最後の時刻まで値を保つ
+	if last := float64(now) * timescale; len(data) != 0 && last > data[len(data)-cols] {
+		data = append(data, last)
+		data = append(data, data[len(data)-cols:len(data)-1]...)
+	}
+	if len(data)/cols < 2 {
+		return nil, nil, fmt.Errorf("VCDファイルに選んだ信号の値の変化がない")
+	}
+
+	header := [][]string{{"Time"}, {"s"}}
+	for _, v := range selected {
+		header[0] = append(header[0], v.name)
+		unit := "V"
+		if logic {
+			unit = ""
+		}
+		header[1] = append(header[1], unit)
+	}
+	return mat.NewDense(len(data)/cols, cols, data), header, nil
+}
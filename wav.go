@@ -0,0 +1,183 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 2チャンネルのオーディオインターフェースで録音したステレオのWAVファイル(.wav)の読み込み
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// WAVファイルの拡張子
+const WavExt = ".wav"
+
+// WAVファイルのサンプルの形式
+const (
+	WavFormatPcm        = 1      // 整数
+	WavFormatFloat      = 3      // IEEE浮動小数点数
+	WavFormatExtensible = 0xfffe // WAVE_FORMAT_EXTENSIBLE(サブフォーマットの先頭2バイトが形式)
+)
+
+// 1ビットの間に欲しいサンプル数(少ない場合は警告する)
+const WavMinSamplesPerBit = 4
+
+// WAVファイルのfmtチャンク
+type WavFormat struct {
+	format        uint16 // サンプルの形式(WavFormatPcm, WavFormatFloat)
+	channels      int
+	sampleRate    int
+	blockAlign    int // 1サンプル分の全チャンネルのバイト数
+	bitsPerSample int
+}
+
+// fmtチャンクを読む
+func parseWavFormat(chunk []byte) (WavFormat, error) {
+	if len(chunk) < 16 {
+		return WavFormat{}, errors.New("WAVファイルのfmtチャンクが短い")
+	}
+	f := WavFormat{
+		format:        binary.LittleEndian.Uint16(chunk[0:]),
+		channels:      int(binary.LittleEndian.Uint16(chunk[2:])),
+		sampleRate:    int(binary.LittleEndian.Uint32(chunk[4:])),
+		blockAlign:    int(binary.LittleEndian.Uint16(chunk[12:])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:])),
+	}
+	if f.format == WavFormatExtensible {
+		if len(chunk) < 26 {
+			return WavFormat{}, errors.New("WAVファイルのfmtチャンクにサブフォーマットがない")
+		}
+		f.format = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	switch {
+	case f.format == WavFormatPcm && (f.bitsPerSample == 8 || f.bitsPerSample == 16 || f.bitsPerSample == 24 || f.bitsPerSample == 32):
+	case f.format == WavFormatFloat && (f.bitsPerSample == 32 || f.bitsPerSample == 64):
+	default:
+		return WavFormat{}, fmt.Errorf("WAVファイルの形式%d, %dビットには対応していない(整数8, 16, 24, 32ビット, 浮動小数点数32, 64ビット)", f.format, f.bitsPerSample)
+	}
+	if f.channels != 2 {
+		return WavFormat{}, fmt.Errorf("WAVファイルのチャンネル数が%d(A線とB線のステレオが必要)", f.channels)
+	}
+	if f.sampleRate <= 0 || f.blockAlign < f.channels*f.bitsPerSample/8 {
+		return WavFormat{}, fmt.Errorf("WAVファイルのサンプリングレート%dHz, ブロック%dバイトを読めない", f.sampleRate, f.blockAlign)
+	}
+	return f, nil
+}
+
+// 1チャンネル分のサンプルをフルスケールに対する比(-1から1)にする
+func (f WavFormat) sample(b []byte) float64 {
+	switch {
+	case f.format == WavFormatFloat && f.bitsPerSample == 32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case f.format == WavFormatFloat:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case f.bitsPerSample == 8:
+		// 8ビットは符号なしで128が0
+		return (float64(b[0]) - 128) / 128
+	case f.bitsPerSample == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case f.bitsPerSample == 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+// ステレオのWAVファイルを読み込んで, 時間(s), A線電圧(V), B線電圧(V)の行列にする
+// 左チャンネルをA線, 右チャンネルをB線とし, フルスケールをfullScale(V)とする
+// 時間はサンプリングレートから決める
+func loadWavFile(filePath string, fullScale float64) (*mat.Dense, [][]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+
+	var riff [12]byte
+	if _, err := io.ReadFull(reader, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, nil, fmt.Errorf("\"%s\" はWAVファイルではない", filePath)
+	}
+	var format *WavFormat
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(reader, chunkHeader[:]); err != nil {
+			return nil, nil, fmt.Errorf("WAVファイルにdataチャンクがない")
+		}
+		id, size := string(chunkHeader[0:4]), int64(binary.LittleEndian.Uint32(chunkHeader[4:]))
+		switch id {
+		case "fmt ":
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(reader, chunk); err != nil {
+				return nil, nil, err
+			}
+			parsed, err := parseWavFormat(chunk)
+			if err != nil {
+				return nil, nil, err
+			}
+			format = &parsed
+		case "data":
+			if format == nil {
+				return nil, nil, fmt.Errorf("WAVファイルのdataチャンクの前にfmtチャンクがない")
+			}
+			return readWavData(reader, size, *format, fullScale)
+		default:
+			if _, err := reader.Discard(int(size)); err != nil {
+				return nil, nil, err
+			}
+		}
+		// チャンクは偶数バイトに揃える
+		if size%2 == 1 {
+			reader.Discard(1)
+		}
+	}
+}
+
+// dataチャンクを行列にする
+// 録音中に書き終えなかったファイルの長さ(0や最大値)は, ファイルの終わりまで読む
+func readWavData(reader io.Reader, size int64, format WavFormat, fullScale float64) (*mat.Dense, [][]string, error) {
+	bytesPerSample := format.bitsPerSample / 8
+	capacity := 0
+	if size > 0 && size < math.MaxUint32 {
+		capacity = int(size) / format.blockAlign
+		reader = io.LimitReader(reader, size)
+	}
+	data := make([]float64, 0, 3*capacity)
+	block := make([]byte, format.blockAlign)
+	rows := 0
+	for {
+		if _, err := io.ReadFull(reader, block); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, nil, err
+		}
+		t := float64(rows) / float64(format.sampleRate)
+		a := format.sample(block[0:]) * fullScale
+		b := format.sample(block[bytesPerSample:]) * fullScale
+		data = append(data, t, a, b)
+		rows++
+	}
+	if rows == 0 {
+		return nil, nil, fmt.Errorf("WAVファイルにサンプルがない")
+	}
+	header := [][]string{{"Time", "L", "R"}, {"s", "V", "V"}}
+	return mat.NewDense(rows, 3, data), header, nil
+}
+
+// サンプリングレートが1ビットの間にWavMinSamplesPerBitサンプルより少ない場合は警告する
+func checkWavSampleRate(matrix mat.Matrix, baudrate int) {
+	interval := sampleInterval(matrix)
+	if interval > 0 && 1/(interval*float64(baudrate)) < WavMinSamplesPerBit {
+		slog.Warn("too few samples per bit", "sample rate", math.Round(1/interval), "baudrate", baudrate)
+	}
+}
@@ -0,0 +1,95 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// Rigol DS1000E/DシリーズのオシロスコープがUSBメモリに保存した波形ファイル(.wfm)の読み込み
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Rigolの独自の波形ファイルの拡張子
+const RigolWfmExt = ".wfm"
+
+// DS1000E/Dの波形ファイルの先頭
+const RigolWfmMagic = "\xa5\xa5\x00\x00"
+
+// DS1000E/Dの波形ファイルの見出し
+const (
+	rigolWfmPoints       = 28  // CH1の点の数(uint32)
+	rigolWfmChannels     = 36  // チャンネル毎の見出し(24バイト)がCH1, CH2の順に続く
+	rigolWfmChannelSize  = 24  // チャンネル毎の見出しの大きさ
+	rigolWfmTimeDelay    = 92  // トリガーからの遅れ(int64, ps)
+	rigolWfmSampleRate   = 100 // サンプリングレート(float32, Hz)
+	rigolWfmHeaderSize   = 272 // 見出しの大きさ, この後に有効なチャンネルの点(1点1バイト)が続く
+	rigolWfmProbe        = 8   // チャンネルの見出しの中のプローブの減衰比(float32)
+	rigolWfmEnabled      = 13  // チャンネルの見出しの中の表示の有無(uint8)
+	rigolWfmScale        = 16  // チャンネルの見出しの中の1目盛りの電圧(int32, 1倍のプローブでμV)
+	rigolWfmOffset       = 20  // チャンネルの見出しの中のオフセット(int16, 1目盛り25の画面の点)
+	rigolWfmDivisionDots = 25  // 1目盛りの画面の点
+	rigolWfmCenterDot    = 125 // 画面の中央の点の値
+)
+
+// Rigol DS1000E/Dの波形ファイルのチャンネル(CH1, CH2)を読む
+// 点の値vは (中央の点 - v) / 1目盛りの点 × 1目盛りの電圧 - オフセット で電圧にする
+// 時間は画面の中央をトリガーからの遅れとし, サンプリングレートから決める
+// 他の機種の.wfmファイルは見出しが違うので読まない
+func loadRigolWfmWaveforms(filePath string) ([]ScopeBinWaveform, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < rigolWfmHeaderSize || !bytes.HasPrefix(data, []byte(RigolWfmMagic)) {
+		return nil, fmt.Errorf("\"%s\" はRigol DS1000E/Dの波形ファイルではない(他の機種の.wfmファイルは.binかCSVで保存し直す)", filePath)
+	}
+	le := binary.LittleEndian
+	points := int(le.Uint32(data[rigolWfmPoints:]))
+	sampleRate := float64(math.Float32frombits(le.Uint32(data[rigolWfmSampleRate:])))
+	if sampleRate <= 0 || math.IsNaN(sampleRate) || math.IsInf(sampleRate, 0) {
+		return nil, fmt.Errorf("\"%s\" のサンプリングレートが%gHz", filePath, sampleRate)
+	}
+	delay := float64(int64(le.Uint64(data[rigolWfmTimeDelay:]))) * 1e-12
+
+	waveforms := []ScopeBinWaveform{}
+	offset := rigolWfmHeaderSize
+	for ch := 0; ch < 2; ch++ {
+		header := data[rigolWfmChannels+ch*rigolWfmChannelSize : rigolWfmChannels+(ch+1)*rigolWfmChannelSize]
+		if header[rigolWfmEnabled] == 0 {
+			continue
+		}
+		if offset+points > len(data) {
+			return nil, fmt.Errorf("\"%s\" のCH%dの点が%d点に足りない", filePath, ch+1, points)
+		}
+		probe := float64(math.Float32frombits(le.Uint32(header[rigolWfmProbe:])))
+		voltsPerDot := probe * float64(int32(le.Uint32(header[rigolWfmScale:]))) * 1e-6 / rigolWfmDivisionDots
+		voltOffset := float64(int16(le.Uint16(header[rigolWfmOffset:]))) * voltsPerDot
+		w := ScopeBinWaveform{
+			label:      fmt.Sprintf("CH%d", ch+1),
+			xIncrement: 1 / sampleRate,
+			xOrigin:    delay - float64(points/2)/sampleRate,
+			values:     make([]float64, points),
+		}
+		for i, v := range data[offset : offset+points] {
+			w.values[i] = (rigolWfmCenterDot-float64(v))*voltsPerDot - voltOffset
+		}
+		waveforms = append(waveforms, w)
+		offset += points
+	}
+	return waveforms, nil
+}
+
+// Rigol DS1000E/Dの波形ファイルを読み込んで, 時間(s), A線電圧(V), B線電圧(V)の行列にする
+// 既定はCH1をA線, CH2をB線にし, 指定した場合はチャンネル名(CH1, CH2)で選ぶ
+func loadRigolWfmFile(filePath string, aName string, bName string) (*mat.Dense, [][]string, error) {
+	waveforms, err := loadRigolWfmWaveforms(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return scopeWaveformMatrix(filePath, waveforms, aName, bName)
+}
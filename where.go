@@ -0,0 +1,365 @@
+// pulseinsight
+// SPDX-License-Identifier: MPL-2.0
+// SPDX-FileCopyrightText: 2025 Akihiro Yamamoto <github.com/ak1211>
+// 復号したフレームを絞り込む式(--where)
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// フレームを評価する式
+// 真偽値は1(真)と0(偽)で表し, フレームに無いバイトは-1とする
+// 絞り込み(--where)の他にアドレスの計算(--hex-address)にも使う
+type FrameExpr func(f UartFrame, addressByte int) int
+
+// 式の字句
+type whereToken struct {
+	text string
+	pos  int // 式の中の位置(0始まり)
+}
+
+// 式を字句に分ける
+func tokenizeWhere(source string) ([]whereToken, error) {
+	tokens := []whereToken{}
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) || unicode.IsLetter(rune(source[j])) || source[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, whereToken{text: source[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<<", ">>", "<", ">", "!", "(", ")", "[", "]", "+", "-", "*", "&", "|"} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("絞り込みの式 \"%s\" の%d文字目: \"%c\" には対応していない", source, i+1, c)
+			}
+			tokens = append(tokens, whereToken{text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// 式の構文解析
+type whereParser struct {
+	source string
+	tokens []whereToken
+	next   int
+}
+
+// 次の字句, 終わりでは空
+func (p *whereParser) peek() string {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next].text
+	}
+	return ""
+}
+
+// 構文の誤り
+func (p *whereParser) errorf(format string, args ...any) error {
+	pos := len(p.source)
+	if p.next < len(p.tokens) {
+		pos = p.tokens[p.next].pos
+	}
+	return fmt.Errorf("絞り込みの式 \"%s\" の%d文字目: %s", p.source, pos+1, fmt.Sprintf(format, args...))
+}
+
+// 真偽値
+func truth(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 論理和
+func (p *whereParser) parseOr() (FrameExpr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next++
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(f UartFrame, a int) int { return truth(l(f, a) != 0 || rhs(f, a) != 0) }
+	}
+	return lhs, nil
+}
+
+// 論理積
+func (p *whereParser) parseAnd() (FrameExpr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next++
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(f UartFrame, a int) int { return truth(l(f, a) != 0 && rhs(f, a) != 0) }
+	}
+	return lhs, nil
+}
+
+// 否定
+func (p *whereParser) parseUnary() (FrameExpr, error) {
+	if p.peek() == "!" {
+		p.next++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f UartFrame, a int) int { return truth(operand(f, a) == 0) }, nil
+	}
+	return p.parseComparison()
+}
+
+// 比較
+func (p *whereParser) parseComparison() (FrameExpr, error) {
+	lhs, err := p.parseBitOr()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	var compare func(x, y int) bool
+	switch op {
+	case "==":
+		compare = func(x, y int) bool { return x == y }
+	case "!=":
+		compare = func(x, y int) bool { return x != y }
+	case "<":
+		compare = func(x, y int) bool { return x < y }
+	case "<=":
+		compare = func(x, y int) bool { return x <= y }
+	case ">":
+		compare = func(x, y int) bool { return x > y }
+	case ">=":
+		compare = func(x, y int) bool { return x >= y }
+	default:
+		return lhs, nil
+	}
+	p.next++
+	rhs, err := p.parseBitOr()
+	if err != nil {
+		return nil, err
+	}
+	return func(f UartFrame, a int) int { return truth(compare(lhs(f, a), rhs(f, a))) }, nil
+}
+
+// 左結合の二項演算子
+func (p *whereParser) parseBinary(operators map[string]func(x, y int) int, operand func() (FrameExpr, error)) (FrameExpr, error) {
+	lhs, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		apply, ok := operators[p.peek()]
+		if !ok {
+			return lhs, nil
+		}
+		p.next++
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(f UartFrame, a int) int { return apply(l(f, a), rhs(f, a)) }
+	}
+}
+
+// ビット毎の論理和
+func (p *whereParser) parseBitOr() (FrameExpr, error) {
+	return p.parseBinary(map[string]func(x, y int) int{
+		"|": func(x, y int) int { return x | y },
+	}, p.parseBitAnd)
+}
+
+// ビット毎の論理積
+func (p *whereParser) parseBitAnd() (FrameExpr, error) {
+	return p.parseBinary(map[string]func(x, y int) int{
+		"&": func(x, y int) int { return x & y },
+	}, p.parseShift)
+}
+
+// シフト
+func (p *whereParser) parseShift() (FrameExpr, error) {
+	return p.parseBinary(map[string]func(x, y int) int{
+		"<<": func(x, y int) int { return x << max(y, 0) },
+		">>": func(x, y int) int { return x >> max(y, 0) },
+	}, p.parseAdditive)
+}
+
+// 加減算
+func (p *whereParser) parseAdditive() (FrameExpr, error) {
+	return p.parseBinary(map[string]func(x, y int) int{
+		"+": func(x, y int) int { return x + y },
+		"-": func(x, y int) int { return x - y },
+	}, p.parseMultiplicative)
+}
+
+// 乗算
+func (p *whereParser) parseMultiplicative() (FrameExpr, error) {
+	return p.parseBinary(map[string]func(x, y int) int{
+		"*": func(x, y int) int { return x * y },
+	}, p.parsePrimary)
+}
+
+// フレームのn番目のバイト, 無い場合は-1
+func frameByte(f UartFrame, n int) int {
+	if n < 0 || n >= len(f.codes) {
+		return -1
+	}
+	return int(f.codes[n].octet)
+}
+
+// 数値, 名前, 括弧
+func (p *whereParser) parsePrimary() (FrameExpr, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, p.errorf("式が途中で終わっている")
+	case token == "(":
+		p.next++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, p.errorf("\")\" が無い")
+		}
+		p.next++
+		return inner, nil
+	case unicode.IsDigit(rune(token[0])):
+		v, err := strconv.ParseInt(token, 0, 64)
+		if err != nil {
+			return nil, p.errorf("数値 \"%s\" を読めない", token)
+		}
+		p.next++
+		return func(UartFrame, int) int { return int(v) }, nil
+	}
+
+	p.next++
+	switch strings.ToLower(token) {
+	case "slave", "addr":
+		return func(f UartFrame, a int) int { return frameByte(f, a) }, nil
+	case "fc":
+		return func(f UartFrame, a int) int { return frameByte(f, a+1) }, nil
+	case "len":
+		return func(f UartFrame, a int) int { return len(f.codes) }, nil
+	case "tx":
+		return func(f UartFrame, a int) int { return truth(f.direction == DirectionTx) }, nil
+	case "rx":
+		return func(f UartFrame, a int) int { return truth(f.direction == DirectionRx) }, nil
+	case "byte":
+		if p.peek() != "[" {
+			return nil, p.errorf("byteの後に \"[\" が無い")
+		}
+		p.next++
+		index, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != "]" {
+			return nil, p.errorf("\"]\" が無い")
+		}
+		p.next++
+		return func(f UartFrame, a int) int { return frameByte(f, index(f, a)) }, nil
+	}
+	p.next--
+	return nil, p.errorf("名前 \"%s\" には対応していない(slave, addr, fc, len, byte[n], tx, rx)", token)
+}
+
+// 絞り込みの式を解析する
+func parseWhere(source string) (FrameExpr, error) {
+	tokens, err := tokenizeWhere(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{source: source, tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next != len(p.tokens) {
+		return nil, p.errorf("余分な \"%s\" がある", p.peek())
+	}
+	return expr, nil
+}
+
+// 式が真になるフレームだけを残す
+func filterFrames(frames []UartFrame, expr FrameExpr, addressByte int) []UartFrame {
+	filtered := make([]UartFrame, 0, len(frames))
+	for _, f := range frames {
+		if expr(f, addressByte) != 0 {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// 報告とグラフに使うフレームを絞り込む(--where)
+// 式を指定しない場合はそのまま返す
+func whereFrames(w io.Writer, frames []UartFrame, option InsightOption) ([]UartFrame, error) {
+	if option.where == "" {
+		return frames, nil
+	}
+	expr, err := parseWhere(option.where)
+	if err != nil {
+		slog.Error("parseWhere", "err", err)
+		return nil, err
+	}
+	filtered := filterFrames(frames, expr, option.addressByte)
+	fmt.Fprintf(w, "where: %d of %d frames match \"%s\"\n", len(filtered), len(frames), option.where)
+	return filtered, nil
+}
+
+// フレームの文字を時間順に並べる
+func frameCodes(frames []UartFrame) []UartCode {
+	codes := []UartCode{}
+	for _, f := range frames {
+		codes = append(codes, f.codes...)
+	}
+	sort.SliceStable(codes, func(i, j int) bool { return codes[i].startTime < codes[j].startTime })
+	return codes
+}
+
+// 文字(時間順)のどれかに含まれるビットだけを残す
+// 全二重では送信と受信の文字が時間で重なるので直前の2文字を調べる
+func codeBits(bits []UartBit, codes []UartCode) []UartBit {
+	kept := []UartBit{}
+	for _, b := range bits {
+		i := sort.Search(len(codes), func(i int) bool { return codes[i].startTime > b.startTime })
+		for k := i - 1; k >= max(0, i-2); k-- {
+			if b.startTime < codes[k].endTime {
+				kept = append(kept, b)
+				break
+			}
+		}
+	}
+	return kept
+}